@@ -4,10 +4,13 @@ import (
 	"caching-web-server/config"
 	_ "caching-web-server/docs"
 	"caching-web-server/internal/handler"
+	"caching-web-server/internal/model"
+	"caching-web-server/internal/ports"
 	"caching-web-server/internal/repository"
 	"caching-web-server/internal/security"
 	"caching-web-server/internal/service"
 	"context"
+	"expvar"
 	"github.com/go-chi/chi/v5"
 	_ "github.com/lib/pq"
 	httpSwagger "github.com/swaggo/http-swagger"
@@ -49,7 +52,7 @@ func main() {
 
 	redisClient, err := config.SetupRedis(&cfg.RedisConfig)
 	if err != nil {
-		log.Fatalf("Ошибка подключения к Redis: %v", err)
+		log.Fatalf("Ошибка настройки Redis: %v", err)
 	}
 	defer func() {
 		if err := redisClient.Close(); err != nil {
@@ -61,64 +64,104 @@ func main() {
 
 	userRepo := repository.NewUserRepository(db)
 	jwtRepo := repository.NewJWTRepository(db)
+	roleRepo := repository.NewRoleRepository(db)
 	docRepo := repository.NewDocumentRepository(db)
 	shareRepo := repository.NewGrantDocumentRepository(db)
-	cacheRepo := repository.NewCacheRepository(redisClient, time.Duration(cfg.TTL.S3AndRedis)*time.Second)
+	quotaRepo := repository.NewQuotaRepository(db)
+	retentionRepo := repository.NewRetentionRepository(db)
+	cacheWarmRepo := repository.NewCacheWarmRepository(db)
+	lockRepo := repository.NewDocumentLockRepository(db, redisClient)
+	accessTokenTTL, err := time.ParseDuration(cfg.JWT.AccessTokenTTL)
+	if err != nil {
+		log.Fatalf("Некорректный jwt.access_token_ttl: %v", err)
+	}
+	tokenDenylist := repository.NewTokenDenylistRepository(redisClient, accessTokenTTL, cfg.JWT.DenylistFailClosed == false)
+	expvar.Publish("token_denylist", expvar.Func(func() any { return tokenDenylist.Health() }))
+	cacheBreaker := setupRedisCircuitBreaker(ctx, redisClient, &cfg.Cache.CircuitBreaker)
+	cacheMetrics := setupCacheMetrics(&cfg.Cache.Metrics)
+	cacheRepo := setupDocumentCache(ctx, redisClient, cacheBreaker, cacheMetrics, time.Duration(cfg.TTL.S3AndRedis)*time.Second, &cfg.Cache)
 
 	s3Service, err := service.NewS3Service(ctx, &cfg.S3Config)
 	if err != nil {
 		log.Fatalf("Ошибка создания S3 сервиса: %v", err)
 	}
-	docService := service.NewDocumentService(docRepo, cacheRepo, shareRepo, s3Service, userRepo, time.Duration(cfg.TTL.S3AndRedis)*time.Second)
+	quotaService := service.NewQuotaService(quotaRepo, &cfg.Quota)
+	docService := service.NewDocumentService(docRepo, cacheRepo, shareRepo, s3Service, userRepo, quotaService, lockRepo, time.Duration(cfg.TTL.S3AndRedis)*time.Second)
+
+	retentionService := service.NewRetentionService(retentionRepo, docService)
+	cacheAdminService := service.NewCacheAdminService(cacheWarmRepo, shareRepo, cacheRepo, &cfg.Cache.Warmup)
 
 	jwtService := security.NewJWTService(&cfg.JWT)
-	userService := service.NewUserService(userRepo, jwtService, jwtRepo, &cfg.Admin)
-	authService := service.NewAuthenticationService(jwtRepo, cfg, jwtService, userRepo)
+	userService := service.NewUserService(userRepo, jwtService, jwtRepo, &cfg.Admin, tokenDenylist)
+	authService := service.NewAuthenticationService(jwtRepo, cfg, jwtService, userRepo, tokenDenylist)
+	roleService := service.NewRoleService(roleRepo, userRepo, jwtRepo, tokenDenylist)
 
 	authHandler := handler.NewAuthenticationHandler(authService, jwtService, jwtRepo)
 	docHandler := handler.NewDocumentHandler(docService, &cfg.TTL)
 	userHandler := handler.NewUserHandler(userService)
+	quotaHandler := handler.NewQuotaHandler(quotaService)
+	retentionHandler := handler.NewRetentionHandler(retentionService)
+	healthHandler := handler.NewHealthHandler(db, cacheBreaker, tokenDenylist)
+	cacheAdminHandler := handler.NewCacheAdminHandler(cacheAdminService)
+	roleHandler := handler.NewRoleHandler(roleService)
 
 	router.Use(config.DBMiddleware(db))
 	router.Get("/swagger/*", httpSwagger.WrapHandler)
+	router.Get("/health", healthHandler.GetHealth)
 
-	setupAuthRoutes(router, authHandler, jwtService, jwtRepo, cfg)
-	setupUserRoutes(router, userHandler, jwtService, jwtRepo, cfg)
-	setupDocumentRoutes(router, docHandler, jwtService, jwtRepo, cfg)
+	setupDebugRoutes(router, jwtService, jwtRepo, roleService, tokenDenylist, cfg)
+	setupAuthRoutes(router, authHandler, jwtService, jwtRepo, roleService, tokenDenylist, cfg)
+	setupUserRoutes(router, userHandler, quotaHandler, jwtService, jwtRepo, roleService, tokenDenylist, cfg)
+	setupDocumentRoutes(router, docHandler, retentionHandler, jwtService, jwtRepo, roleService, tokenDenylist, cfg)
+	setupAdminRoutes(router, retentionHandler, cacheAdminHandler, userHandler, roleHandler, authHandler, jwtService, jwtRepo, roleService, tokenDenylist, cfg)
+
+	startRetentionWorker(ctx, db, retentionService, &cfg.Retention)
+	if cfg.Cache.Warmup.OnStartup {
+		go cacheAdminService.WarmOnStartup(context.WithValue(ctx, "db", db))
+	}
 
 	runServer(ctx, srv)
 }
 
-func setupAuthRoutes(r chi.Router, h *handler.AuthenticationHandler, jwtService *security.JWTService, jwtRepo *repository.JWTRepository, cfg *config.AppConfig) {
+func setupAuthRoutes(r chi.Router, h *handler.AuthenticationHandler, jwtService *security.JWTService, jwtRepo *repository.JWTRepository, roles security.PermissionResolver, denylist *repository.TokenDenylistRepository, cfg *config.AppConfig) {
 	r.Route("/api/auth", func(r chi.Router) {
 		r.Group(func(r chi.Router) {
-			r.Use(security.JWTMiddleware([]byte(cfg.JWT.SecretKey), jwtRepo, jwtService, cfg.Admin.AdminToken))
+			r.Use(security.JWTMiddleware([]byte(cfg.JWT.SecretKey), jwtRepo, jwtService, roles, denylist))
 			r.Get("/me", h.GetCurrentUsersUUID)
 			r.Head("/me", h.GetCurrentUsersUUIDHead)
-			r.Post("/refresh", h.RefreshToken)
+			r.Get("/sessions", h.ListSessions)
+			r.Delete("/sessions", h.RevokeOtherSessions)
+			r.Delete("/sessions/{id}", h.RevokeSession)
+			r.Get("/security-events", h.ListSecurityEvents)
+			r.Delete("/", h.Logout)
 		})
+		// refresh не за JWTMiddleware: access-токен использованного refresh-токена middleware отклонил бы,
+		// и повторное предъявление не дошло бы до проверки в AuthenticationService.RefreshToken
 		r.Group(func(r chi.Router) {
 			r.Post("/", h.Login)
-			r.Delete("/{token}", h.Logout)
+			r.Post("/refresh", h.RefreshToken)
 		})
 	})
 }
 
-func setupUserRoutes(r chi.Router, h *handler.UserHandler, jwtService *security.JWTService, jwtRepo *repository.JWTRepository, cfg *config.AppConfig) {
+func setupUserRoutes(r chi.Router, h *handler.UserHandler, qh *handler.QuotaHandler, jwtService *security.JWTService, jwtRepo *repository.JWTRepository, roles security.PermissionResolver, denylist *repository.TokenDenylistRepository, cfg *config.AppConfig) {
 	r.Route("/api", func(r chi.Router) {
 		r.Post("/register", h.RegisterUser)
 
 		r.Group(func(r chi.Router) {
-			r.Use(security.JWTMiddleware([]byte(cfg.JWT.SecretKey), jwtRepo, jwtService, cfg.Admin.AdminToken))
+			r.Use(security.JWTMiddleware([]byte(cfg.JWT.SecretKey), jwtRepo, jwtService, roles, denylist))
 
-			r.Get("/users", h.ListUsers)
-			r.Head("/users", h.ListUsers)
+			r.With(security.RequirePermission(model.PermissionUsersList)).Get("/users", h.ListUsers)
+			r.With(security.RequirePermission(model.PermissionUsersList)).Head("/users", h.ListUsers)
 
 			r.Route("/users/{uuid}", func(r chi.Router) {
 				r.Get("/", h.GetUser)
 				r.Head("/", h.GetUserHead)
 				r.Put("/", h.UpdateUser)
 				r.Put("/password", h.UpdatePassword)
+				r.Get("/usage", qh.GetUsage)
+				r.With(security.RequirePermission(model.PermissionUsersManage)).Put("/quota", qh.SetQuota)
+				r.With(security.RequirePermission(model.PermissionUsersManage)).Delete("/quota", qh.ResetQuota)
 			})
 
 			r.Delete("/users/{uuid}", h.DeleteUser)
@@ -126,19 +169,30 @@ func setupUserRoutes(r chi.Router, h *handler.UserHandler, jwtService *security.
 	})
 }
 
-func setupDocumentRoutes(r chi.Router, h *handler.DocumentHandler, jwtService *security.JWTService, jwtRepo *repository.JWTRepository, cfg *config.AppConfig) {
+func setupDocumentRoutes(r chi.Router, h *handler.DocumentHandler, rh *handler.RetentionHandler, jwtService *security.JWTService, jwtRepo *repository.JWTRepository, roles security.PermissionResolver, denylist *repository.TokenDenylistRepository, cfg *config.AppConfig) {
 	r.Route("/api/docs", func(r chi.Router) {
-		r.Use(security.JWTMiddleware([]byte(cfg.JWT.SecretKey), jwtRepo, jwtService, cfg.Admin.AdminToken))
+		r.Use(security.JWTMiddleware([]byte(cfg.JWT.SecretKey), jwtRepo, jwtService, roles, denylist))
+		r.Use(security.RequirePermission(model.PermissionDocumentsRead))
+		write := security.RequirePermission(model.PermissionDocumentsWrite)
+
 		r.Get("/", h.ListDocuments)
 		r.Head("/", h.ListDocumentsHead)
-		r.Post("/", h.CreateDocument)
+		r.With(write).Post("/", h.CreateDocument)
+		r.Post("/archive", h.DownloadArchive)
 
 		r.Route("/{doc_id}", func(r chi.Router) {
 			r.Get("/", h.GetDocument)
 			r.Head("/", h.GetDocumentHead)
-			r.Post("/share", h.ShareDocument)
-			r.Post("/remove-grant", h.RemoveGrantFromDocument)
-			r.Delete("/", h.DeleteDocument)
+			r.With(write).Post("/share", h.ShareDocument)
+			r.With(write).Post("/copy", h.CopyDocument)
+			r.With(write).Post("/remove-grant", h.RemoveGrantFromDocument)
+			r.With(write).Put("/expiry", rh.SetDocumentExpiry)
+			r.With(security.RequirePermission(model.PermissionRetentionManage)).Put("/legal-hold", rh.SetLegalHold)
+			r.Get("/lock", h.GetDocumentLock)
+			r.With(write).Post("/lock", h.LockDocument)
+			r.With(write).Delete("/lock", h.UnlockDocument)
+			r.With(write).Delete("/lock/force", h.ForceUnlockDocument)
+			r.With(write).Delete("/", h.DeleteDocument)
 		})
 	})
 
@@ -152,6 +206,184 @@ func setupDocumentRoutes(r chi.Router, h *handler.DocumentHandler, jwtService *s
 	r.Get("/api/docs/public/{token}", h.GetDocumentByToken)
 }
 
+func setupAdminRoutes(r chi.Router, rh *handler.RetentionHandler, ch *handler.CacheAdminHandler, uh *handler.UserHandler, roleHandler *handler.RoleHandler, ah *handler.AuthenticationHandler, jwtService *security.JWTService, jwtRepo *repository.JWTRepository, roles security.PermissionResolver, denylist *repository.TokenDenylistRepository, cfg *config.AppConfig) {
+	r.Route("/api/admin", func(r chi.Router) {
+		r.Use(security.JWTMiddleware([]byte(cfg.JWT.SecretKey), jwtRepo, jwtService, roles, denylist))
+
+		r.With(security.RequirePermission(model.PermissionRetentionRead)).Get("/retention-policies", rh.ListPolicies)
+		r.With(security.RequirePermission(model.PermissionRetentionManage)).Post("/retention-policies", rh.SavePolicy)
+		r.With(security.RequirePermission(model.PermissionRetentionManage)).Delete("/retention-policies/{policy_id}", rh.DeletePolicy)
+
+		r.Route("/cache", func(r chi.Router) {
+			read := security.RequirePermission(model.PermissionCacheRead)
+			manage := security.RequirePermission(model.PermissionCacheManage)
+			r.With(read).Get("/documents/{doc_id}", ch.InspectDocument)
+			r.With(manage).Delete("/documents", ch.FlushDocuments)
+			r.With(read).Get("/keys", ch.InspectKey)
+			r.With(manage).Delete("/keys", ch.EvictKeys)
+			r.With(manage).Post("/warm", ch.WarmCache)
+		})
+
+		r.With(security.RequirePermission(model.PermissionUsersManage)).Post("/users", uh.CreateUser)
+		r.With(security.RequirePermission(model.PermissionUsersRead)).Get("/users/{uuid}/sessions", ah.ListUserSessions)
+		r.With(security.RequirePermission(model.PermissionUsersManage)).Delete("/users/{uuid}/sessions", ah.RevokeUserSessions)
+		r.With(security.RequirePermission(model.PermissionUsersManage)).Delete("/users/{uuid}/sessions/{id}", ah.RevokeUserSession)
+		r.With(security.RequirePermission(model.PermissionUsersRead)).Get("/users/{uuid}/security-events", ah.ListUserSecurityEvents)
+
+		r.Group(func(r chi.Router) {
+			r.Use(security.RequirePermission(model.PermissionRolesManage))
+			r.Get("/roles", roleHandler.ListRoles)
+			r.Put("/roles/{name}", roleHandler.SaveRole)
+			r.Delete("/roles/{name}", roleHandler.DeleteRole)
+			r.Put("/users/{uuid}/roles", roleHandler.SetUserRoles)
+		})
+	})
+}
+
+// setupDebugRoutes : /debug/vars раскрывает состояние кэша и Redis, поэтому доступен только с правом cache:read
+func setupDebugRoutes(r chi.Router, jwtService *security.JWTService, jwtRepo *repository.JWTRepository, roles security.PermissionResolver, denylist *repository.TokenDenylistRepository, cfg *config.AppConfig) {
+	r.Group(func(r chi.Router) {
+		r.Use(security.JWTMiddleware([]byte(cfg.JWT.SecretKey), jwtRepo, jwtService, roles, denylist))
+		r.Use(security.RequirePermission(model.PermissionCacheRead))
+		r.Get("/debug/vars", expvar.Handler().ServeHTTP)
+	})
+}
+
+// setupRedisCircuitBreaker : ставит предохранитель на клиент Redis до первых команд. Если Redis недоступен
+// при старте, сервис запускается с отключённым кэшем и включает его, когда Redis ответит на ping
+func setupRedisCircuitBreaker(ctx context.Context, redisClient *config.RedisClient, cfg *config.CircuitBreakerConfig) *repository.RedisCircuitBreaker {
+	breaker := repository.NewRedisCircuitBreaker(redisClient.Client, repository.CircuitBreakerOptions{
+		FailureThreshold: cfg.FailureThreshold,
+		ProbeInterval:    parseDuration(cfg.ProbeInterval, 5*time.Second, "cache.circuit_breaker.probe_interval"),
+	})
+	if err := redisClient.Ping(ctx); err != nil {
+		breaker.Open(err)
+	}
+	go breaker.Run(ctx)
+	expvar.Publish("redis_circuit_breaker", expvar.Func(func() any { return breaker.Health() }))
+	return breaker
+}
+
+// setupCacheMetrics : метрики обращений к кэшу по уровням; исход считает репозиторий кэша. nil — метрики выключены
+func setupCacheMetrics(cfg *config.CacheMetricsConfig) *repository.CacheMetrics {
+	if cfg.Enabled == false {
+		return nil
+	}
+	metrics := repository.NewCacheMetrics(repository.CacheMetricsOptions{DebugSampleRate: cfg.DebugSampleRate})
+	expvar.Publish("cache_metrics", expvar.Func(func() any { return metrics.Snapshot() }))
+	return metrics
+}
+
+// maxDocumentListTTL : предел TTL страницы списка документов с запасом до истечения pre-signed URL в ней
+const maxDocumentListTTL = 5 * time.Minute
+
+// DocumentService находит возможности кэша приведением типа, поэтому оба кэша обязаны реализовать их все,
+// иначе возможность молча отключится. Проверки здесь, а не в repository: ports импортирует security, а тот — repository
+var (
+	_ ports.CacheRepository        = (*repository.CacheRepository)(nil)
+	_ ports.CacheLoadLocker        = (*repository.CacheRepository)(nil)
+	_ ports.StaleDocumentCache     = (*repository.CacheRepository)(nil)
+	_ ports.NegativeDocumentCache  = (*repository.CacheRepository)(nil)
+	_ ports.GrantCache             = (*repository.CacheRepository)(nil)
+	_ ports.DocumentListCache      = (*repository.CacheRepository)(nil)
+	_ ports.PublicDocumentCache    = (*repository.CacheRepository)(nil)
+	_ ports.PresignedURLCache      = (*repository.CacheRepository)(nil)
+	_ ports.DocumentPopularity     = (*repository.CacheRepository)(nil)
+	_ ports.VersionedDocumentCache = (*repository.CacheRepository)(nil)
+	_ ports.CacheAdmin             = (*repository.CacheRepository)(nil)
+
+	_ ports.CacheRepository        = (*repository.TieredCacheRepository)(nil)
+	_ ports.CacheLoadLocker        = (*repository.TieredCacheRepository)(nil)
+	_ ports.StaleDocumentCache     = (*repository.TieredCacheRepository)(nil)
+	_ ports.NegativeDocumentCache  = (*repository.TieredCacheRepository)(nil)
+	_ ports.GrantCache             = (*repository.TieredCacheRepository)(nil)
+	_ ports.DocumentListCache      = (*repository.TieredCacheRepository)(nil)
+	_ ports.PublicDocumentCache    = (*repository.TieredCacheRepository)(nil)
+	_ ports.PresignedURLCache      = (*repository.TieredCacheRepository)(nil)
+	_ ports.DocumentPopularity     = (*repository.TieredCacheRepository)(nil)
+	_ ports.VersionedDocumentCache = (*repository.TieredCacheRepository)(nil)
+	_ ports.CacheAdmin             = (*repository.TieredCacheRepository)(nil)
+)
+
+// setupDocumentCache : при включённом local-кэше ставит in-process LRU перед Redis,
+// подписывает его на шину инвалидаций между репликами и публикует статистику уровней кэша в /debug/vars
+func setupDocumentCache(ctx context.Context, redisClient *config.RedisClient, breaker *repository.RedisCircuitBreaker, metrics *repository.CacheMetrics, ttl time.Duration, cfg *config.CacheConfig) ports.CacheRepository {
+	options := repository.CacheOptions{
+		TTLJitter:            cfg.TTLJitter,
+		StaleWhileRevalidate: parseDuration(cfg.StaleWhileRevalidate, 0, "cache.stale_while_revalidate"),
+		StaleMaxAge:          parseDuration(cfg.StaleMaxAge, 0, "cache.stale_max_age"),
+		NegativeTTL:          parseDuration(cfg.NegativeTTL, 0, "cache.negative_ttl"),
+		GrantTTL:             parseDuration(cfg.GrantsTTL, 0, "cache.grants_ttl"),
+		ListTTL:              parseDuration(cfg.ListTTL, 0, "cache.list_ttl"),
+		PresignMinRemaining:  parseDuration(cfg.PresignedURLMinRemaining, 0, "cache.presigned_url_min_remaining"),
+		Encoding:             cfg.Encoding,
+		CompressThreshold:    cfg.CompressThreshold,
+		PopularityMaxTracked: cfg.Popularity.MaxTracked,
+		WriteThrough:         cfg.WriteThrough,
+		Metrics:              metrics,
+	}
+	// страницы списка содержат pre-signed URL на 15 минут, кэшированная страница не должна их пережить;
+	// ссылка из кэша ссылок может быть выдана, когда ей осталось лишь presigned_url_min_remaining
+	maxListTTL := maxDocumentListTTL
+	if options.PresignMinRemaining > 0 && options.PresignMinRemaining/2 < maxListTTL {
+		maxListTTL = options.PresignMinRemaining / 2
+	}
+	if options.ListTTL > maxListTTL {
+		log.Printf("[main] cache.list_ttl %s больше допустимого, используется %s", options.ListTTL, maxListTTL)
+		options.ListTTL = maxListTTL
+	}
+	if cfg.Stampede.LockEnabled {
+		options.LoadLockTTL = parseDuration(cfg.Stampede.LockTTL, 5*time.Second, "cache.stampede.lock_ttl")
+		options.LoadWaitTimeout = parseDuration(cfg.Stampede.WaitTimeout, 2*time.Second, "cache.stampede.wait_timeout")
+	}
+
+	popularityFlushInterval := parseDuration(cfg.Popularity.FlushInterval, 10*time.Second, "cache.popularity.flush_interval")
+
+	if cfg.Local.Enabled == false {
+		redisCache := repository.NewCacheRepository(redisClient.Client, ttl, nil, options)
+		breaker.OnRecover(redisCache.ReplayInvalidations)
+		go redisCache.RunPopularityFlush(ctx, popularityFlushInterval)
+		return redisCache
+	}
+
+	localTTL := parseDuration(cfg.Local.TTL, 5*time.Second, "cache.local.ttl")
+
+	bus := repository.NewCacheInvalidationBus(redisClient.Client)
+	redisCache := repository.NewCacheRepository(redisClient.Client, ttl, bus, options)
+	breaker.OnRecover(redisCache.ReplayInvalidations)
+	go redisCache.RunPopularityFlush(ctx, popularityFlushInterval)
+	tiered := repository.NewTieredCacheRepository(redisCache, cfg.Local.MaxEntries, cfg.Local.MaxBytes, localTTL, metrics)
+	go bus.Run(ctx, tiered)
+	expvar.Publish("document_cache", expvar.Func(func() any { return tiered.Stats() }))
+	return tiered
+}
+
+// parseDuration : разбирает длительность из конфига, при пустом или некорректном значении возвращает fallback
+func parseDuration(value string, fallback time.Duration, name string) time.Duration {
+	if value == "" {
+		return fallback
+	}
+
+	parsed, err := time.ParseDuration(value)
+	if err != nil || parsed <= 0 {
+		log.Printf("некорректный %s %q, используется %v", name, value, fallback)
+		return fallback
+	}
+	return parsed
+}
+
+// startRetentionWorker : запускает фоновое удаление документов с истёкшим сроком хранения
+func startRetentionWorker(ctx context.Context, db *config.Database, retentionService *service.RetentionService, cfg *config.RetentionConfig) {
+	interval := parseDuration(cfg.Interval, time.Hour, "retention.interval")
+
+	batchSize := cfg.BatchSize
+	if batchSize <= 0 {
+		batchSize = 100
+	}
+
+	go retentionService.RunExpiryWorker(context.WithValue(ctx, "db", db), interval, batchSize)
+}
+
 //func main() {
 //	ctx, cancel := context.WithCancel(context.Background())
 //	defer cancel()
@@ -25,6 +25,9 @@ type JWTConfig struct {
 	SecretKey       string `yaml:"secret_key"`
 	AccessTokenTTL  string `yaml:"access_token_ttl"`
 	RefreshTokenTTL string `yaml:"refresh_token_ttl"`
+	// DenylistFailClosed : отклонять запросы (503), если отзыв access-токена не удалось проверить из-за недоступного Redis.
+	// По умолчанию такие токены принимаются, а отзыв сессий проверяется только по refresh-токенам в БД
+	DenylistFailClosed bool `yaml:"denylist_fail_closed"`
 }
 
 type WebhookConfig struct {
@@ -33,9 +36,92 @@ type WebhookConfig struct {
 }
 
 type AdminConfig struct {
+	// AdminToken : токен для создания первого администратора через /api/register, пустое значение — регистрация выключена
 	AdminToken string `yaml:"admin_token"`
 }
 
+type QuotaConfig struct {
+	DefaultBytes int64 `yaml:"default_bytes"` // 0 — без ограничений
+}
+
+type RetentionConfig struct {
+	Interval  string `yaml:"interval"`   // период запуска удаления просроченных документов, например "1h"
+	BatchSize int    `yaml:"batch_size"` // сколько документов удалять за один проход
+}
+
+type CacheConfig struct {
+	Local     LocalCacheConfig    `yaml:"local"`
+	TTLJitter float64             `yaml:"ttl_jitter"` // доля TTL, на которую случайно сокращается срок жизни записи в Redis
+	Stampede  CacheStampedeConfig `yaml:"stampede"`
+	// StaleWhileRevalidate : сколько после TTL устаревшая копия отдаётся сразу, а обновляется в фоне
+	StaleWhileRevalidate string `yaml:"stale_while_revalidate"`
+	// StaleMaxAge : максимальный возраст копии, которую можно отдать, если БД недоступна
+	StaleMaxAge string `yaml:"stale_max_age"`
+	// NegativeTTL : сколько помнить, что документ не найден или публичный токен неверен
+	NegativeTTL string `yaml:"negative_ttl"`
+	// GrantsTTL : сколько живёт кэшированное множество пользователей с grant на документ
+	GrantsTTL string `yaml:"grants_ttl"`
+	// ListTTL : сколько живёт страница списка документов; не больше 5m и половины PresignedURLMinRemaining, т.к. в ней pre-signed URL
+	ListTTL string `yaml:"list_ttl"`
+	// PresignedURLMinRemaining : pre-signed ссылка переиспользуется, пока до её истечения осталось не меньше
+	PresignedURLMinRemaining string `yaml:"presigned_url_min_remaining"`
+	// Encoding : кодировка документов в Redis — "binary" или "json"; читаются обе независимо от настройки
+	Encoding string `yaml:"encoding"`
+	// CompressThreshold : записи больше стольких байт сжимаются, 0 — без сжатия
+	CompressThreshold int `yaml:"compress_threshold"`
+	// WriteThrough : после изменения документ записывается в кэш с проверкой версии, а не только удаляется из него
+	WriteThrough bool `yaml:"write_through"`
+	// CircuitBreaker : после серии ошибок соединения кэш отключается, пока Redis не ответит на ping
+	CircuitBreaker CircuitBreakerConfig `yaml:"circuit_breaker"`
+	// Popularity : учёт частоты чтения документов в sorted set document:popularity
+	Popularity CachePopularityConfig `yaml:"popularity"`
+	// Warmup : прогрев самых популярных документов при старте и по запросу администратора
+	Warmup CacheWarmupConfig `yaml:"warmup"`
+	// Metrics : счётчики, задержки и размеры значений кэша документов (L1 и Redis) в /debug/vars
+	Metrics CacheMetricsConfig `yaml:"metrics"`
+}
+
+// CacheMetricsConfig : метрики кэша по уровням, операциям и пространствам ключей
+type CacheMetricsConfig struct {
+	Enabled         bool    `yaml:"enabled"`
+	DebugSampleRate float64 `yaml:"debug_sample_rate"` // доля обращений в отладочном логе, 0 — без лога
+}
+
+// CachePopularityConfig : учёт обращений к документам
+type CachePopularityConfig struct {
+	MaxTracked    int    `yaml:"max_tracked"`    // сколько самых популярных документов хранить, 0 — не учитывать
+	FlushInterval string `yaml:"flush_interval"` // как часто сбрасывать накопленные обращения в Redis
+}
+
+// CacheWarmupConfig : прогрев кэша. Документы читаются из БД пачками не быстрее Rate документов в секунду
+type CacheWarmupConfig struct {
+	OnStartup bool `yaml:"on_startup"`
+	Top       int  `yaml:"top"`        // сколько популярных документов прогревать при старте
+	Rate      int  `yaml:"rate"`       // документов в секунду
+	BatchSize int  `yaml:"batch_size"` // документов в одном запросе к БД
+}
+
+// CircuitBreakerConfig : предохранитель Redis
+type CircuitBreakerConfig struct {
+	FailureThreshold int    `yaml:"failure_threshold"` // ошибок соединения подряд до отключения кэша
+	ProbeInterval    string `yaml:"probe_interval"`    // как часто проверять Redis, пока кэш отключён
+}
+
+// CacheStampedeConfig : короткая блокировка в Redis, чтобы при промахе в БД ходила только одна реплика
+type CacheStampedeConfig struct {
+	LockEnabled bool   `yaml:"lock_enabled"`
+	LockTTL     string `yaml:"lock_ttl"`     // сколько держится блокировка, если загрузившая реплика упала
+	WaitTimeout string `yaml:"wait_timeout"` // сколько остальные ждут появления документа в кэше
+}
+
+// LocalCacheConfig : in-process кэш (L1) перед Redis
+type LocalCacheConfig struct {
+	Enabled    bool   `yaml:"enabled"`
+	MaxEntries int    `yaml:"max_entries"`
+	MaxBytes   int64  `yaml:"max_bytes"`
+	TTL        string `yaml:"ttl"` // короткий, ограничивает время жизни записи, если инвалидация до реплики не дошла
+}
+
 type TTL struct {
 	S3AndRedis int `yaml:"s3_and_redis"`
 }
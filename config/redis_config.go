@@ -2,37 +2,165 @@ package config
 
 import (
 	"context"
+	"crypto/tls"
+	"crypto/x509"
 	"fmt"
 	"github.com/redis/go-redis/v9"
+	"os"
 	"time"
 )
 
+// Режимы подключения к Redis
+const (
+	RedisModeStandalone = "standalone"
+	RedisModeSentinel   = "sentinel"
+	RedisModeCluster    = "cluster"
+)
+
 type RedisConfig struct {
-	Address  string `yaml:"address"`
-	Password string `yaml:"password"`
-	Database int    `yaml:"database"`
-	TTL      int    `yaml:"ttl"`
+	Mode      string   `yaml:"mode"`      // standalone (по умолчанию), sentinel или cluster
+	Address   string   `yaml:"address"`   // адрес для standalone
+	Addresses []string `yaml:"addresses"` // адреса sentinel'ей или узлов кластера
+	// MasterName : имя мастера, за которым следят sentinel'и
+	MasterName       string         `yaml:"master_name"`
+	Username         string         `yaml:"username"` // ACL-пользователь, пусто — default
+	Password         string         `yaml:"password"`
+	SentinelUsername string         `yaml:"sentinel_username"`
+	SentinelPassword string         `yaml:"sentinel_password"`
+	Database         int            `yaml:"database"` // в cluster допустима только 0
+	TTL              int            `yaml:"ttl"`
+	PoolSize         int            `yaml:"pool_size"`      // 0 — по умолчанию go-redis (10 на CPU)
+	MinIdleConns     int            `yaml:"min_idle_conns"` // 0 — не держать простаивающие соединения
+	DialTimeout      string         `yaml:"dial_timeout"`
+	ReadTimeout      string         `yaml:"read_timeout"`
+	WriteTimeout     string         `yaml:"write_timeout"`
+	TLS              RedisTLSConfig `yaml:"tls"`
+}
+
+// RedisTLSConfig : TLS до Redis; без ca_file используются системные корневые сертификаты
+type RedisTLSConfig struct {
+	Enabled            bool   `yaml:"enabled"`
+	ServerName         string `yaml:"server_name"`
+	CAFile             string `yaml:"ca_file"`
+	InsecureSkipVerify bool   `yaml:"insecure_skip_verify"`
 }
 
+// RedisClient : клиент любой топологии — одиночный узел, sentinel или кластер
 type RedisClient struct {
-	Client *redis.Client
+	Client redis.UniversalClient
 }
 
 func NewRedisClient(cfg *RedisConfig) (*RedisClient, error) {
-	client := redis.NewClient(&redis.Options{
-		Addr:     cfg.Address,
-		Password: cfg.Password,
-		DB:       cfg.Database,
-	})
+	options, err := cfg.universalOptions()
+	if err != nil {
+		return nil, err
+	}
+
+	var client redis.UniversalClient
+	switch cfg.Mode {
+	case "", RedisModeStandalone:
+		if cfg.Address != "" {
+			options.Addrs = []string{cfg.Address}
+		}
+		if len(options.Addrs) != 1 {
+			return nil, fmt.Errorf("для Redis standalone нужен ровно один адрес")
+		}
+		client = redis.NewClient(options.Simple())
+	case RedisModeSentinel:
+		if cfg.MasterName == "" || len(options.Addrs) == 0 {
+			return nil, fmt.Errorf("для Redis sentinel нужны master_name и addresses")
+		}
+		client = redis.NewFailoverClient(options.Failover())
+	case RedisModeCluster:
+		if len(options.Addrs) == 0 {
+			return nil, fmt.Errorf("для Redis cluster нужны addresses")
+		}
+		if cfg.Database != 0 {
+			return nil, fmt.Errorf("Redis cluster поддерживает только database 0")
+		}
+		client = redis.NewClusterClient(options.Cluster())
+	default:
+		return nil, fmt.Errorf("неизвестный режим Redis: %s", cfg.Mode)
+	}
+
+	return &RedisClient{Client: client}, nil
+}
 
-	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+// Ping : проверка доступности Redis. Клиент создаётся и без неё — go-redis подключится, когда Redis появится
+func (r *RedisClient) Ping(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(ctx, 2*time.Second)
 	defer cancel()
 
-	if err := client.Ping(ctx).Err(); err != nil {
-		return nil, fmt.Errorf("ошибка пинга БД Redis'а: %w", err)
+	if err := r.Client.Ping(ctx).Err(); err != nil {
+		return fmt.Errorf("ошибка пинга БД Redis'а: %w", err)
 	}
+	return nil
+}
 
-	return &RedisClient{Client: client}, nil
+func (cfg *RedisConfig) universalOptions() (*redis.UniversalOptions, error) {
+	options := &redis.UniversalOptions{
+		Addrs:            cfg.Addresses,
+		MasterName:       cfg.MasterName,
+		Username:         cfg.Username,
+		Password:         cfg.Password,
+		SentinelUsername: cfg.SentinelUsername,
+		SentinelPassword: cfg.SentinelPassword,
+		DB:               cfg.Database,
+		PoolSize:         cfg.PoolSize,
+		MinIdleConns:     cfg.MinIdleConns,
+	}
+
+	timeouts := []struct {
+		value  string
+		target *time.Duration
+		name   string
+	}{
+		{cfg.DialTimeout, &options.DialTimeout, "dial_timeout"},
+		{cfg.ReadTimeout, &options.ReadTimeout, "read_timeout"},
+		{cfg.WriteTimeout, &options.WriteTimeout, "write_timeout"},
+	}
+	for _, timeout := range timeouts {
+		if timeout.value == "" {
+			continue
+		}
+		d, err := time.ParseDuration(timeout.value)
+		if err != nil {
+			return nil, fmt.Errorf("неверный redisConfig.%s: %w", timeout.name, err)
+		}
+		*timeout.target = d
+	}
+
+	if cfg.TLS.Enabled {
+		tlsConfig, err := cfg.TLS.build()
+		if err != nil {
+			return nil, err
+		}
+		options.TLSConfig = tlsConfig
+	}
+
+	return options, nil
+}
+
+func (cfg *RedisTLSConfig) build() (*tls.Config, error) {
+	tlsConfig := &tls.Config{
+		MinVersion:         tls.VersionTLS12,
+		ServerName:         cfg.ServerName,
+		InsecureSkipVerify: cfg.InsecureSkipVerify,
+	}
+
+	if cfg.CAFile != "" {
+		pem, err := os.ReadFile(cfg.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("ошибка чтения CA для Redis: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if pool.AppendCertsFromPEM(pem) == false {
+			return nil, fmt.Errorf("в %s нет сертификатов CA для Redis", cfg.CAFile)
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	return tlsConfig, nil
 }
 
 func (r *RedisClient) Close() error {
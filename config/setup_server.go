@@ -8,14 +8,17 @@ import (
 )
 
 type AppConfig struct {
-	DatabaseConfig DatabaseConfig `yaml:"databaseConfig"`
-	RedisConfig    RedisConfig    `yaml:"redisConfig"`
-	ServerAddr     string         `yaml:"serverAddr"`
-	S3Config       S3Config       `yaml:"s3Config"`
-	JWT            JWTConfig      `yaml:"jwt"`
-	Webhook        WebhookConfig  `yaml:"webhook"`
-	Admin          AdminConfig    `yaml:"admin"`
-	TTL            TTL            `yaml:"TTL"`
+	DatabaseConfig DatabaseConfig  `yaml:"databaseConfig"`
+	RedisConfig    RedisConfig     `yaml:"redisConfig"`
+	ServerAddr     string          `yaml:"serverAddr"`
+	S3Config       S3Config        `yaml:"s3Config"`
+	JWT            JWTConfig       `yaml:"jwt"`
+	Webhook        WebhookConfig   `yaml:"webhook"`
+	Admin          AdminConfig     `yaml:"admin"`
+	TTL            TTL             `yaml:"TTL"`
+	Quota          QuotaConfig     `yaml:"quota"`
+	Retention      RetentionConfig `yaml:"retention"`
+	Cache          CacheConfig     `yaml:"cache"`
 }
 
 func LoadConfig(path string) (*AppConfig, error) {
@@ -16,6 +16,41 @@ const docTemplate = `{
     "basePath": "{{.BasePath}}",
     "paths": {
         "/api/auth": {
+            "delete": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Инвалидирует refresh-токен и access-токен, которым выполнен запрос.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Authentication"
+                ],
+                "summary": "Завершение авторизованной сессии",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/requestresponse.LogoutResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/requestresponse.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/requestresponse.ErrorResponse"
+                        }
+                    }
+                }
+            },
             "post": {
                 "description": "Получение access токена по логину и паролю",
                 "consumes": [
@@ -221,58 +256,6 @@ const docTemplate = `{
                 }
             }
         },
-        "/api/auth/{token}": {
-            "delete": {
-                "security": [
-                    {
-                        "ApiKeyAuth": []
-                    }
-                ],
-                "description": "Инвалидирует refresh-токен и завершает сессию пользователя по access-токену, переданному в URL.",
-                "produces": [
-                    "application/json"
-                ],
-                "tags": [
-                    "Authentication"
-                ],
-                "summary": "Завершение авторизованной сессии",
-                "parameters": [
-                    {
-                        "type": "string",
-                        "description": "Access-токен пользователя (JWT)",
-                        "name": "token",
-                        "in": "path",
-                        "required": true
-                    }
-                ],
-                "responses": {
-                    "200": {
-                        "description": "OK",
-                        "schema": {
-                            "$ref": "#/definitions/requestresponse.LogoutResponse"
-                        }
-                    },
-                    "400": {
-                        "description": "Bad Request",
-                        "schema": {
-                            "$ref": "#/definitions/requestresponse.ErrorResponse"
-                        }
-                    },
-                    "401": {
-                        "description": "Unauthorized",
-                        "schema": {
-                            "$ref": "#/definitions/requestresponse.ErrorResponse"
-                        }
-                    },
-                    "500": {
-                        "description": "Internal Server Error",
-                        "schema": {
-                            "$ref": "#/definitions/requestresponse.ErrorResponse"
-                        }
-                    }
-                }
-            }
-        },
         "/api/docs": {
             "get": {
                 "security": [
@@ -11,6 +11,26 @@ CREATE UNIQUE INDEX uq_users_login_lower ON users (lower(login));
 CREATE INDEX idx_users_created_at_uuid
     ON users (created_at ASC, uuid ASC);
 
+-- роли: набор прав ("*" — все права). Встроенные роли нельзя изменить или удалить
+CREATE TABLE roles (
+    name        TEXT PRIMARY KEY,
+    permissions TEXT[] NOT NULL DEFAULT '{}',
+    builtin     BOOLEAN NOT NULL DEFAULT false,
+    created_at  TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+INSERT INTO roles (name, permissions, builtin) VALUES
+    ('admin', '{*}', true),
+    ('user', '{documents:read,documents:write,users:list}', true),
+    ('auditor', '{documents:read,users:list,users:read,retention:read,cache:read}', true);
+
+-- роли пользователей; пользователь без ролей считается обычным (user)
+CREATE TABLE user_roles (
+    user_uuid  UUID NOT NULL REFERENCES users(uuid) ON DELETE CASCADE,
+    role_name  TEXT NOT NULL REFERENCES roles(name) ON DELETE CASCADE,
+    PRIMARY KEY (user_uuid, role_name)
+);
+CREATE INDEX idx_user_roles_role ON user_roles(role_name);
+
 
 -- documents
 CREATE TABLE documents (
@@ -26,7 +46,14 @@ CREATE TABLE documents (
     access_token   TEXT UNIQUE,
     created_at     TIMESTAMPTZ NOT NULL DEFAULT now(),
     updated_at     TIMESTAMPTZ NOT NULL DEFAULT now(),
-    deleted_at     TIMESTAMPTZ NULL
+    deleted_at     TIMESTAMPTZ NULL,
+    expires_at     TIMESTAMPTZ NULL,
+    legal_hold     BOOLEAN NOT NULL DEFAULT false,
+    -- неудачные попытки удалить просроченный документ: следующая не раньше expiry_retry_at
+    expiry_failures INTEGER NOT NULL DEFAULT 0,
+    expiry_retry_at TIMESTAMPTZ NULL,
+    -- растёт при каждом изменении документа и его grant, по нему кэш отбрасывает устаревшие записи
+    version        BIGINT NOT NULL DEFAULT 1
 );
 CREATE INDEX idx_documents_owner_created ON documents(owner_uuid, created_at DESC, uuid);
 CREATE INDEX idx_documents_sha256 ON documents(sha256);
@@ -34,6 +61,32 @@ CREATE INDEX idx_documents_access_token ON documents(access_token);
 CREATE INDEX idx_documents_owner_name_created
     ON documents(owner_uuid, filename_original ASC, created_at ASC);
 
+CREATE INDEX idx_documents_expires_at ON documents(expires_at) WHERE expires_at IS NOT NULL AND legal_hold = false;
+
+-- политики хранения: срок жизни документов по MIME-типу ("image/png" или "image/*")
+CREATE TABLE retention_policies (
+    uuid           UUID PRIMARY KEY,
+    mime_type      TEXT NOT NULL UNIQUE,
+    retention_days INTEGER NOT NULL CHECK (retention_days > 0),
+    created_at     TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+-- блокировки документов (основное хранилище — Redis, таблица используется, пока Redis недоступен)
+CREATE TABLE document_locks (
+    document_uuid UUID PRIMARY KEY REFERENCES documents(uuid) ON DELETE CASCADE,
+    holder_uuid   UUID NOT NULL REFERENCES users(uuid) ON DELETE CASCADE,
+    reason        TEXT NOT NULL DEFAULT '',
+    locked_at     TIMESTAMPTZ NOT NULL DEFAULT now(),
+    expires_at    TIMESTAMPTZ NOT NULL
+);
+
+-- индивидуальные квоты (переопределяют quota.default_bytes из конфига)
+CREATE TABLE user_quotas (
+    user_uuid   UUID PRIMARY KEY REFERENCES users(uuid) ON DELETE CASCADE,
+    quota_bytes BIGINT NOT NULL CHECK (quota_bytes >= 0),
+    updated_at  TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
 -- sharing ACL
 CREATE TABLE document_grants (
     document_uuid  UUID NOT NULL REFERENCES documents(uuid) ON DELETE CASCADE,
@@ -67,7 +120,26 @@ CREATE TABLE refresh_tokens (
     used        BOOLEAN NOT NULL DEFAULT false,
     user_agent  TEXT,
     ip_address  TEXT,
-    created_at  TIMESTAMPTZ NOT NULL DEFAULT now()
+    created_at  TIMESTAMPTZ NOT NULL DEFAULT now(),
+    -- сессия (семейство токенов): цепочка refresh-токенов от одного входа, при обновлении токенов переносится в новый токен.
+    -- Повторное предъявление уже использованного токена семейства отзывает всё семейство
+    session_uuid UUID NOT NULL,
+    last_used_at TIMESTAMPTZ,
+    revoked_at   TIMESTAMPTZ -- время выхода или отзыва сессии
 );
 CREATE INDEX idx_rt_user_exp ON refresh_tokens(user_uuid, expire_at);
+CREATE INDEX idx_rt_session ON refresh_tokens(session_uuid);
 CREATE UNIQUE INDEX uq_rt_token_hash ON refresh_tokens(token_hash);
+
+-- журнал событий безопасности пользователя (повторное использование refresh-токена, вход с нового IP)
+CREATE TABLE security_events (
+    id           BIGSERIAL PRIMARY KEY,
+    user_uuid    UUID NOT NULL REFERENCES users(uuid) ON DELETE CASCADE,
+    event        TEXT NOT NULL,
+    session_uuid UUID,
+    ip_address   TEXT NOT NULL DEFAULT '',
+    user_agent   TEXT NOT NULL DEFAULT '',
+    details      TEXT NOT NULL DEFAULT '',
+    created_at   TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+CREATE INDEX idx_security_events_user ON security_events(user_uuid, id DESC);
@@ -8,7 +8,6 @@ import (
 	"caching-web-server/internal/util"
 	"encoding/json"
 	"fmt"
-	"github.com/go-chi/chi/v5"
 	"log"
 	"net/http"
 	"strings"
@@ -195,40 +194,30 @@ func (h *AuthenticationHandler) RefreshToken(w http.ResponseWriter, r *http.Requ
 
 // Logout godoc
 // @Summary Завершение авторизованной сессии
-// @Description Инвалидирует refresh-токен и завершает сессию пользователя по access-токену, переданному в URL.
+// @Description Инвалидирует refresh-токен и access-токен, которым выполнен запрос.
 // @Tags Authentication
 // @Produce json
-// @Param token path string true "Access-токен пользователя (JWT)"
 // @Success 200 {object} requestresponse.LogoutResponse
-// @Failure 400 {object} requestresponse.ErrorResponse
 // @Failure 401 {object} requestresponse.ErrorResponse
 // @Failure 500 {object} requestresponse.ErrorResponse
 // @Security ApiKeyAuth
-// @Router /api/auth/{token} [delete]
+// @Router /api/auth [delete]
 func (h *AuthenticationHandler) Logout(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 	ctx := r.Context()
 
-	accessToken := chi.URLParam(r, "token")
-	if accessToken == "" {
-		sendErrorResponse(w, http.StatusBadRequest, "токен не указан")
-		return
-	}
-
-	claims, err := h.JWTServiceInterface.ParseAccessToken(accessToken)
+	// токен берётся из заголовка Authorization, уже проверенного JWTMiddleware: в URL он попал бы в логи и историю
+	claims, err := security.GetClaimsFromContext(ctx)
 	if err != nil {
-		sendErrorResponse(w, http.StatusUnauthorized, fmt.Sprintf("невалидный токен: %v", err))
+		sendErrorResponse(w, http.StatusUnauthorized, "пользователь не авторизован")
 		return
 	}
 
 	refreshTokenUUID := claims.RefreshTokenUUID
 
-	if err := h.AuthenticationService.Logout(ctx, refreshTokenUUID); err != nil {
-		if strings.Contains(err.Error(), "не удалось использовать токен") {
-			sendErrorResponse(w, http.StatusBadRequest, err.Error())
-		} else {
-			sendErrorResponse(w, http.StatusInternalServerError, "неизвестная ошибка")
-		}
+	if err := h.AuthenticationService.Logout(ctx, claims); err != nil {
+		log.Println(err)
+		sendErrorResponse(w, http.StatusInternalServerError, "не удалось завершить сессию")
 		return
 	}
 
@@ -0,0 +1,141 @@
+package handler_test
+
+import (
+	"caching-web-server/config"
+	"caching-web-server/internal/handler"
+	"caching-web-server/internal/model"
+	"caching-web-server/internal/security"
+	"caching-web-server/internal/service"
+	"context"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+type MockJWTRepo struct{ mock.Mock }
+
+func (m *MockJWTRepo) FindByUUID(ctx context.Context, uuid string) (*model.RefreshToken, error) {
+	args := m.Called(ctx, uuid)
+	if token, ok := args.Get(0).(*model.RefreshToken); ok {
+		return token, args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *MockJWTRepo) MarkRefreshTokenUsedByUUID(ctx context.Context, uuid string) error {
+	return m.Called(ctx, uuid).Error(0)
+}
+
+func (m *MockJWTRepo) SaveRefreshToken(ctx context.Context, token *model.RefreshToken, ipAddress string) error {
+	return m.Called(ctx, token, ipAddress).Error(0)
+}
+
+func (m *MockJWTRepo) RevokeRefreshToken(ctx context.Context, uuid string) error {
+	return m.Called(ctx, uuid).Error(0)
+}
+
+func (m *MockJWTRepo) ListSessions(ctx context.Context, userUUID string) ([]*model.Session, error) {
+	args := m.Called(ctx, userUUID)
+	if sessions, ok := args.Get(0).([]*model.Session); ok {
+		return sessions, args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *MockJWTRepo) RevokeSession(ctx context.Context, userUUID string, sessionUUID string) ([]string, error) {
+	args := m.Called(ctx, userUUID, sessionUUID)
+	if tokenUUIDs, ok := args.Get(0).([]string); ok {
+		return tokenUUIDs, args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *MockJWTRepo) RevokeSessions(ctx context.Context, userUUID string, exceptSessionUUID string) ([]string, error) {
+	args := m.Called(ctx, userUUID, exceptSessionUUID)
+	if tokenUUIDs, ok := args.Get(0).([]string); ok {
+		return tokenUUIDs, args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *MockJWTRepo) SaveSecurityEvent(ctx context.Context, event *model.SecurityEvent) error {
+	return m.Called(ctx, event).Error(0)
+}
+
+func (m *MockJWTRepo) ListSecurityEvents(ctx context.Context, userUUID string, cursor string, limit int) ([]*model.SecurityEvent, string, error) {
+	args := m.Called(ctx, userUUID, cursor, limit)
+	if events, ok := args.Get(0).([]*model.SecurityEvent); ok {
+		return events, args.String(1), args.Error(2)
+	}
+	return nil, "", args.Error(2)
+}
+
+type MockTokenDenylist struct{ mock.Mock }
+
+func (m *MockTokenDenylist) DenyToken(ctx context.Context, jti string, expiresAt time.Time) error {
+	return m.Called(ctx, jti, expiresAt).Error(0)
+}
+
+func (m *MockTokenDenylist) DenyTokens(ctx context.Context, jtis []string) error {
+	return m.Called(ctx, jtis).Error(0)
+}
+
+func (m *MockTokenDenylist) RevokeIssuedBefore(ctx context.Context, userUUID string, issuedBefore time.Time) error {
+	return m.Called(ctx, userUUID, issuedBefore).Error(0)
+}
+
+func refreshRequest(accessToken string, refreshToken string) *http.Request {
+	request := httptest.NewRequest(http.MethodPost, "/api/auth/refresh", strings.NewReader(`{"refresh_token": "`+refreshToken+`"}`))
+	request.Header.Set("Authorization", "Bearer "+accessToken)
+	request.Header.Set("User-Agent", "test-agent")
+	return request
+}
+
+func TestAuthenticationHandler_RefreshTokenReplay(t *testing.T) {
+	cfg := &config.AppConfig{JWT: config.JWTConfig{SecretKey: "test-secret", AccessTokenTTL: "15m", RefreshTokenTTL: "10h"}}
+	jwtService := security.NewJWTService(&cfg.JWT)
+	jwtRepo := new(MockJWTRepo)
+	denylist := new(MockTokenDenylist)
+	authService := service.NewAuthenticationService(jwtRepo, cfg, jwtService, nil, denylist)
+	h := handler.NewAuthenticationHandler(authService, jwtService, jwtRepo)
+
+	tokens, stored, err := jwtService.GenerateAccessRefreshTokens("user1", []string{model.RoleUser})
+	require.NoError(t, err)
+	stored.UserAgent = "test-agent"
+	stored.IpAddress = "192.0.2.1:1234"
+
+	// первое обновление проходит и помечает refresh-токен использованным
+	jwtRepo.On("FindByUUID", mock.Anything, stored.UUID).Return(stored, nil).Once()
+	jwtRepo.On("MarkRefreshTokenUsedByUUID", mock.Anything, stored.UUID).Return(nil).Once()
+	var issued *model.RefreshToken
+	jwtRepo.On("SaveRefreshToken", mock.Anything, mock.MatchedBy(func(token *model.RefreshToken) bool {
+		issued = token
+		return token.SessionUUID == stored.SessionUUID
+	}), mock.Anything).Return(nil).Once()
+
+	recorder := httptest.NewRecorder()
+	h.RefreshToken(recorder, refreshRequest(tokens.AccessToken, tokens.RefreshToken))
+	require.Equal(t, http.StatusOK, recorder.Code, recorder.Body.String())
+
+	// тот же refresh-токен предъявлен ещё раз: клиенту 401, сессия отзывается вместе с access-токеном,
+	// выданным при обновлении, событие пишется в журнал
+	used := *stored
+	used.Used = true
+	jwtRepo.On("FindByUUID", mock.Anything, stored.UUID).Return(&used, nil).Once()
+	jwtRepo.On("RevokeSession", mock.Anything, "user1", stored.SessionUUID).Return([]string{issued.UUID}, nil).Once()
+	denylist.On("DenyTokens", mock.Anything, []string{issued.UUID}).Return(nil).Once()
+	jwtRepo.On("SaveSecurityEvent", mock.Anything, mock.MatchedBy(func(event *model.SecurityEvent) bool {
+		return event.Event == model.SecurityEventRefreshTokenReuse && event.UserUUID == "user1"
+	})).Return(nil).Once()
+
+	recorder = httptest.NewRecorder()
+	h.RefreshToken(recorder, refreshRequest(tokens.AccessToken, tokens.RefreshToken))
+	assert.Equal(t, http.StatusUnauthorized, recorder.Code)
+	jwtRepo.AssertExpectations(t)
+	denylist.AssertExpectations(t)
+}
@@ -0,0 +1,206 @@
+package handler
+
+import (
+	"caching-web-server/internal/model"
+	"caching-web-server/internal/model/requestresponse"
+	"caching-web-server/internal/ports"
+	"caching-web-server/internal/util"
+	"encoding/json"
+	"github.com/go-chi/chi/v5"
+	"log"
+	"net/http"
+	"strings"
+)
+
+type CacheAdminHandler struct {
+	ports.CacheAdminService
+}
+
+func NewCacheAdminHandler(cacheAdminService ports.CacheAdminService) *CacheAdminHandler {
+	return &CacheAdminHandler{cacheAdminService}
+}
+
+// InspectDocument godoc
+// @Summary Запись кэша документа
+// @Description Показывает запись document:{doc_id}: значение, оставшийся TTL, размер и кодировку. Требуется право cache:read.
+// @Tags Cache
+// @Produce json
+// @Param doc_id path string true "UUID документа"
+// @Param Authorization header string true "Bearer токен" default(Bearer <access_token>)
+// @Success 200 {object} requestresponse.CacheKeyResponse
+// @Failure 401 {object} requestresponse.ErrorResponse
+// @Failure 403 {object} requestresponse.ErrorResponse
+// @Failure 404 {object} requestresponse.ErrorResponse
+// @Failure 500 {object} requestresponse.ErrorResponse
+// @Router /api/admin/cache/documents/{doc_id} [get]
+// @Security BearerAuth
+func (h *CacheAdminHandler) InspectDocument(w http.ResponseWriter, r *http.Request) {
+	info, err := h.CacheAdminService.InspectDocument(r.Context(), chi.URLParam(r, "doc_id"))
+	if err != nil {
+		handleCacheAdminError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(requestresponse.CacheKeyResponse{Data: *info})
+}
+
+// InspectKey godoc
+// @Summary Ключ кэша
+// @Description Показывает любой ключ пространства document: (например, document:token:<token> или document:list:{owner:<uuid>}:tag). Требуется право cache:read.
+// @Tags Cache
+// @Produce json
+// @Param key query string true "Ключ Redis"
+// @Param Authorization header string true "Bearer токен" default(Bearer <access_token>)
+// @Success 200 {object} requestresponse.CacheKeyResponse
+// @Failure 400 {object} requestresponse.ErrorResponse
+// @Failure 401 {object} requestresponse.ErrorResponse
+// @Failure 403 {object} requestresponse.ErrorResponse
+// @Failure 404 {object} requestresponse.ErrorResponse
+// @Failure 500 {object} requestresponse.ErrorResponse
+// @Router /api/admin/cache/keys [get]
+// @Security BearerAuth
+func (h *CacheAdminHandler) InspectKey(w http.ResponseWriter, r *http.Request) {
+	info, err := h.CacheAdminService.InspectKey(r.Context(), r.URL.Query().Get("key"))
+	if err != nil {
+		handleCacheAdminError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(requestresponse.CacheKeyResponse{Data: *info})
+}
+
+// EvictKeys godoc
+// @Summary Удаление ключей кэша
+// @Description Удаляет ключ (key) или все ключи по шаблону SCAN (pattern, например document:presign:*) в пространстве document:.
+// Блокировки документов и версии grant не удаляются. Требуется право cache:manage.
+// @Tags Cache
+// @Produce json
+// @Param key query string false "Ключ Redis"
+// @Param pattern query string false "Шаблон ключей"
+// @Param Authorization header string true "Bearer токен" default(Bearer <access_token>)
+// @Success 200 {object} requestresponse.EvictCacheResponse
+// @Failure 400 {object} requestresponse.ErrorResponse
+// @Failure 401 {object} requestresponse.ErrorResponse
+// @Failure 403 {object} requestresponse.ErrorResponse
+// @Failure 500 {object} requestresponse.ErrorResponse
+// @Router /api/admin/cache/keys [delete]
+// @Security BearerAuth
+func (h *CacheAdminHandler) EvictKeys(w http.ResponseWriter, r *http.Request) {
+	pattern := r.URL.Query().Get("key")
+	if pattern == "" {
+		pattern = r.URL.Query().Get("pattern")
+	} else if strings.ContainsAny(pattern, "*?[") {
+		util.HandleError(w, "В key нельзя использовать шаблон, используйте pattern", http.StatusBadRequest)
+		return
+	}
+
+	deleted, err := h.CacheAdminService.EvictKeys(r.Context(), pattern)
+	if err != nil {
+		handleCacheAdminError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(requestresponse.EvictCacheResponse{Deleted: deleted})
+}
+
+// FlushDocuments godoc
+// @Summary Очистка кэша документов
+// @Description Удаляет все записи пространства document: во всех узлах Redis и очищает локальный кэш реплик. Требуется право cache:manage.
+// @Tags Cache
+// @Produce json
+// @Param Authorization header string true "Bearer токен" default(Bearer <access_token>)
+// @Success 200 {object} requestresponse.EvictCacheResponse
+// @Failure 401 {object} requestresponse.ErrorResponse
+// @Failure 403 {object} requestresponse.ErrorResponse
+// @Failure 500 {object} requestresponse.ErrorResponse
+// @Router /api/admin/cache/documents [delete]
+// @Security BearerAuth
+func (h *CacheAdminHandler) FlushDocuments(w http.ResponseWriter, r *http.Request) {
+	deleted, err := h.CacheAdminService.FlushDocuments(r.Context())
+	if err != nil {
+		handleCacheAdminError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(requestresponse.EvictCacheResponse{Deleted: deleted})
+}
+
+// WarmCache godoc
+// @Summary Прогрев кэша
+// @Description Загружает в кэш документы из списка documents, последние изменённые документы пользователя user_uuid (limit, по умолчанию 20)
+// или top самых читаемых документов. Документы читаются из БД с ограничением cache.warmup.rate. Требуется право cache:manage.
+// @Tags Cache
+// @Accept json
+// @Produce json
+// @Param body body requestresponse.WarmCacheRequest true "Что прогреть"
+// @Param Authorization header string true "Bearer токен" default(Bearer <access_token>)
+// @Success 200 {object} requestresponse.WarmCacheResponse
+// @Failure 400 {object} requestresponse.ErrorResponse
+// @Failure 401 {object} requestresponse.ErrorResponse
+// @Failure 403 {object} requestresponse.ErrorResponse
+// @Failure 500 {object} requestresponse.ErrorResponse
+// @Router /api/admin/cache/warm [post]
+// @Security BearerAuth
+func (h *CacheAdminHandler) WarmCache(w http.ResponseWriter, r *http.Request) {
+	var req requestresponse.WarmCacheRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		util.HandleError(w, "Неверный формат запроса", http.StatusBadRequest)
+		return
+	}
+	var result *model.CacheWarmResult
+	var err error
+	switch {
+	case len(req.Documents) > 0:
+		result, err = h.CacheAdminService.WarmDocuments(r.Context(), req.Documents)
+	case req.UserUUID != "":
+		result, err = h.CacheAdminService.WarmUserDocuments(r.Context(), req.UserUUID, req.Limit)
+	case req.Top > 0:
+		result, err = h.CacheAdminService.WarmPopularDocuments(r.Context(), req.Top)
+	default:
+		util.HandleError(w, "Нужно указать documents, user_uuid или top", http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		handleCacheAdminError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(requestresponse.WarmCacheResponse{Data: *result})
+}
+
+func handleCacheAdminError(w http.ResponseWriter, err error) {
+	log.Println(err)
+	switch {
+	case strings.Contains(err.Error(), "не авторизован"):
+		util.HandleError(w, "Пользователь не авторизован", http.StatusUnauthorized)
+	case strings.Contains(err.Error(), "доступ запрещён"):
+		util.HandleError(w, "Доступ запрещен", http.StatusForbidden)
+	case strings.Contains(err.Error(), "не найден в кэше"):
+		util.HandleError(w, "Ключ не найден в кэше", http.StatusNotFound)
+	case strings.Contains(err.Error(), "вне пространства"):
+		util.HandleError(w, "Доступны только ключи document:", http.StatusBadRequest)
+	case strings.Contains(err.Error(), "не удаляются через кэш"):
+		util.HandleError(w, "Блокировки, версии grant и популярность нельзя удалить", http.StatusBadRequest)
+	case strings.Contains(err.Error(), "не указан"):
+		util.HandleError(w, "Не указаны ключи или документы", http.StatusBadRequest)
+	case strings.Contains(err.Error(), "слишком много документов"):
+		util.HandleError(w, "Слишком много документов в одном запросе", http.StatusBadRequest)
+	case strings.Contains(err.Error(), "не поддерживает администрирование"),
+		strings.Contains(err.Error(), "не учитывает популярность"):
+		util.HandleError(w, "Кэш не поддерживает администрирование", http.StatusNotImplemented)
+	case strings.Contains(err.Error(), "Redis недоступен"):
+		util.HandleError(w, "Кэш временно недоступен", http.StatusServiceUnavailable)
+	default:
+		util.HandleError(w, "внутренняя ошибка сервера", http.StatusInternalServerError)
+	}
+}
@@ -18,7 +18,6 @@ import (
 	"io"
 	"log"
 	"net/http"
-	"net/url"
 	"os"
 	"path/filepath"
 	"strconv"
@@ -48,6 +47,7 @@ func NewDocumentHandler(documentService ports.DocumentService, cfg *config.TTL)
 // @Success 202 {object} requestresponse.CreateDocumentResponse "Успешный ответ, содержит данные документа и pre-signed URL"
 // @Failure 400 {object} requestresponse.ErrorResponse "Неверный формат запроса или мета-данных"
 // @Failure 401 {object} requestresponse.ErrorResponse "Пользователь не авторизован"
+// @Failure 413 {object} requestresponse.ErrorResponse "Превышена квота хранилища"
 // @Failure 500 {object} requestresponse.ErrorResponse "Внутренняя ошибка сервера"
 // @Router /api/docs [post]
 func (h *DocumentHandler) CreateDocument(w http.ResponseWriter, r *http.Request) {
@@ -86,14 +86,7 @@ func (h *DocumentHandler) CreateDocument(w http.ResponseWriter, r *http.Request)
 		mimeType = "application/octet-stream"
 	}
 
-	fileExt := filepath.Ext(header.Filename)
-	fileName := strings.TrimSuffix(header.Filename, fileExt)
-	storagePath := fmt.Sprintf("users/%s/documents/%s-%s%s",
-		claims.UserUUID,
-		url.PathEscape(fileName),
-		uuid.New().String()[:8],
-		fileExt,
-	)
+	storagePath := util.BuildStoragePath(claims.UserUUID, header.Filename)
 
 	isPublic := false
 	if publicStr := r.FormValue("public"); publicStr != "" {
@@ -127,6 +120,8 @@ func (h *DocumentHandler) CreateDocument(w http.ResponseWriter, r *http.Request)
 			return
 		}
 		switch {
+		case strings.Contains(err.Error(), "превышена квота хранилища"):
+			util.HandleError(w, "превышена квота хранилища", http.StatusRequestEntityTooLarge)
 		case strings.Contains(err.Error(), "не удалось URL"),
 			strings.Contains(err.Error(), "не удалось сохранить документ"),
 			strings.Contains(err.Error(), "database connection не найден"):
@@ -227,6 +222,8 @@ func (h *DocumentHandler) monitorUpload(documentUUID string, uploader *util.S3Up
 // @Param doc_id path string true "UUID документа"
 // @Param Authorization header string true "Bearer токен" default(Bearer <access_token>)
 // @Success 200 {object} requestresponse.GetDocumentResponse
+// @Header 200 {string} X-Cache-Status "STALE — документ отдан из устаревшей копии кэша"
+// @Header 200 {integer} Age "возраст устаревшей копии в секундах"
 // @Failure 400 {object} requestresponse.ErrorResponse
 // @Failure 403 {object} requestresponse.ErrorResponse
 // @Failure 404 {object} requestresponse.ErrorResponse
@@ -261,6 +258,11 @@ func (h *DocumentHandler) GetDocument(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if result.Stale {
+		w.Header().Set("X-Cache-Status", "STALE")
+		w.Header().Set("Age", strconv.Itoa(int(result.Age.Seconds())))
+	}
+
 	if r.Method == http.MethodHead {
 		w.Header().Set("Content-Type", result.Document.MimeType)
 		w.Header().Set("Content-Length", strconv.FormatInt(result.Document.SizeBytes, 10))
@@ -278,7 +280,7 @@ func (h *DocumentHandler) GetDocument(w http.ResponseWriter, r *http.Request) {
 		Data: requestresponse.GetDocumentData{
 			Document: requestresponse.DocumentResponseFromModel(result.Document, result.GetURL),
 		},
-		ExpiresIn: strconv.Itoa(h.cfg.S3AndRedis),
+		ExpiresIn: h.urlExpiresIn(result),
 	}
 
 	w.Header().Set("Content-Type", "application/json")
@@ -294,6 +296,8 @@ func (h *DocumentHandler) GetDocument(w http.ResponseWriter, r *http.Request) {
 // @Param doc_id path string true "UUID документа"
 // @Param Authorization header string true "Bearer токен" default(Bearer <access_token>)
 // @Success 200 {object} requestresponse.GetDocumentResponse
+// @Header 200 {string} X-Cache-Status "STALE — документ отдан из устаревшей копии кэша"
+// @Header 200 {integer} Age "возраст устаревшей копии в секундах"
 // @Failure 400 {object} requestresponse.ErrorResponse
 // @Failure 403 {object} requestresponse.ErrorResponse
 // @Failure 404 {object} requestresponse.ErrorResponse
@@ -352,7 +356,7 @@ func (h *DocumentHandler) GetDocumentByToken(w http.ResponseWriter, r *http.Requ
 		Data: requestresponse.GetDocumentData{
 			Document: requestresponse.DocumentResponseFromModel(result.Document, result.GetURL),
 		},
-		ExpiresIn: strconv.Itoa(h.cfg.S3AndRedis),
+		ExpiresIn: h.urlExpiresIn(result),
 	}
 
 	w.Header().Set("Content-Type", "application/json")
@@ -524,6 +528,7 @@ func (h *DocumentHandler) GetPublicDocumentByTokenHead(w http.ResponseWriter, r
 // @Failure 401 {object} requestresponse.ErrorResponse "Пользователь не авторизован"
 // @Failure 403 {object} requestresponse.ErrorResponse "Доступ запрещен"
 // @Failure 404 {object} requestresponse.ErrorResponse "Документ не найден"
+// @Failure 423 {object} requestresponse.ErrorResponse "Документ заблокирован другим пользователем"
 // @Failure 500 {object} requestresponse.ErrorResponse "Внутренняя ошибка"
 // @Router /api/docs/{doc_id}/share [post]
 func (h *DocumentHandler) ShareDocument(w http.ResponseWriter, r *http.Request) {
@@ -549,6 +554,8 @@ func (h *DocumentHandler) ShareDocument(w http.ResponseWriter, r *http.Request)
 	if err != nil {
 		log.Println(err)
 		switch {
+		case strings.Contains(err.Error(), "документ заблокирован"):
+			util.HandleError(w, "Документ заблокирован другим пользователем", http.StatusLocked)
 		case strings.Contains(err.Error(), "доступ запрещён"):
 			util.HandleError(w, "Доступ запрещен", http.StatusForbidden)
 		case strings.Contains(err.Error(), "документ не найден"):
@@ -581,6 +588,7 @@ func (h *DocumentHandler) ShareDocument(w http.ResponseWriter, r *http.Request)
 // @Failure 401 {object} requestresponse.ErrorResponse "Пользователь не авторизован"
 // @Failure 403 {object} requestresponse.ErrorResponse "Доступ запрещен"
 // @Failure 404 {object} requestresponse.ErrorResponse "Документ не найден"
+// @Failure 423 {object} requestresponse.ErrorResponse "Документ заблокирован другим пользователем"
 // @Failure 500 {object} requestresponse.ErrorResponse "Внутренняя ошибка сервера"
 // @Router /api/docs/{doc_id}/remove-grant [post]
 // @Security BearerAuth
@@ -606,6 +614,10 @@ func (h *DocumentHandler) RemoveGrantFromDocument(w http.ResponseWriter, r *http
 	err := h.DocumentService.RemoveGrant(r.Context(), docUUID, claims.UserUUID, req.TargetUserUUID)
 	if err != nil {
 		log.Println(err)
+		if strings.Contains(err.Error(), "документ заблокирован") {
+			util.HandleError(w, "Документ заблокирован другим пользователем", http.StatusLocked)
+			return
+		}
 		switch err.Error() {
 		case "[DocumentService] доступ запрещён: документ не принадлежит владельцу":
 			util.HandleError(w, "Доступ запрещен", http.StatusForbidden)
@@ -638,6 +650,8 @@ func (h *DocumentHandler) RemoveGrantFromDocument(w http.ResponseWriter, r *http
 // @Failure 400 {object} requestresponse.ErrorResponse
 // @Failure 403 {object} requestresponse.ErrorResponse
 // @Failure 404 {object} requestresponse.ErrorResponse
+// @Failure 409 {object} requestresponse.ErrorResponse "Документ на юридическом удержании"
+// @Failure 423 {object} requestresponse.ErrorResponse "Документ заблокирован другим пользователем"
 // @Failure 500 {object} requestresponse.ErrorResponse
 // @Router /api/docs/{doc_id} [delete]
 // @Security BearerAuth
@@ -662,6 +676,10 @@ func (h *DocumentHandler) DeleteDocument(w http.ResponseWriter, r *http.Request)
 			util.HandleError(w, "Документ не найден", http.StatusNotFound)
 		case strings.Contains(err.Error(), "только владелец"):
 			util.HandleError(w, "Недостаточно прав для удаления", http.StatusForbidden)
+		case strings.Contains(err.Error(), "документ заблокирован"):
+			util.HandleError(w, "Документ заблокирован другим пользователем", http.StatusLocked)
+		case strings.Contains(err.Error(), "юридическом удержании"):
+			util.HandleError(w, "Документ находится на юридическом удержании", http.StatusConflict)
 		case strings.Contains(err.Error(), "S3"):
 			log.Println(err)
 			util.HandleError(w, "Ошибка при удалении файла", http.StatusInternalServerError)
@@ -679,6 +697,123 @@ func (h *DocumentHandler) DeleteDocument(w http.ResponseWriter, r *http.Request)
 	json.NewEncoder(w).Encode(response)
 }
 
+// CopyDocument godoc
+// @Summary Копирование документа
+// @Description Создаёт копию документа для текущего пользователя. Файл копируется на стороне S3 в префикс пользователя.
+// Копировать можно свои документы, документы, выданные через grant, и публичные документы.
+// @Tags Documents
+// @Accept json
+// @Produce json
+// @Param doc_id path string true "UUID документа"
+// @Param body body requestresponse.CopyDocumentRequest false "Новое имя и публичность копии"
+// @Param Authorization header string true "Bearer токен" default(Bearer <access_token>)
+// @Success 201 {object} requestresponse.GetDocumentResponse "Копия документа"
+// @Failure 400 {object} requestresponse.ErrorResponse "Некорректный запрос"
+// @Failure 401 {object} requestresponse.ErrorResponse "Пользователь не авторизован"
+// @Failure 403 {object} requestresponse.ErrorResponse "Доступ запрещен"
+// @Failure 404 {object} requestresponse.ErrorResponse "Документ не найден"
+// @Failure 413 {object} requestresponse.ErrorResponse "Превышена квота хранилища"
+// @Failure 500 {object} requestresponse.ErrorResponse "Внутренняя ошибка сервера"
+// @Router /api/docs/{doc_id}/copy [post]
+// @Security BearerAuth
+func (h *DocumentHandler) CopyDocument(w http.ResponseWriter, r *http.Request) {
+	docUUID := chi.URLParam(r, "doc_id")
+	if docUUID == "" {
+		util.HandleError(w, "ID документа обязателен", http.StatusBadRequest)
+		return
+	}
+
+	var req requestresponse.CopyDocumentRequest
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil && errors.Is(err, io.EOF) == false {
+			util.HandleError(w, "Неверный формат запроса", http.StatusBadRequest)
+			return
+		}
+	}
+
+	document, err := h.DocumentService.CopyDocument(r.Context(), docUUID, req.Name, req.Public)
+	if err != nil {
+		log.Println(err)
+		switch {
+		case strings.Contains(err.Error(), "не авторизован"):
+			util.HandleError(w, "Пользователь не авторизован", http.StatusUnauthorized)
+		case strings.Contains(err.Error(), "документ не найден"):
+			util.HandleError(w, "Документ не найден", http.StatusNotFound)
+		case strings.Contains(err.Error(), "доступ запрещён"):
+			util.HandleError(w, "Доступ запрещен", http.StatusForbidden)
+		case strings.Contains(err.Error(), "превышена квота хранилища"):
+			util.HandleError(w, "превышена квота хранилища", http.StatusRequestEntityTooLarge)
+		default:
+			util.HandleError(w, "внутренняя ошибка сервера", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	resp := requestresponse.GetDocumentResponse{
+		Data: requestresponse.GetDocumentData{
+			Document: requestresponse.DocumentResponseFromModel(document, ""),
+		},
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(resp)
+}
+
+// DownloadArchive godoc
+// @Summary Скачивание нескольких документов ZIP-архивом
+// @Description Формирует ZIP-архив на лету из файлов в S3. Документы передаются списком UUID, либо, если список пуст, выбираются среди своих по фильтру key/value.
+// Каждый документ проверяется так же, как при получении по ID. Одинаковые имена файлов получают суффикс " (N)".
+// @Tags Documents
+// @Accept json
+// @Produce application/zip
+// @Param body body requestresponse.ArchiveDocumentsRequest true "Список документов или фильтр"
+// @Param Authorization header string true "Bearer токен" default(Bearer <access_token>)
+// @Success 200 {file} file "ZIP-архив"
+// @Failure 400 {object} requestresponse.ErrorResponse "Некорректный запрос"
+// @Failure 401 {object} requestresponse.ErrorResponse "Пользователь не авторизован"
+// @Failure 403 {object} requestresponse.ErrorResponse "Доступ запрещен"
+// @Failure 404 {object} requestresponse.ErrorResponse "Документ не найден"
+// @Failure 500 {object} requestresponse.ErrorResponse "Внутренняя ошибка сервера"
+// @Router /api/docs/archive [post]
+// @Security BearerAuth
+func (h *DocumentHandler) DownloadArchive(w http.ResponseWriter, r *http.Request) {
+	var req requestresponse.ArchiveDocumentsRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		util.HandleError(w, "Неверный формат запроса", http.StatusBadRequest)
+		return
+	}
+
+	documents, err := h.DocumentService.ResolveArchiveDocuments(r.Context(), req.Documents, req.Key, req.Value)
+	if err != nil {
+		log.Println(err)
+		switch {
+		case strings.Contains(err.Error(), "не авторизован"):
+			util.HandleError(w, "Пользователь не авторизован", http.StatusUnauthorized)
+		case strings.Contains(err.Error(), "не выбрано ни одного документа"),
+			strings.Contains(err.Error(), "слишком много документов"):
+			util.HandleError(w, err.Error(), http.StatusBadRequest)
+		case strings.Contains(err.Error(), "документ не найден"):
+			util.HandleError(w, "Документ не найден", http.StatusNotFound)
+		case strings.Contains(err.Error(), "доступ запрещён"):
+			util.HandleError(w, "Доступ запрещен", http.StatusForbidden)
+		default:
+			util.HandleError(w, "внутренняя ошибка сервера", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	archiveName := fmt.Sprintf("documents-%s.zip", time.Now().UTC().Format("20060102-150405"))
+	w.Header().Set("Content-Type", "application/zip")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", archiveName))
+	w.WriteHeader(http.StatusOK)
+
+	// заголовки уже отправлены, поэтому ошибку посреди архива можно только залогировать
+	if err := h.DocumentService.WriteArchive(r.Context(), documents, w); err != nil {
+		log.Printf("[DocumentHandler] архив %s сформирован не полностью: %v", archiveName, err)
+	}
+}
+
 // ListDocuments godoc
 // @Summary Список документов
 // @Description Возвращает список документов с фильтрацией и пагинацией. Если параметр `login` пустой — возвращаются свои документы.
@@ -775,3 +910,12 @@ func (h *DocumentHandler) ListDocuments(w http.ResponseWriter, r *http.Request)
 func (h *DocumentHandler) ListDocumentsHead(w http.ResponseWriter, r *http.Request) {
 	h.ListDocuments(w, r)
 }
+
+// urlExpiresIn : сколько секунд ещё действительна ссылка на файл — она может быть взята из кэша
+// и выдана раньше; без ссылки остаётся TTL из конфигурации
+func (h *DocumentHandler) urlExpiresIn(result *model.GetDocumentResult) string {
+	if result.URLExpiresAt.IsZero() {
+		return strconv.Itoa(h.cfg.S3AndRedis)
+	}
+	return strconv.Itoa(int(time.Until(result.URLExpiresAt).Seconds()))
+}
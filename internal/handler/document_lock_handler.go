@@ -0,0 +1,160 @@
+package handler
+
+import (
+	"caching-web-server/internal/model/requestresponse"
+	"caching-web-server/internal/util"
+	"encoding/json"
+	"errors"
+	"github.com/go-chi/chi/v5"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// LockDocument godoc
+// @Summary Блокировка документа
+// @Description Ставит рекомендательную блокировку на время редактирования. Пока блокировка действует, изменять документ и его метаданные может только её держатель.
+// Повторный вызов держателем продлевает блокировку. Доступно владельцу и пользователям с grant.
+// @Tags Locks
+// @Accept json
+// @Produce json
+// @Param doc_id path string true "UUID документа"
+// @Param body body requestresponse.LockDocumentRequest false "Причина и срок блокировки (по умолчанию 15 минут, максимум 8 часов)"
+// @Param Authorization header string true "Bearer токен" default(Bearer <access_token>)
+// @Success 200 {object} requestresponse.DocumentLockResponse
+// @Failure 400 {object} requestresponse.ErrorResponse
+// @Failure 401 {object} requestresponse.ErrorResponse
+// @Failure 403 {object} requestresponse.ErrorResponse
+// @Failure 404 {object} requestresponse.ErrorResponse
+// @Failure 423 {object} requestresponse.DocumentLockResponse "Документ заблокирован другим пользователем"
+// @Failure 500 {object} requestresponse.ErrorResponse
+// @Router /api/docs/{doc_id}/lock [post]
+// @Security BearerAuth
+func (h *DocumentHandler) LockDocument(w http.ResponseWriter, r *http.Request) {
+	docUUID := chi.URLParam(r, "doc_id")
+
+	var req requestresponse.LockDocumentRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && errors.Is(err, io.EOF) == false {
+		util.HandleError(w, "Неверный формат запроса", http.StatusBadRequest)
+		return
+	}
+
+	lock, err := h.DocumentService.LockDocument(r.Context(), docUUID, req.Reason, time.Duration(req.TTLSeconds)*time.Second)
+	if err != nil {
+		log.Println(err)
+		if strings.Contains(err.Error(), "документ заблокирован") && lock != nil {
+			resp := requestresponse.DocumentLockResponse{}
+			resp.Data.Locked = true
+			resp.Data.Lock = lock
+
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusLocked)
+			json.NewEncoder(w).Encode(resp)
+			return
+		}
+		handleLockError(w, err)
+		return
+	}
+
+	resp := requestresponse.DocumentLockResponse{}
+	resp.Data.Locked = true
+	resp.Data.Lock = lock
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(resp)
+}
+
+// UnlockDocument godoc
+// @Summary Снятие блокировки документа
+// @Description Снимает блокировку, поставленную текущим пользователем.
+// @Tags Locks
+// @Param doc_id path string true "UUID документа"
+// @Param Authorization header string true "Bearer токен" default(Bearer <access_token>)
+// @Success 204 "Блокировка снята"
+// @Failure 401 {object} requestresponse.ErrorResponse
+// @Failure 404 {object} requestresponse.ErrorResponse "Блокировка не найдена или принадлежит другому пользователю"
+// @Failure 500 {object} requestresponse.ErrorResponse
+// @Router /api/docs/{doc_id}/lock [delete]
+// @Security BearerAuth
+func (h *DocumentHandler) UnlockDocument(w http.ResponseWriter, r *http.Request) {
+	if err := h.DocumentService.UnlockDocument(r.Context(), chi.URLParam(r, "doc_id")); err != nil {
+		log.Println(err)
+		handleLockError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// ForceUnlockDocument godoc
+// @Summary Принудительное снятие блокировки
+// @Description Снимает блокировку любого пользователя. Требует права documents:write, как и остальные изменения блокировок; доступно владельцу документа и с правом locks:manage.
+// @Tags Locks
+// @Param doc_id path string true "UUID документа"
+// @Param Authorization header string true "Bearer токен" default(Bearer <access_token>)
+// @Success 204 "Блокировка снята"
+// @Failure 401 {object} requestresponse.ErrorResponse
+// @Failure 403 {object} requestresponse.ErrorResponse
+// @Failure 500 {object} requestresponse.ErrorResponse
+// @Router /api/docs/{doc_id}/lock/force [delete]
+// @Security BearerAuth
+func (h *DocumentHandler) ForceUnlockDocument(w http.ResponseWriter, r *http.Request) {
+	if err := h.DocumentService.ForceUnlockDocument(r.Context(), chi.URLParam(r, "doc_id")); err != nil {
+		log.Println(err)
+		handleLockError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// GetDocumentLock godoc
+// @Summary Состояние блокировки документа
+// @Description Возвращает текущую блокировку документа (держатель, причина, срок) или locked=false.
+// @Tags Locks
+// @Produce json
+// @Param doc_id path string true "UUID документа"
+// @Param Authorization header string true "Bearer токен" default(Bearer <access_token>)
+// @Success 200 {object} requestresponse.DocumentLockResponse
+// @Failure 401 {object} requestresponse.ErrorResponse
+// @Failure 403 {object} requestresponse.ErrorResponse
+// @Failure 404 {object} requestresponse.ErrorResponse
+// @Failure 500 {object} requestresponse.ErrorResponse
+// @Router /api/docs/{doc_id}/lock [get]
+// @Security BearerAuth
+func (h *DocumentHandler) GetDocumentLock(w http.ResponseWriter, r *http.Request) {
+	lock, err := h.DocumentService.GetDocumentLock(r.Context(), chi.URLParam(r, "doc_id"))
+	if err != nil {
+		log.Println(err)
+		handleLockError(w, err)
+		return
+	}
+
+	resp := requestresponse.DocumentLockResponse{}
+	resp.Data.Locked = lock != nil
+	resp.Data.Lock = lock
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(resp)
+}
+
+func handleLockError(w http.ResponseWriter, err error) {
+	switch {
+	case strings.Contains(err.Error(), "не авторизован"):
+		util.HandleError(w, "Пользователь не авторизован", http.StatusUnauthorized)
+	case strings.Contains(err.Error(), "документ заблокирован"):
+		util.HandleError(w, "Документ заблокирован другим пользователем", http.StatusLocked)
+	case strings.Contains(err.Error(), "доступ запрещён"):
+		util.HandleError(w, "Доступ запрещен", http.StatusForbidden)
+	case strings.Contains(err.Error(), "блокировка не найдена"):
+		util.HandleError(w, "Блокировка не найдена или принадлежит другому пользователю", http.StatusNotFound)
+	case strings.Contains(err.Error(), "документ не найден"):
+		util.HandleError(w, "Документ не найден", http.StatusNotFound)
+	default:
+		util.HandleError(w, "внутренняя ошибка сервера", http.StatusInternalServerError)
+	}
+}
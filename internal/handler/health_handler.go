@@ -0,0 +1,50 @@
+package handler
+
+import (
+	"caching-web-server/config"
+	"caching-web-server/internal/model"
+	"caching-web-server/internal/model/requestresponse"
+	"caching-web-server/internal/ports"
+	"context"
+	"encoding/json"
+	"net/http"
+	"time"
+)
+
+type HealthHandler struct {
+	db            *config.Database
+	cache         ports.CacheHealthReporter
+	tokenDenylist ports.TokenDenylistHealthReporter
+}
+
+func NewHealthHandler(db *config.Database, cache ports.CacheHealthReporter, tokenDenylist ports.TokenDenylistHealthReporter) *HealthHandler {
+	return &HealthHandler{db, cache, tokenDenylist}
+}
+
+// GetHealth godoc
+// @Summary Состояние сервиса
+// @Description Проверяет БД и сообщает состояние кэша и проверки отзыва access-токенов. Недоступный Redis не делает сервис неработоспособным — статус degraded и код 200 (token_denylist.state = unchecked, если токены при этом принимаются без проверки отзыва); недоступная БД — unavailable и 503.
+// @Tags Health
+// @Produce json
+// @Success 200 {object} requestresponse.HealthResponse
+// @Failure 503 {object} requestresponse.HealthResponse
+// @Router /health [get]
+func (h *HealthHandler) GetHealth(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 2*time.Second)
+	defer cancel()
+
+	resp := requestresponse.HealthResponse{Status: "ok", Database: "up", Cache: h.cache.Health(), TokenDenylist: h.tokenDenylist.Health()}
+	statusCode := http.StatusOK
+
+	if err := h.db.PingContext(ctx); err != nil {
+		resp.Status = "unavailable"
+		resp.Database = "down"
+		statusCode = http.StatusServiceUnavailable
+	} else if resp.Cache.State != model.CacheStateClosed || resp.TokenDenylist.State != model.TokenDenylistStateOK {
+		resp.Status = "degraded"
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(statusCode)
+	json.NewEncoder(w).Encode(resp)
+}
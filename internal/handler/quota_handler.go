@@ -0,0 +1,133 @@
+package handler
+
+import (
+	"caching-web-server/internal/model"
+	"caching-web-server/internal/model/requestresponse"
+	"caching-web-server/internal/ports"
+	"encoding/json"
+	"github.com/go-chi/chi/v5"
+	"log"
+	"net/http"
+	"strings"
+)
+
+type QuotaHandler struct {
+	ports.QuotaService
+}
+
+func NewQuotaHandler(quotaService ports.QuotaService) *QuotaHandler {
+	return &QuotaHandler{quotaService}
+}
+
+// GetUsage godoc
+// @Summary Использование хранилища пользователем
+// @Description Возвращает занятое место, количество документов (в т.ч. по MIME-типам) и квоту. Доступно самому пользователю и с правом users:read.
+// @Tags Users
+// @Produce json
+// @Param uuid path string true "UUID пользователя"
+// @Param Authorization header string true "Bearer токен" default(Bearer <access_token>)
+// @Success 200 {object} requestresponse.UsageResponse
+// @Failure 401 {object} requestresponse.ErrorResponse
+// @Failure 403 {object} requestresponse.ErrorResponse
+// @Failure 500 {object} requestresponse.ErrorResponse
+// @Router /api/users/{uuid}/usage [get]
+func (h *QuotaHandler) GetUsage(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	targetUUID := chi.URLParam(r, "uuid")
+	if restrictToOwner(w, r, targetUUID, model.PermissionUsersRead) == false {
+		return
+	}
+
+	usage, err := h.QuotaService.GetUsage(r.Context(), targetUUID)
+	if err != nil {
+		log.Println(err)
+		switch {
+		case strings.Contains(err.Error(), "не авторизован"):
+			sendErrorResponse(w, 401, "пользователь не авторизован")
+		case strings.Contains(err.Error(), "доступ запрещён"):
+			sendErrorResponse(w, 403, "доступ запрещён")
+		default:
+			sendErrorResponse(w, 500, "внутренняя ошибка сервера")
+		}
+		return
+	}
+
+	resp := requestresponse.UsageResponse{Data: *usage}
+
+	w.WriteHeader(200)
+	json.NewEncoder(w).Encode(resp)
+}
+
+// SetQuota godoc
+// @Summary Установка квоты пользователя
+// @Description Переопределяет квоту хранилища по умолчанию для пользователя (0 — без ограничений). Требуется право users:manage.
+// @Tags Users
+// @Accept json
+// @Produce json
+// @Param uuid path string true "UUID пользователя"
+// @Param body body requestresponse.SetQuotaRequest true "Тело запроса"
+// @Param Authorization header string true "Bearer токен" default(Bearer <access_token>)
+// @Success 200 {object} requestresponse.SetQuotaResponse
+// @Failure 400 {object} requestresponse.ErrorResponse
+// @Failure 401 {object} requestresponse.ErrorResponse
+// @Failure 403 {object} requestresponse.ErrorResponse
+// @Failure 500 {object} requestresponse.ErrorResponse
+// @Router /api/users/{uuid}/quota [put]
+func (h *QuotaHandler) SetQuota(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	targetUUID := chi.URLParam(r, "uuid")
+
+	var req requestresponse.SetQuotaRequest
+	if err := decodeJSON(w, r, &req); err != nil {
+		return
+	}
+
+	if err := h.QuotaService.SetQuota(r.Context(), targetUUID, &req.QuotaBytes); err != nil {
+		handleQuotaError(w, err)
+		return
+	}
+
+	resp := requestresponse.SetQuotaResponse{}
+	resp.Response.QuotaBytes = req.QuotaBytes
+
+	w.WriteHeader(200)
+	json.NewEncoder(w).Encode(resp)
+}
+
+// ResetQuota godoc
+// @Summary Сброс квоты пользователя
+// @Description Удаляет индивидуальную квоту, после чего действует квота по умолчанию из конфига. Требуется право users:manage.
+// @Tags Users
+// @Param uuid path string true "UUID пользователя"
+// @Param Authorization header string true "Bearer токен" default(Bearer <access_token>)
+// @Success 204 "Квота сброшена"
+// @Failure 401 {object} requestresponse.ErrorResponse
+// @Failure 403 {object} requestresponse.ErrorResponse
+// @Failure 500 {object} requestresponse.ErrorResponse
+// @Router /api/users/{uuid}/quota [delete]
+func (h *QuotaHandler) ResetQuota(w http.ResponseWriter, r *http.Request) {
+	targetUUID := chi.URLParam(r, "uuid")
+
+	if err := h.QuotaService.SetQuota(r.Context(), targetUUID, nil); err != nil {
+		handleQuotaError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func handleQuotaError(w http.ResponseWriter, err error) {
+	log.Println(err)
+	switch {
+	case strings.Contains(err.Error(), "не авторизован"):
+		sendErrorResponse(w, 401, "пользователь не авторизован")
+	case strings.Contains(err.Error(), "доступ запрещён"):
+		sendErrorResponse(w, 403, "доступ запрещён")
+	case strings.Contains(err.Error(), "не может быть отрицательной"):
+		sendErrorResponse(w, 400, "квота не может быть отрицательной")
+	default:
+		sendErrorResponse(w, 500, "внутренняя ошибка сервера")
+	}
+}
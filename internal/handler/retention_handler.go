@@ -0,0 +1,191 @@
+package handler
+
+import (
+	"caching-web-server/internal/model/requestresponse"
+	"caching-web-server/internal/ports"
+	"caching-web-server/internal/util"
+	"encoding/json"
+	"github.com/go-chi/chi/v5"
+	"log"
+	"net/http"
+	"strings"
+)
+
+type RetentionHandler struct {
+	ports.RetentionService
+}
+
+func NewRetentionHandler(retentionService ports.RetentionService) *RetentionHandler {
+	return &RetentionHandler{retentionService}
+}
+
+// SetDocumentExpiry godoc
+// @Summary Срок хранения документа
+// @Description Задаёт дату, после которой документ будет удалён автоматически. null — хранить бессрочно.
+// Если для MIME-типа документа есть политика хранения, срок нельзя снять или сделать позже, чем требует политика. Доступно только владельцу.
+// @Tags Retention
+// @Accept json
+// @Produce json
+// @Param doc_id path string true "UUID документа"
+// @Param body body requestresponse.SetExpiryRequest true "Срок хранения"
+// @Param Authorization header string true "Bearer токен" default(Bearer <access_token>)
+// @Success 204 "Срок хранения изменён"
+// @Failure 400 {object} requestresponse.ErrorResponse
+// @Failure 401 {object} requestresponse.ErrorResponse
+// @Failure 404 {object} requestresponse.ErrorResponse
+// @Failure 423 {object} requestresponse.ErrorResponse
+// @Failure 500 {object} requestresponse.ErrorResponse
+// @Router /api/docs/{doc_id}/expiry [put]
+// @Security BearerAuth
+func (h *RetentionHandler) SetDocumentExpiry(w http.ResponseWriter, r *http.Request) {
+	docUUID := chi.URLParam(r, "doc_id")
+
+	var req requestresponse.SetExpiryRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		util.HandleError(w, "Неверный формат запроса", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.RetentionService.SetDocumentExpiry(r.Context(), docUUID, req.ExpiresAt); err != nil {
+		handleRetentionError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// SetLegalHold godoc
+// @Summary Юридическое удержание документа
+// @Description Ставит или снимает legal hold. Документ на удержании не удаляется ни владельцем, ни по сроку хранения. Требуется право retention:manage.
+// @Tags Retention
+// @Accept json
+// @Produce json
+// @Param doc_id path string true "UUID документа"
+// @Param body body requestresponse.SetLegalHoldRequest true "Удержание"
+// @Param Authorization header string true "Bearer токен" default(Bearer <access_token>)
+// @Success 204 "Удержание изменено"
+// @Failure 400 {object} requestresponse.ErrorResponse
+// @Failure 401 {object} requestresponse.ErrorResponse
+// @Failure 403 {object} requestresponse.ErrorResponse
+// @Failure 404 {object} requestresponse.ErrorResponse
+// @Failure 500 {object} requestresponse.ErrorResponse
+// @Router /api/docs/{doc_id}/legal-hold [put]
+// @Security BearerAuth
+func (h *RetentionHandler) SetLegalHold(w http.ResponseWriter, r *http.Request) {
+	docUUID := chi.URLParam(r, "doc_id")
+
+	var req requestresponse.SetLegalHoldRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		util.HandleError(w, "Неверный формат запроса", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.RetentionService.SetLegalHold(r.Context(), docUUID, req.LegalHold); err != nil {
+		handleRetentionError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// ListPolicies godoc
+// @Summary Список политик хранения
+// @Description Возвращает политики хранения документов по MIME-типам. Требуется право retention:read.
+// @Tags Retention
+// @Produce json
+// @Param Authorization header string true "Bearer токен" default(Bearer <access_token>)
+// @Success 200 {object} requestresponse.ListRetentionPoliciesResponse
+// @Failure 401 {object} requestresponse.ErrorResponse
+// @Failure 403 {object} requestresponse.ErrorResponse
+// @Failure 500 {object} requestresponse.ErrorResponse
+// @Router /api/admin/retention-policies [get]
+// @Security BearerAuth
+func (h *RetentionHandler) ListPolicies(w http.ResponseWriter, r *http.Request) {
+	policies, err := h.RetentionService.ListPolicies(r.Context())
+	if err != nil {
+		handleRetentionError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(requestresponse.ListRetentionPoliciesResponse{Data: policies})
+}
+
+// SavePolicy godoc
+// @Summary Создание политики хранения
+// @Description Задаёт срок хранения для MIME-типа ("application/pdf" или шаблон "image/*"). Новые документы получают expires_at при загрузке,
+// apply_existing выставляет срок уже загруженным документам без срока. Политики задаются только по MIME-типу: тегов у документов нет,
+// поэтому выбрать документы по тегу нельзя. Требуется право retention:manage.
+// @Tags Retention
+// @Accept json
+// @Produce json
+// @Param body body requestresponse.RetentionPolicyRequest true "Политика"
+// @Param Authorization header string true "Bearer токен" default(Bearer <access_token>)
+// @Success 201 {object} requestresponse.RetentionPolicyResponse
+// @Failure 400 {object} requestresponse.ErrorResponse
+// @Failure 401 {object} requestresponse.ErrorResponse
+// @Failure 403 {object} requestresponse.ErrorResponse
+// @Failure 500 {object} requestresponse.ErrorResponse
+// @Router /api/admin/retention-policies [post]
+// @Security BearerAuth
+func (h *RetentionHandler) SavePolicy(w http.ResponseWriter, r *http.Request) {
+	var req requestresponse.RetentionPolicyRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		util.HandleError(w, "Неверный формат запроса", http.StatusBadRequest)
+		return
+	}
+
+	policy, err := h.RetentionService.SavePolicy(r.Context(), req.MimeType, req.RetentionDays, req.ApplyExisting)
+	if err != nil {
+		handleRetentionError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(requestresponse.RetentionPolicyResponse{Data: *policy})
+}
+
+// DeletePolicy godoc
+// @Summary Удаление политики хранения
+// @Description Удаляет политику. Уже выставленные документам сроки хранения не меняются. Требуется право retention:manage.
+// @Tags Retention
+// @Param policy_id path string true "UUID политики"
+// @Param Authorization header string true "Bearer токен" default(Bearer <access_token>)
+// @Success 204 "Политика удалена"
+// @Failure 401 {object} requestresponse.ErrorResponse
+// @Failure 403 {object} requestresponse.ErrorResponse
+// @Failure 404 {object} requestresponse.ErrorResponse
+// @Failure 500 {object} requestresponse.ErrorResponse
+// @Router /api/admin/retention-policies/{policy_id} [delete]
+// @Security BearerAuth
+func (h *RetentionHandler) DeletePolicy(w http.ResponseWriter, r *http.Request) {
+	if err := h.RetentionService.DeletePolicy(r.Context(), chi.URLParam(r, "policy_id")); err != nil {
+		handleRetentionError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func handleRetentionError(w http.ResponseWriter, err error) {
+	log.Println(err)
+	switch {
+	case strings.Contains(err.Error(), "не авторизован"):
+		util.HandleError(w, "Пользователь не авторизован", http.StatusUnauthorized)
+	case strings.Contains(err.Error(), "доступ запрещён"):
+		util.HandleError(w, "Доступ запрещен", http.StatusForbidden)
+	case strings.Contains(err.Error(), "документ заблокирован"):
+		util.HandleError(w, "Документ заблокирован другим пользователем", http.StatusLocked)
+	case strings.Contains(err.Error(), "не найден"):
+		util.HandleError(w, "Не найдено", http.StatusNotFound)
+	case strings.Contains(err.Error(), "некорректный MIME-тип"),
+		strings.Contains(err.Error(), "срок хранения должен"):
+		util.HandleError(w, "Некорректная политика хранения", http.StatusBadRequest)
+	case strings.Contains(err.Error(), "ограничен политикой"):
+		util.HandleError(w, "Срок хранения превышает политику хранения", http.StatusBadRequest)
+	default:
+		util.HandleError(w, "внутренняя ошибка сервера", http.StatusInternalServerError)
+	}
+}
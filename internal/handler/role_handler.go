@@ -0,0 +1,156 @@
+package handler
+
+import (
+	"caching-web-server/internal/model"
+	"caching-web-server/internal/model/requestresponse"
+	"caching-web-server/internal/ports"
+	"caching-web-server/internal/util"
+	"encoding/json"
+	"github.com/go-chi/chi/v5"
+	"log"
+	"net/http"
+	"strings"
+)
+
+type RoleHandler struct {
+	ports.RoleService
+}
+
+func NewRoleHandler(roleService ports.RoleService) *RoleHandler {
+	return &RoleHandler{roleService}
+}
+
+// ListRoles godoc
+// @Summary Список ролей
+// @Description Возвращает встроенные (admin, user, auditor) и пользовательские роли с правами. Требуется право roles:manage.
+// @Tags Roles
+// @Produce json
+// @Param Authorization header string true "Bearer токен" default(Bearer <access_token>)
+// @Success 200 {object} requestresponse.ListRolesResponse
+// @Failure 401 {object} requestresponse.ErrorResponse
+// @Failure 403 {object} requestresponse.ErrorResponse
+// @Failure 500 {object} requestresponse.ErrorResponse
+// @Router /api/admin/roles [get]
+// @Security BearerAuth
+func (h *RoleHandler) ListRoles(w http.ResponseWriter, r *http.Request) {
+	roles, err := h.RoleService.ListRoles(r.Context())
+	if err != nil {
+		handleRoleError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(requestresponse.ListRolesResponse{Data: roles})
+}
+
+// SaveRole godoc
+// @Summary Создание или изменение роли
+// @Description Задаёт права пользовательской роли. Встроенные роли не изменяются. Права действуют сразу, без перевыпуска токенов.
+// Требуется право roles:manage.
+// @Tags Roles
+// @Accept json
+// @Produce json
+// @Param name path string true "Имя роли"
+// @Param body body requestresponse.RoleRequest true "Права роли"
+// @Param Authorization header string true "Bearer токен" default(Bearer <access_token>)
+// @Success 200 {object} requestresponse.RoleResponse
+// @Failure 400 {object} requestresponse.ErrorResponse
+// @Failure 401 {object} requestresponse.ErrorResponse
+// @Failure 403 {object} requestresponse.ErrorResponse
+// @Failure 500 {object} requestresponse.ErrorResponse
+// @Router /api/admin/roles/{name} [put]
+// @Security BearerAuth
+func (h *RoleHandler) SaveRole(w http.ResponseWriter, r *http.Request) {
+	var req requestresponse.RoleRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		util.HandleError(w, "Неверный формат запроса", http.StatusBadRequest)
+		return
+	}
+
+	role := &model.Role{Name: chi.URLParam(r, "name"), Permissions: req.Permissions}
+	if err := h.RoleService.SaveRole(r.Context(), role); err != nil {
+		handleRoleError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(requestresponse.RoleResponse{Data: *role})
+}
+
+// DeleteRole godoc
+// @Summary Удаление роли
+// @Description Удаляет пользовательскую роль и снимает её со всех пользователей. Требуется право roles:manage.
+// @Tags Roles
+// @Param name path string true "Имя роли"
+// @Param Authorization header string true "Bearer токен" default(Bearer <access_token>)
+// @Success 204 "Роль удалена"
+// @Failure 400 {object} requestresponse.ErrorResponse
+// @Failure 401 {object} requestresponse.ErrorResponse
+// @Failure 403 {object} requestresponse.ErrorResponse
+// @Failure 404 {object} requestresponse.ErrorResponse
+// @Failure 500 {object} requestresponse.ErrorResponse
+// @Router /api/admin/roles/{name} [delete]
+// @Security BearerAuth
+func (h *RoleHandler) DeleteRole(w http.ResponseWriter, r *http.Request) {
+	if err := h.RoleService.DeleteRole(r.Context(), chi.URLParam(r, "name")); err != nil {
+		handleRoleError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// SetUserRoles godoc
+// @Summary Назначение ролей пользователю
+// @Description Заменяет роли пользователя. В токенах пользователя новые роли появятся при следующем обновлении токенов.
+// Требуется право roles:manage.
+// @Tags Roles
+// @Accept json
+// @Param uuid path string true "UUID пользователя"
+// @Param body body requestresponse.SetUserRolesRequest true "Роли"
+// @Param Authorization header string true "Bearer токен" default(Bearer <access_token>)
+// @Success 204 "Роли назначены"
+// @Failure 400 {object} requestresponse.ErrorResponse
+// @Failure 401 {object} requestresponse.ErrorResponse
+// @Failure 403 {object} requestresponse.ErrorResponse
+// @Failure 404 {object} requestresponse.ErrorResponse
+// @Failure 500 {object} requestresponse.ErrorResponse
+// @Router /api/admin/users/{uuid}/roles [put]
+// @Security BearerAuth
+func (h *RoleHandler) SetUserRoles(w http.ResponseWriter, r *http.Request) {
+	var req requestresponse.SetUserRolesRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		util.HandleError(w, "Неверный формат запроса", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.RoleService.SetUserRoles(r.Context(), chi.URLParam(r, "uuid"), req.Roles); err != nil {
+		handleRoleError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func handleRoleError(w http.ResponseWriter, err error) {
+	log.Println(err)
+	switch {
+	case strings.Contains(err.Error(), "не авторизован"):
+		util.HandleError(w, "Пользователь не авторизован", http.StatusUnauthorized)
+	case strings.Contains(err.Error(), "доступ запрещён"):
+		util.HandleError(w, "Доступ запрещен", http.StatusForbidden)
+	case strings.Contains(err.Error(), "пользователь не найден"),
+		strings.Contains(err.Error(), "роль не найдена или встроенная"):
+		util.HandleError(w, "Не найдено", http.StatusNotFound)
+	case strings.Contains(err.Error(), "роль не найдена"),
+		strings.Contains(err.Error(), "некорректное имя роли"),
+		strings.Contains(err.Error(), "неизвестное право"),
+		strings.Contains(err.Error(), "встроенная роль"),
+		strings.Contains(err.Error(), "хотя бы одна роль"):
+		util.HandleError(w, "Некорректная роль", http.StatusBadRequest)
+	default:
+		util.HandleError(w, "внутренняя ошибка сервера", http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,242 @@
+package handler
+
+import (
+	"caching-web-server/internal/model/requestresponse"
+	"caching-web-server/internal/security"
+	"caching-web-server/internal/util"
+	"encoding/json"
+	"github.com/go-chi/chi/v5"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// ListSessions godoc
+// @Summary Активные сессии
+// @Description Возвращает активные сессии текущего пользователя: устройство (User-Agent), IP, время входа и последнего использования.
+// Сессия, которой выполнен запрос, помечена current.
+// @Tags Authentication
+// @Produce json
+// @Param Authorization header string true "Bearer токен" default(Bearer <access_token>)
+// @Success 200 {object} requestresponse.ListSessionsResponse
+// @Failure 401 {object} requestresponse.ErrorResponse
+// @Failure 500 {object} requestresponse.ErrorResponse
+// @Router /api/auth/sessions [get]
+// @Security BearerAuth
+func (h *AuthenticationHandler) ListSessions(w http.ResponseWriter, r *http.Request) {
+	claims, err := security.GetClaimsFromContext(r.Context())
+	if err != nil {
+		handleSessionError(w, err)
+		return
+	}
+	h.listSessions(w, r, claims.UserUUID)
+}
+
+// RevokeSession godoc
+// @Summary Завершение сессии
+// @Description Завершает свою сессию по её UUID: refresh-токен отзывается, access-токены сессии перестают приниматься.
+// @Tags Authentication
+// @Param id path string true "UUID сессии"
+// @Param Authorization header string true "Bearer токен" default(Bearer <access_token>)
+// @Success 204 "Сессия завершена"
+// @Failure 401 {object} requestresponse.ErrorResponse
+// @Failure 404 {object} requestresponse.ErrorResponse
+// @Failure 500 {object} requestresponse.ErrorResponse
+// @Router /api/auth/sessions/{id} [delete]
+// @Security BearerAuth
+func (h *AuthenticationHandler) RevokeSession(w http.ResponseWriter, r *http.Request) {
+	claims, err := security.GetClaimsFromContext(r.Context())
+	if err != nil {
+		handleSessionError(w, err)
+		return
+	}
+	h.revokeSession(w, r, claims.UserUUID)
+}
+
+// RevokeOtherSessions godoc
+// @Summary Выход на остальных устройствах
+// @Description Завершает все сессии текущего пользователя, кроме той, которой выполнен запрос.
+// @Tags Authentication
+// @Produce json
+// @Param Authorization header string true "Bearer токен" default(Bearer <access_token>)
+// @Success 200 {object} requestresponse.RevokeSessionsResponse
+// @Failure 401 {object} requestresponse.ErrorResponse
+// @Failure 500 {object} requestresponse.ErrorResponse
+// @Router /api/auth/sessions [delete]
+// @Security BearerAuth
+func (h *AuthenticationHandler) RevokeOtherSessions(w http.ResponseWriter, r *http.Request) {
+	revoked, err := h.AuthenticationService.RevokeOtherSessions(r.Context())
+	if err != nil {
+		handleSessionError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(requestresponse.RevokeSessionsResponse{Revoked: revoked})
+}
+
+// ListUserSessions godoc
+// @Summary Активные сессии пользователя
+// @Description Возвращает активные сессии любого пользователя. Требуется право users:read.
+// @Tags Authentication
+// @Produce json
+// @Param uuid path string true "UUID пользователя"
+// @Param Authorization header string true "Bearer токен" default(Bearer <access_token>)
+// @Success 200 {object} requestresponse.ListSessionsResponse
+// @Failure 401 {object} requestresponse.ErrorResponse
+// @Failure 403 {object} requestresponse.ErrorResponse
+// @Failure 500 {object} requestresponse.ErrorResponse
+// @Router /api/admin/users/{uuid}/sessions [get]
+// @Security BearerAuth
+func (h *AuthenticationHandler) ListUserSessions(w http.ResponseWriter, r *http.Request) {
+	h.listSessions(w, r, chi.URLParam(r, "uuid"))
+}
+
+// RevokeUserSession godoc
+// @Summary Завершение сессии пользователя
+// @Description Завершает сессию любого пользователя. Требуется право users:manage.
+// @Tags Authentication
+// @Param uuid path string true "UUID пользователя"
+// @Param id path string true "UUID сессии"
+// @Param Authorization header string true "Bearer токен" default(Bearer <access_token>)
+// @Success 204 "Сессия завершена"
+// @Failure 401 {object} requestresponse.ErrorResponse
+// @Failure 403 {object} requestresponse.ErrorResponse
+// @Failure 404 {object} requestresponse.ErrorResponse
+// @Failure 500 {object} requestresponse.ErrorResponse
+// @Router /api/admin/users/{uuid}/sessions/{id} [delete]
+// @Security BearerAuth
+func (h *AuthenticationHandler) RevokeUserSession(w http.ResponseWriter, r *http.Request) {
+	h.revokeSession(w, r, chi.URLParam(r, "uuid"))
+}
+
+// RevokeUserSessions godoc
+// @Summary Завершение всех сессий пользователя
+// @Description Завершает все сессии пользователя, например при компрометации учётной записи. Требуется право users:manage.
+// @Tags Authentication
+// @Produce json
+// @Param uuid path string true "UUID пользователя"
+// @Param Authorization header string true "Bearer токен" default(Bearer <access_token>)
+// @Success 200 {object} requestresponse.RevokeSessionsResponse
+// @Failure 401 {object} requestresponse.ErrorResponse
+// @Failure 403 {object} requestresponse.ErrorResponse
+// @Failure 500 {object} requestresponse.ErrorResponse
+// @Router /api/admin/users/{uuid}/sessions [delete]
+// @Security BearerAuth
+func (h *AuthenticationHandler) RevokeUserSessions(w http.ResponseWriter, r *http.Request) {
+	revoked, err := h.AuthenticationService.RevokeAllSessions(r.Context(), chi.URLParam(r, "uuid"))
+	if err != nil {
+		handleSessionError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(requestresponse.RevokeSessionsResponse{Revoked: revoked})
+}
+
+// ListSecurityEvents godoc
+// @Summary Журнал безопасности
+// @Description Возвращает события безопасности текущего пользователя от новых к старым: повторное использование refresh-токена
+// (сессия при этом отзывается), обновление токенов с нового IP.
+// @Tags Authentication
+// @Produce json
+// @Param cursor query string false "Курсор для пагинации"
+// @Param limit query int false "Количество событий" default(50) minimum(1) maximum(100)
+// @Param Authorization header string true "Bearer токен" default(Bearer <access_token>)
+// @Success 200 {object} requestresponse.ListSecurityEventsResponse
+// @Failure 400 {object} requestresponse.ErrorResponse
+// @Failure 401 {object} requestresponse.ErrorResponse
+// @Failure 500 {object} requestresponse.ErrorResponse
+// @Router /api/auth/security-events [get]
+// @Security BearerAuth
+func (h *AuthenticationHandler) ListSecurityEvents(w http.ResponseWriter, r *http.Request) {
+	claims, err := security.GetClaimsFromContext(r.Context())
+	if err != nil {
+		handleSessionError(w, err)
+		return
+	}
+	h.listSecurityEvents(w, r, claims.UserUUID)
+}
+
+// ListUserSecurityEvents godoc
+// @Summary Журнал безопасности пользователя
+// @Description Возвращает события безопасности любого пользователя от новых к старым. Требуется право users:read.
+// @Tags Authentication
+// @Produce json
+// @Param uuid path string true "UUID пользователя"
+// @Param cursor query string false "Курсор для пагинации"
+// @Param limit query int false "Количество событий" default(50) minimum(1) maximum(100)
+// @Param Authorization header string true "Bearer токен" default(Bearer <access_token>)
+// @Success 200 {object} requestresponse.ListSecurityEventsResponse
+// @Failure 400 {object} requestresponse.ErrorResponse
+// @Failure 401 {object} requestresponse.ErrorResponse
+// @Failure 403 {object} requestresponse.ErrorResponse
+// @Failure 500 {object} requestresponse.ErrorResponse
+// @Router /api/admin/users/{uuid}/security-events [get]
+// @Security BearerAuth
+func (h *AuthenticationHandler) ListUserSecurityEvents(w http.ResponseWriter, r *http.Request) {
+	h.listSecurityEvents(w, r, chi.URLParam(r, "uuid"))
+}
+
+func (h *AuthenticationHandler) listSecurityEvents(w http.ResponseWriter, r *http.Request, userUUID string) {
+	cursor := r.URL.Query().Get("cursor")
+	limit := 50
+	if limitStr := r.URL.Query().Get("limit"); limitStr != "" {
+		if l, err := strconv.Atoi(limitStr); err == nil && l > 0 {
+			limit = min(l, 100)
+		}
+	}
+
+	events, nextCursor, err := h.AuthenticationService.ListSecurityEvents(r.Context(), userUUID, cursor, limit)
+	if err != nil {
+		handleSessionError(w, err)
+		return
+	}
+
+	resp := requestresponse.ListSecurityEventsResponse{}
+	resp.Data.Events = events
+	resp.Data.NextCursor = nextCursor
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(resp)
+}
+
+func (h *AuthenticationHandler) listSessions(w http.ResponseWriter, r *http.Request, userUUID string) {
+	sessions, err := h.AuthenticationService.ListSessions(r.Context(), userUUID)
+	if err != nil {
+		handleSessionError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(requestresponse.ListSessionsResponse{Data: sessions})
+}
+
+func (h *AuthenticationHandler) revokeSession(w http.ResponseWriter, r *http.Request, userUUID string) {
+	if err := h.AuthenticationService.RevokeSession(r.Context(), userUUID, chi.URLParam(r, "id")); err != nil {
+		handleSessionError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func handleSessionError(w http.ResponseWriter, err error) {
+	log.Println(err)
+	switch {
+	case strings.Contains(err.Error(), "не авторизован"):
+		util.HandleError(w, "Пользователь не авторизован", http.StatusUnauthorized)
+	case strings.Contains(err.Error(), "доступ запрещён"):
+		util.HandleError(w, "Доступ запрещен", http.StatusForbidden)
+	case strings.Contains(err.Error(), "сессия не найдена"):
+		util.HandleError(w, "Сессия не найдена", http.StatusNotFound)
+	case strings.Contains(err.Error(), "invalid cursor"):
+		util.HandleError(w, "Некорректный курсор", http.StatusBadRequest)
+	default:
+		util.HandleError(w, "внутренняя ошибка сервера", http.StatusInternalServerError)
+	}
+}
@@ -23,8 +23,9 @@ func NewUserHandler(userService ports.UserService) *UserHandler {
 }
 
 // RegisterUser godoc
-// @Summary Регистрация нового пользователя
-// @Description Создает нового пользователя с логином и паролем. Требуется токен администратора (токен в config.yaml: "super-secret-admin-token").
+// @Summary Создание первого администратора
+// @Description Создает пользователя с ролью admin. Требуется токен администратора из config.yaml (admin.admin_token);
+// работает, только пока в системе нет ни одного администратора. Остальных пользователей создаёт администратор через POST /api/admin/users.
 // @Tags Users
 // @Accept json
 // @Produce json
@@ -32,6 +33,7 @@ func NewUserHandler(userService ports.UserService) *UserHandler {
 // @Success 200 {object} requestresponse.RegisterResponse
 // @Failure 400 {object} requestresponse.ErrorResponse
 // @Failure 401 {object} requestresponse.ErrorResponse
+// @Failure 409 {object} requestresponse.ErrorResponse
 // @Failure 500 {object} requestresponse.ErrorResponse
 // @Router /api/register [post]
 func (h *UserHandler) RegisterUser(w http.ResponseWriter, r *http.Request) {
@@ -47,6 +49,8 @@ func (h *UserHandler) RegisterUser(w http.ResponseWriter, r *http.Request) {
 	if err != nil {
 		log.Println(err)
 		switch {
+		case strings.Contains(err.Error(), "администратор уже создан"):
+			sendErrorResponse(w, 409, "администратор уже создан")
 		case strings.Contains(err.Error(), "неверный токен администратора"),
 			strings.Contains(err.Error(), "логин должен быть не меньше"),
 			strings.Contains(err.Error(), "логин должен содержать"),
@@ -73,6 +77,51 @@ func (h *UserHandler) RegisterUser(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(resp)
 }
 
+// CreateUser godoc
+// @Summary Создание пользователя
+// @Description Создает пользователя с логином, паролем и ролями (по умолчанию user). Требуется право users:manage,
+// роли кроме user может назначить только пользователь с правом roles:manage.
+// @Tags Users
+// @Accept json
+// @Produce json
+// @Param body body requestresponse.CreateUserRequest true "Тело запроса"
+// @Param Authorization header string true "Bearer токен" default(Bearer <access_token>)
+// @Success 201 {object} requestresponse.CreateUserResponse
+// @Failure 400 {object} requestresponse.ErrorResponse
+// @Failure 401 {object} requestresponse.ErrorResponse
+// @Failure 403 {object} requestresponse.ErrorResponse
+// @Failure 500 {object} requestresponse.ErrorResponse
+// @Router /api/admin/users [post]
+// @Security BearerAuth
+func (h *UserHandler) CreateUser(w http.ResponseWriter, r *http.Request) {
+	var req requestresponse.CreateUserRequest
+	if err := decodeJSON(w, r, &req); err != nil {
+		return
+	}
+
+	user, err := h.UserService.CreateUser(r.Context(), req.Login, req.Password, req.Roles)
+	if err != nil {
+		log.Println(err)
+		switch {
+		case strings.Contains(err.Error(), "не авторизован"):
+			sendErrorResponse(w, 401, "пользователь не авторизован")
+		case strings.Contains(err.Error(), "доступ запрещён"):
+			sendErrorResponse(w, 403, "доступ запрещён")
+		case strings.Contains(err.Error(), "логин должен"),
+			strings.Contains(err.Error(), "пароль"),
+			strings.Contains(err.Error(), "роль не найдена"):
+			sendErrorResponse(w, 400, "bad request")
+		default:
+			sendErrorResponse(w, 500, "внутренняя ошибка сервера")
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(requestresponse.CreateUserResponse{Data: user})
+}
+
 // GetUser godoc
 // @Summary Получение информации о пользователе
 // @Description Возвращает данные пользователя. Доступен только самому пользователю.
@@ -90,7 +139,7 @@ func (h *UserHandler) GetUser(w http.ResponseWriter, r *http.Request) {
 
 	if r.Method == http.MethodHead {
 		targetUUID := chi.URLParam(r, "uuid")
-		if restrictToOwner(w, r, targetUUID) == false {
+		if restrictToOwner(w, r, targetUUID, model.PermissionUsersRead) == false {
 			return
 		}
 
@@ -115,7 +164,7 @@ func (h *UserHandler) GetUser(w http.ResponseWriter, r *http.Request) {
 	}
 
 	targetUUID := chi.URLParam(r, "uuid")
-	if restrictToOwner(w, r, targetUUID) == false {
+	if restrictToOwner(w, r, targetUUID, model.PermissionUsersRead) == false {
 		return
 	}
 
@@ -177,7 +226,7 @@ func (h *UserHandler) UpdateUser(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 
 	targetUUID := chi.URLParam(r, "uuid")
-	if restrictToOwner(w, r, targetUUID) == false {
+	if restrictToOwner(w, r, targetUUID, model.PermissionUsersManage) == false {
 		return
 	}
 
@@ -229,7 +278,7 @@ func (h *UserHandler) UpdatePassword(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 
 	targetUUID := chi.URLParam(r, "uuid")
-	if restrictToOwner(w, r, targetUUID) == false {
+	if restrictToOwner(w, r, targetUUID, model.PermissionUsersManage) == false {
 		return
 	}
 
@@ -260,7 +309,7 @@ func (h *UserHandler) UpdatePassword(w http.ResponseWriter, r *http.Request) {
 
 // DeleteUser godoc
 // @Summary Удаление пользователя
-// @Description Удаляет пользователя. Доступен только владельцу или администратору.
+// @Description Удаляет пользователя. Доступен владельцу или с правом users:manage.
 // @Tags Users
 // @Produce json
 // @Param uuid path string true "UUID пользователя"
@@ -273,7 +322,7 @@ func (h *UserHandler) UpdatePassword(w http.ResponseWriter, r *http.Request) {
 // @Security BearerAuth
 func (h *UserHandler) DeleteUser(w http.ResponseWriter, r *http.Request) {
 	targetUUID := chi.URLParam(r, "uuid")
-	if restrictToOwner(w, r, targetUUID) == false {
+	if restrictToOwner(w, r, targetUUID, model.PermissionUsersManage) == false {
 		return
 	}
 
@@ -297,7 +346,7 @@ func (h *UserHandler) DeleteUser(w http.ResponseWriter, r *http.Request) {
 
 // ListUsers godoc
 // @Summary Получение списка пользователей
-// @Description Возвращает список пользователей с постраничной навигацией (cursor-based). Доступно только авторизованным пользователям или администратору.
+// @Description Возвращает список пользователей с постраничной навигацией (cursor-based). Требуется право users:list.
 // @Tags Users
 // @Produce json
 // @Param cursor query string false "Курсор для пагинации"
@@ -313,13 +362,7 @@ func (h *UserHandler) ListUsers(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 
 	if r.Method == http.MethodHead {
-		authHeader := r.Header.Get("Authorization")
-		var adminToken string
-		if strings.HasPrefix(authHeader, "Bearer ") {
-			adminToken = strings.TrimPrefix(authHeader, "Bearer ")
-		}
-
-		_, _, err := h.UserService.ListUsers(r.Context(), adminToken, "", 50)
+		_, _, err := h.UserService.ListUsers(r.Context(), "", 50)
 		if err != nil {
 			fmt.Println(err)
 			switch {
@@ -337,12 +380,6 @@ func (h *UserHandler) ListUsers(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	authHeader := r.Header.Get("Authorization")
-	var adminToken string
-	if strings.HasPrefix(authHeader, "Bearer ") {
-		adminToken = strings.TrimPrefix(authHeader, "Bearer ")
-	}
-
 	cursor := r.URL.Query().Get("cursor")
 	limit := 50
 	if limitStr := r.URL.Query().Get("limit"); limitStr != "" {
@@ -356,7 +393,7 @@ func (h *UserHandler) ListUsers(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Вызываем сервис
-	users, nextCursor, err := h.UserService.ListUsers(r.Context(), adminToken, cursor, limit)
+	users, nextCursor, err := h.UserService.ListUsers(r.Context(), cursor, limit)
 	if err != nil {
 		fmt.Println(err)
 		switch {
@@ -380,7 +417,7 @@ func (h *UserHandler) ListUsers(w http.ResponseWriter, r *http.Request) {
 
 // ListUsersHead godoc
 // @Summary Получение списка пользователей
-// @Description Возвращает список пользователей с постраничной навигацией (cursor-based). Доступно только авторизованным пользователям или администратору.
+// @Description Возвращает список пользователей с постраничной навигацией (cursor-based). Требуется право users:list.
 // @Tags Users
 // @Produce json
 // @Param cursor query string false "Курсор для пагинации"
@@ -412,8 +449,8 @@ func decodeJSON(w http.ResponseWriter, r *http.Request, target interface{}) erro
 	return nil
 }
 
-// restrictToOwner проверяет, имеет ли пользователь право доступа к ресурсу
-func restrictToOwner(w http.ResponseWriter, r *http.Request, targetUUID string) bool {
+// restrictToOwner проверяет, имеет ли пользователь право доступа к ресурсу: свой ресурс или право permission
+func restrictToOwner(w http.ResponseWriter, r *http.Request, targetUUID string, permission string) bool {
 	claims, ok := r.Context().Value(security.UserContextKey).(*security.Claims)
 	if ok == false || claims == nil {
 		w.Header().Set("Content-Type", "application/json")
@@ -427,7 +464,7 @@ func restrictToOwner(w http.ResponseWriter, r *http.Request, targetUUID string)
 		return false
 	}
 
-	if claims.IsAdmin == false && claims.UserUUID != targetUUID {
+	if claims.Can(permission) == false && claims.UserUUID != targetUUID {
 		w.Header().Set("Content-Type", "application/json")
 		w.WriteHeader(http.StatusForbidden)
 		json.NewEncoder(w).Encode(requestresponse.ErrorResponse{
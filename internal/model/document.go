@@ -18,6 +18,25 @@ type Document struct {
 	CreatedAt        time.Time  `db:"created_at" json:"created_at"`
 	UpdatedAt        time.Time  `db:"updated_at" json:"updated_at"`
 	DeletedAt        *time.Time `db:"deleted_at" json:"deleted_at,omitempty"`
+	ExpiresAt        *time.Time `db:"expires_at" json:"expires_at,omitempty"`
+	LegalHold        bool       `db:"legal_hold" json:"legal_hold"`
+}
+
+// Clone : глубокая копия документа — срезы и указатели не разделяются с исходным
+func (d *Document) Clone() *Document {
+	clone := *d
+	if d.GrantLogins != nil {
+		clone.GrantLogins = append([]string(nil), d.GrantLogins...)
+	}
+	if d.DeletedAt != nil {
+		deletedAt := *d.DeletedAt
+		clone.DeletedAt = &deletedAt
+	}
+	if d.ExpiresAt != nil {
+		expiresAt := *d.ExpiresAt
+		clone.ExpiresAt = &expiresAt
+	}
+	return &clone
 }
 
 type DocumentGrant struct {
@@ -38,6 +57,112 @@ type DocumentResponse struct {
 }
 
 type GetDocumentResult struct {
-	Document *Document
-	GetURL   string // если IsFile=true, содержит pre-signed URL
+	Document     *Document
+	GetURL       string        // если IsFile=true, содержит pre-signed URL
+	Stale        bool          // документ отдан из устаревшей копии кэша
+	Age          time.Duration // возраст копии кэша, если Stale
+	URLExpiresAt time.Time     // до какого момента действительна GetURL
+}
+
+// DocumentListQuery : параметры страницы списка документов, из них строится ключ кэша.
+// OwnerUUID пустой, если список запрошен по login — такая страница одинакова для всех пользователей
+type DocumentListQuery struct {
+	OwnerUUID   string `json:"owner_uuid,omitempty"`
+	Login       string `json:"login,omitempty"`
+	FilterKey   string `json:"key,omitempty"`
+	FilterValue string `json:"value,omitempty"`
+	Limit       int    `json:"limit"`
+}
+
+// DocumentListPage : закэшированная страница списка документов
+type DocumentListPage struct {
+	Documents  []DocumentResponse `json:"documents"`
+	NextCursor string             `json:"next_cursor,omitempty"`
+}
+
+// PresignedURL : сгенерированная pre-signed ссылка и момент, до которого она действительна
+type PresignedURL struct {
+	URL       string    `json:"url"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// Состояния предохранителя Redis
+const (
+	CacheStateClosed   = "closed"    // команды идут в Redis
+	CacheStateOpen     = "open"      // команды сразу завершаются ошибкой, Redis проверяется фоновым ping
+	CacheStateHalfOpen = "half_open" // ping прошёл, досылаются пропущенные инвалидации; остальные команды ещё отклоняются
+)
+
+// CacheHealth : состояние предохранителя Redis для health-check и метрик
+type CacheHealth struct {
+	State               string    `json:"state"`
+	Since               time.Time `json:"since"`
+	ConsecutiveFailures int       `json:"consecutive_failures"`
+	Trips               int64     `json:"trips"`    // сколько раз цепь размыкалась
+	Rejected            int64     `json:"rejected"` // сколько команд не отправлено в Redis
+}
+
+// CachedDocument : запись кэша документа. До FreshUntil копия свежая, до StaleUntil её можно отдавать,
+// обновляя в фоне, до ErrorUntil — только если БД недоступна. Нулевой FreshUntil — возраст неизвестен, копия свежая
+type CachedDocument struct {
+	Document   *Document
+	StoredAt   time.Time
+	FreshUntil time.Time
+	StaleUntil time.Time
+	ErrorUntil time.Time
+}
+
+func (c *CachedDocument) IsFresh(now time.Time) bool {
+	return c.FreshUntil.IsZero() || now.Before(c.FreshUntil)
+}
+
+func (c *CachedDocument) CanServeWhileRevalidating(now time.Time) bool {
+	return now.Before(c.StaleUntil)
+}
+
+func (c *CachedDocument) CanServeOnError(now time.Time) bool {
+	return now.Before(c.ErrorUntil)
+}
+
+func (c *CachedDocument) Age(now time.Time) time.Duration {
+	if c.StoredAt.IsZero() {
+		return 0
+	}
+	return now.Sub(c.StoredAt)
+}
+
+// RetentionPolicy : срок хранения документов с заданным MIME-типом ("image/png" или "image/*")
+type RetentionPolicy struct {
+	UUID          string    `db:"uuid" json:"uuid"`
+	MimeType      string    `db:"mime_type" json:"mime_type"`
+	RetentionDays int       `db:"retention_days" json:"retention_days"`
+	CreatedAt     time.Time `db:"created_at" json:"created_at"`
+}
+
+// DocumentLock : рекомендательная блокировка документа на время редактирования
+type DocumentLock struct {
+	DocumentUUID string    `db:"document_uuid" json:"document_uuid"`
+	HolderUUID   string    `db:"holder_uuid" json:"holder_uuid"`
+	Reason       string    `db:"reason" json:"reason,omitempty"`
+	LockedAt     time.Time `db:"locked_at" json:"locked_at"`
+	ExpiresAt    time.Time `db:"expires_at" json:"expires_at"`
+}
+
+// CacheKeyInfo : содержимое ключа кэша для администратора
+type CacheKeyInfo struct {
+	Key        string `json:"key"`
+	Type       string `json:"type"`                 // тип Redis: string, set, ...
+	TTL        string `json:"ttl,omitempty"`        // пусто — ключ без срока жизни
+	SizeBytes  int64  `json:"size_bytes"`           // длина значения для string, иначе MEMORY USAGE
+	Encoding   string `json:"encoding,omitempty"`   // json, binary или raw — без конверта
+	Compressed bool   `json:"compressed,omitempty"` // нагрузка конверта сжата
+	Value      any    `json:"value,omitempty"`      // расшифрованное значение или элементы множества
+	Truncated  bool   `json:"truncated,omitempty"`  // показаны не все элементы множества
+}
+
+// CacheWarmResult : итог прогрева кэша документов
+type CacheWarmResult struct {
+	Warmed  int      `json:"warmed"`
+	Missing []string `json:"missing,omitempty"` // документов нет в БД или они удалены
+	Failed  []string `json:"failed,omitempty"`  // не удалось прочитать из БД или записать в кэш
 }
@@ -0,0 +1,17 @@
+package model
+
+// MimeUsage : занятое место и количество документов одного MIME-типа
+type MimeUsage struct {
+	MimeType string `db:"mime_type" json:"mime_type"`
+	Bytes    int64  `db:"bytes" json:"bytes"`
+	Count    int64  `db:"count" json:"count"`
+}
+
+// StorageUsage : использование хранилища пользователем
+type StorageUsage struct {
+	UserUUID   string      `json:"user_uuid"`
+	UsedBytes  int64       `json:"used_bytes"`
+	Count      int64       `json:"count"`
+	QuotaBytes int64       `json:"quota_bytes"` // 0 — без ограничений
+	ByMime     []MimeUsage `json:"by_mime"`
+}
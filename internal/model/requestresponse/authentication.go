@@ -1,5 +1,7 @@
 package requestresponse
 
+import "caching-web-server/internal/model"
+
 // LoginRequest : тело запроса на аутентификацию
 type LoginRequest struct {
 	Login    string `json:"login" example:"user1"`
@@ -43,3 +45,21 @@ type LogoutItem struct {
 type LogoutResponse struct {
 	Response []LogoutItem `json:"response"`
 }
+
+// ListSessionsResponse : активные сессии пользователя
+type ListSessionsResponse struct {
+	Data []*model.Session `json:"data"`
+}
+
+// RevokeSessionsResponse : сколько сессий завершено
+type RevokeSessionsResponse struct {
+	Revoked int64 `json:"revoked" example:"2"`
+}
+
+// ListSecurityEventsResponse : страница журнала безопасности
+type ListSecurityEventsResponse struct {
+	Data struct {
+		Events     []*model.SecurityEvent `json:"events"`
+		NextCursor string                 `json:"next_cursor,omitempty"`
+	} `json:"data"`
+}
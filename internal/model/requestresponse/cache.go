@@ -0,0 +1,27 @@
+package requestresponse
+
+import "caching-web-server/internal/model"
+
+// CacheKeyResponse : содержимое ключа кэша
+type CacheKeyResponse struct {
+	Data model.CacheKeyInfo `json:"data"`
+}
+
+// EvictCacheResponse : сколько ключей удалено из кэша
+type EvictCacheResponse struct {
+	Deleted int64 `json:"deleted" example:"3"`
+}
+
+// WarmCacheRequest : прогрев кэша — по списку документов, по последним документам пользователя
+// или самыми популярными документами (top)
+type WarmCacheRequest struct {
+	Documents []string `json:"documents" example:"[\"qwdj1q4o34u34ih759ou1\"]"`
+	UserUUID  string   `json:"user_uuid" example:"user-uuid-1234"`
+	Limit     int      `json:"limit" example:"20"`
+	Top       int      `json:"top" example:"500"`
+}
+
+// WarmCacheResponse : итог прогрева кэша
+type WarmCacheResponse struct {
+	Data model.CacheWarmResult `json:"data"`
+}
@@ -41,11 +41,13 @@ type DocumentResponse struct {
 	CreatedAt        string   `json:"created" example:"2025-08-23T12:34:56Z"`
 	GrantLogins      []string `json:"grant" example:"[\"login1\",\"login2\"]"`
 	GetURL           string   `json:"get_url,omitempty"`
+	ExpiresAt        string   `json:"expires_at,omitempty" example:"2026-08-23T12:34:56Z"`
+	LegalHold        bool     `json:"legal_hold,omitempty" example:"false"`
 }
 
 // DocumentResponseFromModel : конвертирует model.Document в DocumentResponse
 func DocumentResponseFromModel(doc *model.Document, getURL string) DocumentResponse {
-	return DocumentResponse{
+	response := DocumentResponse{
 		UUID:             doc.UUID,
 		FilenameOriginal: doc.FilenameOriginal,
 		MimeType:         doc.MimeType,
@@ -54,7 +56,12 @@ func DocumentResponseFromModel(doc *model.Document, getURL string) DocumentRespo
 		CreatedAt:        doc.CreatedAt.Format(time.RFC3339),
 		GrantLogins:      doc.GrantLogins,
 		GetURL:           getURL,
+		LegalHold:        doc.LegalHold,
 	}
+	if doc.ExpiresAt != nil {
+		response.ExpiresAt = doc.ExpiresAt.Format(time.RFC3339)
+	}
+	return response
 }
 
 // ShareDocumentRequest : представляет тело запроса для предоставления доступа
@@ -67,6 +74,20 @@ type RemoveGrantRequest struct {
 	TargetUserUUID string `json:"target_user_uuid" validate:"required,uuid"`
 }
 
+// ArchiveDocumentsRequest : тело запроса на скачивание нескольких документов одним ZIP-архивом.
+// Если список documents пуст, документы выбираются среди своих по фильтру key/value
+type ArchiveDocumentsRequest struct {
+	Documents []string `json:"documents" example:"[\"qwdj1q4o34u34ih759ou1\",\"a1b2c3d4e5f6\"]"`
+	Key       string   `json:"key,omitempty" example:"mime"`
+	Value     string   `json:"value,omitempty" example:"image/jpg"`
+}
+
+// CopyDocumentRequest : тело запроса на копирование документа, пустые поля наследуются от оригинала
+type CopyDocumentRequest struct {
+	Name   string `json:"name,omitempty" example:"photo-copy.jpg"`
+	Public *bool  `json:"public,omitempty" example:"false"`
+}
+
 // ResponseMessage : общий ответ для подтверждения действий
 type ResponseMessage struct {
 	Response map[string]interface{} `json:"response,omitempty"`
@@ -101,3 +122,44 @@ type ListDocumentsResponse struct {
 type CreateDocumentMeta struct {
 	Public bool `json:"public" example:"true"`
 }
+
+// SetExpiryRequest : тело запроса на изменение срока хранения документа, null — хранить бессрочно
+type SetExpiryRequest struct {
+	ExpiresAt *time.Time `json:"expires_at" example:"2026-08-23T12:34:56Z"`
+}
+
+// SetLegalHoldRequest : тело запроса на постановку/снятие юридического удержания
+type SetLegalHoldRequest struct {
+	LegalHold bool `json:"legal_hold" example:"true"`
+}
+
+// RetentionPolicyRequest : тело запроса на создание политики хранения
+type RetentionPolicyRequest struct {
+	MimeType      string `json:"mime_type" example:"image/*"` // единственный селектор политики: точный MIME-тип или шаблон "type/*"
+	RetentionDays int    `json:"retention_days" example:"365"`
+	ApplyExisting bool   `json:"apply_existing" example:"false"`
+}
+
+// RetentionPolicyResponse : политика хранения
+type RetentionPolicyResponse struct {
+	Data model.RetentionPolicy `json:"data"`
+}
+
+// ListRetentionPoliciesResponse : список политик хранения
+type ListRetentionPoliciesResponse struct {
+	Data []model.RetentionPolicy `json:"data"`
+}
+
+// LockDocumentRequest : тело запроса на блокировку документа
+type LockDocumentRequest struct {
+	Reason     string `json:"reason,omitempty" example:"правлю раздел 3"`
+	TTLSeconds int    `json:"ttl_seconds,omitempty" example:"900"`
+}
+
+// DocumentLockResponse : состояние блокировки документа
+type DocumentLockResponse struct {
+	Data struct {
+		Locked bool                `json:"locked" example:"true"`
+		Lock   *model.DocumentLock `json:"lock,omitempty"`
+	} `json:"data"`
+}
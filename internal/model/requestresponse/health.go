@@ -0,0 +1,11 @@
+package requestresponse
+
+import "caching-web-server/internal/model"
+
+// HealthResponse : состояние сервиса и его зависимостей
+type HealthResponse struct {
+	Status        string                    `json:"status" example:"ok"`   // ok, degraded (кэш или проверка отзыва токенов недоступны) или unavailable (БД недоступна)
+	Database      string                    `json:"database" example:"up"` // up или down
+	Cache         model.CacheHealth         `json:"cache"`
+	TokenDenylist model.TokenDenylistHealth `json:"token_denylist"`
+}
@@ -0,0 +1,23 @@
+package requestresponse
+
+import "caching-web-server/internal/model"
+
+// RoleRequest : права пользовательской роли
+type RoleRequest struct {
+	Permissions []string `json:"permissions" example:"documents:read,users:list"`
+}
+
+// RoleResponse : роль с правами
+type RoleResponse struct {
+	Data model.Role `json:"data"`
+}
+
+// ListRolesResponse : все роли
+type ListRolesResponse struct {
+	Data []*model.Role `json:"data"`
+}
+
+// SetUserRolesRequest : новые роли пользователя, заменяют прежние
+type SetUserRolesRequest struct {
+	Roles []string `json:"roles" example:"auditor"`
+}
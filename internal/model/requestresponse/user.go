@@ -9,6 +9,18 @@ type RegisterRequest struct {
 	Password string `json:"password" example:"P@ssw0rd!"`
 }
 
+// CreateUserRequest : тело запроса создания пользователя администратором
+type CreateUserRequest struct {
+	Login    string   `json:"login" example:"newuser123"`
+	Password string   `json:"password" example:"P@ssw0rd!"`
+	Roles    []string `json:"roles" example:"user"`
+}
+
+// CreateUserResponse : созданный пользователь
+type CreateUserResponse struct {
+	Data *model.User `json:"data"`
+}
+
 // RegisterResponse : успешный ответ
 type RegisterResponse struct {
 	Response RegisterData `json:"response"`
@@ -68,3 +80,20 @@ type ListUsersResponse struct {
 		NextCursor string        `json:"next_cursor,omitempty"`
 	} `json:"data"`
 }
+
+// UsageResponse : использование хранилища пользователем
+type UsageResponse struct {
+	Data model.StorageUsage `json:"data"`
+}
+
+// SetQuotaRequest : тело запроса установки квоты
+type SetQuotaRequest struct {
+	QuotaBytes int64 `json:"quota_bytes" example:"1073741824"`
+}
+
+// SetQuotaResponse : успешный ответ
+type SetQuotaResponse struct {
+	Response struct {
+		QuotaBytes int64 `json:"quota_bytes" example:"1073741824"`
+	} `json:"response"`
+}
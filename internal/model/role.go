@@ -0,0 +1,49 @@
+package model
+
+import "time"
+
+// Права. Роль с правом PermissionAll может всё
+const (
+	PermissionAll             = "*"
+	PermissionDocumentsRead   = "documents:read"
+	PermissionDocumentsWrite  = "documents:write"
+	PermissionUsersList       = "users:list"
+	PermissionUsersRead       = "users:read"       // профили и использование квоты любых пользователей
+	PermissionUsersManage     = "users:manage"     // создание и удаление любых пользователей, квоты
+	PermissionRetentionRead   = "retention:read"   // политики хранения
+	PermissionRetentionManage = "retention:manage" // политики хранения и юридическое удержание
+	PermissionCacheRead       = "cache:read"
+	PermissionCacheManage     = "cache:manage"
+	PermissionLocksManage     = "locks:manage" // снятие блокировок с чужих документов
+	PermissionRolesManage     = "roles:manage" // роли и их назначение пользователям
+)
+
+// Permissions : все известные права, кроме PermissionAll
+var Permissions = []string{
+	PermissionDocumentsRead,
+	PermissionDocumentsWrite,
+	PermissionUsersList,
+	PermissionUsersRead,
+	PermissionUsersManage,
+	PermissionRetentionRead,
+	PermissionRetentionManage,
+	PermissionCacheRead,
+	PermissionCacheManage,
+	PermissionLocksManage,
+	PermissionRolesManage,
+}
+
+// Встроенные роли
+const (
+	RoleAdmin   = "admin"
+	RoleUser    = "user"
+	RoleAuditor = "auditor" // только чтение: пользователи, политики хранения, кэш
+)
+
+// Role : именованный набор прав
+type Role struct {
+	Name        string    `db:"name" json:"name"`
+	Permissions []string  `db:"permissions" json:"permissions"`
+	Builtin     bool      `db:"builtin" json:"builtin"`
+	CreatedAt   time.Time `db:"created_at" json:"created_at"`
+}
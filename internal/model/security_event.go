@@ -0,0 +1,22 @@
+package model
+
+import "time"
+
+const (
+	// SecurityEventRefreshTokenReuse : предъявлен уже использованный refresh-токен, семейство токенов отозвано
+	SecurityEventRefreshTokenReuse = "refresh_token_reuse"
+	// SecurityEventNewIP : токены обновлены с нового IP-адреса
+	SecurityEventNewIP = "refresh_token_from_new_ip"
+)
+
+// SecurityEvent : запись журнала безопасности пользователя
+type SecurityEvent struct {
+	ID          int64     `db:"id" json:"id"`
+	UserUUID    string    `db:"user_uuid" json:"user_uuid"`
+	Event       string    `db:"event" json:"event"`
+	SessionUUID *string   `db:"session_uuid" json:"session_uuid,omitempty"`
+	IpAddress   string    `db:"ip_address" json:"ip_address"`
+	UserAgent   string    `db:"user_agent" json:"user_agent"`
+	Details     string    `db:"details" json:"details,omitempty"`
+	CreatedAt   time.Time `db:"created_at" json:"created_at"`
+}
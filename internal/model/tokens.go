@@ -3,15 +3,29 @@ package model
 import "time"
 
 type RefreshToken struct {
-	UUID      string     `db:"uuid"`
-	UserUUID  string     `db:"user_uuid"`
-	TokenHash string     `db:"token_hash"`
-	ExpireAt  time.Time  `db:"expire_at"`
-	Used      bool       `db:"used"`
-	UserAgent string     `db:"user_agent"`
-	IpAddress string     `db:"ip_address"`
-	CreatedAt time.Time  `db:"created_at" json:"created_at"`
-	RevokedAt *time.Time `db:"revoked_at" json:"revoked_at,omitempty"`
+	UUID        string     `db:"uuid"`
+	UserUUID    string     `db:"user_uuid"`
+	TokenHash   string     `db:"token_hash"`
+	ExpireAt    time.Time  `db:"expire_at"`
+	Used        bool       `db:"used"`
+	UserAgent   string     `db:"user_agent"`
+	IpAddress   string     `db:"ip_address"`
+	CreatedAt   time.Time  `db:"created_at" json:"created_at"`
+	SessionUUID string     `db:"session_uuid"`
+	LastUsedAt  *time.Time `db:"last_used_at" json:"last_used_at,omitempty"`
+	RevokedAt   *time.Time `db:"revoked_at" json:"revoked_at,omitempty"`
+}
+
+// Session : активная сессия пользователя — действующий refresh-токен последнего обновления
+type Session struct {
+	UUID       string     `db:"session_uuid" json:"uuid"`
+	TokenUUID  string     `db:"uuid" json:"-"`
+	UserAgent  string     `db:"user_agent" json:"user_agent"`
+	IpAddress  string     `db:"ip_address" json:"ip_address"`
+	CreatedAt  time.Time  `db:"created_at" json:"created_at"` // время входа
+	LastUsedAt *time.Time `db:"last_used_at" json:"last_used_at,omitempty"`
+	ExpireAt   time.Time  `db:"expire_at" json:"expire_at"`
+	Current    bool       `db:"-" json:"current"` // сессия, которой выполнен запрос
 }
 
 // TokensPair содержит пару access и refresh токенов
@@ -25,3 +39,18 @@ type TokensPair struct {
 	// example: vcSi0369y1I62wOpxZFpgZ...
 	RefreshToken string `json:"refreshToken"`
 }
+
+// состояния проверки отзыва access-токенов
+const (
+	TokenDenylistStateOK        = "ok"
+	TokenDenylistStateUnchecked = "unchecked" // последняя проверка не удалась, токены принимаются без неё
+	TokenDenylistStateRejecting = "rejecting" // последняя проверка не удалась, запросы отклоняются
+)
+
+// TokenDenylistHealth : состояние проверки отзыва access-токенов для health-check и метрик
+type TokenDenylistHealth struct {
+	State       string     `json:"state"`
+	FailOpen    bool       `json:"fail_open"`
+	CheckErrors int64      `json:"check_errors"` // сколько проверок не удалось
+	LastErrorAt *time.Time `json:"last_error_at,omitempty"`
+}
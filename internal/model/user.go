@@ -7,4 +7,5 @@ type User struct {
 	Login        string    `db:"login" json:"login"`
 	PasswordHash string    `db:"password_hash" json:"-"`
 	CreatedAt    time.Time `db:"created_at" json:"created_at"`
+	Roles        []string  `db:"-" json:"roles,omitempty"`
 }
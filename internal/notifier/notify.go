@@ -10,20 +10,27 @@ import (
 )
 
 type WebhookNotify struct {
-	UserUUID  string
-	NewIP     string
-	OldIP     string
-	Event     string
-	TimeStamp string
+	UserUUID    string
+	NewIP       string
+	OldIP       string
+	Event       string
+	SessionUUID string `json:",omitempty"`
+	TimeStamp   string
 }
 
 func NotifyWebhook(webhookURL string, userUUID string, newIP string, oldIP string) error {
-	payload := &WebhookNotify{
-		UserUUID:  userUUID,
-		NewIP:     newIP,
-		OldIP:     oldIP,
-		Event:     "refresh_token_from_new_ip",
-		TimeStamp: time.Now().Format(time.RFC3339),
+	return NotifySecurityEvent(webhookURL, &WebhookNotify{
+		UserUUID: userUUID,
+		NewIP:    newIP,
+		OldIP:    oldIP,
+		Event:    "refresh_token_from_new_ip",
+	})
+}
+
+// NotifySecurityEvent отправляет событие безопасности на webhook. Если TimeStamp не заполнен, ставится текущее время
+func NotifySecurityEvent(webhookURL string, payload *WebhookNotify) error {
+	if payload.TimeStamp == "" {
+		payload.TimeStamp = time.Now().Format(time.RFC3339)
 	}
 
 	jsonBody, err := json.Marshal(payload)
@@ -2,11 +2,17 @@ package ports
 
 import (
 	"caching-web-server/internal/model"
+	"caching-web-server/internal/security"
 	"context"
 )
 
 type AuthenticationService interface {
 	Login(ctx context.Context, email, password, userAgent, ipAddress string) (*model.TokensPair, error)
 	RefreshToken(ctx context.Context, userAgent, ipAddress, accessToken, refreshToken string) (*model.TokensPair, error)
-	Logout(ctx context.Context, refreshTokenUUID string) error
+	Logout(ctx context.Context, claims *security.Claims) error
+	ListSessions(ctx context.Context, userUUID string) ([]*model.Session, error)
+	RevokeSession(ctx context.Context, userUUID string, sessionUUID string) error
+	RevokeOtherSessions(ctx context.Context) (int64, error)
+	RevokeAllSessions(ctx context.Context, userUUID string) (int64, error)
+	ListSecurityEvents(ctx context.Context, userUUID string, cursor string, limit int) ([]*model.SecurityEvent, string, error)
 }
@@ -3,6 +3,8 @@ package ports
 import (
 	"caching-web-server/internal/model"
 	"context"
+	"github.com/jmoiron/sqlx"
+	"time"
 )
 
 // CacheRepository : Redis слой
@@ -11,3 +13,119 @@ type CacheRepository interface {
 	GetDocument(ctx context.Context, uuid string) (*model.Document, error)
 	DeleteDocument(ctx context.Context, uuid string) error
 }
+
+// CacheLoadLocker : короткая блокировка загрузки документа между репликами (защита от cache stampede).
+// Реализуется кэшем опционально
+type CacheLoadLocker interface {
+	AcquireLoadLock(ctx context.Context, uuid string) (release func(), acquired bool, err error)
+	WaitForDocument(ctx context.Context, uuid string) (*model.Document, error)
+}
+
+// StaleDocumentCache : кэш, хранящий копии документа после мягкого TTL (stale-while-revalidate / stale-if-error).
+// GetDocument такого кэша возвращает только свежие копии. Реализуется кэшем опционально
+type StaleDocumentCache interface {
+	GetDocumentEntry(ctx context.Context, uuid string) (*model.CachedDocument, error)
+}
+
+// NegativeDocumentCache : кэш отрицательных результатов — документов, не найденных для пользователя,
+// и публичных токенов без документа. Реализуется кэшем опционально
+type NegativeDocumentCache interface {
+	IsDocumentMissing(ctx context.Context, uuid string, userUUID string) (bool, error)
+	SetDocumentMissing(ctx context.Context, uuid string, userUUID string) error
+	IsPublicTokenMissing(ctx context.Context, token string) (bool, error)
+	SetPublicTokenMissing(ctx context.Context, token string) error
+	// ForgetMissing : снимает отрицательные записи, когда документ появился в БД
+	ForgetMissing(ctx context.Context, uuid string, token string) error
+}
+
+// GrantCache : кэш множеств пользователей с grant на документ. Реализуется кэшем опционально.
+// Если множества нет или оно неполное, доступ проверяется по БД — кэш никогда не выдаёт доступ сам по себе
+type GrantCache interface {
+	// IsGranted : cached = false — решение нужно принять по БД
+	IsGranted(ctx context.Context, documentUUID string, userUUID string) (granted bool, cached bool, err error)
+	// GrantSetVersion : читается до загрузки grant из БД и передаётся в StoreGrantSet
+	GrantSetVersion(ctx context.Context, documentUUID string) (int64, error)
+	StoreGrantSet(ctx context.Context, documentUUID string, userUUIDs []string, version int64) error
+	AddToGrantSet(ctx context.Context, documentUUID string, userUUID string) error
+	RemoveFromGrantSet(ctx context.Context, documentUUID string, userUUID string) error
+	// DropGrantSet : удаляет множество целиком, если его не удалось изменить точечно
+	DropGrantSet(ctx context.Context, documentUUID string) error
+}
+
+// DocumentListCache : кэш страниц списка документов с инвалидацией по тегам документов и владельцев.
+// Изменение документа инвалидирует его страницы через DeleteDocument. Реализуется кэшем опционально
+type DocumentListCache interface {
+	GetDocumentList(ctx context.Context, query model.DocumentListQuery) (*model.DocumentListPage, error)
+	StoreDocumentList(ctx context.Context, query model.DocumentListQuery, page *model.DocumentListPage, documentUUIDs []string, ownerUUIDs []string) error
+	InvalidateOwnerLists(ctx context.Context, ownerUUID string) error
+}
+
+// PublicDocumentCache : публичные копии документов и соответствие публичного токена UUID документа.
+// Публичная копия хранится отдельно от записи документа и не содержит списка grant; при каждом чтении
+// она проверяется по токену и is_public. Реализуется кэшем опционально
+type PublicDocumentCache interface {
+	GetPublicDocument(ctx context.Context, uuid string) (*model.Document, error)
+	SetPublicDocument(ctx context.Context, document *model.Document) error
+	GetPublicTokenUUID(ctx context.Context, token string) (string, error)
+	SetPublicTokenUUID(ctx context.Context, token string, uuid string) error
+}
+
+// PresignedURLCache : кэш сгенерированных pre-signed ссылок по ключу объекта в хранилище и запрошенному
+// сроку действия ссылки. Реализуется кэшем опционально
+type PresignedURLCache interface {
+	GetPresignedURL(ctx context.Context, storagePath string, expire time.Duration) (*model.PresignedURL, error)
+	SetPresignedURL(ctx context.Context, storagePath string, expire time.Duration, url *model.PresignedURL) error
+}
+
+// CacheHealthReporter : состояние подключения к Redis для health-check и метрик
+type CacheHealthReporter interface {
+	Health() model.CacheHealth
+}
+
+// DocumentPopularity : частота обращений к документам для прогрева кэша. Реализуется кэшем опционально
+type DocumentPopularity interface {
+	// RecordAccess : не ждёт Redis, обращения сбрасываются пачками
+	RecordAccess(uuid string)
+	TopDocuments(ctx context.Context, limit int) ([]string, error)
+	ForgetPopularity(ctx context.Context, uuids ...string) error
+}
+
+// VersionedDocumentCache : кэш, сравнивающий версии документов, чтобы из двух параллельных изменений
+// в нём осталось последнее. Реализуется кэшем опционально
+type VersionedDocumentCache interface {
+	// WriteThroughEnabled : записывать документ в кэш после изменения, а не только инвалидировать
+	WriteThroughEnabled() bool
+	// StoreDocumentVersion : stored = false — в кэше уже более новая версия
+	StoreDocumentVersion(ctx context.Context, document *model.Document) (bool, error)
+	// InvalidateDocumentVersion : удаляет документ и не даёт записать версии старше version
+	InvalidateDocumentVersion(ctx context.Context, uuid string, version int64) error
+	// TombstoneDocument : удаляет документ и не даёт записать ни одну его версию
+	TombstoneDocument(ctx context.Context, uuid string) error
+}
+
+// CacheAdmin : администрирование кэша документов — ключи только из пространства document:,
+// блокировки, версии документов и множеств grant и популярность не удаляются. Реализуется кэшем опционально
+type CacheAdmin interface {
+	// InspectKey : nil — ключа нет
+	InspectKey(ctx context.Context, key string) (*model.CacheKeyInfo, error)
+	// EvictKeys : ключ или шаблон SCAN, возвращает число удалённых ключей
+	EvictKeys(ctx context.Context, pattern string) (int64, error)
+	FlushDocuments(ctx context.Context) (int64, error)
+}
+
+// CacheWarmRepository : чтение документов из БД для прогрева кэша, без проверки доступа пользователя
+type CacheWarmRepository interface {
+	GetDocuments(ctx context.Context, exec sqlx.ExtContext, documentUUIDs []string) ([]model.Document, error)
+	ListRecentDocumentUUIDs(ctx context.Context, exec sqlx.ExtContext, ownerUUID string, limit int) ([]string, error)
+}
+
+// CacheAdminService : администрирование кэша документов (только администратор)
+type CacheAdminService interface {
+	InspectDocument(ctx context.Context, documentUUID string) (*model.CacheKeyInfo, error)
+	InspectKey(ctx context.Context, key string) (*model.CacheKeyInfo, error)
+	EvictKeys(ctx context.Context, pattern string) (int64, error)
+	FlushDocuments(ctx context.Context) (int64, error)
+	WarmDocuments(ctx context.Context, documentUUIDs []string) (*model.CacheWarmResult, error)
+	WarmUserDocuments(ctx context.Context, userUUID string, limit int) (*model.CacheWarmResult, error)
+	WarmPopularDocuments(ctx context.Context, limit int) (*model.CacheWarmResult, error)
+}
@@ -4,6 +4,8 @@ import (
 	"caching-web-server/internal/model"
 	"context"
 	"github.com/jmoiron/sqlx"
+	"io"
+	"time"
 )
 
 // DocumentRepository : SQL слой
@@ -22,10 +24,19 @@ type GrantDocumentRepository interface {
 	AddGrant(ctx context.Context, exec sqlx.ExtContext, documentUUID string, ownerUUID string, targetUserUUID string) error
 	RemoveGrant(ctx context.Context, exec sqlx.ExtContext, documentUUID, userUUID string) error
 	ListGrants(ctx context.Context, exec sqlx.ExtContext, documentUUID string) ([]string, error)
+	ListGrantUserUUIDs(ctx context.Context, exec sqlx.ExtContext, documentUUID string) ([]string, error)
 	CheckOwner(ctx context.Context, exec sqlx.ExtContext, documentUUID, ownerUUID string) (bool, error)
 	HasAccess(ctx context.Context, exec sqlx.ExtContext, documentUUID, userUUID string) (bool, error)
 }
 
+// DocumentLockRepository : блокировки документов (Redis с запасным хранением в БД)
+type DocumentLockRepository interface {
+	Acquire(ctx context.Context, lock *model.DocumentLock) (*model.DocumentLock, bool, error)
+	Get(ctx context.Context, documentUUID string) (*model.DocumentLock, error)
+	Release(ctx context.Context, documentUUID, holderUUID string) (bool, error)
+	ForceRelease(ctx context.Context, documentUUID string) error
+}
+
 type DocumentService interface {
 	CreateDocument(ctx context.Context, document *model.Document) (string, error)
 	GetDocumentByUUID(ctx context.Context, documentUUID string) (*model.GetDocumentResult, error)
@@ -36,4 +47,13 @@ type DocumentService interface {
 	ListDocuments(ctx context.Context, userUUID, login, filterKey, filterValue string, limit int) ([]model.DocumentResponse, string, error)
 	AddGrant(ctx context.Context, documentUUID, ownerUUID, targetUserUUID string) error
 	RemoveGrant(ctx context.Context, documentUUID, ownerUUID, targetUserUUID string) error
+	ResolveArchiveDocuments(ctx context.Context, documentUUIDs []string, filterKey, filterValue string) ([]*model.Document, error)
+	WriteArchive(ctx context.Context, documents []*model.Document, writer io.Writer) error
+	CopyDocument(ctx context.Context, documentUUID string, name string, isPublic *bool) (*model.Document, error)
+	LockDocument(ctx context.Context, documentUUID, reason string, ttl time.Duration) (*model.DocumentLock, error)
+	UnlockDocument(ctx context.Context, documentUUID string) error
+	ForceUnlockDocument(ctx context.Context, documentUUID string) error
+	GetDocumentLock(ctx context.Context, documentUUID string) (*model.DocumentLock, error)
+	EnsureDocumentUnlocked(ctx context.Context, documentUUID, userUUID string) error
+	CacheDocumentVersion(ctx context.Context, documentUUID, ownerUUID string, version int64)
 }
@@ -4,16 +4,35 @@ import (
 	"caching-web-server/internal/model"
 	"caching-web-server/internal/security"
 	"context"
+	"time"
 )
 
 type JWTRepositoryInterface interface {
 	FindByUUID(ctx context.Context, uuid string) (*model.RefreshToken, error)
 	MarkRefreshTokenUsedByUUID(ctx context.Context, uuid string) error
 	SaveRefreshToken(ctx context.Context, token *model.RefreshToken, ipAddress string) error
+	RevokeRefreshToken(ctx context.Context, uuid string) error
+	ListSessions(ctx context.Context, userUUID string) ([]*model.Session, error)
+	RevokeSession(ctx context.Context, userUUID string, sessionUUID string) ([]string, error)
+	RevokeSessions(ctx context.Context, userUUID string, exceptSessionUUID string) ([]string, error)
+	SaveSecurityEvent(ctx context.Context, event *model.SecurityEvent) error
+	ListSecurityEvents(ctx context.Context, userUUID string, cursor string, limit int) ([]*model.SecurityEvent, string, error)
 }
 
 type JWTServiceInterface interface {
-	GenerateAccessRefreshTokens(userUUID string) (*model.TokensPair, *model.RefreshToken, error)
+	GenerateAccessRefreshTokens(userUUID string, roles []string) (*model.TokensPair, *model.RefreshToken, error)
 	ValidateJWT(tokenString string, secret []byte) (*security.Claims, error)
 	ParseAccessToken(tokenStr string) (*security.Claims, error)
 }
+
+// TokenDenylist : отзыв access-токенов до истечения exp
+type TokenDenylist interface {
+	DenyToken(ctx context.Context, jti string, expiresAt time.Time) error
+	DenyTokens(ctx context.Context, jtis []string) error
+	RevokeIssuedBefore(ctx context.Context, userUUID string, issuedBefore time.Time) error
+}
+
+// TokenDenylistHealthReporter : состояние проверки отзыва access-токенов для health-check и метрик
+type TokenDenylistHealthReporter interface {
+	Health() model.TokenDenylistHealth
+}
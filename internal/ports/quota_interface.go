@@ -0,0 +1,22 @@
+package ports
+
+import (
+	"caching-web-server/internal/model"
+	"context"
+	"github.com/jmoiron/sqlx"
+)
+
+type QuotaRepository interface {
+	GetQuota(ctx context.Context, exec sqlx.ExtContext, userUUID string) (*int64, error)
+	SetQuota(ctx context.Context, exec sqlx.ExtContext, userUUID string, quotaBytes int64) error
+	DeleteQuota(ctx context.Context, exec sqlx.ExtContext, userUUID string) error
+	LockUsage(ctx context.Context, exec sqlx.ExtContext, userUUID string) error
+	GetUsedBytes(ctx context.Context, exec sqlx.ExtContext, userUUID string) (int64, error)
+	GetUsageByMime(ctx context.Context, exec sqlx.ExtContext, userUUID string) ([]model.MimeUsage, error)
+}
+
+type QuotaService interface {
+	CheckQuota(ctx context.Context, exec sqlx.ExtContext, userUUID string, additionalBytes int64) error
+	GetUsage(ctx context.Context, userUUID string) (*model.StorageUsage, error)
+	SetQuota(ctx context.Context, userUUID string, quotaBytes *int64) error
+}
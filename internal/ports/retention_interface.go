@@ -0,0 +1,29 @@
+package ports
+
+import (
+	"caching-web-server/internal/model"
+	"context"
+	"github.com/jmoiron/sqlx"
+	"time"
+)
+
+type RetentionRepository interface {
+	ListPolicies(ctx context.Context, exec sqlx.ExtContext) ([]model.RetentionPolicy, error)
+	SavePolicy(ctx context.Context, exec sqlx.ExtContext, policy *model.RetentionPolicy) error
+	DeletePolicy(ctx context.Context, exec sqlx.ExtContext, policyUUID string) error
+	ApplyPolicy(ctx context.Context, exec sqlx.ExtContext, policy *model.RetentionPolicy) ([]model.Document, error)
+	PolicyExpiry(ctx context.Context, exec sqlx.ExtContext, documentUUID, ownerUUID string) (*time.Time, error)
+	SetExpiry(ctx context.Context, exec sqlx.ExtContext, documentUUID, ownerUUID string, expiresAt *time.Time) (int64, error)
+	SetLegalHold(ctx context.Context, exec sqlx.ExtContext, documentUUID string, hold bool) (*model.Document, error)
+	ListExpired(ctx context.Context, exec sqlx.ExtContext, now time.Time, limit int) ([]model.Document, error)
+	DeferExpired(ctx context.Context, exec sqlx.ExtContext, documentUUID string, now time.Time) error
+}
+
+type RetentionService interface {
+	ListPolicies(ctx context.Context) ([]model.RetentionPolicy, error)
+	SavePolicy(ctx context.Context, mimeType string, retentionDays int, applyExisting bool) (*model.RetentionPolicy, error)
+	DeletePolicy(ctx context.Context, policyUUID string) error
+	SetDocumentExpiry(ctx context.Context, documentUUID string, expiresAt *time.Time) error
+	SetLegalHold(ctx context.Context, documentUUID string, hold bool) error
+	DeleteExpired(ctx context.Context, limit int) (int, error)
+}
@@ -0,0 +1,21 @@
+package ports
+
+import (
+	"caching-web-server/internal/model"
+	"context"
+	"github.com/jmoiron/sqlx"
+)
+
+type RoleRepository interface {
+	ListRoles(ctx context.Context, exec sqlx.ExtContext) ([]*model.Role, error)
+	SaveRole(ctx context.Context, exec sqlx.ExtContext, role *model.Role) error
+	DeleteRole(ctx context.Context, exec sqlx.ExtContext, name string) error
+}
+
+type RoleService interface {
+	Permissions(ctx context.Context, roles []string) ([]string, error)
+	ListRoles(ctx context.Context) ([]*model.Role, error)
+	SaveRole(ctx context.Context, role *model.Role) error
+	DeleteRole(ctx context.Context, name string) error
+	SetUserRoles(ctx context.Context, userUUID string, roles []string) error
+}
@@ -2,6 +2,7 @@ package ports
 
 import (
 	"context"
+	"io"
 	"time"
 )
 
@@ -10,4 +11,6 @@ type S3Storage interface {
 	GeneratePresignedGetURL(ctx context.Context, key string, expire time.Duration) (string, error)
 	GeneratePresignedPutURL(ctx context.Context, key string, expire time.Duration) (string, error)
 	DeleteObject(ctx context.Context, key string) error
+	GetObject(ctx context.Context, key string) (io.ReadCloser, error)
+	CopyObject(ctx context.Context, sourceKey, destinationKey string) error
 }
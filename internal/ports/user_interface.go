@@ -7,6 +7,7 @@ import (
 )
 
 type UserRepository interface {
+	BeginTX(ctx context.Context) (sqlx.ExtContext, func() error, func() error, error)
 	CreateUser(ctx context.Context, exec sqlx.ExtContext, user *model.User) (*model.User, error)
 	FindByUUID(ctx context.Context, exec sqlx.ExtContext, uuid string) (*model.User, error)
 	FindByEmail(ctx context.Context, exec sqlx.ExtContext, email string) (*model.User, error)
@@ -15,13 +16,17 @@ type UserRepository interface {
 	DeleteUser(ctx context.Context, exec sqlx.ExtContext, uuid string) error
 	ListUsers(ctx context.Context, exec sqlx.ExtContext, cursor string, limit int) ([]*model.User, string, error)
 	Exists(ctx context.Context, exec sqlx.ExtContext, uuid string) (bool, error)
+	SetRoles(ctx context.Context, exec sqlx.ExtContext, userUUID string, roles []string) error
+	RoleHolderExists(ctx context.Context, exec sqlx.ExtContext, role string) (bool, error)
+	LockRoleHolders(ctx context.Context, exec sqlx.ExtContext, role string) error
 }
 
 type UserService interface {
 	Register(ctx context.Context, adminToken string, login string, password string, ipAddress string) (*model.TokensPair, error)
+	CreateUser(ctx context.Context, login string, password string, roles []string) (*model.User, error)
 	GetUser(ctx context.Context, uuid string) (*model.User, error)
 	UpdateUser(ctx context.Context, updatedUser *model.User) error
 	UpdatePassword(ctx context.Context, uuid string, newPassword string) error
 	DeleteUser(ctx context.Context, uuid string) error
-	ListUsers(ctx context.Context, adminToken string, cursor string, limit int) ([]*model.User, string, error)
+	ListUsers(ctx context.Context, cursor string, limit int) ([]*model.User, string, error)
 }
@@ -0,0 +1,147 @@
+package repository
+
+import (
+	"caching-web-server/internal/model"
+	"caching-web-server/internal/util"
+	"context"
+	"errors"
+	"fmt"
+	"github.com/redis/go-redis/v9"
+	"strings"
+	"time"
+)
+
+// maxInspectMembers : сколько элементов множества показывать администратору
+const maxInspectMembers = 1000
+
+// ErrKeyOutsideNamespace : администратор может трогать только ключи кэша документов
+var ErrKeyOutsideNamespace = errors.New("[CacheRepo] ключ вне пространства кэша документов")
+
+// InspectKey : значение, TTL, размер и кодировка ключа кэша; nil — ключа нет
+func (r *CacheRepository) InspectKey(ctx context.Context, key string) (*model.CacheKeyInfo, error) {
+	if strings.HasPrefix(key, "document:") == false {
+		return nil, ErrKeyOutsideNamespace
+	}
+
+	keyType, err := r.client.Type(ctx, key).Result()
+	if err != nil {
+		return nil, util.LogError("[CacheRepo] ошибка чтения типа ключа", err)
+	}
+	if keyType == "none" {
+		return nil, nil
+	}
+
+	info := &model.CacheKeyInfo{Key: key, Type: keyType}
+	ttl, err := r.client.PTTL(ctx, key).Result()
+	if err != nil {
+		return nil, util.LogError("[CacheRepo] ошибка чтения TTL ключа", err)
+	}
+	if ttl > 0 {
+		info.TTL = ttl.Round(time.Millisecond).String()
+	}
+
+	switch keyType {
+	case "string":
+		data, err := r.client.Get(ctx, key).Bytes()
+		if errors.Is(err, redis.Nil) {
+			return nil, nil // ключ истёк между командами
+		} else if err != nil {
+			return nil, util.LogError("[CacheRepo] ошибка чтения ключа", err)
+		}
+		info.SizeBytes = int64(len(data))
+		info.Encoding, info.Compressed, info.Value, err = r.codec.describe(data)
+		if err != nil {
+			return nil, util.LogError("[CacheRepo] ошибка расшифровки записи кэша", err)
+		}
+	case "set":
+		members, cursor, err := r.client.SScan(ctx, key, 0, "", maxInspectMembers).Result()
+		if err != nil {
+			return nil, util.LogError("[CacheRepo] ошибка чтения множества", err)
+		}
+		info.Value, info.Truncated = members, cursor != 0
+		info.SizeBytes = r.memoryUsage(ctx, key)
+	default:
+		info.SizeBytes = r.memoryUsage(ctx, key)
+	}
+	return info, nil
+}
+
+// memoryUsage : MEMORY USAGE может быть запрещён ACL, тогда размер неизвестен
+func (r *CacheRepository) memoryUsage(ctx context.Context, key string) int64 {
+	size, err := r.client.MemoryUsage(ctx, key).Result()
+	if err != nil {
+		return 0
+	}
+	return size
+}
+
+// EvictKeys : удаляет ключ или все ключи по шаблону SCAN (*, ?, [...]) внутри пространства document:.
+// Запись документа удаляется через DeleteDocument, чтобы снять её и со страниц списков, и с L1 реплик
+func (r *CacheRepository) EvictKeys(ctx context.Context, pattern string) (int64, error) {
+	if strings.HasPrefix(pattern, "document:") == false {
+		return 0, ErrKeyOutsideNamespace
+	}
+	if isFlushableKey(pattern) == false {
+		return 0, fmt.Errorf("[CacheRepo] блокировки, версии grant и популярность не удаляются через кэш")
+	}
+
+	if strings.ContainsAny(pattern, "*?[") == false {
+		if uuid, ok := r.documentUUIDFromKey(pattern); ok {
+			exists, err := r.client.Exists(ctx, pattern).Result()
+			if err != nil {
+				return 0, util.LogError("[CacheRepo] ошибка проверки ключа", err)
+			}
+			return exists, r.DeleteDocument(ctx, uuid)
+		}
+		deleted, err := r.client.Del(ctx, pattern).Result()
+		if err != nil {
+			return 0, util.LogError("[CacheRepo] ошибка удаления ключа", err)
+		}
+		return deleted, nil
+	}
+
+	var deleted int64
+	err := r.scanKeys(ctx, pattern, func(keys []string) error {
+		evictable := keys[:0]
+		for _, key := range keys {
+			if isFlushableKey(key) {
+				evictable = append(evictable, key)
+			}
+		}
+		if len(evictable) == 0 {
+			return nil
+		}
+		if err := r.deleteKeys(ctx, evictable...); err != nil {
+			return err
+		}
+		deleted += int64(len(evictable))
+		return nil
+	})
+	if err != nil {
+		return deleted, util.LogError("[CacheRepo] ошибка удаления ключей по шаблону", err)
+	}
+
+	// под шаблон могли попасть документы из L1 реплик, разбирать какие — дороже, чем очистить L1
+	if deleted > 0 && r.bus != nil {
+		if err := r.bus.PublishFlush(ctx); err != nil {
+			return deleted, util.LogError("[CacheRepo] ошибка рассылки очистки кэша", err)
+		}
+	}
+	return deleted, nil
+}
+
+// documentUUIDFromKey : UUID, если ключ — запись документа document:{uuid}, а не служебный ключ
+func (r *CacheRepository) documentUUIDFromKey(key string) (string, bool) {
+	uuid := strings.TrimPrefix(key, "document:")
+	if uuid == "" || strings.ContainsAny(uuid, ":{}") {
+		return "", false
+	}
+	return uuid, true
+}
+
+// isFlushableKey : блокировки редактирования — не кэш, версии документов и множеств grant защищают от записи устаревших копий,
+// а популярность нужна, чтобы прогреть кэш после очистки
+func isFlushableKey(key string) bool {
+	return strings.HasPrefix(key, "document:lock:") == false && strings.HasPrefix(key, "document:grants:version:") == false &&
+		strings.HasPrefix(key, "document:version:") == false && key != popularityKey
+}
@@ -0,0 +1,387 @@
+package repository
+
+import (
+	"bytes"
+	"caching-web-server/internal/model"
+	"compress/flate"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+)
+
+// Запись кэша — конверт: заголовок [magic, версия схемы, кодировка, флаги] и полезная нагрузка.
+// Версия схемы увеличивается при любом изменении формата нагрузки (в том числе полей model.Document
+// в бинарной кодировке): записи чужой версии, как и записи без конверта, считаются промахом,
+// поэтому реплики разных сборок не читают данные друг друга. В конверте хранятся документы, списки,
+// UUID по публичному токену и pre-signed ссылки; версия множества grant — в его маркере загрузки
+const (
+	cacheEnvelopeMagic      byte = 0xCE
+	cacheSchemaVersion      byte = 1
+	cacheEnvelopeHeaderSize      = 4
+
+	cacheFlagCompressed byte = 1 << 0
+)
+
+// cacheEncoding : кодировка полезной нагрузки конверта
+type cacheEncoding byte
+
+const (
+	cacheEncodingJSON   cacheEncoding = 1
+	cacheEncodingBinary cacheEncoding = 2
+)
+
+// errUnknownCacheEnvelope : запись другой версии схемы или без конверта — для читателя это промах
+var errUnknownCacheEnvelope = errors.New("[CacheRepo] неизвестный формат записи кэша")
+
+// cacheCodec : сериализация записей кэша в конверт; читает любую известную кодировку независимо от настроенной
+type cacheCodec struct {
+	encoding          cacheEncoding
+	compressThreshold int // 0 — не сжимать
+}
+
+func newCacheCodec(encoding string, compressThreshold int) cacheCodec {
+	codec := cacheCodec{encoding: cacheEncodingJSON, compressThreshold: compressThreshold}
+	if encoding == "binary" {
+		codec.encoding = cacheEncodingBinary
+	}
+	return codec
+}
+
+// encodeEntry : запись документа в настроенной кодировке
+func (c cacheCodec) encodeEntry(entry cacheEntry) ([]byte, error) {
+	if c.encoding == cacheEncodingBinary {
+		return c.seal(cacheEncodingBinary, marshalEntryBinary(entry))
+	}
+	payload, err := json.Marshal(entry)
+	if err != nil {
+		return nil, err
+	}
+	return c.seal(cacheEncodingJSON, payload)
+}
+
+// decodeEntry : запись документа; errUnknownCacheEnvelope, если формат записи неизвестен
+func (c cacheCodec) decodeEntry(data []byte) (*cacheEntry, error) {
+	encoding, payload, err := c.open(data)
+	if err != nil {
+		return nil, err
+	}
+
+	var entry cacheEntry
+	switch encoding {
+	case cacheEncodingBinary:
+		err = unmarshalEntryBinary(payload, &entry)
+	case cacheEncodingJSON:
+		err = json.Unmarshal(payload, &entry)
+	default:
+		return nil, errUnknownCacheEnvelope
+	}
+	if err != nil {
+		return nil, err
+	}
+	if entry.Document == nil {
+		return nil, errUnknownCacheEnvelope
+	}
+	return &entry, nil
+}
+
+// encodeJSON : произвольное значение в конверте с JSON-нагрузкой
+func (c cacheCodec) encodeJSON(value any) ([]byte, error) {
+	payload, err := json.Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+	return c.seal(cacheEncodingJSON, payload)
+}
+
+func (c cacheCodec) decodeJSON(data []byte, value any) error {
+	encoding, payload, err := c.open(data)
+	if err != nil {
+		return err
+	}
+	if encoding != cacheEncodingJSON {
+		return errUnknownCacheEnvelope
+	}
+	return json.Unmarshal(payload, value)
+}
+
+// describe : расшифровка записи для администратора. Значения без конверта (маркеры, версии, счётчики)
+// возвращаются как есть с кодировкой raw
+func (c cacheCodec) describe(data []byte) (string, bool, any, error) {
+	encoding, payload, err := c.open(data)
+	if errors.Is(err, errUnknownCacheEnvelope) {
+		return "raw", false, string(data), nil
+	} else if err != nil {
+		return "", false, nil, err
+	}
+
+	compressed := data[3]&cacheFlagCompressed != 0
+	switch encoding {
+	case cacheEncodingBinary:
+		var entry cacheEntry
+		if err := unmarshalEntryBinary(payload, &entry); err != nil {
+			return "", compressed, nil, err
+		}
+		return "binary", compressed, entry, nil
+	case cacheEncodingJSON:
+		return "json", compressed, json.RawMessage(payload), nil
+	default:
+		return "raw", false, string(data), nil
+	}
+}
+
+// seal : добавляет заголовок и сжимает нагрузку больше порога, если это действительно уменьшает запись
+func (c cacheCodec) seal(encoding cacheEncoding, payload []byte) ([]byte, error) {
+	flags := byte(0)
+	if c.compressThreshold > 0 && len(payload) > c.compressThreshold {
+		compressed, err := deflate(payload)
+		if err != nil {
+			return nil, err
+		}
+		if len(compressed) < len(payload) {
+			payload = compressed
+			flags |= cacheFlagCompressed
+		}
+	}
+
+	data := make([]byte, 0, cacheEnvelopeHeaderSize+len(payload))
+	data = append(data, cacheEnvelopeMagic, cacheSchemaVersion, byte(encoding), flags)
+	return append(data, payload...), nil
+}
+
+func (c cacheCodec) open(data []byte) (cacheEncoding, []byte, error) {
+	if len(data) < cacheEnvelopeHeaderSize || data[0] != cacheEnvelopeMagic || data[1] != cacheSchemaVersion {
+		return 0, nil, errUnknownCacheEnvelope
+	}
+
+	encoding, flags, payload := cacheEncoding(data[2]), data[3], data[cacheEnvelopeHeaderSize:]
+	if flags&^cacheFlagCompressed != 0 {
+		return 0, nil, errUnknownCacheEnvelope
+	}
+	if flags&cacheFlagCompressed != 0 {
+		inflated, err := io.ReadAll(flate.NewReader(bytes.NewReader(payload)))
+		if err != nil {
+			return 0, nil, fmt.Errorf("[CacheRepo] ошибка распаковки записи кэша: %w", err)
+		}
+		payload = inflated
+	}
+	return encoding, payload, nil
+}
+
+func deflate(payload []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	writer, err := flate.NewWriter(&buf, flate.BestSpeed)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := writer.Write(payload); err != nil {
+		return nil, err
+	}
+	if err := writer.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// marshalEntryBinary : компактная бинарная запись документа. Порядок полей — часть схемы,
+// при его изменении нужно увеличить cacheSchemaVersion
+func marshalEntryBinary(entry cacheEntry) []byte {
+	var w binaryWriter
+	w.time(entry.StoredAt)
+	w.time(entry.FreshUntil)
+
+	d := entry.Document
+	w.string(d.UUID)
+	w.string(d.OwnerUUID)
+	w.string(d.FilenameOriginal)
+	w.varint(d.SizeBytes)
+	w.string(d.MimeType)
+	w.string(d.Sha256)
+	w.string(d.StoragePath)
+	w.bool(d.IsFile)
+	w.bool(d.IsPublic)
+	w.string(d.AccessToken)
+	w.strings(d.GrantLogins)
+	w.varint(int64(d.Version))
+	w.time(d.CreatedAt)
+	w.time(d.UpdatedAt)
+	w.optionalTime(d.DeletedAt)
+	w.optionalTime(d.ExpiresAt)
+	w.bool(d.LegalHold)
+	return w.buf
+}
+
+func unmarshalEntryBinary(data []byte, entry *cacheEntry) error {
+	r := binaryReader{data: data}
+	entry.StoredAt = r.time()
+	entry.FreshUntil = r.time()
+
+	d := &model.Document{}
+	d.UUID = r.string()
+	d.OwnerUUID = r.string()
+	d.FilenameOriginal = r.string()
+	d.SizeBytes = r.varint()
+	d.MimeType = r.string()
+	d.Sha256 = r.string()
+	d.StoragePath = r.string()
+	d.IsFile = r.bool()
+	d.IsPublic = r.bool()
+	d.AccessToken = r.string()
+	d.GrantLogins = r.strings()
+	d.Version = int(r.varint())
+	d.CreatedAt = r.time()
+	d.UpdatedAt = r.time()
+	d.DeletedAt = r.optionalTime()
+	d.ExpiresAt = r.optionalTime()
+	d.LegalHold = r.bool()
+
+	if r.err == nil && len(r.data) != 0 {
+		r.err = errors.New("лишние байты в записи")
+	}
+	if r.err != nil {
+		return fmt.Errorf("[CacheRepo] повреждённая бинарная запись кэша: %w", r.err)
+	}
+	entry.Document = d
+	return nil
+}
+
+type binaryWriter struct {
+	buf []byte
+}
+
+func (w *binaryWriter) uvarint(v uint64) { w.buf = binary.AppendUvarint(w.buf, v) }
+func (w *binaryWriter) varint(v int64)   { w.buf = binary.AppendVarint(w.buf, v) }
+
+func (w *binaryWriter) bytes(b []byte) {
+	w.uvarint(uint64(len(b)))
+	w.buf = append(w.buf, b...)
+}
+
+func (w *binaryWriter) string(s string) {
+	w.uvarint(uint64(len(s)))
+	w.buf = append(w.buf, s...)
+}
+
+func (w *binaryWriter) bool(b bool) {
+	if b {
+		w.buf = append(w.buf, 1)
+	} else {
+		w.buf = append(w.buf, 0)
+	}
+}
+
+func (w *binaryWriter) strings(values []string) {
+	w.uvarint(uint64(len(values)))
+	for _, value := range values {
+		w.string(value)
+	}
+}
+
+// time : MarshalBinary сохраняет смещение часового пояса, как и JSON
+func (w *binaryWriter) time(t time.Time) {
+	data, _ := t.MarshalBinary()
+	w.bytes(data)
+}
+
+func (w *binaryWriter) optionalTime(t *time.Time) {
+	w.bool(t != nil)
+	if t != nil {
+		w.time(*t)
+	}
+}
+
+// binaryReader : читает поля по порядку; первая ошибка запоминается, последующие чтения возвращают нули
+type binaryReader struct {
+	data []byte
+	err  error
+}
+
+func (r *binaryReader) fail(err error) {
+	if r.err == nil {
+		r.err = err
+	}
+	r.data = nil
+}
+
+func (r *binaryReader) uvarint() uint64 {
+	v, n := binary.Uvarint(r.data)
+	if n <= 0 {
+		r.fail(errors.New("неверный uvarint"))
+		return 0
+	}
+	r.data = r.data[n:]
+	return v
+}
+
+func (r *binaryReader) varint() int64 {
+	v, n := binary.Varint(r.data)
+	if n <= 0 {
+		r.fail(errors.New("неверный varint"))
+		return 0
+	}
+	r.data = r.data[n:]
+	return v
+}
+
+func (r *binaryReader) bytes() []byte {
+	n := r.uvarint()
+	if n > uint64(len(r.data)) {
+		r.fail(errors.New("длина поля больше записи"))
+		return nil
+	}
+	b := r.data[:n]
+	r.data = r.data[n:]
+	return b
+}
+
+func (r *binaryReader) string() string {
+	return string(r.bytes())
+}
+
+func (r *binaryReader) bool() bool {
+	if len(r.data) == 0 {
+		r.fail(errors.New("запись обрезана"))
+		return false
+	}
+	b := r.data[0]
+	r.data = r.data[1:]
+	return b == 1
+}
+
+func (r *binaryReader) strings() []string {
+	n := r.uvarint()
+	if n == 0 || r.err != nil {
+		return nil
+	}
+	if n > uint64(len(r.data)) {
+		r.fail(errors.New("длина списка больше записи"))
+		return nil
+	}
+	values := make([]string, 0, n)
+	for i := uint64(0); i < n; i++ {
+		values = append(values, r.string())
+	}
+	return values
+}
+
+func (r *binaryReader) time() time.Time {
+	var t time.Time
+	data := r.bytes()
+	if r.err != nil {
+		return t
+	}
+	if err := t.UnmarshalBinary(data); err != nil {
+		r.fail(err)
+	}
+	return t
+}
+
+func (r *binaryReader) optionalTime() *time.Time {
+	if r.bool() == false {
+		return nil
+	}
+	t := r.time()
+	return &t
+}
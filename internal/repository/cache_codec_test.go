@@ -0,0 +1,164 @@
+package repository
+
+import (
+	"caching-web-server/internal/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// filledDocument : документ, у которого заполнено каждое поле. Поля перебираются через reflect,
+// поэтому новое поле model.Document тоже окажется заполненным — и тест упадёт, пока его не добавят в кодек
+func filledDocument(t *testing.T) *model.Document {
+	t.Helper()
+	stamp := time.Date(2024, 3, 1, 12, 30, 45, 123456789, time.UTC)
+
+	document := &model.Document{}
+	value := reflect.ValueOf(document).Elem()
+	for i := 0; i < value.NumField(); i++ {
+		field, name := value.Field(i), value.Type().Field(i).Name
+		switch field.Kind() {
+		case reflect.String:
+			field.SetString(name + "-value")
+		case reflect.Bool:
+			field.SetBool(true)
+		case reflect.Int, reflect.Int64:
+			field.SetInt(int64(i + 1))
+		case reflect.Slice:
+			require.Equal(t, reflect.String, field.Type().Elem().Kind(), "поле %s: неизвестный тип среза", name)
+			field.Set(reflect.ValueOf([]string{name + "-a", name + "-b"}))
+		case reflect.Struct:
+			require.Equal(t, reflect.TypeOf(time.Time{}), field.Type(), "поле %s: неизвестный тип", name)
+			field.Set(reflect.ValueOf(stamp.Add(time.Duration(i) * time.Hour)))
+		case reflect.Ptr:
+			require.Equal(t, reflect.TypeOf(&time.Time{}), field.Type(), "поле %s: неизвестный тип", name)
+			at := stamp.Add(time.Duration(i) * time.Hour)
+			field.Set(reflect.ValueOf(&at))
+		default:
+			t.Fatalf("поле %s: тип %s не поддержан тестом кодека", name, field.Kind())
+		}
+	}
+	return document
+}
+
+func TestCacheCodec_BinaryCoversEveryDocumentField(t *testing.T) {
+	document := filledDocument(t)
+	now := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+
+	var entry cacheEntry
+	require.NoError(t, unmarshalEntryBinary(marshalEntryBinary(cacheEntry{StoredAt: now, FreshUntil: now.Add(time.Minute), Document: document}), &entry))
+
+	value, decoded := reflect.ValueOf(document).Elem(), reflect.ValueOf(entry.Document).Elem()
+	for i := 0; i < value.NumField(); i++ {
+		name := value.Type().Field(i).Name
+		assert.False(t, decoded.Field(i).IsZero(), "поле %s не сохраняется в бинарной записи — добавьте его в кодек и увеличьте cacheSchemaVersion", name)
+		assert.Equal(t, value.Field(i).Interface(), decoded.Field(i).Interface(), "поле %s", name)
+	}
+	assert.Equal(t, now, entry.StoredAt)
+	assert.Equal(t, now.Add(time.Minute), entry.FreshUntil)
+}
+
+func TestCacheCodec_EntryRoundTrip(t *testing.T) {
+	now := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	large := filledDocument(t)
+	large.FilenameOriginal = strings.Repeat("a", 4096)
+
+	cases := []struct {
+		name     string
+		encoding string
+		document *model.Document
+	}{
+		{"binary", "binary", filledDocument(t)},
+		{"binary со сжатием", "binary", large},
+		{"json", "json", filledDocument(t)},
+		{"json со сжатием", "json", large},
+		{"binary без необязательных полей", "binary", &model.Document{UUID: "doc1"}},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			codec := newCacheCodec(tc.encoding, 1024)
+			data, err := codec.encodeEntry(cacheEntry{StoredAt: now, FreshUntil: now.Add(time.Minute), Document: tc.document})
+			require.NoError(t, err)
+			assert.Equal(t, tc.document == large, data[3]&cacheFlagCompressed != 0)
+
+			// читатель декодирует любую известную кодировку, независимо от своей настройки
+			for _, reader := range []cacheCodec{newCacheCodec("binary", 0), newCacheCodec("json", 0)} {
+				entry, err := reader.decodeEntry(data)
+				require.NoError(t, err)
+				assert.Equal(t, tc.document, entry.Document)
+				assert.True(t, now.Equal(entry.StoredAt))
+			}
+		})
+	}
+}
+
+func TestCacheCodec_UnknownEnvelopeIsMiss(t *testing.T) {
+	codec := newCacheCodec("binary", 0)
+	data, err := codec.encodeEntry(cacheEntry{Document: &model.Document{UUID: "doc1"}})
+	require.NoError(t, err)
+
+	otherVersion := append([]byte(nil), data...)
+	otherVersion[1] = cacheSchemaVersion + 1
+	unknownFlags := append([]byte(nil), data...)
+	unknownFlags[3] |= 1 << 7
+
+	for name, value := range map[string][]byte{
+		"другая версия схемы":   otherVersion,
+		"неизвестный флаг":      unknownFlags,
+		"значение без конверта": []byte("doc1"),
+		"пустое значение":       nil,
+	} {
+		_, err := codec.decodeEntry(value)
+		assert.ErrorIs(t, err, errUnknownCacheEnvelope, name)
+
+		var uuid string
+		assert.ErrorIs(t, codec.decodeJSON(value, &uuid), errUnknownCacheEnvelope, name)
+	}
+}
+
+func TestCacheCodec_TruncatedBinaryEntry(t *testing.T) {
+	data := marshalEntryBinary(cacheEntry{Document: filledDocument(t)})
+
+	for _, size := range []int{0, 1, len(data) / 2, len(data) - 1} {
+		var entry cacheEntry
+		assert.Error(t, unmarshalEntryBinary(data[:size], &entry), "длина %d", size)
+		assert.Nil(t, entry.Document)
+	}
+
+	var entry cacheEntry
+	assert.Error(t, unmarshalEntryBinary(append(data, 0), &entry), "лишние байты")
+}
+
+func TestCacheCodec_JSONValuesRoundTrip(t *testing.T) {
+	codec := newCacheCodec("binary", 16)
+
+	// UUID по токену и pre-signed ссылка хранятся в конверте с JSON-нагрузкой при любой настроенной кодировке
+	data, err := codec.encodeJSON("doc1")
+	require.NoError(t, err)
+	assert.Equal(t, byte(cacheEncodingJSON), data[2])
+	var uuid string
+	require.NoError(t, codec.decodeJSON(data, &uuid))
+	assert.Equal(t, "doc1", uuid)
+
+	url := model.PresignedURL{URL: "http://storage/" + strings.Repeat("x", 256), ExpiresAt: time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)}
+	data, err = codec.encodeJSON(url)
+	require.NoError(t, err)
+	var decoded model.PresignedURL
+	require.NoError(t, codec.decodeJSON(data, &decoded))
+	assert.Equal(t, url, decoded)
+
+	// бинарная запись документа не читается как JSON-значение
+	data, err = codec.encodeEntry(cacheEntry{Document: &model.Document{UUID: "doc1"}})
+	require.NoError(t, err)
+	assert.ErrorIs(t, codec.decodeJSON(data, &uuid), errUnknownCacheEnvelope)
+}
+
+func TestGrantSetLoadedMarker_CarriesSchemaVersion(t *testing.T) {
+	// множество от сборки с другой версией схемы не содержит текущего маркера и считается неполным
+	assert.NotEqual(t, "#loaded", grantSetLoadedMarker)
+	assert.True(t, strings.HasSuffix(grantSetLoadedMarker, ":v"+strconv.Itoa(int(cacheSchemaVersion))))
+}
@@ -0,0 +1,117 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+	"log"
+	"strings"
+	"time"
+)
+
+const (
+	invalidationChannel = "document:invalidate"
+	flushAllMarker      = "*"
+)
+
+// LocalCacheListener : локальный кэш реплики, который шина держит в актуальном состоянии
+type LocalCacheListener interface {
+	InvalidateLocal(uuid string)
+	// SetLocalActive : false — сообщения могли быть потеряны, локальный кэш нужно очистить и не использовать
+	SetLocalActive(active bool)
+}
+
+// CacheInvalidationBus : рассылка инвалидаций документов между репликами через Redis pub/sub.
+// Pub/sub не гарантирует доставку, поэтому при любом обрыве подписки локальный кэш очищается
+// и отключается до восстановления подписки
+type CacheInvalidationBus struct {
+	client    redis.UniversalClient
+	replicaID string
+}
+
+func NewCacheInvalidationBus(rdb redis.UniversalClient) *CacheInvalidationBus {
+	return &CacheInvalidationBus{client: rdb, replicaID: uuid.New().String()}
+}
+
+// Publish : сообщает всем репликам, что документ изменился
+func (b *CacheInvalidationBus) Publish(ctx context.Context, documentUUID string) error {
+	return b.client.Publish(ctx, invalidationChannel, b.replicaID+"|"+documentUUID).Err()
+}
+
+// PublishFlush : просит все реплики очистить локальный кэш целиком
+func (b *CacheInvalidationBus) PublishFlush(ctx context.Context) error {
+	return b.Publish(ctx, flushAllMarker)
+}
+
+// Run : подписывается на канал и передаёт инвалидации в listener, пока не отменён ctx.
+// При разрыве соединения переподключается с экспоненциальной задержкой
+func (b *CacheInvalidationBus) Run(ctx context.Context, listener LocalCacheListener) {
+	listener.SetLocalActive(false)
+	backoff := 100 * time.Millisecond
+
+	for ctx.Err() == nil {
+		err := b.listen(ctx, listener)
+		listener.SetLocalActive(false)
+		if ctx.Err() != nil {
+			return
+		}
+
+		log.Printf("[CacheBus] подписка на инвалидации потеряна, локальный кэш отключён: %v", err)
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(backoff):
+		}
+		if backoff < 5*time.Second {
+			backoff *= 2
+		}
+	}
+}
+
+func (b *CacheInvalidationBus) listen(ctx context.Context, listener LocalCacheListener) error {
+	pubsub := b.client.Subscribe(ctx, invalidationChannel)
+	defer pubsub.Close()
+
+	// дожидаемся подтверждения подписки: только после него можно доверять локальному кэшу
+	if _, err := pubsub.Receive(ctx); err != nil {
+		return err
+	}
+	listener.SetLocalActive(true)
+	log.Printf("[CacheBus] подписка на инвалидации активна (реплика %s)", b.replicaID)
+
+	for {
+		msg, err := pubsub.Receive(ctx)
+		if err != nil {
+			return err
+		}
+
+		switch m := msg.(type) {
+		case *redis.Message:
+			b.handle(m.Payload, listener)
+		case *redis.Subscription:
+			if m.Kind == "unsubscribe" {
+				return errors.New("подписка отменена сервером")
+			}
+		case *redis.Pong:
+		}
+	}
+}
+
+func (b *CacheInvalidationBus) handle(payload string, listener LocalCacheListener) {
+	sender, documentUUID, ok := strings.Cut(payload, "|")
+	if !ok {
+		return
+	}
+
+	if documentUUID == flushAllMarker {
+		listener.SetLocalActive(false)
+		listener.SetLocalActive(true)
+		return
+	}
+	// свой локальный кэш реплика уже инвалидировала сама
+	if sender == b.replicaID {
+		return
+	}
+	listener.InvalidateLocal(documentUUID)
+}
@@ -0,0 +1,278 @@
+package repository
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"math/rand"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// границы корзин гистограмм; последняя корзина — всё, что больше
+var (
+	cacheLatencyBucketsMs = []float64{0.5, 1, 2, 5, 10, 25, 50, 100, 250, 500, 1000}
+	cacheSizeBucketsBytes = []float64{64, 256, 1024, 4096, 16384, 65536, 262144, 1048576}
+)
+
+// уровни кэша в метриках
+const (
+	cacheTierLocal  = "l1"
+	cacheTierRemote = "l2"
+)
+
+// cacheKeyNamespaces : пространства ключей кэша документов и отзыва токенов, от более длинного префикса к более короткому
+var cacheKeyNamespaces = []struct {
+	prefix    string
+	namespace string
+}{
+	{popularityKey, "popularity"},
+	{"document:invalidate", "invalidation"},
+	{"document:token:missing:", "token_missing"},
+	{"document:token:", "token"},
+	{"document:missing:", "missing"},
+	{"document:presign:", "presign"},
+	{"document:public:", "public_document"},
+	{listKeyPrefix, "list"},
+	{"document:grants:version:", "grant_version"},
+	{"document:grants:", "grants"},
+	{"document:version:", "version"},
+	{"document:lock:", "lock"},
+	{"document:load:", "load_lock"},
+	{"document:", "document"},
+	{"auth:denied:", "token_denylist"},
+	{"auth:issued-before:", "token_issued_before"},
+}
+
+// cacheOutcome : решение репозитория по одному обращению к кэшу
+type cacheOutcome uint8
+
+const (
+	cacheHit   cacheOutcome = iota
+	cacheMiss               // записи нет или она в неизвестном формате
+	cacheStale              // запись есть, но уже не свежая — для чтения свежих копий это промах
+	cacheError              // ошибка Redis или повреждённая запись
+	cacheSet
+	cacheEvict
+)
+
+func (o cacheOutcome) String() string {
+	return [...]string{"hit", "miss", "stale", "error", "set", "evict"}[o]
+}
+
+// CacheMetricsOptions : настройки метрик кэша
+type CacheMetricsOptions struct {
+	DebugSampleRate float64 // доля обращений, попадающих в отладочный лог, 0 — лог выключен
+}
+
+// CacheMetrics : обращения к кэшу по уровню (L1, L2), пространству ключей и операции — попадания, промахи,
+// устаревшие копии, ошибки, записи, удаления, задержка и размер значений. Исход определяет репозиторий,
+// а не ответ Redis: устаревшая или нечитаемая запись — не попадание. Счётчики атомарные, общей блокировки нет.
+// Нулевой *CacheMetrics ничего не считает
+type CacheMetrics struct {
+	options CacheMetricsOptions
+	stats   sync.Map // cacheMetricsKey -> *cacheOperationCounters
+}
+
+type cacheMetricsKey struct {
+	tier      string
+	namespace string
+	operation string
+}
+
+type cacheOperationCounters struct {
+	calls, hits, misses, stale, errors, sets, evictions atomic.Uint64
+	latency                                             *histogram
+	valueSize                                           *histogram
+}
+
+// CacheOperationStats : счётчики одной операции в одном пространстве ключей одного уровня
+type CacheOperationStats struct {
+	Tier      string         `json:"tier"`
+	Namespace string         `json:"namespace"`
+	Operation string         `json:"operation"`
+	Calls     uint64         `json:"calls"`
+	Hits      uint64         `json:"hits,omitempty"`
+	Misses    uint64         `json:"misses,omitempty"`
+	Stale     uint64         `json:"stale,omitempty"`
+	Errors    uint64         `json:"errors,omitempty"`
+	Sets      uint64         `json:"sets,omitempty"`
+	Evictions uint64         `json:"evictions,omitempty"` // сколько ключей удалено
+	LatencyMs HistogramStats `json:"latency_ms"`
+	// ValueBytes : размер записанных и прочитанных значений
+	ValueBytes *HistogramStats `json:"value_bytes,omitempty"`
+}
+
+// CacheNamespaceStats : попадания и промахи по пространству ключей за всё время работы; устаревшие копии — промахи
+type CacheNamespaceStats struct {
+	Hits     uint64  `json:"hits"`
+	Misses   uint64  `json:"misses"`
+	HitRatio float64 `json:"hit_ratio"`
+}
+
+// CacheMetricsSnapshot : метрики кэша для /debug/vars. Namespaces — по ключу "уровень:пространство", например "l1:document"
+type CacheMetricsSnapshot struct {
+	Namespaces map[string]CacheNamespaceStats `json:"namespaces"`
+	Operations []CacheOperationStats          `json:"operations"`
+}
+
+// HistogramStats : кумулятивные корзины вида "le" -> количество, как в Prometheus
+type HistogramStats struct {
+	Count   uint64            `json:"count"`
+	Sum     float64           `json:"sum"`
+	Buckets map[string]uint64 `json:"buckets"`
+}
+
+type histogram struct {
+	bounds []float64
+	counts []atomic.Uint64 // последняя — больше последней границы
+	count  atomic.Uint64
+	sum    atomic.Uint64 // math.Float64bits суммы
+}
+
+func newHistogram(bounds []float64) *histogram {
+	return &histogram{bounds: bounds, counts: make([]atomic.Uint64, len(bounds)+1)}
+}
+
+func (h *histogram) observe(value float64) {
+	h.counts[sort.SearchFloat64s(h.bounds, value)].Add(1)
+	h.count.Add(1)
+	for {
+		old := h.sum.Load()
+		if h.sum.CompareAndSwap(old, math.Float64bits(math.Float64frombits(old)+value)) {
+			return
+		}
+	}
+}
+
+// snapshot : счётчики читаются без блокировки, поэтому сумма корзин может на единицы расходиться с count
+func (h *histogram) snapshot() HistogramStats {
+	stats := HistogramStats{Count: h.count.Load(), Sum: math.Float64frombits(h.sum.Load()), Buckets: make(map[string]uint64, len(h.counts))}
+	var cumulative uint64
+	for i := range h.counts {
+		cumulative += h.counts[i].Load()
+		le := "+Inf"
+		if i < len(h.bounds) {
+			le = strconv.FormatFloat(h.bounds[i], 'f', -1, 64)
+		}
+		stats.Buckets[le] = cumulative
+	}
+	return stats
+}
+
+func NewCacheMetrics(options CacheMetricsOptions) *CacheMetrics {
+	return &CacheMetrics{options: options}
+}
+
+// observe : учитывает одно обращение. n — размер значения (для hit, stale и set) или число удалённых ключей (для evict),
+// -1 — неизвестно. В отладочный лог пишется только пространство ключей: сами ключи содержат публичные токены
+func (m *CacheMetrics) observe(tier, namespace, operation string, outcome cacheOutcome, elapsed time.Duration, n int) {
+	if m == nil {
+		return
+	}
+
+	key := cacheMetricsKey{tier, namespace, operation}
+	value, ok := m.stats.Load(key)
+	if ok == false {
+		value, _ = m.stats.LoadOrStore(key, &cacheOperationCounters{latency: newHistogram(cacheLatencyBucketsMs), valueSize: newHistogram(cacheSizeBucketsBytes)})
+	}
+	counters := value.(*cacheOperationCounters)
+
+	counters.calls.Add(1)
+	counters.latency.observe(float64(elapsed.Microseconds()) / 1000)
+	switch outcome {
+	case cacheHit:
+		counters.hits.Add(1)
+	case cacheMiss:
+		counters.misses.Add(1)
+	case cacheStale:
+		counters.stale.Add(1)
+	case cacheError:
+		counters.errors.Add(1)
+	case cacheSet:
+		counters.sets.Add(1)
+	case cacheEvict:
+		if n > 0 {
+			counters.evictions.Add(uint64(n))
+		}
+	}
+	if n >= 0 && outcome != cacheEvict && outcome != cacheMiss && outcome != cacheError {
+		counters.valueSize.observe(float64(n))
+	}
+
+	if m.options.DebugSampleRate > 0 && rand.Float64() < m.options.DebugSampleRate {
+		log.Printf("[CacheMetrics] %s %s %s: %s за %s%s", tier, operation, namespace, outcome, elapsed, sizeSuffix(outcome, n))
+	}
+}
+
+// Snapshot : копия метрик для /debug/vars
+func (m *CacheMetrics) Snapshot() CacheMetricsSnapshot {
+	snapshot := CacheMetricsSnapshot{Namespaces: map[string]CacheNamespaceStats{}, Operations: []CacheOperationStats{}}
+	m.stats.Range(func(k, v any) bool {
+		key, counters := k.(cacheMetricsKey), v.(*cacheOperationCounters)
+		stats := CacheOperationStats{
+			Tier:      key.tier,
+			Namespace: key.namespace,
+			Operation: key.operation,
+			Calls:     counters.calls.Load(),
+			Hits:      counters.hits.Load(),
+			Misses:    counters.misses.Load(),
+			Stale:     counters.stale.Load(),
+			Errors:    counters.errors.Load(),
+			Sets:      counters.sets.Load(),
+			Evictions: counters.evictions.Load(),
+			LatencyMs: counters.latency.snapshot(),
+		}
+		if counters.valueSize.count.Load() > 0 {
+			valueSize := counters.valueSize.snapshot()
+			stats.ValueBytes = &valueSize
+		}
+		snapshot.Operations = append(snapshot.Operations, stats)
+
+		if misses := stats.Misses + stats.Stale; stats.Hits+misses > 0 {
+			name := key.tier + ":" + key.namespace
+			namespace := snapshot.Namespaces[name]
+			namespace.Hits += stats.Hits
+			namespace.Misses += misses
+			namespace.HitRatio = float64(namespace.Hits) / float64(namespace.Hits+namespace.Misses)
+			snapshot.Namespaces[name] = namespace
+		}
+		return true
+	})
+
+	sort.Slice(snapshot.Operations, func(i, j int) bool {
+		a, b := snapshot.Operations[i], snapshot.Operations[j]
+		if a.Tier != b.Tier {
+			return a.Tier < b.Tier
+		}
+		if a.Namespace != b.Namespace {
+			return a.Namespace < b.Namespace
+		}
+		return a.Operation < b.Operation
+	})
+	return snapshot
+}
+
+func cacheKeyNamespace(key string) string {
+	for _, namespace := range cacheKeyNamespaces {
+		if strings.HasPrefix(key, namespace.prefix) {
+			return namespace.namespace
+		}
+	}
+	return "other"
+}
+
+func sizeSuffix(outcome cacheOutcome, n int) string {
+	switch {
+	case n < 0:
+		return ""
+	case outcome == cacheEvict:
+		return fmt.Sprintf(", ключей %d", n)
+	default:
+		return fmt.Sprintf(", %d байт", n)
+	}
+}
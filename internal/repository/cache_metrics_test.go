@@ -0,0 +1,138 @@
+package repository
+
+import (
+	"bytes"
+	"caching-web-server/internal/model"
+	"context"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"log"
+	"sync"
+	"testing"
+	"time"
+)
+
+// operationStats : счётчики операции из снимка метрик
+func operationStats(t *testing.T, metrics *CacheMetrics, tier, namespace, operation string) CacheOperationStats {
+	t.Helper()
+	for _, stats := range metrics.Snapshot().Operations {
+		if stats.Tier == tier && stats.Namespace == namespace && stats.Operation == operation {
+			return stats
+		}
+	}
+	t.Fatalf("нет метрик %s %s %s", tier, operation, namespace)
+	return CacheOperationStats{}
+}
+
+func newMetricsTestRepository(t *testing.T, metrics *CacheMetrics) (*CacheRepository, *fakeRedis) {
+	client, fake := newFakeRedisClient(t)
+	client.AddHook(fake)
+	repo := NewCacheRepository(client, time.Minute, nil, CacheOptions{GrantTTL: time.Minute, Metrics: metrics})
+	return repo, fake
+}
+
+// putEntry : кладёт в fakeRedis запись документа, свежую до freshUntil
+func putEntry(t *testing.T, repo *CacheRepository, fake *fakeRedis, uuid string, freshUntil time.Time) []byte {
+	t.Helper()
+	data, err := repo.codec.encodeEntry(cacheEntry{StoredAt: time.Now(), FreshUntil: freshUntil, Document: &model.Document{UUID: uuid}})
+	require.NoError(t, err)
+	fake.values[repo.key(uuid)] = string(data)
+	return data
+}
+
+func TestCacheMetrics_CountsRepositoryOutcomes(t *testing.T) {
+	ctx := context.Background()
+	metrics := NewCacheMetrics(CacheMetricsOptions{})
+	repo, fake := newMetricsTestRepository(t, metrics)
+
+	putEntry(t, repo, fake, "fresh", time.Now().Add(time.Minute))
+	putEntry(t, repo, fake, "stale", time.Now().Add(-time.Second))
+	fake.values[repo.key("foreign")] = "значение без конверта"
+	corrupt := putEntry(t, repo, fake, "corrupt", time.Now().Add(time.Minute))
+	fake.values[repo.key("corrupt")] = string(corrupt[:len(corrupt)-3])
+
+	for _, uuid := range []string{"fresh", "stale", "foreign", "absent"} {
+		_, err := repo.GetDocumentEntry(ctx, uuid)
+		require.NoError(t, err, uuid)
+	}
+	_, err := repo.GetDocumentEntry(ctx, "corrupt")
+	assert.Error(t, err)
+
+	// устаревшая копия — не попадание, запись чужого формата и отсутствующая — промахи, повреждённая — ошибка
+	stats := operationStats(t, metrics, cacheTierRemote, "document", "get")
+	assert.Equal(t, uint64(5), stats.Calls)
+	assert.Equal(t, uint64(1), stats.Hits)
+	assert.Equal(t, uint64(1), stats.Stale)
+	assert.Equal(t, uint64(2), stats.Misses)
+	assert.Equal(t, uint64(1), stats.Errors)
+	assert.Equal(t, CacheNamespaceStats{Hits: 1, Misses: 3, HitRatio: 0.25}, metrics.Snapshot().Namespaces["l2:document"])
+
+	// проверка grant идёт скриптом, но тоже считается
+	_, cached, err := repo.IsGranted(ctx, "doc1", "user1")
+	require.NoError(t, err)
+	assert.True(t, cached)
+	assert.Equal(t, uint64(1), operationStats(t, metrics, cacheTierRemote, "grants", "ismember").Hits)
+
+	require.NoError(t, repo.DeleteDocument(ctx, "fresh"))
+	assert.NotZero(t, operationStats(t, metrics, cacheTierRemote, "document", "del").Evictions)
+}
+
+func TestCacheMetrics_TieredCountsLocalHits(t *testing.T) {
+	ctx := context.Background()
+	metrics := NewCacheMetrics(CacheMetricsOptions{})
+	repo, fake := newMetricsTestRepository(t, metrics)
+	putEntry(t, repo, fake, "doc1", time.Now().Add(time.Minute))
+	tiered := NewTieredCacheRepository(repo, 100, 0, time.Minute, metrics)
+
+	for i := 0; i < 3; i++ {
+		entry, err := tiered.GetDocumentEntry(ctx, "doc1")
+		require.NoError(t, err)
+		require.NotNil(t, entry)
+	}
+
+	local := operationStats(t, metrics, cacheTierLocal, "document", "get")
+	assert.Equal(t, uint64(2), local.Hits)
+	assert.Equal(t, uint64(1), local.Misses)
+	assert.Equal(t, uint64(1), operationStats(t, metrics, cacheTierRemote, "document", "get").Hits)
+}
+
+func TestCacheMetrics_DebugLogOmitsKeys(t *testing.T) {
+	var buf bytes.Buffer
+	previous := log.Writer()
+	log.SetOutput(&buf)
+	t.Cleanup(func() { log.SetOutput(previous) })
+
+	metrics := NewCacheMetrics(CacheMetricsOptions{DebugSampleRate: 1})
+	repo, _ := newMetricsTestRepository(t, metrics)
+	_, err := repo.GetPublicTokenUUID(context.Background(), "secret-public-token")
+	require.NoError(t, err)
+
+	assert.Contains(t, buf.String(), "token")
+	assert.NotContains(t, buf.String(), "secret-public-token")
+}
+
+func TestCacheMetrics_ConcurrentObserve(t *testing.T) {
+	metrics := NewCacheMetrics(CacheMetricsOptions{})
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 1000; j++ {
+				metrics.observe(cacheTierRemote, "document", "get", cacheHit, time.Millisecond, 100)
+			}
+		}()
+	}
+	wg.Wait()
+
+	stats := operationStats(t, metrics, cacheTierRemote, "document", "get")
+	assert.Equal(t, uint64(8000), stats.Hits)
+	assert.Equal(t, uint64(8000), stats.LatencyMs.Count)
+	assert.InDelta(t, 8000.0, stats.LatencyMs.Sum, 1e-6)
+	assert.Equal(t, uint64(8000), stats.ValueBytes.Buckets["256"])
+
+	// нулевые метрики ничего не считают и не падают
+	var disabled *CacheMetrics
+	disabled.observe(cacheTierRemote, "document", "get", cacheHit, time.Millisecond, 100)
+}
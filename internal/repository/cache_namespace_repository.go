@@ -0,0 +1,179 @@
+package repository
+
+import (
+	"caching-web-server/internal/util"
+	"context"
+	"github.com/redis/go-redis/v9"
+	"log"
+	"sync"
+)
+
+const (
+	maxPendingInvalidations  = 10000
+	documentNamespacePattern = "document:*"
+	scanBatchSize            = 1000
+)
+
+// pendingInvalidations : документы и владельцы, инвалидацию которых (копий документов и страниц списков
+// владельца) не удалось записать в Redis. При переполнении помнить отдельные ключи бессмысленно —
+// после восстановления очищается всё пространство
+type pendingInvalidations struct {
+	mu       sync.Mutex
+	uuids    map[string]struct{}
+	owners   map[string]struct{}
+	overflow bool
+}
+
+func newPendingInvalidations() *pendingInvalidations {
+	return &pendingInvalidations{uuids: make(map[string]struct{}), owners: make(map[string]struct{})}
+}
+
+func (p *pendingInvalidations) add(uuids ...string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.addLocked(p.uuids, uuids)
+}
+
+// addOwners : владельцы, страницы списков которых не удалось удалить
+func (p *pendingInvalidations) addOwners(ownerUUIDs ...string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.addLocked(p.owners, ownerUUIDs)
+}
+
+func (p *pendingInvalidations) addLocked(set map[string]struct{}, values []string) {
+	for _, value := range values {
+		if len(p.uuids)+len(p.owners) >= maxPendingInvalidations {
+			p.overflow = true
+			return
+		}
+		set[value] = struct{}{}
+	}
+}
+
+func (p *pendingInvalidations) markOverflow() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.overflow = true
+}
+
+// take : забирает накопленное (документы, владельцы, переполнение), оставляя очередь пустой
+func (p *pendingInvalidations) take() ([]string, []string, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	uuids := make([]string, 0, len(p.uuids))
+	for uuid := range p.uuids {
+		uuids = append(uuids, uuid)
+	}
+	owners := make([]string, 0, len(p.owners))
+	for owner := range p.owners {
+		owners = append(owners, owner)
+	}
+	overflow := p.overflow
+	p.uuids = make(map[string]struct{})
+	p.owners = make(map[string]struct{})
+	p.overflow = false
+	return uuids, owners, overflow
+}
+
+// ReplayInvalidations : досылает инвалидации, пропущенные, пока Redis был недоступен, — иначе после
+// восстановления в нём остались бы копии документов, изменённых за это время. Неудавшиеся остаются в очереди
+func (r *CacheRepository) ReplayInvalidations(ctx context.Context) {
+	uuids, owners, overflow := r.pending.take()
+	if overflow {
+		deleted, err := r.FlushDocuments(ctx)
+		if err != nil {
+			r.pending.add(uuids...)
+			r.pending.addOwners(owners...)
+			r.pending.markOverflow()
+			log.Printf("[CacheRepo] не удалось очистить кэш документов после восстановления Redis: %v", err)
+			return
+		}
+		log.Printf("[CacheRepo] пропущенных инвалидаций слишком много, кэш документов очищен: удалено ключей %d", deleted)
+		return
+	}
+
+	for _, uuid := range uuids {
+		// DeleteDocument сам вернёт документ в очередь при ошибке
+		if err := r.DeleteDocument(ctx, uuid); err != nil {
+			continue
+		}
+		if err := r.deleteKeys(ctx, r.grantSetKey(uuid)); err != nil {
+			r.pending.add(uuid)
+		}
+	}
+	// InvalidateOwnerLists сам вернёт владельца в очередь при ошибке
+	for _, owner := range owners {
+		_ = r.InvalidateOwnerLists(ctx, owner)
+	}
+	if len(uuids)+len(owners) > 0 {
+		log.Printf("[CacheRepo] после восстановления Redis досланы инвалидации: документов %d, списков владельцев %d", len(uuids), len(owners))
+	}
+}
+
+// FlushDocuments : удаляет все записи кэша документов. Блокировки редактирования, версии множеств grant
+// и популярность документов не трогаются (см. isFlushableKey)
+func (r *CacheRepository) FlushDocuments(ctx context.Context) (int64, error) {
+	var deleted int64
+	err := r.scanKeys(ctx, documentNamespacePattern, func(keys []string) error {
+		flushable := keys[:0]
+		for _, key := range keys {
+			if isFlushableKey(key) {
+				flushable = append(flushable, key)
+			}
+		}
+		if len(flushable) == 0 {
+			return nil
+		}
+		if err := r.deleteKeys(ctx, flushable...); err != nil {
+			return err
+		}
+		deleted += int64(len(flushable))
+		return nil
+	})
+	if err != nil {
+		return deleted, util.LogError("[CacheRepo] ошибка очистки кэша документов", err)
+	}
+
+	if r.bus != nil {
+		if err := r.bus.PublishFlush(ctx); err != nil {
+			return deleted, util.LogError("[CacheRepo] ошибка рассылки очистки кэша", err)
+		}
+	}
+	return deleted, nil
+}
+
+// scanKeys : SCAN по шаблону; в Redis Cluster — по каждому мастеру
+func (r *CacheRepository) scanKeys(ctx context.Context, pattern string, fn func(keys []string) error) error {
+	scan := func(ctx context.Context, client redis.Cmdable, fn func(keys []string) error) error {
+		var cursor uint64
+		for {
+			keys, next, err := client.Scan(ctx, cursor, pattern, scanBatchSize).Result()
+			if err != nil {
+				return err
+			}
+			if len(keys) > 0 {
+				if err := fn(keys); err != nil {
+					return err
+				}
+			}
+			if next == 0 {
+				return nil
+			}
+			cursor = next
+		}
+	}
+
+	if cluster, ok := r.client.(*redis.ClusterClient); ok {
+		// мастера обходятся параллельно
+		var mu sync.Mutex
+		return cluster.ForEachMaster(ctx, func(ctx context.Context, client *redis.Client) error {
+			return scan(ctx, client, func(keys []string) error {
+				mu.Lock()
+				defer mu.Unlock()
+				return fn(keys)
+			})
+		})
+	}
+	return scan(ctx, r.client, fn)
+}
@@ -1,69 +1,372 @@
 package repository
 
 import (
-	"caching-web-server/config"
 	"caching-web-server/internal/model"
 	"caching-web-server/internal/util"
 	"context"
-	"encoding/json"
 	"errors"
 	"fmt"
+	uuidpkg "github.com/google/uuid"
 	"github.com/redis/go-redis/v9"
+	"log"
+	"math/rand"
 	"time"
 )
 
+const (
+	loadWaitPollInterval = 50 * time.Millisecond
+	grantVersionExtraTTL = time.Minute
+)
+
+// releaseLoadLockScript : снимает блокировку загрузки, только если она всё ещё наша
+var releaseLoadLockScript = redis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("DEL", KEYS[1])
+end
+return 0
+`)
+
+// CacheOptions : дополнительные настройки Redis-кэша документов
+type CacheOptions struct {
+	TTLJitter            float64       // доля TTL, на которую случайно сокращается срок жизни записи
+	LoadLockTTL          time.Duration // 0 — блокировка загрузки между репликами выключена
+	LoadWaitTimeout      time.Duration
+	StaleWhileRevalidate time.Duration // сколько после мягкого TTL копия отдаётся с обновлением в фоне
+	StaleMaxAge          time.Duration // максимальный возраст копии, которую можно отдать при ошибке БД
+	NegativeTTL          time.Duration // 0 — отрицательные записи («не найден») не кэшируются
+	GrantTTL             time.Duration // 0 — множества grant не кэшируются, доступ всегда проверяется по БД
+	ListTTL              time.Duration // 0 — страницы списков документов не кэшируются
+	PresignMinRemaining  time.Duration // 0 — pre-signed ссылки не кэшируются; иначе ссылка отдаётся, пока ей осталось не меньше
+	Encoding             string        // "binary" — компактная бинарная кодировка документов, иначе JSON
+	CompressThreshold    int           // записи больше стольких байт сжимаются; 0 — без сжатия
+	PopularityMaxTracked int           // 0 — частота обращений к документам не учитывается
+	WriteThrough         bool          // после изменения документ записывается в кэш, а не только удаляется из него
+	Metrics              *CacheMetrics // nil — метрики обращений к кэшу не считаются
+}
+
+// cacheEntry : формат записи документа в Redis. Мягкий TTL хранится в записи,
+// жёсткий — это TTL самого ключа
+type cacheEntry struct {
+	StoredAt   time.Time       `json:"stored_at"`
+	FreshUntil time.Time       `json:"fresh_until"`
+	Document   *model.Document `json:"document"`
+}
+
 type CacheRepository struct {
-	client *config.RedisClient
-	ttl    time.Duration
+	client   redis.UniversalClient
+	ttl      time.Duration
+	bus      *CacheInvalidationBus // nil — реплики без локального кэша, рассылать нечего
+	options  CacheOptions
+	codec    cacheCodec
+	pending  *pendingInvalidations // инвалидации, не дошедшие до Redis
+	accesses *accessCounter        // обращения к документам до сброса в document:popularity
 }
 
-func NewCacheRepository(rdb *config.RedisClient, ttl time.Duration) *CacheRepository {
-	return &CacheRepository{rdb, ttl}
+func NewCacheRepository(rdb redis.UniversalClient, ttl time.Duration, bus *CacheInvalidationBus, options CacheOptions) *CacheRepository {
+	return &CacheRepository{
+		client:   rdb,
+		ttl:      ttl,
+		bus:      bus,
+		options:  options,
+		codec:    newCacheCodec(options.Encoding, options.CompressThreshold),
+		pending:  newPendingInvalidations(),
+		accesses: newAccessCounter(),
+	}
 }
 
+// SetDocument : сохраняет документ, прочитанный из БД. Если в кэше уже более новая версия
+// (документ изменили, пока шла загрузка), запись молча отбрасывается
 func (r *CacheRepository) SetDocument(ctx context.Context, document *model.Document) error {
-	data, err := json.Marshal(document)
-	if err != nil {
-		return util.LogError("[CacheRepo] ошибка сериализации заказа", err)
-	}
+	_, err := r.setDocumentVersion(ctx, document)
+	return err
+}
 
-	cmd := r.client.Client.Set(ctx, r.key(document.UUID), data, r.ttl)
-	if err = cmd.Err(); err != nil {
-		return util.LogError("[CacheRepo] ошибка сохранения в Redis", err)
+// GetDocument : возвращает только свежую копию, устаревшие считаются промахом
+func (r *CacheRepository) GetDocument(ctx context.Context, uuid string) (*model.Document, error) {
+	entry, err := r.GetDocumentEntry(ctx, uuid)
+	if err != nil || entry == nil {
+		return nil, err
 	}
-	if cmd.Val() != "OK" {
-		return fmt.Errorf("[CacheRepo] неожиданный ответ Redis: %s", cmd.Val())
+	if entry.IsFresh(time.Now()) == false {
+		return nil, nil
 	}
-
-	return nil
+	return entry.Document, nil
 }
 
-func (r *CacheRepository) GetDocument(ctx context.Context, uuid string) (*model.Document, error) {
-	val, err := r.client.Client.Get(ctx, r.key(uuid)).Result()
+// GetDocumentEntry : возвращает копию документа вместе с её сроками свежести, в том числе устаревшую
+func (r *CacheRepository) GetDocumentEntry(ctx context.Context, uuid string) (*model.CachedDocument, error) {
+	started := time.Now()
+	val, err := r.client.Get(ctx, r.key(uuid)).Bytes()
 	if errors.Is(err, redis.Nil) {
+		r.observe("document", "get", cacheMiss, started, -1)
 		return nil, nil // нет в кэше
 	} else if err != nil {
+		r.observe("document", "get", cacheError, started, -1)
 		return nil, util.LogError("[CacheRepo] ошибка получения документа из Redis", err)
 	}
 
-	var order model.Document
-	if err := json.Unmarshal([]byte(val), &order); err != nil {
+	entry, err := r.codec.decodeEntry(val)
+	if errors.Is(err, errUnknownCacheEnvelope) {
+		r.observe("document", "get", cacheMiss, started, -1)
+		return nil, nil // запись другой версии схемы или старого формата — считаем промахом
+	} else if err != nil {
+		r.observe("document", "get", cacheError, started, -1)
 		return nil, util.LogError("[CacheRepo] ошибка десериализации документа из кэша", err)
 	}
-	return &order, nil
+
+	errorUntil := entry.StoredAt.Add(r.options.StaleMaxAge)
+	if errorUntil.Before(entry.FreshUntil) {
+		errorUntil = entry.FreshUntil
+	}
+	cached := &model.CachedDocument{
+		Document:   entry.Document,
+		StoredAt:   entry.StoredAt,
+		FreshUntil: entry.FreshUntil,
+		StaleUntil: entry.FreshUntil.Add(r.options.StaleWhileRevalidate),
+		ErrorUntil: errorUntil,
+	}
+
+	// устаревшая копия может быть отдана, но для чтения свежих данных это не попадание
+	outcome := cacheHit
+	if cached.IsFresh(started) == false {
+		outcome = cacheStale
+	}
+	r.observe("document", "get", outcome, started, len(val))
+	return cached, nil
 }
 
+// DeleteDocument : удаляет копию документа вместе с публичной копией, отрицательными записями по нему и страницами
+// списков, на которых он есть, — любое изменение документа или прав доступа затрагивает их все.
+// Если Redis недоступен, инвалидация запоминается и досылается после его восстановления
 func (r *CacheRepository) DeleteDocument(ctx context.Context, uuid string) error {
-	if err := r.client.Client.Del(ctx, r.key(uuid)).Err(); err != nil {
+	if err := r.deleteKeys(ctx, r.key(uuid), r.missingKey(uuid), r.publicDocumentKey(uuid)); err != nil {
+		r.pending.add(uuid)
 		return util.LogError("[CacheRepo] ошибка удаления документа из Redis", err)
 	}
+
+	// L1 других реплик инвалидируется, даже если страницы списков удалить не удалось
+	listErr := r.invalidateListTags(ctx, r.listDocumentTag(uuid))
+	if listErr != nil {
+		r.pending.add(uuid)
+	}
+
+	if r.bus != nil {
+		if err := r.bus.Publish(ctx, uuid); err != nil {
+			return util.LogError("[CacheRepo] ошибка рассылки инвалидации документа", err)
+		}
+	}
+	return listErr
+}
+
+// AcquireLoadLock : короткая блокировка загрузки документа из БД, чтобы при промахе
+// в БД шла только одна реплика. Если блокировка выключена, всегда считается захваченной
+func (r *CacheRepository) AcquireLoadLock(ctx context.Context, uuid string) (func(), bool, error) {
+	if r.options.LoadLockTTL <= 0 {
+		return func() {}, true, nil
+	}
+
+	token := uuidpkg.New().String()
+	acquired, err := r.client.SetNX(ctx, r.loadLockKey(uuid), token, r.options.LoadLockTTL).Result()
+	if err != nil {
+		return nil, false, util.LogError("[CacheRepo] ошибка захвата блокировки загрузки", err)
+	}
+	if acquired == false {
+		return nil, false, nil
+	}
+
+	release := func() {
+		releaseCtx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		if err := releaseLoadLockScript.Run(releaseCtx, r.client, []string{r.loadLockKey(uuid)}, token).Err(); err != nil {
+			log.Printf("[CacheRepo] не удалось снять блокировку загрузки %s: %v", uuid, err)
+		}
+	}
+	return release, true, nil
+}
+
+// WaitForDocument : ждёт, пока реплика, захватившая блокировку загрузки, положит документ в кэш.
+// Возвращает nil, если время ожидания вышло или блокировка снята без результата
+func (r *CacheRepository) WaitForDocument(ctx context.Context, uuid string) (*model.Document, error) {
+	waitCtx, cancel := context.WithTimeout(ctx, r.options.LoadWaitTimeout)
+	defer cancel()
+
+	ticker := time.NewTicker(loadWaitPollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-waitCtx.Done():
+			return nil, nil
+		case <-ticker.C:
+		}
+
+		document, err := r.GetDocument(waitCtx, uuid)
+		if err != nil || document != nil {
+			return document, err
+		}
+
+		locked, err := r.client.Exists(waitCtx, r.loadLockKey(uuid)).Result()
+		if err != nil {
+			return nil, util.LogError("[CacheRepo] ошибка проверки блокировки загрузки", err)
+		}
+		if locked == 0 {
+			return nil, nil
+		}
+	}
+}
+
+// IsDocumentMissing : есть ли отрицательная запись «документ не найден» для пользователя.
+// Запрос документа в БД идёт от имени пользователя, поэтому «нет доступа» тоже попадает сюда
+func (r *CacheRepository) IsDocumentMissing(ctx context.Context, uuid string, userUUID string) (bool, error) {
+	if r.options.NegativeTTL <= 0 {
+		return false, nil
+	}
+
+	started := time.Now()
+	missing, err := r.client.HExists(ctx, r.missingKey(uuid), userUUID).Result()
+	if err != nil {
+		r.observe("missing", "get", cacheError, started, -1)
+		return false, util.LogError("[CacheRepo] ошибка чтения отрицательной записи документа", err)
+	}
+	r.observe("missing", "get", hitOrMiss(missing), started, -1)
+	return missing, nil
+}
+
+// SetDocumentMissing : запоминает, что документ не найден для пользователя. Все записи по документу
+// лежат в одном хэше, чтобы инвалидировать их одной командой
+func (r *CacheRepository) SetDocumentMissing(ctx context.Context, uuid string, userUUID string) error {
+	if r.options.NegativeTTL <= 0 {
+		return nil
+	}
+
+	started := time.Now()
+	pipe := r.client.TxPipeline()
+	pipe.HSet(ctx, r.missingKey(uuid), userUUID, 1)
+	pipe.ExpireNX(ctx, r.missingKey(uuid), r.options.NegativeTTL)
+	if _, err := pipe.Exec(ctx); err != nil {
+		r.observe("missing", "set", cacheError, started, -1)
+		return util.LogError("[CacheRepo] ошибка сохранения отрицательной записи документа", err)
+	}
+	r.observe("missing", "set", cacheSet, started, -1)
+	return nil
+}
+
+// IsPublicTokenMissing : есть ли отрицательная запись для публичного токена
+func (r *CacheRepository) IsPublicTokenMissing(ctx context.Context, token string) (bool, error) {
+	if r.options.NegativeTTL <= 0 {
+		return false, nil
+	}
+
+	started := time.Now()
+	exists, err := r.client.Exists(ctx, r.missingTokenKey(token)).Result()
+	if err != nil {
+		r.observe("token_missing", "get", cacheError, started, -1)
+		return false, util.LogError("[CacheRepo] ошибка чтения отрицательной записи токена", err)
+	}
+	r.observe("token_missing", "get", hitOrMiss(exists > 0), started, -1)
+	return exists > 0, nil
+}
+
+// SetPublicTokenMissing : запоминает, что по токену нет публичного документа
+func (r *CacheRepository) SetPublicTokenMissing(ctx context.Context, token string) error {
+	if r.options.NegativeTTL <= 0 {
+		return nil
+	}
+
+	started := time.Now()
+	if err := r.client.Set(ctx, r.missingTokenKey(token), 1, r.options.NegativeTTL).Err(); err != nil {
+		r.observe("token_missing", "set", cacheError, started, -1)
+		return util.LogError("[CacheRepo] ошибка сохранения отрицательной записи токена", err)
+	}
+	r.observe("token_missing", "set", cacheSet, started, -1)
+	return nil
+}
+
+// ForgetMissing : снимает отрицательные записи, когда документ с этим UUID и токеном появился в БД
+func (r *CacheRepository) ForgetMissing(ctx context.Context, uuid string, token string) error {
+	keys := []string{r.missingKey(uuid)}
+	if token != "" {
+		keys = append(keys, r.missingTokenKey(token))
+	}
+
+	if err := r.deleteKeys(ctx, keys...); err != nil {
+		return util.LogError("[CacheRepo] ошибка удаления отрицательных записей", err)
+	}
 	return nil
 }
 
 func (r *CacheRepository) Pipeline() redis.Pipeliner {
-	return r.client.Client.Pipeline()
+	return r.client.Pipeline()
+}
+
+// deleteKeys : удаляет ключи по одному в пайплайне — в Redis Cluster они могут лежать в разных слотах,
+// и DEL с несколькими ключами вернул бы CROSSSLOT
+// deleteKeys : удалённые ключи учитываются в метриках по своему пространству
+func (r *CacheRepository) deleteKeys(ctx context.Context, keys ...string) error {
+	started := time.Now()
+	pipe := r.client.Pipeline()
+	deletes := make([]*redis.IntCmd, 0, len(keys))
+	for _, key := range keys {
+		deletes = append(deletes, pipe.Del(ctx, key))
+	}
+	_, err := pipe.Exec(ctx)
+
+	for i, del := range deletes {
+		if del.Err() != nil {
+			r.observe(cacheKeyNamespace(keys[i]), "del", cacheError, started, -1)
+		} else {
+			r.observe(cacheKeyNamespace(keys[i]), "del", cacheEvict, started, int(del.Val()))
+		}
+	}
+	return err
+}
+
+// observe : исход обращения к Redis для метрик; n — размер значения или число удалённых ключей, -1 — неизвестно
+func (r *CacheRepository) observe(namespace, operation string, outcome cacheOutcome, started time.Time, n int) {
+	r.options.Metrics.observe(cacheTierRemote, namespace, operation, outcome, time.Since(started), n)
+}
+
+func hitOrMiss(hit bool) cacheOutcome {
+	if hit {
+		return cacheHit
+	}
+	return cacheMiss
 }
 
 func (r *CacheRepository) key(uuid string) string {
 	return fmt.Sprintf("document:%s", uuid)
 }
+
+func (r *CacheRepository) missingKey(uuid string) string {
+	return fmt.Sprintf("document:missing:%s", uuid)
+}
+
+func (r *CacheRepository) missingTokenKey(token string) string {
+	return fmt.Sprintf("document:token:missing:%s", token)
+}
+
+func (r *CacheRepository) loadLockKey(uuid string) string {
+	return fmt.Sprintf("document:load:%s", uuid)
+}
+
+// hardTTL : время жизни ключа — пока копию можно отдать хоть в каком-то режиме
+func (r *CacheRepository) hardTTL(freshTTL time.Duration) time.Duration {
+	ttl := freshTTL + r.options.StaleWhileRevalidate
+	if r.options.StaleMaxAge > ttl {
+		ttl = r.options.StaleMaxAge
+	}
+	return ttl
+}
+
+// jitteredTTL : TTL, случайно сокращённый на долю TTLJitter, чтобы популярные записи не истекали разом
+func (r *CacheRepository) jitteredTTL() time.Duration {
+	if r.options.TTLJitter <= 0 || r.ttl <= 0 {
+		return r.ttl
+	}
+
+	span := int64(float64(r.ttl) * min(r.options.TTLJitter, 1))
+	if span <= 0 {
+		return r.ttl
+	}
+	return r.ttl - time.Duration(rand.Int63n(span))
+}
@@ -0,0 +1,54 @@
+package repository
+
+import (
+	"caching-web-server/config"
+	"caching-web-server/internal/model"
+	"caching-web-server/internal/util"
+	"context"
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+)
+
+// CacheWarmRepository : чтение документов для прогрева кэша. Доступ пользователя здесь не проверяется —
+// в кэш кладётся документ целиком, а доступ проверяется при каждом чтении из кэша
+type CacheWarmRepository struct {
+	*config.Database
+}
+
+func NewCacheWarmRepository(database *config.Database) *CacheWarmRepository {
+	return &CacheWarmRepository{database}
+}
+
+// GetDocuments : неудалённые документы из списка; отсутствующих UUID в результате нет
+func (r *CacheWarmRepository) GetDocuments(ctx context.Context, exec sqlx.ExtContext, documentUUIDs []string) ([]model.Document, error) {
+	query := `
+		SELECT d.uuid, d.owner_uuid, d.filename_original, d.size_bytes, d.mime_type,
+		       d.sha256, d.storage_path, d.is_file, d.is_public, d.access_token,
+		       d.created_at, d.updated_at, d.deleted_at, d.expires_at, d.legal_hold, d.version
+		FROM documents AS d
+		WHERE d.uuid = ANY($1) AND d.deleted_at IS NULL
+	`
+
+	documents := []model.Document{}
+	if err := sqlx.SelectContext(ctx, exec, &documents, query, pq.Array(documentUUIDs)); err != nil {
+		return nil, util.LogError("[CacheWarmRepo] не удалось получить документы для прогрева", err)
+	}
+	return documents, nil
+}
+
+// ListRecentDocumentUUIDs : последние изменённые документы владельца
+func (r *CacheWarmRepository) ListRecentDocumentUUIDs(ctx context.Context, exec sqlx.ExtContext, ownerUUID string, limit int) ([]string, error) {
+	query := `
+		SELECT d.uuid
+		FROM documents AS d
+		WHERE d.owner_uuid = $1 AND d.deleted_at IS NULL
+		ORDER BY d.updated_at DESC
+		LIMIT $2
+	`
+
+	uuids := []string{}
+	if err := sqlx.SelectContext(ctx, exec, &uuids, query, ownerUUID, limit); err != nil {
+		return nil, util.LogError("[CacheWarmRepo] не удалось получить документы пользователя", err)
+	}
+	return uuids, nil
+}
@@ -0,0 +1,146 @@
+package repository
+
+import (
+	"caching-web-server/internal/model"
+	"caching-web-server/internal/util"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/redis/go-redis/v9"
+	"time"
+)
+
+// Тег — множество ключей страниц, в которые попал документ или владелец. Страницы лежат в слоте
+// своего владельца (или login, по которому запрошен список), теги — в слоте документа или владельца,
+// чтобы списки разных пользователей распределялись по Redis Cluster, а не занимали один слот.
+// Поэтому страницы из тега удаляются pipeline'ом отдельных команд, а не одним скриптом
+const listKeyPrefix = "document:list:"
+
+// GetDocumentList : закэшированная страница списка документов или nil
+func (r *CacheRepository) GetDocumentList(ctx context.Context, query model.DocumentListQuery) (*model.DocumentListPage, error) {
+	if r.options.ListTTL <= 0 {
+		return nil, nil
+	}
+
+	started := time.Now()
+	val, err := r.client.Get(ctx, r.listPageKey(query)).Bytes()
+	if errors.Is(err, redis.Nil) {
+		r.observe("list", "get", cacheMiss, started, -1)
+		return nil, nil
+	} else if err != nil {
+		r.observe("list", "get", cacheError, started, -1)
+		return nil, util.LogError("[CacheRepo] ошибка получения списка документов из Redis", err)
+	}
+
+	var page model.DocumentListPage
+	if err := r.codec.decodeJSON(val, &page); errors.Is(err, errUnknownCacheEnvelope) {
+		r.observe("list", "get", cacheMiss, started, -1)
+		return nil, nil
+	} else if err != nil {
+		r.observe("list", "get", cacheError, started, -1)
+		return nil, util.LogError("[CacheRepo] ошибка десериализации списка документов", err)
+	}
+	r.observe("list", "get", cacheHit, started, len(val))
+	return &page, nil
+}
+
+// StoreDocumentList : сохраняет страницу и добавляет её в теги всех документов и владельцев на ней
+func (r *CacheRepository) StoreDocumentList(ctx context.Context, query model.DocumentListQuery, page *model.DocumentListPage, documentUUIDs []string, ownerUUIDs []string) error {
+	if r.options.ListTTL <= 0 {
+		return nil
+	}
+
+	started := time.Now()
+	data, err := r.codec.encodeJSON(page)
+	if err != nil {
+		return util.LogError("[CacheRepo] ошибка сериализации списка документов", err)
+	}
+
+	pageKey := r.listPageKey(query)
+	tags := make([]string, 0, len(documentUUIDs)+len(ownerUUIDs))
+	for _, documentUUID := range documentUUIDs {
+		tags = append(tags, r.listDocumentTag(documentUUID))
+	}
+	for _, ownerUUID := range ownerUUIDs {
+		tags = append(tags, r.listOwnerTag(ownerUUID))
+	}
+
+	// тег живёт не меньше самой свежей страницы в нём; страница и теги в разных слотах, поэтому без MULTI
+	pipe := r.client.Pipeline()
+	pipe.Set(ctx, pageKey, data, r.options.ListTTL)
+	for _, tag := range tags {
+		pipe.SAdd(ctx, tag, pageKey)
+		pipe.Expire(ctx, tag, r.options.ListTTL)
+	}
+	if _, err := pipe.Exec(ctx); err != nil {
+		r.observe("list", "set", cacheError, started, -1)
+		return util.LogError("[CacheRepo] ошибка сохранения списка документов в Redis", err)
+	}
+	r.observe("list", "set", cacheSet, started, len(data))
+	return nil
+}
+
+// InvalidateOwnerLists : удаляет все страницы со списками документов владельца — нужно при создании документа.
+// Если Redis недоступен, инвалидация запоминается и досылается после его восстановления
+func (r *CacheRepository) InvalidateOwnerLists(ctx context.Context, ownerUUID string) error {
+	if err := r.invalidateListTags(ctx, r.listOwnerTag(ownerUUID)); err != nil {
+		r.pending.addOwners(ownerUUID)
+		return err
+	}
+	return nil
+}
+
+// invalidateListTags : удаляет все страницы, помеченные тегами, и убирает их из тегов. Из тега удаляются
+// только прочитанные страницы: страница, добавленная в тег за это время, останется в нём до следующей инвалидации
+func (r *CacheRepository) invalidateListTags(ctx context.Context, tags ...string) error {
+	if r.options.ListTTL <= 0 {
+		return nil
+	}
+
+	for _, tag := range tags {
+		started := time.Now()
+		pages, err := r.client.SMembers(ctx, tag).Result()
+		if err != nil {
+			return util.LogError("[CacheRepo] ошибка инвалидации списков документов", err)
+		}
+		if len(pages) == 0 {
+			continue
+		}
+
+		members := make([]interface{}, 0, len(pages))
+		pipe := r.client.Pipeline()
+		for _, page := range pages {
+			pipe.Del(ctx, page)
+			members = append(members, page)
+		}
+		pipe.SRem(ctx, tag, members...)
+		if _, err := pipe.Exec(ctx); err != nil {
+			r.observe("list", "del", cacheError, started, -1)
+			return util.LogError("[CacheRepo] ошибка инвалидации списков документов", err)
+		}
+		r.observe("list", "del", cacheEvict, started, len(pages))
+	}
+	return nil
+}
+
+// listPageKey : страницы своих документов лежат в слоте владельца, страницы по чужому login — в слоте login
+func (r *CacheRepository) listPageKey(query model.DocumentListQuery) string {
+	data, _ := json.Marshal(query)
+	hash := sha256.Sum256(data)
+	slot := "owner:" + query.OwnerUUID
+	if query.OwnerUUID == "" {
+		slot = "login:" + query.Login
+	}
+	return fmt.Sprintf("%s{%s}:page:%s", listKeyPrefix, slot, hex.EncodeToString(hash[:16]))
+}
+
+func (r *CacheRepository) listDocumentTag(documentUUID string) string {
+	return fmt.Sprintf("%s{doc:%s}:tag", listKeyPrefix, documentUUID)
+}
+
+func (r *CacheRepository) listOwnerTag(ownerUUID string) string {
+	return fmt.Sprintf("%s{owner:%s}:tag", listKeyPrefix, ownerUUID)
+}
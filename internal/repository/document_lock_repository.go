@@ -0,0 +1,178 @@
+package repository
+
+import (
+	"caching-web-server/config"
+	"caching-web-server/internal/model"
+	"caching-web-server/internal/util"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/redis/go-redis/v9"
+	"log"
+	"time"
+)
+
+// acquireLockScript : ставит блокировку, если её нет или она принадлежит тому же пользователю (продление).
+// Возвращает текущую блокировку другого пользователя либо nil при успехе
+var acquireLockScript = redis.NewScript(`
+local current = redis.call('GET', KEYS[1])
+if current and cjson.decode(current).holder_uuid ~= ARGV[2] then
+	return current
+end
+redis.call('SET', KEYS[1], ARGV[1], 'PX', ARGV[3])
+return false
+`)
+
+// releaseLockScript : снимает блокировку, только если её держит указанный пользователь
+var releaseLockScript = redis.NewScript(`
+local current = redis.call('GET', KEYS[1])
+if current and cjson.decode(current).holder_uuid == ARGV[1] then
+	return redis.call('DEL', KEYS[1])
+end
+return 0
+`)
+
+// DocumentLockRepository : блокировки хранятся в Redis с TTL; если Redis недоступен,
+// блокировки ставятся и проверяются через таблицу document_locks
+type DocumentLockRepository struct {
+	*config.Database
+	client *config.RedisClient
+}
+
+func NewDocumentLockRepository(database *config.Database, rdb *config.RedisClient) *DocumentLockRepository {
+	return &DocumentLockRepository{database, rdb}
+}
+
+// Acquire : ставит или продлевает блокировку. Если документ заблокирован другим пользователем,
+// возвращает эту блокировку и false
+func (r *DocumentLockRepository) Acquire(ctx context.Context, lock *model.DocumentLock) (*model.DocumentLock, bool, error) {
+	// блокировка, поставленная в БД во время недоступности Redis, остаётся в силе до истечения
+	current, err := r.getFromDB(ctx, lock.DocumentUUID)
+	if err != nil {
+		return nil, false, err
+	}
+	if current != nil && current.HolderUUID != lock.HolderUUID {
+		return current, false, nil
+	}
+
+	data, err := json.Marshal(lock)
+	if err != nil {
+		return nil, false, util.LogError("[LockRepo] ошибка сериализации блокировки", err)
+	}
+
+	ttl := time.Until(lock.ExpiresAt)
+	val, err := acquireLockScript.Run(ctx, r.client.Client, []string{r.key(lock.DocumentUUID)}, data, lock.HolderUUID, ttl.Milliseconds()).Text()
+	switch {
+	case errors.Is(err, redis.Nil):
+		return lock, true, nil
+	case err == nil:
+		var other model.DocumentLock
+		if err := json.Unmarshal([]byte(val), &other); err != nil {
+			return nil, false, util.LogError("[LockRepo] ошибка десериализации блокировки", err)
+		}
+		return &other, false, nil
+	}
+
+	log.Printf("[LockRepo] Redis недоступен, блокировка документа %s сохраняется в БД: %v", lock.DocumentUUID, err)
+	return r.acquireInDB(ctx, lock)
+}
+
+// Get : действующая блокировка документа или nil
+func (r *DocumentLockRepository) Get(ctx context.Context, documentUUID string) (*model.DocumentLock, error) {
+	val, err := r.client.Client.Get(ctx, r.key(documentUUID)).Result()
+	if err == nil {
+		var lock model.DocumentLock
+		if err := json.Unmarshal([]byte(val), &lock); err != nil {
+			return nil, util.LogError("[LockRepo] ошибка десериализации блокировки", err)
+		}
+		return &lock, nil
+	}
+	if errors.Is(err, redis.Nil) == false {
+		log.Printf("[LockRepo] ошибка получения блокировки из Redis, проверяем БД: %v", err)
+	}
+
+	return r.getFromDB(ctx, documentUUID)
+}
+
+// Release : снимает блокировку, если её держит holderUUID
+func (r *DocumentLockRepository) Release(ctx context.Context, documentUUID, holderUUID string) (bool, error) {
+	released, redisErr := releaseLockScript.Run(ctx, r.client.Client, []string{r.key(documentUUID)}, holderUUID).Int()
+	if redisErr != nil {
+		log.Printf("[LockRepo] ошибка снятия блокировки в Redis: %v", redisErr)
+	}
+
+	result, err := r.DB.ExecContext(ctx, `DELETE FROM document_locks WHERE document_uuid = $1 AND holder_uuid = $2`, documentUUID, holderUUID)
+	if err != nil {
+		if redisErr != nil {
+			return false, util.LogError("[LockRepo] не удалось снять блокировку", err)
+		}
+		log.Printf("[LockRepo] ошибка снятия блокировки в БД: %v", err)
+		return released > 0, nil
+	}
+
+	affected, _ := result.RowsAffected()
+	return released > 0 || affected > 0, nil
+}
+
+// ForceRelease : снимает блокировку независимо от того, кто её держит
+func (r *DocumentLockRepository) ForceRelease(ctx context.Context, documentUUID string) error {
+	redisErr := r.client.Client.Del(ctx, r.key(documentUUID)).Err()
+	if redisErr != nil {
+		log.Printf("[LockRepo] ошибка снятия блокировки в Redis: %v", redisErr)
+	}
+
+	if _, err := r.DB.ExecContext(ctx, `DELETE FROM document_locks WHERE document_uuid = $1`, documentUUID); err != nil {
+		return util.LogError("[LockRepo] не удалось снять блокировку в БД", err)
+	}
+	if redisErr != nil {
+		return fmt.Errorf("[LockRepo] не удалось снять блокировку в Redis: %w", redisErr)
+	}
+	return nil
+}
+
+func (r *DocumentLockRepository) acquireInDB(ctx context.Context, lock *model.DocumentLock) (*model.DocumentLock, bool, error) {
+	query := `
+		INSERT INTO document_locks (document_uuid, holder_uuid, reason, locked_at, expires_at)
+		VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (document_uuid) DO UPDATE
+		SET holder_uuid = EXCLUDED.holder_uuid, reason = EXCLUDED.reason,
+		    locked_at = EXCLUDED.locked_at, expires_at = EXCLUDED.expires_at
+		WHERE document_locks.expires_at <= now() OR document_locks.holder_uuid = EXCLUDED.holder_uuid
+	`
+	result, err := r.DB.ExecContext(ctx, query, lock.DocumentUUID, lock.HolderUUID, lock.Reason, lock.LockedAt, lock.ExpiresAt)
+	if err != nil {
+		return nil, false, util.LogError("[LockRepo] не удалось сохранить блокировку в БД", err)
+	}
+	if affected, _ := result.RowsAffected(); affected > 0 {
+		return lock, true, nil
+	}
+
+	current, err := r.getFromDB(ctx, lock.DocumentUUID)
+	if err != nil {
+		return nil, false, err
+	}
+	return current, false, nil
+}
+
+func (r *DocumentLockRepository) getFromDB(ctx context.Context, documentUUID string) (*model.DocumentLock, error) {
+	var lock model.DocumentLock
+	query := `
+		SELECT document_uuid, holder_uuid, reason, locked_at, expires_at
+		FROM document_locks
+		WHERE document_uuid = $1 AND expires_at > now()
+	`
+	err := r.DB.GetContext(ctx, &lock, query, documentUUID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, util.LogError("[LockRepo] не удалось получить блокировку из БД", err)
+	}
+	return &lock, nil
+}
+
+func (r *DocumentLockRepository) key(uuid string) string {
+	return fmt.Sprintf("document:lock:%s", uuid)
+}
@@ -0,0 +1,118 @@
+package repository
+
+import (
+	"caching-web-server/internal/util"
+	"context"
+	"log"
+	"sync"
+	"time"
+)
+
+const (
+	popularityKey = "document:popularity"
+	// maxPendingAccesses : сколько разных документов копится между сбросами; обращения к остальным теряются
+	maxPendingAccesses = 10000
+)
+
+// accessCounter : обращения к документам, накопленные в памяти до сброса в Redis,
+// чтобы чтение документа не ждало лишней команды
+type accessCounter struct {
+	mu     sync.Mutex
+	counts map[string]int64
+}
+
+func newAccessCounter() *accessCounter {
+	return &accessCounter{counts: make(map[string]int64)}
+}
+
+func (c *accessCounter) add(uuid string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, ok := c.counts[uuid]; !ok && len(c.counts) >= maxPendingAccesses {
+		return
+	}
+	c.counts[uuid]++
+}
+
+func (c *accessCounter) take() map[string]int64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	counts := c.counts
+	c.counts = make(map[string]int64)
+	return counts
+}
+
+// RecordAccess : учитывает чтение документа; в Redis счётчики попадают при следующем сбросе
+func (r *CacheRepository) RecordAccess(uuid string) {
+	if r.options.PopularityMaxTracked <= 0 {
+		return
+	}
+	r.accesses.add(uuid)
+}
+
+// RunPopularityFlush : раз в interval переносит накопленные обращения в sorted set document:popularity
+func (r *CacheRepository) RunPopularityFlush(ctx context.Context, interval time.Duration) {
+	if r.options.PopularityMaxTracked <= 0 {
+		return
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := r.flushPopularity(ctx); err != nil {
+				log.Printf("%v", err)
+			}
+		}
+	}
+}
+
+// flushPopularity : ZINCRBY по накопленным обращениям и обрезка множества до PopularityMaxTracked
+// самых популярных. Если Redis недоступен, обращения за интервал теряются — это только статистика
+func (r *CacheRepository) flushPopularity(ctx context.Context) error {
+	counts := r.accesses.take()
+	if len(counts) == 0 {
+		return nil
+	}
+
+	pipe := r.client.Pipeline()
+	for uuid, count := range counts {
+		pipe.ZIncrBy(ctx, popularityKey, float64(count), uuid)
+	}
+	pipe.ZRemRangeByRank(ctx, popularityKey, 0, -int64(r.options.PopularityMaxTracked)-1)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return util.LogError("[CacheRepo] ошибка сохранения популярности документов", err)
+	}
+	return nil
+}
+
+// TopDocuments : UUID самых читаемых документов по убыванию числа обращений
+func (r *CacheRepository) TopDocuments(ctx context.Context, limit int) ([]string, error) {
+	if limit <= 0 {
+		return nil, nil
+	}
+	uuids, err := r.client.ZRevRange(ctx, popularityKey, 0, int64(limit)-1).Result()
+	if err != nil {
+		return nil, util.LogError("[CacheRepo] ошибка чтения популярных документов", err)
+	}
+	return uuids, nil
+}
+
+// ForgetPopularity : убирает удалённые документы из статистики
+func (r *CacheRepository) ForgetPopularity(ctx context.Context, uuids ...string) error {
+	if len(uuids) == 0 {
+		return nil
+	}
+	members := make([]interface{}, 0, len(uuids))
+	for _, uuid := range uuids {
+		members = append(members, uuid)
+	}
+	if err := r.client.ZRem(ctx, popularityKey, members...).Err(); err != nil {
+		return util.LogError("[CacheRepo] ошибка удаления документов из популярных", err)
+	}
+	return nil
+}
@@ -28,11 +28,21 @@ func (r *DocumentRepository) Create(ctx context.Context, exec sqlx.ExtContext, d
 	}
 	document.AccessToken = token
 
+	// если срок хранения не задан явно, он берётся из политики хранения для MIME-типа
+	// (точное совпадение приоритетнее шаблона вида "image/*")
 	query := `
-		INSERT INTO documents (uuid, owner_uuid, filename_original, size_bytes, mime_type, sha256, storage_path, is_file, is_public, access_token)
-		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+		INSERT INTO documents (uuid, owner_uuid, filename_original, size_bytes, mime_type, sha256, storage_path, is_file, is_public, access_token, expires_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, COALESCE($11, (
+			SELECT now() + p.retention_days * INTERVAL '1 day'
+			FROM retention_policies AS p
+			WHERE p.mime_type = $5
+			   OR (p.mime_type LIKE '%/*' AND $5 LIKE rtrim(p.mime_type, '*') || '%')
+			ORDER BY (p.mime_type = $5) DESC, p.retention_days ASC
+			LIMIT 1
+		)))
+		RETURNING created_at, updated_at, expires_at, version
 	`
-	_, err = exec.ExecContext(
+	row := exec.QueryRowxContext(
 		ctx,
 		query,
 		document.UUID,
@@ -45,9 +55,10 @@ func (r *DocumentRepository) Create(ctx context.Context, exec sqlx.ExtContext, d
 		document.IsFile,
 		document.IsPublic,
 		document.AccessToken,
+		document.ExpiresAt,
 	)
 
-	if err != nil {
+	if err = row.Scan(&document.CreatedAt, &document.UpdatedAt, &document.ExpiresAt, &document.Version); err != nil {
 		return util.LogError("[DocumentRepo] не удалось вставить данные в БД", err)
 	}
 
@@ -59,7 +70,7 @@ func (r *DocumentRepository) GetByUUID(ctx context.Context, exec sqlx.ExtContext
 	query := `
 		SELECT d.uuid, d.owner_uuid, d.filename_original, d.size_bytes, d.mime_type,
 		       d.sha256, d.storage_path, d.is_file, d.is_public, d.access_token,
-		       d.created_at, d.updated_at, d.deleted_at
+		       d.created_at, d.updated_at, d.deleted_at, d.expires_at, d.legal_hold, d.version
 		FROM documents AS d
 		LEFT JOIN document_grants AS g
 		  ON d.uuid = g.document_uuid AND g.target_user_uuid = $2
@@ -92,7 +103,7 @@ func (r *DocumentRepository) GetByToken(ctx context.Context, exec sqlx.ExtContex
 	query := `
 		SELECT d.uuid, d.owner_uuid, d.filename_original, d.size_bytes, d.mime_type,
 		       d.sha256, d.storage_path, d.is_file, d.is_public, d.access_token,
-		       d.created_at, d.updated_at, d.deleted_at
+		       d.created_at, d.updated_at, d.deleted_at, d.expires_at, d.legal_hold, d.version
 		FROM documents AS d
 		WHERE d.access_token = $1
 	`
@@ -110,7 +121,7 @@ func (r *DocumentRepository) GetPublicByUUID(ctx context.Context, exec sqlx.ExtC
 	query := `
         SELECT d.uuid, d.owner_uuid, d.filename_original, d.size_bytes, d.mime_type,
                d.sha256, d.storage_path, d.is_file, d.is_public, d.access_token,
-               d.created_at, d.updated_at, d.deleted_at
+               d.created_at, d.updated_at, d.deleted_at, d.expires_at, d.legal_hold, d.version
         FROM documents AS d
         WHERE d.is_public = true AND d.uuid = $1
     `
@@ -126,7 +137,7 @@ func (r *DocumentRepository) GetPublicByToken(ctx context.Context, exec sqlx.Ext
 	query := `
         SELECT d.uuid, d.owner_uuid, d.filename_original, d.size_bytes, d.mime_type,
                d.sha256, d.storage_path, d.is_file, d.is_public, d.access_token,
-               d.created_at, d.updated_at, d.deleted_at
+               d.created_at, d.updated_at, d.deleted_at, d.expires_at, d.legal_hold, d.version
         FROM documents AS d
         WHERE d.is_public = true AND d.access_token = $1
     `
@@ -167,7 +178,10 @@ func (r *DocumentRepository) ListDocuments(
 			d.storage_path,
 			d.access_token,
 			d.updated_at,
-			d.deleted_at
+			d.deleted_at,
+			d.expires_at,
+			d.legal_hold,
+			d.version
 		FROM documents AS d
 		LEFT JOIN users AS u ON u.uuid = d.owner_uuid
 		WHERE d.deleted_at IS NULL
@@ -0,0 +1,143 @@
+package repository
+
+import (
+	"caching-web-server/internal/model"
+	"caching-web-server/internal/util"
+	"context"
+	"fmt"
+	"github.com/redis/go-redis/v9"
+	"strconv"
+	"time"
+)
+
+const (
+	// documentVersionExtraTTL : версия живёт дольше записи, чтобы запись, начатая до изменения, не вернула старый документ
+	documentVersionExtraTTL = time.Minute
+	// tombstoneVersion : версия удалённого документа — больше любой версии из БД и точно представима числом Lua
+	tombstoneVersion int64 = 1 << 53
+)
+
+// storeDocumentVersionScript : сохраняет запись документа, если в кэше нет более новой версии.
+// Равная версия перезаписывается — это та же строка БД, только прочитанная позже
+var storeDocumentVersionScript = redis.NewScript(`
+local current = tonumber(redis.call("GET", KEYS[2]) or "0")
+if current > tonumber(ARGV[1]) then
+	return 0
+end
+redis.call("SET", KEYS[1], ARGV[2], "PX", ARGV[3])
+redis.call("SET", KEYS[2], ARGV[1], "PX", ARGV[4])
+return 1
+`)
+
+// raiseDocumentVersionScript : поднимает версию до ARGV[1] (но не опускает) и удаляет запись документа
+var raiseDocumentVersionScript = redis.NewScript(`
+local current = tonumber(redis.call("GET", KEYS[2]) or "0")
+if current < tonumber(ARGV[1]) then
+	redis.call("SET", KEYS[2], ARGV[1], "PX", ARGV[2])
+end
+redis.call("DEL", KEYS[1])
+return 1
+`)
+
+// WriteThroughEnabled : записывать ли документ в кэш сразу после изменения, а не только инвалидировать
+func (r *CacheRepository) WriteThroughEnabled() bool {
+	return r.options.WriteThrough
+}
+
+// StoreDocumentVersion : write-through после коммита. Документ сохраняется, только если в кэше нет более новой версии,
+// поэтому из двух параллельных изменений в кэше останется последнее. stored = false — запись отброшена как устаревшая
+func (r *CacheRepository) StoreDocumentVersion(ctx context.Context, document *model.Document) (bool, error) {
+	stored, err := r.setDocumentVersion(ctx, document)
+	if err != nil {
+		r.pending.add(document.UUID)
+		return false, err
+	}
+
+	if err := r.invalidateDocumentCopies(ctx, document.UUID); err != nil {
+		return stored, err
+	}
+	return stored, nil
+}
+
+// InvalidateDocumentVersion : инвалидация после изменения, которое не записывается в кэш.
+// Версия поднимается, чтобы загрузка, начатая до изменения, не сохранила старый документ
+func (r *CacheRepository) InvalidateDocumentVersion(ctx context.Context, uuid string, version int64) error {
+	keys := []string{r.key(uuid), r.versionKey(uuid)}
+	if err := raiseDocumentVersionScript.Run(ctx, r.client, keys, strconv.FormatInt(version, 10), r.versionTTL().Milliseconds()).Err(); err != nil {
+		r.pending.add(uuid)
+		return util.LogError("[CacheRepo] ошибка инвалидации версии документа", err)
+	}
+
+	return r.invalidateDocumentCopies(ctx, uuid)
+}
+
+// TombstoneDocument : инвалидация удалённого документа — после неё в кэш не попадёт ни одна его версия
+func (r *CacheRepository) TombstoneDocument(ctx context.Context, uuid string) error {
+	return r.InvalidateDocumentVersion(ctx, uuid, tombstoneVersion)
+}
+
+// setDocumentVersion : запись документа с проверкой версии. Документы без версии записываются как раньше
+func (r *CacheRepository) setDocumentVersion(ctx context.Context, document *model.Document) (bool, error) {
+	now := time.Now()
+	freshTTL := r.jitteredTTL()
+	data, err := r.codec.encodeEntry(cacheEntry{StoredAt: now, FreshUntil: now.Add(freshTTL), Document: document})
+	if err != nil {
+		return false, util.LogError("[CacheRepo] ошибка сериализации документа", err)
+	}
+
+	entryTTL := r.hardTTL(freshTTL)
+	if document.Version <= 0 {
+		if err := r.client.Set(ctx, r.key(document.UUID), data, entryTTL).Err(); err != nil {
+			r.observe("document", "set", cacheError, now, -1)
+			return false, util.LogError("[CacheRepo] ошибка сохранения в Redis", err)
+		}
+		r.observe("document", "set", cacheSet, now, len(data))
+		return true, nil
+	}
+
+	keys := []string{r.key(document.UUID), r.versionKey(document.UUID)}
+	stored, err := storeDocumentVersionScript.Run(ctx, r.client, keys, document.Version, data, entryTTL.Milliseconds(), r.versionTTL().Milliseconds()).Int()
+	if err != nil {
+		r.observe("document", "set", cacheError, now, -1)
+		return false, util.LogError("[CacheRepo] ошибка сохранения в Redis", err)
+	}
+	// запись отклонена скриптом, если в кэше уже более новая версия — это не запись значения
+	if stored == 1 {
+		r.observe("document", "set", cacheSet, now, len(data))
+	}
+	return stored == 1, nil
+}
+
+// invalidateDocumentCopies : то, что строится из документа, но не перезаписывается вместе с ним —
+// публичная копия, отрицательные записи, страницы списков и копии в L1 других реплик
+func (r *CacheRepository) invalidateDocumentCopies(ctx context.Context, uuid string) error {
+	if err := r.deleteKeys(ctx, r.missingKey(uuid), r.publicDocumentKey(uuid)); err != nil {
+		r.pending.add(uuid)
+		return util.LogError("[CacheRepo] ошибка удаления документа из Redis", err)
+	}
+
+	// L1 других реплик инвалидируется, даже если страницы списков удалить не удалось
+	listErr := r.invalidateListTags(ctx, r.listDocumentTag(uuid))
+	if listErr != nil {
+		r.pending.add(uuid)
+	}
+
+	if r.bus != nil {
+		if err := r.bus.Publish(ctx, uuid); err != nil {
+			return util.LogError("[CacheRepo] ошибка рассылки инвалидации документа", err)
+		}
+	}
+	return listErr
+}
+
+// versionTTL : версия живёт дольше любой записи, которую она защищает. Записи документа и публичной копии
+// живут не дольше hardTTL(r.ttl) — джиттер только сокращает TTL, поэтому версия не истечёт раньше записи,
+// записанной до неё, и не будет укорочена следующей записью с меньшим TTL
+func (r *CacheRepository) versionTTL() time.Duration {
+	return r.hardTTL(r.ttl) + documentVersionExtraTTL
+}
+
+// versionKey : hash tag совпадает со слотом ключа документа, чтобы скрипты работали и в Redis Cluster
+func (r *CacheRepository) versionKey(uuid string) string {
+	return fmt.Sprintf("document:version:{%s}", r.key(uuid))
+}
@@ -0,0 +1,35 @@
+package repository
+
+import (
+	"caching-web-server/internal/model"
+	"context"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func TestDocumentVersionTTL_OutlivesEntries(t *testing.T) {
+	ctx := context.Background()
+	client, fake := newFakeRedisClient(t)
+	client.AddHook(fake)
+	repo := NewCacheRepository(client, time.Minute, nil, CacheOptions{TTLJitter: 0.5, StaleWhileRevalidate: 30 * time.Second})
+	versionTTL := (time.Minute + 30*time.Second + documentVersionExtraTTL).Milliseconds()
+
+	// у записей с джиттером TTL разный, у версии — всегда наибольший, и следующая запись её не укорачивает
+	for i := 0; i < 5; i++ {
+		stored, err := repo.StoreDocumentVersion(ctx, &model.Document{UUID: "doc1", Version: 2})
+		require.NoError(t, err)
+		assert.True(t, stored)
+	}
+	require.NoError(t, repo.InvalidateDocumentVersion(ctx, "doc1", 3))
+
+	require.Len(t, fake.scripts, 6)
+	for _, args := range fake.scripts[:5] {
+		entryTTL, recorded := args[len(args)-2].(int64), args[len(args)-1].(int64)
+		assert.LessOrEqual(t, entryTTL, (time.Minute + 30*time.Second).Milliseconds())
+		assert.Equal(t, versionTTL, recorded)
+	}
+	raise := fake.scripts[5]
+	assert.Equal(t, versionTTL, raise[len(raise)-1].(int64))
+}
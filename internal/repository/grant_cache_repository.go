@@ -0,0 +1,149 @@
+package repository
+
+import (
+	"caching-web-server/internal/util"
+	"context"
+	"errors"
+	"fmt"
+	"github.com/redis/go-redis/v9"
+	"strconv"
+	"time"
+)
+
+// grantSetLoadedMarker : служебный элемент множества. Без него множество считается неполным
+// (например, созданным SADD после истечения TTL) и для проверки доступа не используется.
+// Множество нельзя завернуть в конверт, поэтому версия схемы кэша входит в маркер: множество,
+// сохранённое сборкой с другой версией, для читателя — промах, как и запись чужого конверта
+var grantSetLoadedMarker = fmt.Sprintf("#loaded:v%d", cacheSchemaVersion)
+
+// isGrantedScript : -1 — множества нет или оно неполное, 0/1 — есть ли пользователь в множестве
+var isGrantedScript = redis.NewScript(`
+if redis.call("SISMEMBER", KEYS[1], ARGV[1]) == 0 then
+	return -1
+end
+return redis.call("SISMEMBER", KEYS[1], ARGV[2])
+`)
+
+// storeGrantSetScript : сохраняет множество, только если версия не менялась с момента чтения grant из БД
+var storeGrantSetScript = redis.NewScript(`
+local current = redis.call("GET", KEYS[2]) or "0"
+if current ~= ARGV[1] then
+	return 0
+end
+redis.call("DEL", KEYS[1])
+redis.call("SADD", KEYS[1], unpack(ARGV, 3))
+redis.call("PEXPIRE", KEYS[1], ARGV[2])
+return 1
+`)
+
+// updateGrantSetScript : поднимает версию (отменяя идущие загрузки из БД) и меняет существующее множество
+// или удаляет его целиком ("drop")
+var updateGrantSetScript = redis.NewScript(`
+redis.call("INCR", KEYS[2])
+redis.call("PEXPIRE", KEYS[2], ARGV[3])
+if ARGV[1] == "drop" then
+	redis.call("DEL", KEYS[1])
+elseif redis.call("EXISTS", KEYS[1]) == 1 then
+	if ARGV[1] == "add" then
+		redis.call("SADD", KEYS[1], ARGV[2])
+	else
+		redis.call("SREM", KEYS[1], ARGV[2])
+	end
+end
+return 1
+`)
+
+// IsGranted : есть ли у пользователя grant по кэшированному множеству.
+// cached = false — множества нет, оно неполное или выключено, решение нужно принять по БД
+func (r *CacheRepository) IsGranted(ctx context.Context, documentUUID string, userUUID string) (bool, bool, error) {
+	if r.options.GrantTTL <= 0 {
+		return false, false, nil
+	}
+
+	started := time.Now()
+	result, err := isGrantedScript.Run(ctx, r.client, []string{r.grantSetKey(documentUUID)}, grantSetLoadedMarker, userUUID).Int()
+	if err != nil {
+		r.observe("grants", "ismember", cacheError, started, -1)
+		return false, false, util.LogError("[CacheRepo] ошибка проверки grant в кэше", err)
+	}
+	// и «есть grant», и «нет grant» — попадание: решение принято без БД
+	r.observe("grants", "ismember", hitOrMiss(result >= 0), started, -1)
+	if result < 0 {
+		return false, false, nil
+	}
+	return result == 1, true, nil
+}
+
+// GrantSetVersion : версия множества grant, читается до загрузки grant из БД
+func (r *CacheRepository) GrantSetVersion(ctx context.Context, documentUUID string) (int64, error) {
+	version, err := r.client.Get(ctx, r.grantVersionKey(documentUUID)).Int64()
+	if errors.Is(err, redis.Nil) {
+		return 0, nil
+	} else if err != nil {
+		return 0, util.LogError("[CacheRepo] ошибка чтения версии grant", err)
+	}
+	return version, nil
+}
+
+// StoreGrantSet : сохраняет полное множество пользователей с grant, если с момента чтения версии
+// grant не менялись. Иначе множество могло бы вернуть только что отозванный доступ
+func (r *CacheRepository) StoreGrantSet(ctx context.Context, documentUUID string, userUUIDs []string, version int64) error {
+	if r.options.GrantTTL <= 0 {
+		return nil
+	}
+
+	args := make([]interface{}, 0, len(userUUIDs)+3)
+	args = append(args, strconv.FormatInt(version, 10), r.options.GrantTTL.Milliseconds(), grantSetLoadedMarker)
+	for _, userUUID := range userUUIDs {
+		args = append(args, userUUID)
+	}
+
+	started := time.Now()
+	keys := []string{r.grantSetKey(documentUUID), r.grantVersionKey(documentUUID)}
+	if err := storeGrantSetScript.Run(ctx, r.client, keys, args...).Err(); err != nil {
+		r.observe("grants", "set", cacheError, started, -1)
+		return util.LogError("[CacheRepo] ошибка сохранения множества grant", err)
+	}
+	r.observe("grants", "set", cacheSet, started, -1)
+	return nil
+}
+
+// AddToGrantSet : атомарно добавляет пользователя в множество grant документа
+func (r *CacheRepository) AddToGrantSet(ctx context.Context, documentUUID string, userUUID string) error {
+	return r.updateGrantSet(ctx, documentUUID, userUUID, "add")
+}
+
+// RemoveFromGrantSet : атомарно убирает пользователя из множества grant документа
+func (r *CacheRepository) RemoveFromGrantSet(ctx context.Context, documentUUID string, userUUID string) error {
+	return r.updateGrantSet(ctx, documentUUID, userUUID, "remove")
+}
+
+// DropGrantSet : удаляет множество grant документа и поднимает его версию — следующая проверка доступа
+// пойдёт в БД. Используется, если изменить множество точечно не удалось
+func (r *CacheRepository) DropGrantSet(ctx context.Context, documentUUID string) error {
+	return r.updateGrantSet(ctx, documentUUID, "", "drop")
+}
+
+func (r *CacheRepository) updateGrantSet(ctx context.Context, documentUUID string, userUUID string, operation string) error {
+	if r.options.GrantTTL <= 0 {
+		return nil
+	}
+
+	// версия живёт дольше множества, чтобы загрузка, начатая до изменения, не сохранила старые grant
+	versionTTL := (r.options.GrantTTL + grantVersionExtraTTL).Milliseconds()
+	keys := []string{r.grantSetKey(documentUUID), r.grantVersionKey(documentUUID)}
+	if err := updateGrantSetScript.Run(ctx, r.client, keys, operation, userUUID, versionTTL).Err(); err != nil {
+		r.pending.add(documentUUID)
+		return util.LogError("[CacheRepo] ошибка изменения множества grant", err)
+	}
+	return nil
+}
+
+// grantSetKey : ключи множества и версии с общим hash tag, чтобы скрипты работали и в Redis Cluster
+func (r *CacheRepository) grantSetKey(documentUUID string) string {
+	return fmt.Sprintf("document:grants:{%s}", documentUUID)
+}
+
+func (r *CacheRepository) grantVersionKey(documentUUID string) string {
+	return fmt.Sprintf("document:grants:version:{%s}", documentUUID)
+}
@@ -48,7 +48,7 @@ func (r *GrantDocumentRepository) AddGrant(ctx context.Context, exec sqlx.ExtCon
 		return util.LogError("[DocumentRepo] не удалось предоставить доступ к документу", err)
 	}
 
-	return nil
+	return r.bumpDocumentVersion(ctx, exec, documentUUID)
 }
 
 func (r *GrantDocumentRepository) CheckOwner(ctx context.Context, exec sqlx.ExtContext, documentUUID, ownerUUID string) (bool, error) {
@@ -69,6 +69,15 @@ func (r *GrantDocumentRepository) RemoveGrant(ctx context.Context, exec sqlx.Ext
 	if err != nil {
 		return util.LogError("[GrantRepo] не удалось удалить доступ к документу", err)
 	}
+	return r.bumpDocumentVersion(ctx, exec, documentUUID)
+}
+
+// bumpDocumentVersion : grant входят в закэшированный документ, поэтому их изменение меняет и версию документа.
+// UPDATE блокирует строку документа до конца транзакции, так что версии растут в порядке коммитов
+func (r *GrantDocumentRepository) bumpDocumentVersion(ctx context.Context, exec sqlx.ExtContext, documentUUID string) error {
+	if _, err := exec.ExecContext(ctx, `UPDATE documents SET version = version + 1 WHERE uuid = $1`, documentUUID); err != nil {
+		return util.LogError("[GrantRepo] не удалось изменить версию документа", err)
+	}
 	return nil
 }
 
@@ -85,3 +94,17 @@ func (r *GrantDocumentRepository) ListGrants(ctx context.Context, exec sqlx.ExtC
 	}
 	return grants, nil
 }
+
+// ListGrantUserUUIDs : UUID всех пользователей с grant на документ — для кэширования множества grant
+func (r *GrantDocumentRepository) ListGrantUserUUIDs(ctx context.Context, exec sqlx.ExtContext, documentUUID string) ([]string, error) {
+	var userUUIDs []string
+	err := sqlx.SelectContext(ctx, exec, &userUUIDs, `
+        SELECT g.target_user_uuid
+        FROM document_grants AS g
+        WHERE g.document_uuid = $1 AND g.deleted_at IS NULL
+    `, documentUUID)
+	if err != nil {
+		return nil, util.LogError("[GrantRepo] не удалось получить пользователей с grant", err)
+	}
+	return userUUIDs, nil
+}
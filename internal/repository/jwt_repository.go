@@ -7,8 +7,15 @@ import (
 	"context"
 	"database/sql"
 	"errors"
+	"fmt"
+	"github.com/jmoiron/sqlx"
+	"strconv"
+	"time"
 )
 
+// sessionTouchInterval : как часто обновляется время последнего использования сессии
+const sessionTouchInterval = time.Minute
+
 type JWTRepository struct {
 	*config.Database
 }
@@ -20,8 +27,8 @@ func NewJWTRepository(database *config.Database) *JWTRepository {
 // SaveRefreshToken сохраняет refresh-токен в базе данных
 // Возвращает ошибку, если операция не удалась
 func (r *JWTRepository) SaveRefreshToken(ctx context.Context, refreshToken *model.RefreshToken, ipAddress string) error {
-	query := `INSERT INTO refresh_tokens (uuid, user_uuid, token_hash, expire_at, used, user_agent, ip_address) 
-				VALUES ($1, $2, $3, $4, $5, $6, $7)
+	query := `INSERT INTO refresh_tokens (uuid, user_uuid, token_hash, expire_at, used, user_agent, ip_address, session_uuid) 
+				VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
 	`
 
 	_, err := r.DB.ExecContext(ctx, query,
@@ -32,6 +39,7 @@ func (r *JWTRepository) SaveRefreshToken(ctx context.Context, refreshToken *mode
 		refreshToken.Used,
 		refreshToken.UserAgent,
 		ipAddress,
+		refreshToken.SessionUUID,
 	)
 
 	if err != nil {
@@ -65,7 +73,10 @@ func (r *JWTRepository) MarkRefreshTokenUsedByUUID(ctx context.Context, refreshT
 // FindByUUID ищет refresh-токен в базе данных
 // Возвращает модель model.RefreshToken или ошибку, если не удалось найти токен
 func (r *JWTRepository) FindByUUID(ctx context.Context, refreshTokenUUID string) (*model.RefreshToken, error) {
-	query := `SELECT uuid, user_uuid, token_hash, expire_at, used, user_agent, ip_address FROM refresh_tokens WHERE uuid = $1`
+	query := `
+		SELECT uuid, user_uuid, token_hash, expire_at, used, user_agent, ip_address, session_uuid, last_used_at, revoked_at
+		FROM refresh_tokens WHERE uuid = $1
+	`
 
 	refreshToken := &model.RefreshToken{}
 
@@ -77,6 +88,9 @@ func (r *JWTRepository) FindByUUID(ctx context.Context, refreshTokenUUID string)
 		&refreshToken.Used,
 		&refreshToken.UserAgent,
 		&refreshToken.IpAddress,
+		&refreshToken.SessionUUID,
+		&refreshToken.LastUsedAt,
+		&refreshToken.RevokedAt,
 	)
 
 	if err != nil {
@@ -88,3 +102,146 @@ func (r *JWTRepository) FindByUUID(ctx context.Context, refreshTokenUUID string)
 
 	return refreshToken, nil
 }
+
+// RevokeRefreshToken отзывает refresh-токен при выходе: токен помечается использованным, revoked_at — временем выхода
+func (r *JWTRepository) RevokeRefreshToken(ctx context.Context, refreshTokenUUID string) error {
+	query := `UPDATE refresh_tokens SET used = TRUE, revoked_at = now() WHERE uuid = $1 AND revoked_at IS NULL`
+
+	result, err := r.DB.ExecContext(ctx, query, refreshTokenUUID)
+	if err != nil {
+		return util.LogError("[JWTRepo] не удалось отозвать рефреш токен", err)
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return util.LogError("[JWTRepo] не удалось проверить, отозван ли токен", err)
+	}
+	if rowsAffected == 0 {
+		return fmt.Errorf("[JWTRepo] не удалось найти токен для отзыва")
+	}
+	return nil
+}
+
+// TouchRefreshToken отмечает использование сессии не чаще раза в sessionTouchInterval, чтобы не писать в БД на каждый запрос.
+// refreshToken — только что прочитанный токен: если сессия недавно отмечена, запрос в БД не отправляется.
+// Условие в UPDATE защищает от повторной записи, когда параллельные запросы прочитали токен одновременно
+func (r *JWTRepository) TouchRefreshToken(ctx context.Context, refreshToken *model.RefreshToken) error {
+	if refreshToken.LastUsedAt != nil && time.Since(*refreshToken.LastUsedAt) < sessionTouchInterval {
+		return nil
+	}
+
+	query := `
+		UPDATE refresh_tokens SET last_used_at = now()
+		WHERE uuid = $1 AND (last_used_at IS NULL OR last_used_at < now() - $2 * interval '1 second')
+	`
+	if _, err := r.DB.ExecContext(ctx, query, refreshToken.UUID, int64(sessionTouchInterval.Seconds())); err != nil {
+		return util.LogError("[JWTRepo] не удалось обновить время использования сессии", err)
+	}
+	return nil
+}
+
+// ListSessions возвращает активные сессии пользователя: по одному действующему refresh-токену на сессию.
+// Время создания сессии — время входа, т.е. первого токена сессии
+func (r *JWTRepository) ListSessions(ctx context.Context, userUUID string) ([]*model.Session, error) {
+	query := `
+		SELECT t.session_uuid, t.uuid, COALESCE(t.user_agent, '') AS user_agent, COALESCE(t.ip_address, '') AS ip_address,
+			(SELECT min(s.created_at) FROM refresh_tokens s WHERE s.session_uuid = t.session_uuid) AS created_at,
+			COALESCE(t.last_used_at, t.created_at) AS last_used_at,
+			t.expire_at
+		FROM refresh_tokens t
+		WHERE t.user_uuid = $1 AND t.used = FALSE AND t.revoked_at IS NULL AND t.expire_at > now()
+		ORDER BY last_used_at DESC
+	`
+	sessions := []*model.Session{}
+	if err := sqlx.SelectContext(ctx, r.DB, &sessions, query, userUUID); err != nil {
+		return nil, util.LogError("[JWTRepo] не удалось получить сессии пользователя", err)
+	}
+	return sessions, nil
+}
+
+// RevokeSession отзывает сессию пользователя и возвращает uuid отозванных refresh-токенов — это jti
+// access-токенов, выданных вместе с ними. Возвращает ошибку "сессия не найдена", если активной сессии нет
+func (r *JWTRepository) RevokeSession(ctx context.Context, userUUID string, sessionUUID string) ([]string, error) {
+	query := `
+		UPDATE refresh_tokens SET used = TRUE, revoked_at = now()
+		WHERE user_uuid = $1 AND session_uuid = $2 AND used = FALSE AND revoked_at IS NULL
+		RETURNING uuid
+	`
+	tokenUUIDs := []string{}
+	if err := sqlx.SelectContext(ctx, r.DB, &tokenUUIDs, query, userUUID, sessionUUID); err != nil {
+		return nil, util.LogError("[JWTRepo] не удалось отозвать сессию", err)
+	}
+	if len(tokenUUIDs) == 0 {
+		return nil, fmt.Errorf("[JWTRepo] сессия не найдена")
+	}
+	return tokenUUIDs, nil
+}
+
+// RevokeSessions отзывает все активные сессии пользователя, кроме exceptSessionUUID (пустая строка — все).
+// Возвращает uuid отозванных refresh-токенов, по одному на сессию
+func (r *JWTRepository) RevokeSessions(ctx context.Context, userUUID string, exceptSessionUUID string) ([]string, error) {
+	query := `
+		UPDATE refresh_tokens SET used = TRUE, revoked_at = now()
+		WHERE user_uuid = $1 AND used = FALSE AND revoked_at IS NULL
+			AND ($2::uuid IS NULL OR session_uuid <> $2::uuid)
+		RETURNING uuid
+	`
+	tokenUUIDs := []string{}
+	if err := sqlx.SelectContext(ctx, r.DB, &tokenUUIDs, query, userUUID, nullableString(exceptSessionUUID)); err != nil {
+		return nil, util.LogError("[JWTRepo] не удалось отозвать сессии", err)
+	}
+	return tokenUUIDs, nil
+}
+
+// SaveSecurityEvent записывает событие в журнал безопасности пользователя
+func (r *JWTRepository) SaveSecurityEvent(ctx context.Context, event *model.SecurityEvent) error {
+	query := `
+		INSERT INTO security_events (user_uuid, event, session_uuid, ip_address, user_agent, details)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING id, created_at
+	`
+	err := r.DB.QueryRowContext(ctx, query,
+		event.UserUUID,
+		event.Event,
+		event.SessionUUID,
+		event.IpAddress,
+		event.UserAgent,
+		event.Details,
+	).Scan(&event.ID, &event.CreatedAt)
+	if err != nil {
+		return util.LogError("[JWTRepo] не удалось сохранить событие безопасности", err)
+	}
+	return nil
+}
+
+// ListSecurityEvents возвращает события безопасности пользователя от новых к старым.
+// cursor — id последнего события предыдущей страницы, пустая строка — первая страница
+func (r *JWTRepository) ListSecurityEvents(ctx context.Context, userUUID string, cursor string, limit int) ([]*model.SecurityEvent, string, error) {
+	query := `
+		SELECT id, user_uuid, event, session_uuid, ip_address, user_agent, details, created_at
+		FROM security_events
+		WHERE user_uuid = $1 AND ($2::bigint IS NULL OR id < $2::bigint)
+		ORDER BY id DESC
+		LIMIT $3
+	`
+
+	var beforeID *int64
+	if cursor != "" {
+		id, err := strconv.ParseInt(cursor, 10, 64)
+		if err != nil {
+			return nil, "", fmt.Errorf("invalid cursor format")
+		}
+		beforeID = &id
+	}
+
+	events := []*model.SecurityEvent{}
+	if err := sqlx.SelectContext(ctx, r.DB, &events, query, userUUID, beforeID, limit+1); err != nil {
+		return nil, "", util.LogError("[JWTRepo] не удалось получить журнал безопасности", err)
+	}
+
+	var nextCursor string
+	if len(events) > limit {
+		events = events[:limit]
+		nextCursor = strconv.FormatInt(events[len(events)-1].ID, 10)
+	}
+	return events, nextCursor, nil
+}
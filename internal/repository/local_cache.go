@@ -0,0 +1,177 @@
+package repository
+
+import (
+	"container/list"
+	"sync"
+	"time"
+)
+
+// LocalCacheStats : счётчики in-process кэша
+type LocalCacheStats struct {
+	Hits      uint64 `json:"hits"`
+	Misses    uint64 `json:"misses"`
+	Evictions uint64 `json:"evictions"`
+	Entries   int    `json:"entries"`
+	Bytes     int64  `json:"bytes"`
+}
+
+// LocalCache : потокобезопасный LRU-кэш в памяти процесса с ограничением по числу записей,
+// суммарному размеру в байтах и временем жизни записи
+type LocalCache[V any] struct {
+	mu         sync.Mutex
+	items      map[string]*list.Element
+	order      *list.List // в начале — недавно использованные записи
+	maxEntries int
+	maxBytes   int64
+	ttl        time.Duration
+	bytes      int64
+	stats      LocalCacheStats
+	now        func() time.Time
+	generation uint64 // растёт при каждом удалении, см. SetIfGeneration
+}
+
+type localCacheEntry[V any] struct {
+	key       string
+	value     V
+	size      int64
+	expiresAt time.Time
+}
+
+// NewLocalCache : maxEntries и maxBytes <= 0 означают отсутствие соответствующего ограничения
+func NewLocalCache[V any](maxEntries int, maxBytes int64, ttl time.Duration) *LocalCache[V] {
+	return &LocalCache[V]{
+		items:      make(map[string]*list.Element),
+		order:      list.New(),
+		maxEntries: maxEntries,
+		maxBytes:   maxBytes,
+		ttl:        ttl,
+		now:        time.Now,
+	}
+}
+
+// Get : значение по ключу; просроченная запись удаляется и считается промахом
+func (c *LocalCache[V]) Get(key string) (V, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var zero V
+	element, ok := c.items[key]
+	if !ok {
+		c.stats.Misses++
+		return zero, false
+	}
+
+	entry := element.Value.(*localCacheEntry[V])
+	if c.now().After(entry.expiresAt) {
+		c.removeElement(element)
+		c.stats.Misses++
+		return zero, false
+	}
+
+	c.order.MoveToFront(element)
+	c.stats.Hits++
+	return entry.value, true
+}
+
+// Set : сохраняет значение размером size байт. Значение больше лимита по байтам не кэшируется
+func (c *LocalCache[V]) Set(key string, value V, size int64) {
+	c.SetWithTTL(key, value, size, c.ttl)
+}
+
+// SetWithTTL : как Set, но с собственным временем жизни (не больше ttl кэша)
+func (c *LocalCache[V]) SetWithTTL(key string, value V, size int64, ttl time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.set(key, value, size, ttl)
+}
+
+// Generation : текущее поколение удалений. Его запоминают перед чтением значения из источника
+func (c *LocalCache[V]) Generation() uint64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.generation
+}
+
+// SetIfGeneration : как SetWithTTL, но только если с момента Generation() не было ни одного Delete или Flush.
+// Иначе значение могло устареть, пока его читали, и инвалидация уже прошла мимо него
+func (c *LocalCache[V]) SetIfGeneration(key string, value V, size int64, ttl time.Duration, generation uint64) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.generation != generation {
+		return false
+	}
+	c.set(key, value, size, ttl)
+	return true
+}
+
+func (c *LocalCache[V]) set(key string, value V, size int64, ttl time.Duration) {
+	if ttl <= 0 || (c.ttl > 0 && ttl > c.ttl) {
+		ttl = c.ttl
+	}
+
+	if element, ok := c.items[key]; ok {
+		c.removeElement(element)
+	}
+	if c.maxBytes > 0 && size > c.maxBytes {
+		return
+	}
+
+	entry := &localCacheEntry[V]{key: key, value: value, size: size, expiresAt: c.now().Add(ttl)}
+	c.items[key] = c.order.PushFront(entry)
+	c.bytes += size
+
+	for c.overLimit() {
+		c.removeElement(c.order.Back())
+		c.stats.Evictions++
+	}
+}
+
+// Delete : удаляет запись
+func (c *LocalCache[V]) Delete(key string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.generation++
+	if element, ok := c.items[key]; ok {
+		c.removeElement(element)
+	}
+}
+
+// Flush : удаляет все записи
+func (c *LocalCache[V]) Flush() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.generation++
+	c.items = make(map[string]*list.Element)
+	c.order.Init()
+	c.bytes = 0
+}
+
+// Stats : снимок счётчиков
+func (c *LocalCache[V]) Stats() LocalCacheStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	stats := c.stats
+	stats.Entries = len(c.items)
+	stats.Bytes = c.bytes
+	return stats
+}
+
+func (c *LocalCache[V]) overLimit() bool {
+	if c.order.Len() == 0 {
+		return false
+	}
+	return (c.maxEntries > 0 && c.order.Len() > c.maxEntries) || (c.maxBytes > 0 && c.bytes > c.maxBytes)
+}
+
+func (c *LocalCache[V]) removeElement(element *list.Element) {
+	entry := element.Value.(*localCacheEntry[V])
+	c.order.Remove(element)
+	delete(c.items, entry.key)
+	c.bytes -= entry.size
+}
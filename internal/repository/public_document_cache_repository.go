@@ -0,0 +1,182 @@
+package repository
+
+import (
+	"caching-web-server/internal/model"
+	"caching-web-server/internal/util"
+	"context"
+	"errors"
+	"fmt"
+	"github.com/redis/go-redis/v9"
+	"time"
+)
+
+// storePublicDocumentScript : сохраняет публичную копию, если документ не менялся после её загрузки из БД
+var storePublicDocumentScript = redis.NewScript(`
+local current = tonumber(redis.call("GET", KEYS[2]) or "0")
+if current > tonumber(ARGV[1]) then
+	return 0
+end
+redis.call("SET", KEYS[1], ARGV[2], "PX", ARGV[3])
+return 1
+`)
+
+// GetPublicDocument : свежая публичная копия документа или nil. Публичные копии хранятся отдельно от записи
+// документа: в них нет списка grant, который видит только владелец
+func (r *CacheRepository) GetPublicDocument(ctx context.Context, uuid string) (*model.Document, error) {
+	started := time.Now()
+	val, err := r.client.Get(ctx, r.publicDocumentKey(uuid)).Bytes()
+	if errors.Is(err, redis.Nil) {
+		r.observe("public_document", "get", cacheMiss, started, -1)
+		return nil, nil
+	} else if err != nil {
+		r.observe("public_document", "get", cacheError, started, -1)
+		return nil, util.LogError("[CacheRepo] ошибка получения публичного документа из Redis", err)
+	}
+
+	entry, err := r.codec.decodeEntry(val)
+	if errors.Is(err, errUnknownCacheEnvelope) {
+		r.observe("public_document", "get", cacheMiss, started, -1)
+		return nil, nil
+	} else if err != nil {
+		r.observe("public_document", "get", cacheError, started, -1)
+		return nil, util.LogError("[CacheRepo] ошибка десериализации публичного документа из кэша", err)
+	}
+	if time.Now().After(entry.FreshUntil) {
+		r.observe("public_document", "get", cacheStale, started, len(val))
+		return nil, nil
+	}
+	r.observe("public_document", "get", cacheHit, started, len(val))
+	return entry.Document, nil
+}
+
+// SetPublicDocument : сохраняет публичную копию документа без списка grant
+func (r *CacheRepository) SetPublicDocument(ctx context.Context, document *model.Document) error {
+	public := document.Clone()
+	public.GrantLogins = nil
+
+	now := time.Now()
+	ttl := r.jitteredTTL()
+	data, err := r.codec.encodeEntry(cacheEntry{StoredAt: now, FreshUntil: now.Add(ttl), Document: public})
+	if err != nil {
+		return util.LogError("[CacheRepo] ошибка сериализации публичного документа", err)
+	}
+
+	keys := []string{r.publicDocumentKey(document.UUID), r.versionKey(document.UUID)}
+	if err := storePublicDocumentScript.Run(ctx, r.client, keys, document.Version, data, ttl.Milliseconds()).Err(); err != nil {
+		r.observe("public_document", "set", cacheError, now, -1)
+		return util.LogError("[CacheRepo] ошибка сохранения публичного документа в Redis", err)
+	}
+	r.observe("public_document", "set", cacheSet, now, len(data))
+	return nil
+}
+
+// GetPublicTokenUUID : UUID документа по публичному токену или пустая строка
+func (r *CacheRepository) GetPublicTokenUUID(ctx context.Context, token string) (string, error) {
+	started := time.Now()
+	val, err := r.client.Get(ctx, r.tokenKey(token)).Bytes()
+	if errors.Is(err, redis.Nil) {
+		r.observe("token", "get", cacheMiss, started, -1)
+		return "", nil
+	} else if err != nil {
+		r.observe("token", "get", cacheError, started, -1)
+		return "", util.LogError("[CacheRepo] ошибка чтения токена из Redis", err)
+	}
+
+	var uuid string
+	if err := r.codec.decodeJSON(val, &uuid); errors.Is(err, errUnknownCacheEnvelope) {
+		r.observe("token", "get", cacheMiss, started, -1)
+		return "", nil
+	} else if err != nil {
+		r.observe("token", "get", cacheError, started, -1)
+		return "", util.LogError("[CacheRepo] ошибка десериализации токена", err)
+	}
+	r.observe("token", "get", cacheHit, started, len(val))
+	return uuid, nil
+}
+
+// SetPublicTokenUUID : запоминает, какому документу принадлежит токен. Запись не инвалидируется явно:
+// после смены токена или is_public публичная копия документа перестаёт ей соответствовать и запись игнорируется
+func (r *CacheRepository) SetPublicTokenUUID(ctx context.Context, token string, uuid string) error {
+	started := time.Now()
+	data, err := r.codec.encodeJSON(uuid)
+	if err != nil {
+		return util.LogError("[CacheRepo] ошибка сериализации токена", err)
+	}
+	if err := r.client.Set(ctx, r.tokenKey(token), data, r.ttl).Err(); err != nil {
+		r.observe("token", "set", cacheError, started, -1)
+		return util.LogError("[CacheRepo] ошибка сохранения токена в Redis", err)
+	}
+	r.observe("token", "set", cacheSet, started, len(data))
+	return nil
+}
+
+// GetPresignedURL : закэшированная pre-signed ссылка на объект, выданная на срок expire, или nil
+func (r *CacheRepository) GetPresignedURL(ctx context.Context, storagePath string, expire time.Duration) (*model.PresignedURL, error) {
+	if r.options.PresignMinRemaining <= 0 {
+		return nil, nil
+	}
+
+	started := time.Now()
+	val, err := r.client.Get(ctx, r.presignKey(storagePath, expire)).Bytes()
+	if errors.Is(err, redis.Nil) {
+		r.observe("presign", "get", cacheMiss, started, -1)
+		return nil, nil
+	} else if err != nil {
+		r.observe("presign", "get", cacheError, started, -1)
+		return nil, util.LogError("[CacheRepo] ошибка чтения pre-signed ссылки из Redis", err)
+	}
+
+	var url model.PresignedURL
+	if err := r.codec.decodeJSON(val, &url); errors.Is(err, errUnknownCacheEnvelope) {
+		r.observe("presign", "get", cacheMiss, started, -1)
+		return nil, nil
+	} else if err != nil {
+		r.observe("presign", "get", cacheError, started, -1)
+		return nil, util.LogError("[CacheRepo] ошибка десериализации pre-signed ссылки", err)
+	}
+	// ключ истекает сам, проверка на случай расхождения часов реплик
+	if time.Until(url.ExpiresAt) < r.options.PresignMinRemaining {
+		r.observe("presign", "get", cacheStale, started, len(val))
+		return nil, nil
+	}
+	r.observe("presign", "get", cacheHit, started, len(val))
+	return &url, nil
+}
+
+// SetPresignedURL : сохраняет ссылку на ту часть её срока, пока до истечения остаётся не меньше PresignMinRemaining
+func (r *CacheRepository) SetPresignedURL(ctx context.Context, storagePath string, expire time.Duration, url *model.PresignedURL) error {
+	if r.options.PresignMinRemaining <= 0 {
+		return nil
+	}
+
+	ttl := time.Until(url.ExpiresAt) - r.options.PresignMinRemaining
+	if ttl <= 0 {
+		return nil
+	}
+
+	started := time.Now()
+	data, err := r.codec.encodeJSON(url)
+	if err != nil {
+		return util.LogError("[CacheRepo] ошибка сериализации pre-signed ссылки", err)
+	}
+	if err := r.client.Set(ctx, r.presignKey(storagePath, expire), data, ttl).Err(); err != nil {
+		r.observe("presign", "set", cacheError, started, -1)
+		return util.LogError("[CacheRepo] ошибка сохранения pre-signed ссылки в Redis", err)
+	}
+	r.observe("presign", "set", cacheSet, started, len(data))
+	return nil
+}
+
+// publicDocumentKey : hash tag совпадает со слотом ключа документа и его версии
+func (r *CacheRepository) publicDocumentKey(uuid string) string {
+	return fmt.Sprintf("document:public:{%s}", r.key(uuid))
+}
+
+func (r *CacheRepository) tokenKey(token string) string {
+	return fmt.Sprintf("document:token:%s", token)
+}
+
+// presignKey : ссылки с разным сроком действия не подменяют друг друга — у каждого срока свой ключ
+func (r *CacheRepository) presignKey(storagePath string, expire time.Duration) string {
+	return fmt.Sprintf("document:presign:%d:%s", int64(expire.Seconds()), storagePath)
+}
@@ -0,0 +1,88 @@
+package repository
+
+import (
+	"caching-web-server/config"
+	"caching-web-server/internal/model"
+	"caching-web-server/internal/util"
+	"context"
+	"database/sql"
+	"errors"
+	"github.com/jmoiron/sqlx"
+)
+
+type QuotaRepository struct {
+	*config.Database
+}
+
+func NewQuotaRepository(database *config.Database) *QuotaRepository {
+	return &QuotaRepository{database}
+}
+
+// GetQuota : возвращает индивидуальную квоту пользователя или nil, если действует квота по умолчанию
+func (r *QuotaRepository) GetQuota(ctx context.Context, exec sqlx.ExtContext, userUUID string) (*int64, error) {
+	var quotaBytes int64
+	err := sqlx.GetContext(ctx, exec, &quotaBytes, `SELECT quota_bytes FROM user_quotas WHERE user_uuid = $1`, userUUID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, util.LogError("[QuotaRepo] не удалось получить квоту пользователя", err)
+	}
+	return &quotaBytes, nil
+}
+
+// SetQuota : устанавливает индивидуальную квоту пользователя
+func (r *QuotaRepository) SetQuota(ctx context.Context, exec sqlx.ExtContext, userUUID string, quotaBytes int64) error {
+	query := `
+		INSERT INTO user_quotas (user_uuid, quota_bytes, updated_at)
+		VALUES ($1, $2, NOW())
+		ON CONFLICT (user_uuid) DO UPDATE SET quota_bytes = EXCLUDED.quota_bytes, updated_at = NOW()
+	`
+	if _, err := exec.ExecContext(ctx, query, userUUID, quotaBytes); err != nil {
+		return util.LogError("[QuotaRepo] не удалось сохранить квоту пользователя", err)
+	}
+	return nil
+}
+
+// DeleteQuota : удаляет индивидуальную квоту, после чего действует квота по умолчанию
+func (r *QuotaRepository) DeleteQuota(ctx context.Context, exec sqlx.ExtContext, userUUID string) error {
+	if _, err := exec.ExecContext(ctx, `DELETE FROM user_quotas WHERE user_uuid = $1`, userUUID); err != nil {
+		return util.LogError("[QuotaRepo] не удалось удалить квоту пользователя", err)
+	}
+	return nil
+}
+
+// LockUsage : берёт advisory-блокировку пользователя до конца транзакции,
+// чтобы параллельные загрузки не могли одновременно пройти проверку квоты
+func (r *QuotaRepository) LockUsage(ctx context.Context, exec sqlx.ExtContext, userUUID string) error {
+	if _, err := exec.ExecContext(ctx, `SELECT pg_advisory_xact_lock(hashtext($1))`, userUUID); err != nil {
+		return util.LogError("[QuotaRepo] не удалось заблокировать квоту пользователя", err)
+	}
+	return nil
+}
+
+// GetUsedBytes : суммарный размер документов пользователя
+func (r *QuotaRepository) GetUsedBytes(ctx context.Context, exec sqlx.ExtContext, userUUID string) (int64, error) {
+	var used int64
+	query := `SELECT COALESCE(SUM(size_bytes), 0) FROM documents WHERE owner_uuid = $1 AND deleted_at IS NULL`
+	if err := sqlx.GetContext(ctx, exec, &used, query, userUUID); err != nil {
+		return 0, util.LogError("[QuotaRepo] не удалось посчитать занятое место", err)
+	}
+	return used, nil
+}
+
+// GetUsageByMime : занятое место и количество документов пользователя в разрезе MIME-типов
+func (r *QuotaRepository) GetUsageByMime(ctx context.Context, exec sqlx.ExtContext, userUUID string) ([]model.MimeUsage, error) {
+	query := `
+		SELECT mime_type, COALESCE(SUM(size_bytes), 0) AS bytes, COUNT(*) AS count
+		FROM documents
+		WHERE owner_uuid = $1 AND deleted_at IS NULL
+		GROUP BY mime_type
+		ORDER BY bytes DESC, mime_type ASC
+	`
+	usage := []model.MimeUsage{}
+	if err := sqlx.SelectContext(ctx, exec, &usage, query, userUUID); err != nil {
+		return nil, util.LogError("[QuotaRepo] не удалось получить статистику по MIME-типам", err)
+	}
+	return usage, nil
+}
@@ -0,0 +1,226 @@
+package repository
+
+import (
+	"caching-web-server/internal/model"
+	"context"
+	"errors"
+	"github.com/redis/go-redis/v9"
+	"io"
+	"log"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ErrCacheUnavailable : Redis признан недоступным, команда не отправлялась
+var ErrCacheUnavailable = errors.New("[CacheRepo] Redis недоступен, кэш временно отключён")
+
+// probeContextKey : ping предохранителя проходит и при разомкнутой цепи
+type probeContextKey struct{}
+
+// CircuitBreakerOptions : настройки предохранителя Redis
+type CircuitBreakerOptions struct {
+	FailureThreshold int           // сколько ошибок соединения подряд размыкают цепь
+	ProbeInterval    time.Duration // как часто проверять Redis, пока цепь разомкнута
+	ProbeTimeout     time.Duration
+}
+
+// RedisCircuitBreaker : hook go-redis, который после серии сетевых ошибок перестаёт отправлять команды в Redis,
+// чтобы запросы не ждали таймаута, и периодически проверяет, не восстановился ли он
+type RedisCircuitBreaker struct {
+	client  redis.UniversalClient
+	options CircuitBreakerOptions
+
+	mu        sync.Mutex
+	state     string
+	since     time.Time
+	failures  int
+	onRecover []func(ctx context.Context)
+
+	trips    atomic.Int64
+	rejected atomic.Int64
+}
+
+func NewRedisCircuitBreaker(client redis.UniversalClient, options CircuitBreakerOptions) *RedisCircuitBreaker {
+	if options.FailureThreshold <= 0 {
+		options.FailureThreshold = 5
+	}
+	if options.ProbeInterval <= 0 {
+		options.ProbeInterval = 5 * time.Second
+	}
+	if options.ProbeTimeout <= 0 {
+		options.ProbeTimeout = time.Second
+	}
+
+	breaker := &RedisCircuitBreaker{client: client, options: options, state: model.CacheStateClosed, since: time.Now()}
+	client.AddHook(breaker)
+	return breaker
+}
+
+// OnRecover : вызывается после восстановления Redis, до того как цепь снова замкнётся
+func (b *RedisCircuitBreaker) OnRecover(fn func(ctx context.Context)) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.onRecover = append(b.onRecover, fn)
+}
+
+// Open : размыкает цепь сразу, например если Redis недоступен при старте
+func (b *RedisCircuitBreaker) Open(reason error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.open(reason)
+}
+
+// Run : пока цепь разомкнута, проверяет Redis ping'ом каждые ProbeInterval
+func (b *RedisCircuitBreaker) Run(ctx context.Context) {
+	ticker := time.NewTicker(b.options.ProbeInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if b.State() == model.CacheStateOpen {
+				b.probe(ctx)
+			}
+		}
+	}
+}
+
+func (b *RedisCircuitBreaker) probe(ctx context.Context) {
+	probeCtx, cancel := context.WithTimeout(context.WithValue(ctx, probeContextKey{}, true), b.options.ProbeTimeout)
+	defer cancel()
+
+	if err := b.client.Ping(probeCtx).Err(); err != nil {
+		return
+	}
+
+	b.mu.Lock()
+	if b.state != model.CacheStateOpen {
+		b.mu.Unlock()
+		return
+	}
+	b.state = model.CacheStateHalfOpen
+	b.since = time.Now()
+	b.failures = 0
+	callbacks := append([]func(ctx context.Context){}, b.onRecover...)
+	b.mu.Unlock()
+	// колбэки (например, досылка пропущенных инвалидаций) выполняются до замыкания цепи,
+	// чтобы никто не прочитал из Redis запись, которую не успели удалить. Ошибка соединения
+	// во время досылки снова размыкает цепь, и цепь не замыкается
+	recoverCtx := context.WithValue(ctx, probeContextKey{}, true)
+	for _, fn := range callbacks {
+		fn(recoverCtx)
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.state == model.CacheStateHalfOpen {
+		b.state = model.CacheStateClosed
+		b.since = time.Now()
+		b.failures = 0
+		log.Printf("[CacheRepo] Redis снова доступен, кэш включён")
+	}
+}
+
+// State : текущее состояние цепи
+func (b *RedisCircuitBreaker) State() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.state
+}
+
+// Health : состояние для health-check и метрик
+func (b *RedisCircuitBreaker) Health() model.CacheHealth {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return model.CacheHealth{
+		State:               b.state,
+		Since:               b.since,
+		ConsecutiveFailures: b.failures,
+		Trips:               b.trips.Load(),
+		Rejected:            b.rejected.Load(),
+	}
+}
+
+func (b *RedisCircuitBreaker) DialHook(next redis.DialHook) redis.DialHook {
+	return next
+}
+
+func (b *RedisCircuitBreaker) ProcessHook(next redis.ProcessHook) redis.ProcessHook {
+	return func(ctx context.Context, cmd redis.Cmder) error {
+		if b.allow(ctx) == false {
+			cmd.SetErr(ErrCacheUnavailable)
+			return ErrCacheUnavailable
+		}
+		err := next(ctx, cmd)
+		b.record(err)
+		return err
+	}
+}
+
+func (b *RedisCircuitBreaker) ProcessPipelineHook(next redis.ProcessPipelineHook) redis.ProcessPipelineHook {
+	return func(ctx context.Context, cmds []redis.Cmder) error {
+		if b.allow(ctx) == false {
+			for _, cmd := range cmds {
+				cmd.SetErr(ErrCacheUnavailable)
+			}
+			return ErrCacheUnavailable
+		}
+		err := next(ctx, cmds)
+		b.record(err)
+		return err
+	}
+}
+
+func (b *RedisCircuitBreaker) allow(ctx context.Context) bool {
+	if ctx.Value(probeContextKey{}) != nil {
+		return true
+	}
+	if b.State() == model.CacheStateClosed {
+		return true
+	}
+	b.rejected.Add(1)
+	return false
+}
+
+// record : цепь размыкают только ошибки соединения — ответы Redis с ошибкой и redis.Nil говорят о том, что он жив
+func (b *RedisCircuitBreaker) record(err error) {
+	failure := isConnectionError(err)
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if failure == false {
+		b.failures = 0
+		return
+	}
+	b.failures++
+	if b.state == model.CacheStateHalfOpen || (b.state == model.CacheStateClosed && b.failures >= b.options.FailureThreshold) {
+		b.open(err)
+	}
+}
+
+func (b *RedisCircuitBreaker) open(reason error) {
+	if b.state == model.CacheStateOpen {
+		return
+	}
+	b.state = model.CacheStateOpen
+	b.since = time.Now()
+	b.trips.Add(1)
+	log.Printf("[CacheRepo] Redis недоступен, кэш отключён до восстановления: %v", reason)
+}
+
+func isConnectionError(err error) bool {
+	if err == nil || errors.Is(err, redis.Nil) || errors.Is(err, context.Canceled) || errors.Is(err, ErrCacheUnavailable) {
+		return false
+	}
+	var redisErr redis.Error
+	if errors.As(err, &redisErr) {
+		return false
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr) || errors.Is(err, io.EOF) || errors.Is(err, context.DeadlineExceeded) ||
+		errors.Is(err, redis.ErrClosed) || errors.Is(err, redis.ErrPoolTimeout)
+}
@@ -0,0 +1,220 @@
+package repository
+
+import (
+	"caching-web-server/internal/model"
+	"context"
+	"errors"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeRedis : hook, который отвечает на команды вместо Redis. Добавляется после предохранителя,
+// поэтому видит только пропущенные им команды. down — имитация разорванного соединения;
+// скрипты и целочисленные команды всегда возвращают 1, аргументы скриптов сохраняются в scripts.
+// GET возвращает значение из values, если оно там есть
+type fakeRedis struct {
+	mu       sync.Mutex
+	down     bool
+	sets     map[string][]string
+	values   map[string]string
+	commands []string
+	scripts  [][]interface{}
+}
+
+func newFakeRedisClient(t *testing.T) (*redis.Client, *fakeRedis) {
+	t.Helper()
+	client := redis.NewClient(&redis.Options{Addr: "127.0.0.1:0"})
+	t.Cleanup(func() { _ = client.Close() })
+	return client, &fakeRedis{sets: map[string][]string{}, values: map[string]string{}}
+}
+
+func (f *fakeRedis) setDown(down bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.down = down
+}
+
+// takeCommands : команды вида "del document:doc1", дошедшие до Redis с прошлого вызова
+func (f *fakeRedis) takeCommands() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	commands := f.commands
+	f.commands = nil
+	return commands
+}
+
+func (f *fakeRedis) reply(cmd redis.Cmder) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	args := make([]string, 0, len(cmd.Args()))
+	for _, arg := range cmd.Args() {
+		if value, ok := arg.(string); ok {
+			args = append(args, value)
+		}
+	}
+	f.commands = append(f.commands, strings.Join(args, " "))
+
+	if f.down {
+		err := &net.OpError{Op: "dial", Net: "tcp", Err: errors.New("connection refused")}
+		cmd.SetErr(err)
+		return err
+	}
+	switch c := cmd.(type) {
+	case *redis.Cmd:
+		f.scripts = append(f.scripts, cmd.Args())
+		c.SetVal(int64(1))
+	case *redis.StatusCmd:
+		c.SetVal("PONG")
+	case *redis.StringSliceCmd:
+		c.SetVal(f.sets[args[1]])
+	case *redis.StringCmd:
+		if value, ok := f.values[args[1]]; ok {
+			c.SetVal(value)
+		}
+	case *redis.IntCmd:
+		c.SetVal(1)
+	}
+	return nil
+}
+
+func (f *fakeRedis) DialHook(next redis.DialHook) redis.DialHook {
+	return next
+}
+
+func (f *fakeRedis) ProcessHook(next redis.ProcessHook) redis.ProcessHook {
+	return func(ctx context.Context, cmd redis.Cmder) error {
+		return f.reply(cmd)
+	}
+}
+
+func (f *fakeRedis) ProcessPipelineHook(next redis.ProcessPipelineHook) redis.ProcessPipelineHook {
+	return func(ctx context.Context, cmds []redis.Cmder) error {
+		var first error
+		for _, cmd := range cmds {
+			if err := f.reply(cmd); err != nil && first == nil {
+				first = err
+			}
+		}
+		return first
+	}
+}
+
+func newTestBreaker(t *testing.T) (*RedisCircuitBreaker, *redis.Client, *fakeRedis) {
+	client, fake := newFakeRedisClient(t)
+	breaker := NewRedisCircuitBreaker(client, CircuitBreakerOptions{FailureThreshold: 2, ProbeInterval: time.Hour})
+	client.AddHook(fake)
+	return breaker, client, fake
+}
+
+func TestRedisCircuitBreaker_StateMachine(t *testing.T) {
+	ctx := context.Background()
+	breaker, client, fake := newTestBreaker(t)
+	assert.Equal(t, model.CacheStateClosed, breaker.State())
+
+	// closed → open после FailureThreshold ошибок соединения подряд; ответ Redis с ошибкой цепь не размыкает
+	fake.setDown(true)
+	assert.Error(t, client.Get(ctx, "document:doc1").Err())
+	assert.Equal(t, model.CacheStateClosed, breaker.State())
+	assert.Error(t, client.Get(ctx, "document:doc1").Err())
+	assert.Equal(t, model.CacheStateOpen, breaker.State())
+	fake.takeCommands()
+
+	// open: команды не отправляются в Redis
+	assert.ErrorIs(t, client.Get(ctx, "document:doc1").Err(), ErrCacheUnavailable)
+	assert.Empty(t, fake.takeCommands())
+
+	// ping не прошёл — цепь остаётся разомкнутой, колбэки не вызываются
+	recovered := 0
+	breaker.OnRecover(func(recoverCtx context.Context) {
+		recovered++
+		// half_open: колбэки восстановления ходят в Redis, обычные запросы ещё отклоняются
+		assert.Equal(t, model.CacheStateHalfOpen, breaker.State())
+		assert.NoError(t, client.Del(recoverCtx, "document:doc1").Err())
+		assert.ErrorIs(t, client.Get(ctx, "document:doc1").Err(), ErrCacheUnavailable)
+	})
+	breaker.probe(ctx)
+	assert.Equal(t, model.CacheStateOpen, breaker.State())
+	assert.Equal(t, 0, recovered)
+
+	// half_open → closed после колбэков
+	fake.setDown(false)
+	breaker.probe(ctx)
+	assert.Equal(t, 1, recovered)
+	assert.Equal(t, model.CacheStateClosed, breaker.State())
+	assert.NoError(t, client.Get(ctx, "document:doc1").Err())
+
+	health := breaker.Health()
+	assert.Equal(t, int64(1), health.Trips)
+	assert.Equal(t, int64(2), health.Rejected)
+	assert.Equal(t, 0, health.ConsecutiveFailures)
+}
+
+func TestRedisCircuitBreaker_FailureWhileHalfOpenReopens(t *testing.T) {
+	ctx := context.Background()
+	breaker, client, fake := newTestBreaker(t)
+	breaker.Open(errors.New("Redis недоступен при старте"))
+
+	// ping прошёл, но соединение снова оборвалось во время досылки инвалидаций
+	breaker.OnRecover(func(recoverCtx context.Context) {
+		fake.setDown(true)
+		assert.Error(t, client.Del(recoverCtx, "document:doc1").Err())
+	})
+	breaker.probe(ctx)
+
+	assert.Equal(t, model.CacheStateOpen, breaker.State())
+	assert.Equal(t, int64(2), breaker.Health().Trips)
+	assert.ErrorIs(t, client.Get(ctx, "document:doc1").Err(), ErrCacheUnavailable)
+}
+
+func TestReplayInvalidations_AfterRecovery(t *testing.T) {
+	ctx := context.Background()
+	breaker, client, fake := newTestBreaker(t)
+	repo := NewCacheRepository(client, time.Minute, nil, CacheOptions{ListTTL: time.Minute})
+	breaker.OnRecover(repo.ReplayInvalidations)
+
+	ownerTag := repo.listOwnerTag("owner1")
+	fake.sets[ownerTag] = []string{"document:list:{owner:owner1}:page:1"}
+
+	// пока цепь разомкнута, инвалидации документа и списков владельца не доходят до Redis и запоминаются
+	breaker.Open(errors.New("Redis недоступен"))
+	assert.Error(t, repo.DeleteDocument(ctx, "doc1"))
+	assert.Error(t, repo.InvalidateOwnerLists(ctx, "owner1"))
+	assert.Empty(t, fake.takeCommands())
+
+	breaker.probe(ctx)
+	require.Equal(t, model.CacheStateClosed, breaker.State())
+
+	commands := fake.takeCommands()
+	assert.Contains(t, commands, "del "+repo.key("doc1"))
+	assert.Contains(t, commands, "del "+repo.publicDocumentKey("doc1"))
+	assert.Contains(t, commands, "del "+repo.grantSetKey("doc1"))
+	assert.Contains(t, commands, "smembers "+ownerTag)
+	assert.Contains(t, commands, "del document:list:{owner:owner1}:page:1")
+
+	// очередь пуста — повторная досылка ничего не отправляет
+	repo.ReplayInvalidations(ctx)
+	assert.Empty(t, fake.takeCommands())
+}
+
+func TestReplayInvalidations_FailedReplayStaysQueued(t *testing.T) {
+	ctx := context.Background()
+	client, fake := newFakeRedisClient(t)
+	client.AddHook(fake)
+	repo := NewCacheRepository(client, time.Minute, nil, CacheOptions{ListTTL: time.Minute})
+
+	fake.setDown(true)
+	assert.Error(t, repo.InvalidateOwnerLists(ctx, "owner1"))
+	repo.ReplayInvalidations(ctx)
+
+	fake.setDown(false)
+	fake.takeCommands()
+	repo.ReplayInvalidations(ctx)
+	assert.Equal(t, []string{"smembers " + repo.listOwnerTag("owner1")}, fake.takeCommands())
+}
@@ -0,0 +1,166 @@
+package repository
+
+import (
+	"caching-web-server/config"
+	"caching-web-server/internal/model"
+	"caching-web-server/internal/util"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/jmoiron/sqlx"
+	"time"
+)
+
+type RetentionRepository struct {
+	*config.Database
+}
+
+func NewRetentionRepository(database *config.Database) *RetentionRepository {
+	return &RetentionRepository{database}
+}
+
+// ListPolicies : все политики хранения
+func (r *RetentionRepository) ListPolicies(ctx context.Context, exec sqlx.ExtContext) ([]model.RetentionPolicy, error) {
+	policies := []model.RetentionPolicy{}
+	query := `SELECT uuid, mime_type, retention_days, created_at FROM retention_policies ORDER BY mime_type ASC`
+	if err := sqlx.SelectContext(ctx, exec, &policies, query); err != nil {
+		return nil, util.LogError("[RetentionRepo] не удалось получить политики хранения", err)
+	}
+	return policies, nil
+}
+
+// SavePolicy : создаёт политику для MIME-типа или обновляет срок существующей
+func (r *RetentionRepository) SavePolicy(ctx context.Context, exec sqlx.ExtContext, policy *model.RetentionPolicy) error {
+	query := `
+		INSERT INTO retention_policies (uuid, mime_type, retention_days)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (mime_type) DO UPDATE SET retention_days = EXCLUDED.retention_days
+		RETURNING uuid, created_at
+	`
+	row := exec.QueryRowxContext(ctx, query, policy.UUID, policy.MimeType, policy.RetentionDays)
+	if err := row.Scan(&policy.UUID, &policy.CreatedAt); err != nil {
+		return util.LogError("[RetentionRepo] не удалось сохранить политику хранения", err)
+	}
+	return nil
+}
+
+// DeletePolicy : удаляет политику. Уже выставленные документам сроки не меняются
+func (r *RetentionRepository) DeletePolicy(ctx context.Context, exec sqlx.ExtContext, policyUUID string) error {
+	result, err := exec.ExecContext(ctx, `DELETE FROM retention_policies WHERE uuid = $1`, policyUUID)
+	if err != nil {
+		return util.LogError("[RetentionRepo] не удалось удалить политику хранения", err)
+	}
+	if affected, _ := result.RowsAffected(); affected == 0 {
+		return fmt.Errorf("[RetentionRepo] политика хранения не найдена")
+	}
+	return nil
+}
+
+// ApplyPolicy : выставляет срок хранения существующим документам без срока, подходящим под политику.
+// Возвращает изменённые документы (uuid, владелец и новая версия), чтобы обновить их в кэше
+func (r *RetentionRepository) ApplyPolicy(ctx context.Context, exec sqlx.ExtContext, policy *model.RetentionPolicy) ([]model.Document, error) {
+	query := `
+		UPDATE documents
+		SET expires_at = created_at + $2 * INTERVAL '1 day', updated_at = NOW(), version = version + 1
+		WHERE expires_at IS NULL AND deleted_at IS NULL
+		  AND (mime_type = $1 OR ($1 LIKE '%/*' AND mime_type LIKE rtrim($1, '*') || '%'))
+		RETURNING uuid, owner_uuid, version
+	`
+	docs := []model.Document{}
+	if err := sqlx.SelectContext(ctx, exec, &docs, query, policy.MimeType, policy.RetentionDays); err != nil {
+		return nil, util.LogError("[RetentionRepo] не удалось применить политику хранения", err)
+	}
+	return docs, nil
+}
+
+// PolicyExpiry : крайний срок хранения документа владельца по политике для его MIME-типа, nil — политики нет
+// (или документ не найден). Точное совпадение MIME-типа приоритетнее шаблона, как при создании документа
+func (r *RetentionRepository) PolicyExpiry(ctx context.Context, exec sqlx.ExtContext, documentUUID, ownerUUID string) (*time.Time, error) {
+	query := `
+		SELECT d.created_at + p.retention_days * INTERVAL '1 day'
+		FROM documents AS d
+		JOIN retention_policies AS p
+		  ON p.mime_type = d.mime_type
+		  OR (p.mime_type LIKE '%/*' AND d.mime_type LIKE rtrim(p.mime_type, '*') || '%')
+		WHERE d.uuid = $1 AND d.owner_uuid = $2 AND d.deleted_at IS NULL
+		ORDER BY (p.mime_type = d.mime_type) DESC, p.retention_days ASC
+		LIMIT 1
+	`
+	var expiresAt time.Time
+	err := sqlx.GetContext(ctx, exec, &expiresAt, query, documentUUID, ownerUUID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	} else if err != nil {
+		return nil, util.LogError("[RetentionRepo] не удалось получить срок хранения по политике", err)
+	}
+	return &expiresAt, nil
+}
+
+// SetExpiry : меняет срок хранения документа владельца (nil — хранить бессрочно), возвращает новую версию документа
+func (r *RetentionRepository) SetExpiry(ctx context.Context, exec sqlx.ExtContext, documentUUID, ownerUUID string, expiresAt *time.Time) (int64, error) {
+	query := `
+		UPDATE documents SET expires_at = $3, updated_at = NOW(), version = version + 1
+		WHERE uuid = $1 AND owner_uuid = $2 AND deleted_at IS NULL
+		RETURNING version
+	`
+	var version int64
+	err := sqlx.GetContext(ctx, exec, &version, query, documentUUID, ownerUUID, expiresAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, fmt.Errorf("[RetentionRepo] документ не найден")
+	} else if err != nil {
+		return 0, util.LogError("[RetentionRepo] не удалось изменить срок хранения документа", err)
+	}
+	return version, nil
+}
+
+// SetLegalHold : ставит или снимает юридическое удержание документа.
+// Возвращает uuid, владельца и новую версию документа, как ApplyPolicy
+func (r *RetentionRepository) SetLegalHold(ctx context.Context, exec sqlx.ExtContext, documentUUID string, hold bool) (*model.Document, error) {
+	query := `
+		UPDATE documents SET legal_hold = $2, updated_at = NOW(), version = version + 1
+		WHERE uuid = $1 AND deleted_at IS NULL
+		RETURNING uuid, owner_uuid, version
+	`
+	var document model.Document
+	err := sqlx.GetContext(ctx, exec, &document, query, documentUUID, hold)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("[RetentionRepo] документ не найден")
+	} else if err != nil {
+		return nil, util.LogError("[RetentionRepo] не удалось изменить юридическое удержание документа", err)
+	}
+	return &document, nil
+}
+
+// ListExpired : документы с истёкшим сроком хранения, не находящиеся на удержании.
+// Документы, которые не удалось удалить, пропускаются до expiry_retry_at, чтобы не занимать начало выборки
+func (r *RetentionRepository) ListExpired(ctx context.Context, exec sqlx.ExtContext, now time.Time, limit int) ([]model.Document, error) {
+	query := `
+		SELECT uuid, owner_uuid, filename_original, storage_path, expires_at
+		FROM documents
+		WHERE expires_at <= $1 AND legal_hold = false AND deleted_at IS NULL
+		  AND (expiry_retry_at IS NULL OR expiry_retry_at <= $1)
+		ORDER BY expires_at ASC
+		LIMIT $2
+	`
+	docs := []model.Document{}
+	if err := sqlx.SelectContext(ctx, exec, &docs, query, now, limit); err != nil {
+		return nil, util.LogError("[RetentionRepo] не удалось получить просроченные документы", err)
+	}
+	return docs, nil
+}
+
+// DeferExpired : откладывает следующую попытку удалить просроченный документ. Интервал удваивается
+// с каждой неудачей, от минуты до суток
+func (r *RetentionRepository) DeferExpired(ctx context.Context, exec sqlx.ExtContext, documentUUID string, now time.Time) error {
+	query := `
+		UPDATE documents
+		SET expiry_failures = expiry_failures + 1,
+		    expiry_retry_at = $2 + LEAST(power(2, LEAST(expiry_failures, 11)), 1440) * INTERVAL '1 minute'
+		WHERE uuid = $1
+	`
+	if _, err := exec.ExecContext(ctx, query, documentUUID, now); err != nil {
+		return util.LogError("[RetentionRepo] не удалось отложить удаление просроченного документа", err)
+	}
+	return nil
+}
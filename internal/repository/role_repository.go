@@ -0,0 +1,84 @@
+package repository
+
+import (
+	"caching-web-server/config"
+	"caching-web-server/internal/model"
+	"caching-web-server/internal/util"
+	"context"
+	"fmt"
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+)
+
+type RoleRepository struct {
+	*config.Database
+}
+
+func NewRoleRepository(database *config.Database) *RoleRepository {
+	return &RoleRepository{database}
+}
+
+// ListRoles : все роли с правами
+func (r *RoleRepository) ListRoles(ctx context.Context, exec sqlx.ExtContext) ([]*model.Role, error) {
+	rows, err := exec.QueryxContext(ctx, `SELECT name, permissions, builtin, created_at FROM roles ORDER BY name`)
+	if err != nil {
+		return nil, util.LogError("[RoleRepo] не удалось получить роли", err)
+	}
+	defer rows.Close()
+
+	roles := []*model.Role{}
+	for rows.Next() {
+		role := &model.Role{}
+		if err := rows.Scan(&role.Name, pq.Array(&role.Permissions), &role.Builtin, &role.CreatedAt); err != nil {
+			return nil, util.LogError("[RoleRepo] не удалось прочитать роль", err)
+		}
+		roles = append(roles, role)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, util.LogError("[RoleRepo] не удалось получить роли", err)
+	}
+	return roles, nil
+}
+
+// SaveRole : создаёт или изменяет пользовательскую роль. Встроенные роли не изменяются — ошибка "встроенная роль"
+func (r *RoleRepository) SaveRole(ctx context.Context, exec sqlx.ExtContext, role *model.Role) error {
+	query := `
+		INSERT INTO roles (name, permissions)
+		VALUES ($1, $2)
+		ON CONFLICT (name) DO UPDATE SET permissions = EXCLUDED.permissions
+		WHERE roles.builtin = false
+		RETURNING created_at
+	`
+	rows, err := exec.QueryxContext(ctx, query, role.Name, pq.Array(role.Permissions))
+	if err != nil {
+		return util.LogError("[RoleRepo] не удалось сохранить роль", err)
+	}
+	defer rows.Close()
+
+	if rows.Next() == false {
+		if err := rows.Err(); err != nil {
+			return util.LogError("[RoleRepo] не удалось сохранить роль", err)
+		}
+		return fmt.Errorf("[RoleRepo] встроенная роль %s не изменяется", role.Name)
+	}
+	if err := rows.Scan(&role.CreatedAt); err != nil {
+		return util.LogError("[RoleRepo] не удалось сохранить роль", err)
+	}
+	return nil
+}
+
+// DeleteRole : удаляет пользовательскую роль; у пользователей она снимается каскадно
+func (r *RoleRepository) DeleteRole(ctx context.Context, exec sqlx.ExtContext, name string) error {
+	result, err := exec.ExecContext(ctx, `DELETE FROM roles WHERE name = $1 AND builtin = false`, name)
+	if err != nil {
+		return util.LogError("[RoleRepo] не удалось удалить роль", err)
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return util.LogError("[RoleRepo] не удалось удалить роль", err)
+	}
+	if affected == 0 {
+		return fmt.Errorf("[RoleRepo] роль не найдена или встроенная")
+	}
+	return nil
+}
@@ -0,0 +1,261 @@
+package repository
+
+import (
+	"caching-web-server/internal/model"
+	"context"
+	"sync/atomic"
+	"time"
+)
+
+// CacheTierStats : счётчики попаданий по уровням кэша
+type CacheTierStats struct {
+	L1Active bool             `json:"l1_active"`
+	L1       LocalCacheStats  `json:"l1"`
+	L2       RemoteCacheStats `json:"l2"`
+}
+
+// RemoteCacheStats : счётчики обращений к Redis из TieredCacheRepository
+type RemoteCacheStats struct {
+	Hits   uint64 `json:"hits"`
+	Misses uint64 `json:"misses"`
+	Errors uint64 `json:"errors"`
+}
+
+// TieredCacheRepository : двухуровневый кэш документов — in-process LRU (L1) перед Redis (L2).
+// Встраивает CacheRepository, поэтому умеет всё, что умеет L2; переопределены только методы, которые читают
+// или меняют запись документа и должны учитывать L1. Отрицательные записи, grant, списки, публичные копии,
+// pre-signed ссылки и статистика обращений живут только в L2, чтобы их изменения сразу видели все реплики.
+// Записи L1 живут недолго (local.ttl), чтобы изменения, сделанные другой репликой, были видны не позже,
+// чем через этот интервал. В L1 попадают только свежие копии, устаревшие всегда читаются из L2. Копия,
+// прочитанная из L2, не попадает в L1, если во время чтения пришла инвалидация (см. LocalCache.SetIfGeneration)
+type TieredCacheRepository struct {
+	*CacheRepository
+	local       *LocalCache[*model.CachedDocument]
+	localTTL    time.Duration
+	localActive atomic.Bool
+	metrics     *CacheMetrics
+
+	remoteHits   atomic.Uint64
+	remoteMisses atomic.Uint64
+	remoteErrors atomic.Uint64
+}
+
+// NewTieredCacheRepository : metrics — счётчики обращений к L1, nil — не считаются
+func NewTieredCacheRepository(remote *CacheRepository, maxEntries int, maxBytes int64, localTTL time.Duration, metrics *CacheMetrics) *TieredCacheRepository {
+	repository := &TieredCacheRepository{
+		CacheRepository: remote,
+		local:           NewLocalCache[*model.CachedDocument](maxEntries, maxBytes, localTTL),
+		localTTL:        localTTL,
+		metrics:         metrics,
+	}
+	repository.localActive.Store(true)
+	return repository
+}
+
+func (r *TieredCacheRepository) SetDocument(ctx context.Context, document *model.Document) error {
+	generation := r.local.Generation()
+	if err := r.CacheRepository.SetDocument(ctx, document); err != nil {
+		// в L1 не кладём: иначе реплика будет отдавать значение, которого нет в Redis
+		r.local.Delete(r.key(document.UUID))
+		r.remoteErrors.Add(1)
+		return err
+	}
+
+	r.storeLocal(document, generation)
+	return nil
+}
+
+// GetDocument : возвращает только свежую копию, устаревшие считаются промахом
+func (r *TieredCacheRepository) GetDocument(ctx context.Context, uuid string) (*model.Document, error) {
+	entry, err := r.GetDocumentEntry(ctx, uuid)
+	if err != nil || entry == nil {
+		return nil, err
+	}
+	if entry.IsFresh(time.Now()) == false {
+		return nil, nil
+	}
+	return entry.Document, nil
+}
+
+// GetDocumentEntry : копия документа со сроками свежести — из L1, если она там есть, иначе из L2
+func (r *TieredCacheRepository) GetDocumentEntry(ctx context.Context, uuid string) (*model.CachedDocument, error) {
+	localActive := r.localActive.Load()
+	if localActive {
+		started := time.Now()
+		entry, ok := r.local.Get(r.key(uuid))
+		if ok {
+			r.metrics.observe(cacheTierLocal, "document", "get", cacheHit, time.Since(started), int(entrySize(entry)))
+			return cloneEntry(entry), nil
+		}
+		r.metrics.observe(cacheTierLocal, "document", "get", cacheMiss, time.Since(started), -1)
+	}
+
+	generation := r.local.Generation()
+	entry, err := r.CacheRepository.GetDocumentEntry(ctx, uuid)
+	if err != nil {
+		r.remoteErrors.Add(1)
+		return nil, err
+	}
+	if entry == nil {
+		r.remoteMisses.Add(1)
+		return nil, nil
+	}
+
+	r.remoteHits.Add(1)
+	now := time.Now()
+	if localActive && entry.IsFresh(now) {
+		// в L1 копия не должна пережить свою свежесть в L2
+		ttl := r.localTTL
+		if entry.FreshUntil.IsZero() == false && entry.FreshUntil.Sub(now) < ttl {
+			ttl = entry.FreshUntil.Sub(now)
+		}
+		r.local.SetIfGeneration(r.key(uuid), cloneEntry(entry), entrySize(entry), ttl, generation)
+	}
+	return entry, nil
+}
+
+// storeLocal : кладёт в L1 только что записанную или дождавшуюся копию, она свежая на localTTL.
+// generation — поколение L1 до обращения к L2
+func (r *TieredCacheRepository) storeLocal(document *model.Document, generation uint64) {
+	if r.localActive.Load() == false {
+		return
+	}
+
+	now := time.Now()
+	freshUntil := now.Add(r.localTTL)
+	entry := &model.CachedDocument{
+		Document:   document.Clone(),
+		StoredAt:   now,
+		FreshUntil: freshUntil,
+		StaleUntil: freshUntil,
+		ErrorUntil: freshUntil,
+	}
+	r.local.SetIfGeneration(r.key(document.UUID), entry, entrySize(entry), r.localTTL, generation)
+}
+
+func (r *TieredCacheRepository) DeleteDocument(ctx context.Context, uuid string) error {
+	r.local.Delete(r.key(uuid))
+	if err := r.CacheRepository.DeleteDocument(ctx, uuid); err != nil {
+		r.remoteErrors.Add(1)
+		return err
+	}
+	return nil
+}
+
+// WaitForDocument : ждёт документ в L2 и кладёт его в L1
+func (r *TieredCacheRepository) WaitForDocument(ctx context.Context, uuid string) (*model.Document, error) {
+	generation := r.local.Generation()
+	document, err := r.CacheRepository.WaitForDocument(ctx, uuid)
+	if err != nil || document == nil {
+		return document, err
+	}
+	r.storeLocal(document, generation)
+	return document, nil
+}
+
+// EvictKeys : после удаления ключей из L2 L1 этой реплики очищается
+func (r *TieredCacheRepository) EvictKeys(ctx context.Context, pattern string) (int64, error) {
+	deleted, err := r.CacheRepository.EvictKeys(ctx, pattern)
+	if deleted > 0 {
+		r.local.Flush()
+	}
+	return deleted, err
+}
+
+func (r *TieredCacheRepository) FlushDocuments(ctx context.Context) (int64, error) {
+	r.local.Flush()
+	return r.CacheRepository.FlushDocuments(ctx)
+}
+
+// StoreDocumentVersion : в L1 документ попадает, только если L2 принял эту версию
+func (r *TieredCacheRepository) StoreDocumentVersion(ctx context.Context, document *model.Document) (bool, error) {
+	generation := r.local.Generation()
+	stored, err := r.CacheRepository.StoreDocumentVersion(ctx, document)
+	if err != nil {
+		r.local.Delete(r.key(document.UUID))
+		r.remoteErrors.Add(1)
+		return false, err
+	}
+	if stored {
+		r.storeLocal(document, generation)
+	} else {
+		r.local.Delete(r.key(document.UUID))
+	}
+	return stored, nil
+}
+
+func (r *TieredCacheRepository) InvalidateDocumentVersion(ctx context.Context, uuid string, version int64) error {
+	r.local.Delete(r.key(uuid))
+	if err := r.CacheRepository.InvalidateDocumentVersion(ctx, uuid, version); err != nil {
+		r.remoteErrors.Add(1)
+		return err
+	}
+	return nil
+}
+
+func (r *TieredCacheRepository) TombstoneDocument(ctx context.Context, uuid string) error {
+	r.local.Delete(r.key(uuid))
+	if err := r.CacheRepository.TombstoneDocument(ctx, uuid); err != nil {
+		r.remoteErrors.Add(1)
+		return err
+	}
+	return nil
+}
+
+// InvalidateLocal : удаляет документ только из L1 этой реплики
+func (r *TieredCacheRepository) InvalidateLocal(uuid string) {
+	r.local.Delete(r.key(uuid))
+}
+
+// FlushLocal : очищает L1 этой реплики целиком
+func (r *TieredCacheRepository) FlushLocal() {
+	r.local.Flush()
+}
+
+// SetLocalActive : включает или выключает L1. При выключении L1 очищается,
+// чтобы после восстановления не отдать записи, инвалидации которых были пропущены
+func (r *TieredCacheRepository) SetLocalActive(active bool) {
+	if active == false {
+		r.localActive.Store(false)
+		r.local.Flush()
+		return
+	}
+	r.local.Flush()
+	r.localActive.Store(true)
+}
+
+// Stats : счётчики по уровням кэша
+func (r *TieredCacheRepository) Stats() CacheTierStats {
+	return CacheTierStats{
+		L1Active: r.localActive.Load(),
+		L1:       r.local.Stats(),
+		L2: RemoteCacheStats{
+			Hits:   r.remoteHits.Load(),
+			Misses: r.remoteMisses.Load(),
+			Errors: r.remoteErrors.Load(),
+		},
+	}
+}
+
+// cloneEntry : копия записи кэша вместе с документом, чтобы вызывающий код не менял значение, лежащее в L1
+func cloneEntry(entry *model.CachedDocument) *model.CachedDocument {
+	clone := *entry
+	clone.Document = entry.Document.Clone()
+	return &clone
+}
+
+// entrySize : приблизительный размер записи кэша в памяти
+func entrySize(entry *model.CachedDocument) int64 {
+	return documentSize(entry.Document) + 96 // сроки свежести
+}
+
+// documentSize : приблизительный размер документа в памяти
+func documentSize(document *model.Document) int64 {
+	size := int64(256) // поля фиксированного размера и накладные расходы
+	size += int64(len(document.UUID) + len(document.OwnerUUID) + len(document.FilenameOriginal) +
+		len(document.MimeType) + len(document.Sha256) + len(document.StoragePath) + len(document.AccessToken))
+	for _, login := range document.GrantLogins {
+		size += int64(len(login)) + 16
+	}
+	return size
+}
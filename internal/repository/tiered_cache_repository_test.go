@@ -0,0 +1,105 @@
+package repository
+
+import (
+	"caching-web-server/internal/model"
+	"context"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+// onGetHook : вызывает onGet после того, как GET уже получил ответ, — посреди чтения из L2
+type onGetHook struct {
+	onGet func()
+}
+
+func (h *onGetHook) DialHook(next redis.DialHook) redis.DialHook {
+	return next
+}
+
+func (h *onGetHook) ProcessHook(next redis.ProcessHook) redis.ProcessHook {
+	return func(ctx context.Context, cmd redis.Cmder) error {
+		err := next(ctx, cmd)
+		if cmd.Name() == "get" && h.onGet != nil {
+			h.onGet()
+		}
+		return err
+	}
+}
+
+func (h *onGetHook) ProcessPipelineHook(next redis.ProcessPipelineHook) redis.ProcessPipelineHook {
+	return next
+}
+
+func newTieredTestRepository(t *testing.T) (*TieredCacheRepository, *CacheRepository, *fakeRedis, *onGetHook) {
+	client, fake := newFakeRedisClient(t)
+	hook := &onGetHook{}
+	client.AddHook(hook)
+	client.AddHook(fake)
+	remote := NewCacheRepository(client, time.Minute, nil, CacheOptions{})
+	return NewTieredCacheRepository(remote, 100, 0, time.Minute, nil), remote, fake, hook
+}
+
+// putDocument : кладёт в fakeRedis свежую запись документа
+func putDocument(t *testing.T, repo *CacheRepository, fake *fakeRedis, document *model.Document) {
+	t.Helper()
+	data, err := repo.codec.encodeEntry(cacheEntry{StoredAt: time.Now(), FreshUntil: time.Now().Add(time.Minute), Document: document})
+	require.NoError(t, err)
+	fake.mu.Lock()
+	defer fake.mu.Unlock()
+	fake.values[repo.key(document.UUID)] = string(data)
+}
+
+func TestTieredCache_InvalidationDuringRemoteReadSkipsL1(t *testing.T) {
+	ctx := context.Background()
+	tiered, remote, fake, hook := newTieredTestRepository(t)
+	putDocument(t, remote, fake, &model.Document{UUID: "doc1", FilenameOriginal: "old.txt"})
+
+	// другая реплика изменила документ: L2 уже обновлён, инвалидация по шине пришла во время чтения
+	hook.onGet = func() {
+		putDocument(t, remote, fake, &model.Document{UUID: "doc1", FilenameOriginal: "new.txt"})
+		tiered.InvalidateLocal("doc1")
+	}
+
+	document, err := tiered.GetDocument(ctx, "doc1")
+	require.NoError(t, err)
+	assert.Equal(t, "old.txt", document.FilenameOriginal)
+
+	// прочитанная до инвалидации копия не должна остаться в L1
+	hook.onGet = nil
+	document, err = tiered.GetDocument(ctx, "doc1")
+	require.NoError(t, err)
+	assert.Equal(t, "new.txt", document.FilenameOriginal)
+	assert.Equal(t, uint64(2), tiered.Stats().L2.Hits)
+}
+
+func TestTieredCache_RemoteReadIsCachedInL1(t *testing.T) {
+	ctx := context.Background()
+	tiered, remote, fake, _ := newTieredTestRepository(t)
+	putDocument(t, remote, fake, &model.Document{UUID: "doc1", FilenameOriginal: "a.txt"})
+
+	for i := 0; i < 3; i++ {
+		document, err := tiered.GetDocument(ctx, "doc1")
+		require.NoError(t, err)
+		assert.Equal(t, "a.txt", document.FilenameOriginal)
+	}
+
+	stats := tiered.Stats()
+	assert.Equal(t, uint64(1), stats.L2.Hits)
+	assert.Equal(t, uint64(2), stats.L1.Hits)
+}
+
+// Возможности L2, которые не касаются записи документа, достаются L1 встраиванием и идут прямо в Redis
+func TestTieredCache_DelegatesRemoteCapabilities(t *testing.T) {
+	ctx := context.Background()
+	tiered, remote, fake, _ := newTieredTestRepository(t)
+	data, err := remote.codec.encodeJSON("doc1")
+	require.NoError(t, err)
+	fake.values[remote.tokenKey("token1")] = string(data)
+
+	uuid, err := tiered.GetPublicTokenUUID(ctx, "token1")
+	require.NoError(t, err)
+	assert.Equal(t, "doc1", uuid)
+}
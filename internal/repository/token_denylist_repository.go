@@ -0,0 +1,135 @@
+package repository
+
+import (
+	"caching-web-server/config"
+	"caching-web-server/internal/model"
+	"caching-web-server/internal/util"
+	"context"
+	"errors"
+	"fmt"
+	"github.com/redis/go-redis/v9"
+	"sync/atomic"
+	"time"
+)
+
+// TokenDenylistRepository : отзыв access-токенов до истечения exp. Отдельные токены отзываются по jti,
+// все токены пользователя — отметкой "выпущенные раньше T недействительны". Записи живут не дольше самих токенов.
+// failOpen — пропускать ли токены, если denylist недоступен; неудачные проверки считаются для /health и метрик
+type TokenDenylistRepository struct {
+	client         *config.RedisClient
+	accessTokenTTL time.Duration
+	failOpen       bool
+
+	checkErrors   atomic.Int64
+	lastCheckOK   atomic.Bool
+	lastErrorAtMs atomic.Int64
+}
+
+func NewTokenDenylistRepository(rdb *config.RedisClient, accessTokenTTL time.Duration, failOpen bool) *TokenDenylistRepository {
+	r := &TokenDenylistRepository{client: rdb, accessTokenTTL: accessTokenTTL, failOpen: failOpen}
+	r.lastCheckOK.Store(true)
+	return r
+}
+
+// FailOpen : пропускать ли токены, отзыв которых не удалось проверить
+func (r *TokenDenylistRepository) FailOpen() bool {
+	return r.failOpen
+}
+
+// Health : состояние проверки отзыва токенов для health-check и метрик
+func (r *TokenDenylistRepository) Health() model.TokenDenylistHealth {
+	health := model.TokenDenylistHealth{State: model.TokenDenylistStateOK, FailOpen: r.failOpen, CheckErrors: r.checkErrors.Load()}
+	if lastErrorAt := r.lastErrorAtMs.Load(); lastErrorAt > 0 {
+		at := time.UnixMilli(lastErrorAt)
+		health.LastErrorAt = &at
+	}
+	if r.lastCheckOK.Load() == false {
+		health.State = model.TokenDenylistStateRejecting
+		if r.failOpen {
+			health.State = model.TokenDenylistStateUnchecked
+		}
+	}
+	return health
+}
+
+// DenyToken : отзывает access-токен до его истечения
+func (r *TokenDenylistRepository) DenyToken(ctx context.Context, jti string, expiresAt time.Time) error {
+	ttl := time.Until(expiresAt)
+	if jti == "" || ttl <= 0 {
+		return nil
+	}
+	if err := r.client.Client.Set(ctx, r.deniedKey(jti), 1, ttl).Err(); err != nil {
+		return util.LogError("[TokenDenylist] не удалось отозвать токен", err)
+	}
+	return nil
+}
+
+// DenyTokens : отзывает access-токены, выпущенные не позже текущего момента, — например, токены отозванных сессий.
+// Их exp неизвестен, но наступит не позже чем через accessTokenTTL
+func (r *TokenDenylistRepository) DenyTokens(ctx context.Context, jtis []string) error {
+	if len(jtis) == 0 {
+		return nil
+	}
+	pipe := r.client.Client.Pipeline()
+	for _, jti := range jtis {
+		pipe.Set(ctx, r.deniedKey(jti), 1, r.accessTokenTTL)
+	}
+	if _, err := pipe.Exec(ctx); err != nil {
+		return util.LogError("[TokenDenylist] не удалось отозвать токены", err)
+	}
+	return nil
+}
+
+// RevokeIssuedBefore : все access-токены пользователя, выпущенные раньше issuedBefore, недействительны.
+// Отметка хранится в миллисекундах, как iat_ms токена; через accessTokenTTL такие токены истекают сами, и отметка удаляется
+func (r *TokenDenylistRepository) RevokeIssuedBefore(ctx context.Context, userUUID string, issuedBefore time.Time) error {
+	if err := r.client.Client.Set(ctx, r.issuedBeforeKey(userUUID), issuedBefore.UnixMilli(), r.accessTokenTTL).Err(); err != nil {
+		return util.LogError("[TokenDenylist] не удалось отозвать токены пользователя", err)
+	}
+	return nil
+}
+
+// IsRevoked : отозван ли токен по jti или отметкой пользователя. Обе проверки — одним pipeline
+func (r *TokenDenylistRepository) IsRevoked(ctx context.Context, jti string, userUUID string, issuedAt time.Time) (bool, error) {
+	pipe := r.client.Client.Pipeline()
+	var denied *redis.IntCmd
+	if jti != "" {
+		denied = pipe.Exists(ctx, r.deniedKey(jti))
+	}
+	issuedBefore := pipe.Get(ctx, r.issuedBeforeKey(userUUID))
+	if _, err := pipe.Exec(ctx); err != nil && errors.Is(err, redis.Nil) == false {
+		r.recordCheck(false)
+		return false, util.LogError("[TokenDenylist] не удалось проверить токен", err)
+	}
+	r.recordCheck(true)
+
+	if denied != nil && denied.Val() > 0 {
+		return true, nil
+	}
+
+	watermark, err := issuedBefore.Int64()
+	if errors.Is(err, redis.Nil) {
+		return false, nil
+	}
+	if err != nil {
+		return false, util.LogError("[TokenDenylist] некорректная отметка отзыва", err)
+	}
+	// у токенов без iat_ms время выпуска округлено до секунды: выпущенный в секунду отзыва токен для них отозван
+	return issuedAt.UnixMilli() < watermark, nil
+}
+
+func (r *TokenDenylistRepository) recordCheck(ok bool) {
+	r.lastCheckOK.Store(ok)
+	if ok == false {
+		r.checkErrors.Add(1)
+		r.lastErrorAtMs.Store(time.Now().UnixMilli())
+	}
+}
+
+func (r *TokenDenylistRepository) deniedKey(jti string) string {
+	return fmt.Sprintf("auth:denied:%s", jti)
+}
+
+func (r *TokenDenylistRepository) issuedBeforeKey(userUUID string) string {
+	return fmt.Sprintf("auth:issued-before:%s", userUUID)
+}
@@ -0,0 +1,72 @@
+package repository
+
+import (
+	"caching-web-server/config"
+	"caching-web-server/internal/model"
+	"context"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func newTestTokenDenylist(t *testing.T, failOpen bool) (*TokenDenylistRepository, *fakeRedis) {
+	client, fake := newFakeRedisClient(t)
+	client.AddHook(fake)
+	return NewTokenDenylistRepository(&config.RedisClient{Client: client}, time.Hour, failOpen), fake
+}
+
+func TestTokenDenylist_WatermarkComparesMilliseconds(t *testing.T) {
+	ctx := context.Background()
+	denylist, fake := newTestTokenDenylist(t, true)
+
+	revokedAt := time.Date(2026, 1, 1, 12, 0, 0, int(500*time.Millisecond), time.UTC)
+	fake.values[denylist.issuedBeforeKey("u1")] = strconv.FormatInt(revokedAt.UnixMilli(), 10)
+
+	// токен, выданный при входе сразу после отзыва, в ту же секунду
+	revoked, err := denylist.IsRevoked(ctx, "", "u1", revokedAt.Add(200*time.Millisecond))
+	require.NoError(t, err)
+	assert.False(t, revoked)
+
+	revoked, err = denylist.IsRevoked(ctx, "", "u1", revokedAt.Add(-200*time.Millisecond))
+	require.NoError(t, err)
+	assert.True(t, revoked)
+}
+
+func TestTokenDenylist_HealthReportsFailedChecks(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("fail-open", func(t *testing.T) {
+		denylist, fake := newTestTokenDenylist(t, true)
+		assert.Equal(t, model.TokenDenylistStateOK, denylist.Health().State)
+
+		fake.setDown(true)
+		_, err := denylist.IsRevoked(ctx, "jti-1", "u1", time.Now())
+		require.Error(t, err)
+
+		health := denylist.Health()
+		assert.Equal(t, model.TokenDenylistStateUnchecked, health.State)
+		assert.True(t, health.FailOpen)
+		assert.Equal(t, int64(1), health.CheckErrors)
+		assert.NotNil(t, health.LastErrorAt)
+
+		// после восстановления Redis состояние снова ok, счётчик ошибок сохраняется
+		fake.setDown(false)
+		fake.values[denylist.issuedBeforeKey("u1")] = "0"
+		_, err = denylist.IsRevoked(ctx, "", "u1", time.Now())
+		require.NoError(t, err)
+		assert.Equal(t, model.TokenDenylistStateOK, denylist.Health().State)
+		assert.Equal(t, int64(1), denylist.Health().CheckErrors)
+	})
+
+	t.Run("fail-closed", func(t *testing.T) {
+		denylist, fake := newTestTokenDenylist(t, false)
+		fake.setDown(true)
+		_, err := denylist.IsRevoked(ctx, "jti-1", "u1", time.Now())
+		require.Error(t, err)
+
+		assert.False(t, denylist.FailOpen())
+		assert.Equal(t, model.TokenDenylistStateRejecting, denylist.Health().State)
+	})
+}
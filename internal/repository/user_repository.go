@@ -5,12 +5,17 @@ import (
 	"caching-web-server/internal/model"
 	"caching-web-server/internal/util"
 	"context"
+	"errors"
 	"fmt"
 	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
 	"strings"
 	"time"
 )
 
+// foreignKeyViolation : код ошибки PostgreSQL при нарушении внешнего ключа
+const foreignKeyViolation = "23503"
+
 type UserRepository struct {
 	*config.Database
 }
@@ -46,6 +51,9 @@ func (r *UserRepository) FindByUUID(ctx context.Context, exec sqlx.ExtContext, u
 	if err != nil {
 		return nil, util.LogError("[UserRepo] не удалось найти пользователя в БД", err)
 	}
+	if user.Roles, err = r.ListRoles(ctx, exec, user.UUID); err != nil {
+		return nil, err
+	}
 	return &user, nil
 }
 
@@ -57,9 +65,69 @@ func (r *UserRepository) FindByEmail(ctx context.Context, exec sqlx.ExtContext,
 	if err != nil {
 		return nil, util.LogError("[UserRepo] не удалось найти пользователя по login", err)
 	}
+	if user.Roles, err = r.ListRoles(ctx, exec, user.UUID); err != nil {
+		return nil, err
+	}
 	return &user, nil
 }
 
+// ListRoles : роли пользователя
+func (r *UserRepository) ListRoles(ctx context.Context, exec sqlx.ExtContext, userUUID string) ([]string, error) {
+	roles := []string{}
+	query := `SELECT role_name FROM user_roles WHERE user_uuid = $1 ORDER BY role_name`
+	if err := sqlx.SelectContext(ctx, exec, &roles, query, userUUID); err != nil {
+		return nil, util.LogError("[UserRepo] не удалось получить роли пользователя", err)
+	}
+	return roles, nil
+}
+
+// SetRoles : заменяет роли пользователя. Несуществующая роль — ошибка "роль не найдена"
+func (r *UserRepository) SetRoles(ctx context.Context, exec sqlx.ExtContext, userUUID string, roles []string) error {
+	if _, err := exec.ExecContext(ctx, `DELETE FROM user_roles WHERE user_uuid = $1`, userUUID); err != nil {
+		return util.LogError("[UserRepo] не удалось удалить роли пользователя", err)
+	}
+	query := `
+		INSERT INTO user_roles (user_uuid, role_name)
+		SELECT $1, role_name FROM unnest($2::text[]) AS role_name
+		ON CONFLICT DO NOTHING
+	`
+	if _, err := exec.ExecContext(ctx, query, userUUID, pq.Array(roles)); err != nil {
+		var pqErr *pq.Error
+		if errors.As(err, &pqErr) && pqErr.Code == foreignKeyViolation {
+			return fmt.Errorf("[UserRepo] роль не найдена")
+		}
+		return util.LogError("[UserRepo] не удалось назначить роли пользователю", err)
+	}
+	return nil
+}
+
+func (r *UserRepository) BeginTX(ctx context.Context) (sqlx.ExtContext, func() error, func() error, error) {
+	tx, err := r.DB.BeginTxx(ctx, nil)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	return tx, func() error { return tx.Rollback() }, func() error { return tx.Commit() }, nil
+}
+
+// LockRoleHolders : берёт advisory-блокировку роли до конца транзакции, чтобы параллельные
+// регистрации не могли одновременно убедиться, что пользователей с ролью нет
+func (r *UserRepository) LockRoleHolders(ctx context.Context, exec sqlx.ExtContext, role string) error {
+	if _, err := exec.ExecContext(ctx, `SELECT pg_advisory_xact_lock(hashtext('user_roles:' || $1))`, role); err != nil {
+		return util.LogError("[UserRepo] не удалось заблокировать роль", err)
+	}
+	return nil
+}
+
+// RoleHolderExists : есть ли хотя бы один пользователь с ролью
+func (r *UserRepository) RoleHolderExists(ctx context.Context, exec sqlx.ExtContext, role string) (bool, error) {
+	var exists bool
+	query := `SELECT EXISTS (SELECT 1 FROM user_roles WHERE role_name = $1)`
+	if err := sqlx.GetContext(ctx, exec, &exists, query, role); err != nil {
+		return false, util.LogError("[UserRepo] ошибка проверки пользователей с ролью", err)
+	}
+	return exists, nil
+}
+
 // UpdateUser : обновляет поле login
 func (r *UserRepository) UpdateUser(ctx context.Context, exec sqlx.ExtContext, user *model.User) error {
 	query := `
@@ -11,6 +11,7 @@ import (
 	"fmt"
 	"log"
 	"net/http"
+	"slices"
 	"strings"
 	"time"
 
@@ -26,12 +27,57 @@ const (
 )
 
 type Claims struct {
-	UserUUID         string `json:"user_uuid"`
-	RefreshTokenUUID string `json:"refresh_token_id"`
-	IsAdmin          bool   `json:"is_admin,omitempty"`
+	UserUUID         string   `json:"user_uuid"`
+	RefreshTokenUUID string   `json:"refresh_token_id"`
+	Roles            []string `json:"roles,omitempty"`
+	// Permissions : права ролей на момент запроса, заполняются JWTMiddleware и в токен не попадают,
+	// поэтому изменение роли действует сразу, без перевыпуска токенов
+	Permissions []string `json:"-"`
+	// IsAdmin : у ролей есть право "*"
+	IsAdmin bool `json:"-"`
+	// IssuedAtMs : время выпуска в миллисекундах. iat округлён до секунды, и по нему нельзя отличить токен,
+	// выпущенный сразу после отзыва токенов пользователя, от выпущенного в ту же секунду до отзыва
+	IssuedAtMs int64 `json:"iat_ms,omitempty"`
 	jwt.RegisteredClaims
 }
 
+// IssuedAtTime : время выпуска токена. У токенов без iat_ms — iat с точностью до секунды
+func (c *Claims) IssuedAtTime() time.Time {
+	if c.IssuedAtMs > 0 {
+		return time.UnixMilli(c.IssuedAtMs)
+	}
+	if c.IssuedAt != nil {
+		return c.IssuedAt.Time
+	}
+	return time.Time{}
+}
+
+// PermissionResolver : права по списку ролей
+type PermissionResolver interface {
+	Permissions(ctx context.Context, roles []string) ([]string, error)
+}
+
+// EffectiveRoles : токены, выпущенные до появления ролей, считаются токенами обычного пользователя
+func (c *Claims) EffectiveRoles() []string {
+	if len(c.Roles) == 0 {
+		return []string{model.RoleUser}
+	}
+	return c.Roles
+}
+
+// Can : есть ли у пользователя право
+func (c *Claims) Can(permission string) bool {
+	if c.IsAdmin {
+		return true
+	}
+	for _, granted := range c.Permissions {
+		if granted == permission || granted == model.PermissionAll {
+			return true
+		}
+	}
+	return false
+}
+
 type JWTService struct {
 	*config.JWTConfig
 }
@@ -40,7 +86,7 @@ func NewJWTService(cfg *config.JWTConfig) *JWTService {
 	return &JWTService{cfg}
 }
 
-func (s *JWTService) GenerateAccessRefreshTokens(userUUID string) (*model.TokensPair, *model.RefreshToken, error) {
+func (s *JWTService) GenerateAccessRefreshTokens(userUUID string, roles []string) (*model.TokensPair, *model.RefreshToken, error) {
 	refreshToken, refreshTokenStr, err := GenerateRefreshToken()
 	if err != nil {
 		return nil, nil, util.LogError("ошибка генерации рефреш токена", err)
@@ -57,12 +103,18 @@ func (s *JWTService) GenerateAccessRefreshTokens(userUUID string) (*model.Tokens
 	if err != nil {
 		return nil, nil, util.LogError("ошибка парсинга", err)
 	}
+	now := time.Now()
 	claims := Claims{
 		UserUUID:         userUUID,
 		RefreshTokenUUID: refreshToken.UUID,
+		Roles:            roles,
+		IssuedAtMs:       now.UnixMilli(),
 		RegisteredClaims: jwt.RegisteredClaims{
-			ExpiresAt: jwt.NewNumericDate(time.Now().Add(timeDuration)),
-			IssuedAt:  jwt.NewNumericDate(time.Now()),
+			// jti: по нему токен отзывается до истечения. Совпадает с uuid refresh-токена пары,
+			// чтобы при отзыве сессии были известны jti её access-токенов
+			ID:        refreshToken.UUID,
+			ExpiresAt: jwt.NewNumericDate(now.Add(timeDuration)),
+			IssuedAt:  jwt.NewNumericDate(now),
 			Issuer:    "Caching-web-server",
 		},
 	}
@@ -95,10 +147,12 @@ func GenerateRefreshToken() (*model.RefreshToken, string, error) {
 
 	// refreshTokenStr отдается клиенту
 	// hashedToken сохраняется в БД
+	// новый вход начинает новую сессию; при обновлении токенов сервис переносит сессию старого токена
 	return &model.RefreshToken{
-		UUID:      refreshUUID,
-		TokenHash: string(hashedToken),
-		Used:      false,
+		UUID:        refreshUUID,
+		TokenHash:   string(hashedToken),
+		Used:        false,
+		SessionUUID: refreshUUID,
 	}, refreshTokenStr, nil
 }
 
@@ -119,13 +173,13 @@ func (s *JWTService) ValidateJWT(jwtTokenStr string, secretKey []byte) (*Claims,
 	return claims, nil
 }
 
-func JWTMiddleware(secretKey []byte, jwtRepository *repository.JWTRepository, jwtService *JWTService, adminToken string) func(handler http.Handler) http.Handler {
+func JWTMiddleware(secretKey []byte, jwtRepository *repository.JWTRepository, jwtService *JWTService, permissions PermissionResolver, denylist *repository.TokenDenylistRepository) func(handler http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
-		return http.HandlerFunc(handleAuthentication(secretKey, jwtRepository, jwtService, adminToken, next))
+		return http.HandlerFunc(handleAuthentication(secretKey, jwtRepository, jwtService, permissions, denylist, next))
 	}
 }
 
-func handleAuthentication(secretKey []byte, jwtRepository *repository.JWTRepository, jwtService *JWTService, adminToken string, next http.Handler) func(writer http.ResponseWriter, request *http.Request) {
+func handleAuthentication(secretKey []byte, jwtRepository *repository.JWTRepository, jwtService *JWTService, permissions PermissionResolver, denylist *repository.TokenDenylistRepository, next http.Handler) func(writer http.ResponseWriter, request *http.Request) {
 	return func(writer http.ResponseWriter, request *http.Request) {
 		authorizationHeader := request.Header.Get("Authorization")
 		if !strings.HasPrefix(authorizationHeader, "Bearer ") {
@@ -135,16 +189,6 @@ func handleAuthentication(secretKey []byte, jwtRepository *repository.JWTReposit
 
 		token := strings.TrimPrefix(authorizationHeader, "Bearer ")
 
-		if token == adminToken {
-			adminClaims := &Claims{
-				UserUUID: "admin",
-				IsAdmin:  true,
-			}
-			req := request.WithContext(context.WithValue(request.Context(), UserContextKey, adminClaims))
-			next.ServeHTTP(writer, req)
-			return
-		}
-
 		claims, err := jwtService.ValidateJWT(token, secretKey)
 		if err != nil {
 			log.Printf("невалидный токен: %v", err)
@@ -152,6 +196,23 @@ func handleAuthentication(secretKey []byte, jwtRepository *repository.JWTReposit
 			return
 		}
 
+		// если Redis недоступен, по умолчанию (jwt.denylist_fail_open) токен пропускается: отзыв сессий всё равно
+		// проверяется по refresh-токену в БД. Такие проверки видны в /health и /debug/vars
+		revoked, err := denylist.IsRevoked(request.Context(), claims.ID, claims.UserUUID, claims.IssuedAtTime())
+		if err != nil {
+			if denylist.FailOpen() == false {
+				log.Printf("не удалось проверить отзыв токена, запрос отклонён: %v", err)
+				http.Error(writer, "service unavailable", http.StatusServiceUnavailable)
+				return
+			}
+			log.Printf("не удалось проверить отзыв токена, токен принят без проверки denylist: %v", err)
+		}
+		if revoked {
+			log.Printf("access токен %s пользователя %s отозван", claims.ID, claims.UserUUID)
+			http.Error(writer, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+
 		refreshToken, err := jwtRepository.FindByUUID(request.Context(), claims.RefreshTokenUUID)
 		if err != nil {
 			log.Printf("рефреш токен не найден: %v", err)
@@ -159,17 +220,47 @@ func handleAuthentication(secretKey []byte, jwtRepository *repository.JWTReposit
 			return
 		}
 
-		if refreshToken.Used {
-			log.Printf("рефреш токен был использован")
+		if refreshToken.Used || refreshToken.RevokedAt != nil {
+			log.Printf("рефреш токен был использован или отозван")
 			http.Error(writer, "unauthorized", http.StatusUnauthorized)
 			return
 		}
 
+		if err := jwtRepository.TouchRefreshToken(request.Context(), refreshToken); err != nil {
+			log.Printf("не удалось отметить использование сессии: %v", err)
+		}
+
+		claims.Permissions, err = permissions.Permissions(request.Context(), claims.EffectiveRoles())
+		if err != nil {
+			log.Printf("не удалось получить права ролей %v: %v", claims.Roles, err)
+			http.Error(writer, "internal error", http.StatusInternalServerError)
+			return
+		}
+		claims.IsAdmin = slices.Contains(claims.Permissions, model.PermissionAll)
+
 		req := request.WithContext(context.WithValue(request.Context(), UserContextKey, claims))
 		next.ServeHTTP(writer, req)
 	}
 }
 
+// RequirePermission : пропускает запрос, только если у пользователя есть право. Ставится после JWTMiddleware
+func RequirePermission(permission string) func(handler http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+			claims, err := GetClaimsFromContext(request.Context())
+			if err != nil {
+				http.Error(writer, "unauthorized", http.StatusUnauthorized)
+				return
+			}
+			if claims.Can(permission) == false {
+				http.Error(writer, "forbidden", http.StatusForbidden)
+				return
+			}
+			next.ServeHTTP(writer, request)
+		})
+	}
+}
+
 func GetClaimsFromContext(ctx context.Context) (*Claims, error) {
 	claims, ok := ctx.Value(UserContextKey).(*Claims)
 	if !ok || claims == nil {
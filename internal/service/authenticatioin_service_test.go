@@ -7,6 +7,7 @@ import (
 	"caching-web-server/internal/service"
 	"context"
 	"errors"
+	"github.com/golang-jwt/jwt/v5"
 	"github.com/jmoiron/sqlx"
 	"testing"
 	"time"
@@ -35,8 +36,8 @@ type MockJWTService struct {
 	mock.Mock
 }
 
-func (m *MockJWTService) GenerateAccessRefreshTokens(userUUID string) (*model.TokensPair, *model.RefreshToken, error) {
-	args := m.Called(userUUID)
+func (m *MockJWTService) GenerateAccessRefreshTokens(userUUID string, roles []string) (*model.TokensPair, *model.RefreshToken, error) {
+	args := m.Called(userUUID, roles)
 
 	var tokens *model.TokensPair
 	if t := args.Get(0); t != nil {
@@ -75,6 +76,46 @@ func (m *MockJWTRepo) MarkRefreshTokenUsedByUUID(ctx context.Context, uuid strin
 	return args.Error(0)
 }
 
+func (m *MockJWTRepo) RevokeRefreshToken(ctx context.Context, uuid string) error {
+	return m.Called(ctx, uuid).Error(0)
+}
+
+func (m *MockJWTRepo) ListSessions(ctx context.Context, userUUID string) ([]*model.Session, error) {
+	args := m.Called(ctx, userUUID)
+	if sessions, ok := args.Get(0).([]*model.Session); ok {
+		return sessions, args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *MockJWTRepo) RevokeSession(ctx context.Context, userUUID string, sessionUUID string) ([]string, error) {
+	args := m.Called(ctx, userUUID, sessionUUID)
+	if tokenUUIDs, ok := args.Get(0).([]string); ok {
+		return tokenUUIDs, args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *MockJWTRepo) RevokeSessions(ctx context.Context, userUUID string, exceptSessionUUID string) ([]string, error) {
+	args := m.Called(ctx, userUUID, exceptSessionUUID)
+	if tokenUUIDs, ok := args.Get(0).([]string); ok {
+		return tokenUUIDs, args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *MockJWTRepo) SaveSecurityEvent(ctx context.Context, event *model.SecurityEvent) error {
+	return m.Called(ctx, event).Error(0)
+}
+
+func (m *MockJWTRepo) ListSecurityEvents(ctx context.Context, userUUID string, cursor string, limit int) ([]*model.SecurityEvent, string, error) {
+	args := m.Called(ctx, userUUID, cursor, limit)
+	if events, ok := args.Get(0).([]*model.SecurityEvent); ok {
+		return events, args.String(1), args.Error(2)
+	}
+	return nil, "", args.Error(2)
+}
+
 func (m *MockUserRepository) CreateUser(ctx context.Context, exec sqlx.ExtContext, user *model.User) (*model.User, error) {
 	args := m.Called(ctx, exec, user)
 	if u, ok := args.Get(0).(*model.User); ok {
@@ -119,6 +160,41 @@ func (m *MockUserRepository) Exists(ctx context.Context, exec sqlx.ExtContext, u
 	return args.Bool(0), args.Error(1)
 }
 
+func (m *MockUserRepository) SetRoles(ctx context.Context, exec sqlx.ExtContext, userUUID string, roles []string) error {
+	return m.Called(ctx, exec, userUUID, roles).Error(0)
+}
+
+func (m *MockUserRepository) RoleHolderExists(ctx context.Context, exec sqlx.ExtContext, role string) (bool, error) {
+	args := m.Called(ctx, exec, role)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *MockUserRepository) LockRoleHolders(ctx context.Context, exec sqlx.ExtContext, role string) error {
+	return m.Called(ctx, exec, role).Error(0)
+}
+
+func (m *MockUserRepository) BeginTX(ctx context.Context) (sqlx.ExtContext, func() error, func() error, error) {
+	args := m.Called(ctx)
+	return args.Get(0).(sqlx.ExtContext), args.Get(1).(func() error), args.Get(2).(func() error), args.Error(3)
+}
+
+// MockTokenDenylist
+type MockTokenDenylist struct {
+	mock.Mock
+}
+
+func (m *MockTokenDenylist) DenyToken(ctx context.Context, jti string, expiresAt time.Time) error {
+	return m.Called(ctx, jti, expiresAt).Error(0)
+}
+
+func (m *MockTokenDenylist) DenyTokens(ctx context.Context, jtis []string) error {
+	return m.Called(ctx, jtis).Error(0)
+}
+
+func (m *MockTokenDenylist) RevokeIssuedBefore(ctx context.Context, userUUID string, issuedBefore time.Time) error {
+	return m.Called(ctx, userUUID, issuedBefore).Error(0)
+}
+
 func (m *MockJWTService) ValidateJWT(tokenString string, secret []byte) (*security.Claims, error) {
 	args := m.Called(tokenString, secret)
 	if claims, ok := args.Get(0).(*security.Claims); ok {
@@ -127,6 +203,14 @@ func (m *MockJWTService) ValidateJWT(tokenString string, secret []byte) (*securi
 	return nil, args.Error(1)
 }
 
+func (m *MockJWTService) ParseAccessToken(tokenStr string) (*security.Claims, error) {
+	args := m.Called(tokenStr)
+	if claims, ok := args.Get(0).(*security.Claims); ok {
+		return claims, args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
 // ===== HELPERS =====
 
 func newTestAuthService() (*service.AuthenticationService, *MockUserRepository, *MockJWTService, *MockJWTRepo) {
@@ -139,6 +223,7 @@ func newTestAuthService() (*service.AuthenticationService, *MockUserRepository,
 		&config.AppConfig{}, // если в тестах что-то нужно от конфига — можно заполнить
 		mockJWTService,
 		mockUserRepo,
+		new(MockTokenDenylist),
 	)
 
 	return svc, mockUserRepo, mockJWTService, mockJWTRepo
@@ -200,7 +285,7 @@ func TestLogin_GenerateTokensError(t *testing.T) {
 
 	mockUserRepo.On("FindByEmail", ctx, mock.Anything, "test@example.com").
 		Return(user, nil)
-	mockJWTService.On("GenerateAccessRefreshTokens", "u1").
+	mockJWTService.On("GenerateAccessRefreshTokens", "u1", mock.Anything).
 		Return(nil, nil, errors.New("token error"))
 
 	_, err := svc.Login(ctx, "test@example.com", "goodpass", "agent", "127.0.0.1")
@@ -228,7 +313,7 @@ func TestLogin_SaveRefreshTokenError(t *testing.T) {
 
 	mockUserRepo.On("FindByEmail", ctx, mock.Anything, "test@example.com").
 		Return(user, nil)
-	mockJWTService.On("GenerateAccessRefreshTokens", "u1").
+	mockJWTService.On("GenerateAccessRefreshTokens", "u1", mock.Anything).
 		Return(tokens, refresh, nil)
 	mockJWTRepo.On("SaveRefreshToken", ctx, refresh, "127.0.0.1").
 		Return(errors.New("db error"))
@@ -259,7 +344,7 @@ func TestLogin_Success(t *testing.T) {
 
 	mockUserRepo.On("FindByEmail", ctx, mock.Anything, "test@example.com").
 		Return(user, nil)
-	mockJWTService.On("GenerateAccessRefreshTokens", "u1").
+	mockJWTService.On("GenerateAccessRefreshTokens", "u1", mock.Anything).
 		Return(tokens, refresh, nil)
 	mockJWTRepo.On("SaveRefreshToken", ctx, refresh, "127.0.0.1").
 		Return(nil)
@@ -289,6 +374,7 @@ func newTestRefreshService() (*service.AuthenticationService, *MockJWTService, *
 		},
 		mockJWTService, // JWTServiceInterface
 		nil,            // UserRepository не нужен для RefreshToken
+		new(MockTokenDenylist),
 	)
 
 	return svc, mockJWTService, mockJWTRepo
@@ -424,7 +510,7 @@ func TestRefreshToken_Success(t *testing.T) {
 	mockJWTService.On("ValidateJWT", "token", mock.Anything).Return(claims, nil)
 	mockJWTRepo.On("FindByUUID", ctx, "r1").Return(rt, nil)
 	mockJWTRepo.On("MarkRefreshTokenUsedByUUID", ctx, "r1").Return(nil)
-	mockJWTService.On("GenerateAccessRefreshTokens", "u1").Return(tokensPair, newRefresh, nil)
+	mockJWTService.On("GenerateAccessRefreshTokens", "u1", mock.Anything).Return(tokensPair, newRefresh, nil)
 	mockJWTRepo.On("SaveRefreshToken", ctx, newRefresh, "127.0.0.1").Return(nil)
 
 	result, err := svc.RefreshToken(ctx, "agent", "127.0.0.1", "token", "refresh123")
@@ -434,3 +520,223 @@ func TestRefreshToken_Success(t *testing.T) {
 	assert.Equal(t, "agent", newRefresh.UserAgent)
 	assert.Equal(t, "127.0.0.1", newRefresh.IpAddress)
 }
+
+func TestRefreshToken_ReuseRevokesFamily(t *testing.T) {
+	mockJWTService := new(MockJWTService)
+	mockJWTRepo := new(MockJWTRepo)
+	denylist := new(MockTokenDenylist)
+	svc := service.NewAuthenticationService(mockJWTRepo, &config.AppConfig{JWT: config.JWTConfig{SecretKey: "secret"}}, mockJWTService, nil, denylist)
+
+	ctx := context.Background()
+	claims := &security.Claims{UserUUID: "u1", RefreshTokenUUID: "r1"}
+
+	hash, _ := security.HashPassword("refresh123")
+	rt := &model.RefreshToken{
+		UUID:        "r1",
+		UserUUID:    "u1",
+		SessionUUID: "s1",
+		Used:        true,
+		ExpireAt:    time.Now().Add(time.Hour),
+		UserAgent:   "agent",
+		IpAddress:   "127.0.0.1",
+		TokenHash:   hash,
+	}
+
+	mockJWTService.On("ValidateJWT", "token", mock.Anything).Return(claims, nil)
+	mockJWTRepo.On("FindByUUID", ctx, "r1").Return(rt, nil)
+	mockJWTRepo.On("RevokeSession", ctx, "u1", "s1").Return([]string{"r2"}, nil)
+	denylist.On("DenyTokens", ctx, []string{"r2"}).Return(nil)
+	mockJWTRepo.On("SaveSecurityEvent", ctx, mock.MatchedBy(func(e *model.SecurityEvent) bool {
+		return e.Event == model.SecurityEventRefreshTokenReuse && e.UserUUID == "u1" && *e.SessionUUID == "s1" && e.IpAddress == "10.0.0.1"
+	})).Return(nil)
+
+	tokens, err := svc.RefreshToken(ctx, "agent", "10.0.0.1", "token", "refresh123")
+
+	assert.Nil(t, tokens)
+	assert.Contains(t, err.Error(), "невалидный токен")
+	mockJWTRepo.AssertExpectations(t)
+	denylist.AssertExpectations(t)
+	mockJWTService.AssertNotCalled(t, "GenerateAccessRefreshTokens", mock.Anything, mock.Anything)
+}
+
+func TestRefreshToken_RevokedTokenNotReported(t *testing.T) {
+	svc, mockJWTService, mockJWTRepo := newTestRefreshService()
+
+	ctx := context.Background()
+	claims := &security.Claims{UserUUID: "u1", RefreshTokenUUID: "r1"}
+
+	hash, _ := security.HashPassword("refresh123")
+	revokedAt := time.Now()
+	rt := &model.RefreshToken{UUID: "r1", UserUUID: "u1", SessionUUID: "s1", Used: true, RevokedAt: &revokedAt, TokenHash: hash}
+
+	mockJWTService.On("ValidateJWT", "token", mock.Anything).Return(claims, nil)
+	mockJWTRepo.On("FindByUUID", ctx, "r1").Return(rt, nil)
+
+	tokens, err := svc.RefreshToken(ctx, "agent", "127.0.0.1", "token", "refresh123")
+
+	assert.Nil(t, tokens)
+	assert.Contains(t, err.Error(), "невалидный токен")
+	mockJWTRepo.AssertNotCalled(t, "RevokeSession", mock.Anything, mock.Anything, mock.Anything)
+	mockJWTRepo.AssertNotCalled(t, "SaveSecurityEvent", mock.Anything, mock.Anything)
+}
+
+func TestRefreshToken_NewIPRecordsEvent(t *testing.T) {
+	svc, mockJWTService, mockJWTRepo := newTestRefreshService()
+
+	ctx := context.Background()
+	claims := &security.Claims{UserUUID: "u1", RefreshTokenUUID: "r1"}
+
+	hash, _ := security.HashPassword("refresh123")
+	rt := &model.RefreshToken{
+		UUID:        "r1",
+		SessionUUID: "s1",
+		ExpireAt:    time.Now().Add(time.Hour),
+		UserAgent:   "agent",
+		IpAddress:   "127.0.0.1",
+		TokenHash:   hash,
+	}
+	newRefresh := &model.RefreshToken{}
+
+	mockJWTService.On("ValidateJWT", "token", mock.Anything).Return(claims, nil)
+	mockJWTRepo.On("FindByUUID", ctx, "r1").Return(rt, nil)
+	mockJWTRepo.On("SaveSecurityEvent", ctx, mock.MatchedBy(func(e *model.SecurityEvent) bool {
+		return e.Event == model.SecurityEventNewIP && e.IpAddress == "10.0.0.1"
+	})).Return(nil)
+	mockJWTRepo.On("MarkRefreshTokenUsedByUUID", ctx, "r1").Return(nil)
+	mockJWTService.On("GenerateAccessRefreshTokens", "u1", mock.Anything).Return(&model.TokensPair{}, newRefresh, nil)
+	mockJWTRepo.On("SaveRefreshToken", ctx, newRefresh, "10.0.0.1").Return(nil)
+
+	_, err := svc.RefreshToken(ctx, "agent", "10.0.0.1", "token", "refresh123")
+
+	assert.NoError(t, err)
+	assert.Equal(t, "s1", newRefresh.SessionUUID)
+	mockJWTRepo.AssertExpectations(t)
+}
+
+func TestSessions(t *testing.T) {
+	t.Run("not authorized", func(t *testing.T) {
+		svc, _, _, _ := newTestAuthService()
+
+		sessions, err := svc.ListSessions(context.Background(), "u1")
+
+		assert.Nil(t, sessions)
+		assert.Contains(t, err.Error(), "не авторизован")
+	})
+
+	t.Run("list marks current session", func(t *testing.T) {
+		svc, _, _, jwtRepo := newTestAuthService()
+		ctx := context.WithValue(context.Background(), security.UserContextKey,
+			&security.Claims{UserUUID: "u1", RefreshTokenUUID: "r2"})
+
+		jwtRepo.On("ListSessions", mock.Anything, "u1").Return([]*model.Session{
+			{UUID: "s1", TokenUUID: "r1"},
+			{UUID: "s2", TokenUUID: "r2"},
+		}, nil)
+
+		sessions, err := svc.ListSessions(ctx, "u1")
+
+		assert.NoError(t, err)
+		assert.False(t, sessions[0].Current)
+		assert.True(t, sessions[1].Current)
+		jwtRepo.AssertExpectations(t)
+	})
+
+	t.Run("other user's session without permission", func(t *testing.T) {
+		svc, _, _, jwtRepo := newTestAuthService()
+		ctx := context.WithValue(context.Background(), security.UserContextKey,
+			&security.Claims{UserUUID: "u1", Permissions: []string{model.PermissionUsersRead}})
+
+		err := svc.RevokeSession(ctx, "u2", "s1")
+
+		assert.Contains(t, err.Error(), "доступ запрещён")
+		jwtRepo.AssertNotCalled(t, "RevokeSession", mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("admin revokes other user's session", func(t *testing.T) {
+		svc, jwtRepo, denylist := newTestDenylistAuthService()
+		ctx := context.WithValue(context.Background(), security.UserContextKey,
+			&security.Claims{UserUUID: "admin", Permissions: []string{model.PermissionUsersManage}})
+
+		jwtRepo.On("RevokeSession", mock.Anything, "u2", "s1").Return([]string{"r1"}, nil)
+		denylist.On("DenyTokens", mock.Anything, []string{"r1"}).Return(nil)
+
+		assert.NoError(t, svc.RevokeSession(ctx, "u2", "s1"))
+		jwtRepo.AssertExpectations(t)
+		denylist.AssertExpectations(t)
+	})
+
+	t.Run("revoke other sessions keeps current", func(t *testing.T) {
+		svc, jwtRepo, denylist := newTestDenylistAuthService()
+		ctx := context.WithValue(context.Background(), security.UserContextKey,
+			&security.Claims{UserUUID: "u1", RefreshTokenUUID: "r2"})
+
+		jwtRepo.On("FindByUUID", mock.Anything, "r2").Return(&model.RefreshToken{UUID: "r2", SessionUUID: "s2"}, nil)
+		jwtRepo.On("RevokeSessions", mock.Anything, "u1", "s2").Return([]string{"r1", "r3", "r4"}, nil)
+		// текущий токен (jti r2) не отзывается ни по jti, ни отметкой времени выпуска
+		denylist.On("DenyTokens", mock.Anything, []string{"r1", "r3", "r4"}).Return(nil)
+
+		revoked, err := svc.RevokeOtherSessions(ctx)
+
+		assert.NoError(t, err)
+		assert.Equal(t, int64(3), revoked)
+		jwtRepo.AssertExpectations(t)
+		denylist.AssertExpectations(t)
+		denylist.AssertNotCalled(t, "RevokeIssuedBefore", mock.Anything, mock.Anything, mock.Anything)
+	})
+}
+
+// newTestDenylistAuthService : сервис с denylist access-токенов, без репозитория пользователей
+func newTestDenylistAuthService() (*service.AuthenticationService, *MockJWTRepo, *MockTokenDenylist) {
+	jwtRepo := new(MockJWTRepo)
+	denylist := new(MockTokenDenylist)
+	svc := service.NewAuthenticationService(jwtRepo, &config.AppConfig{}, new(MockJWTService), nil, denylist)
+	return svc, jwtRepo, denylist
+}
+
+func TestAccessTokenRevocation(t *testing.T) {
+	t.Run("logout denies access token until exp", func(t *testing.T) {
+		svc, jwtRepo, denylist := newTestDenylistAuthService()
+		expiresAt := time.Now().Add(time.Hour).Truncate(time.Second)
+		claims := &security.Claims{UserUUID: "u1", RefreshTokenUUID: "r1"}
+		claims.ID = "jti-1"
+		claims.ExpiresAt = jwt.NewNumericDate(expiresAt)
+
+		jwtRepo.On("RevokeRefreshToken", mock.Anything, "r1").Return(nil)
+		denylist.On("DenyToken", mock.Anything, "jti-1", expiresAt).Return(nil)
+
+		assert.NoError(t, svc.Logout(context.Background(), claims))
+		jwtRepo.AssertExpectations(t)
+		denylist.AssertExpectations(t)
+	})
+
+	t.Run("logout fails without denying when refresh token is unknown", func(t *testing.T) {
+		svc, jwtRepo, denylist := newTestDenylistAuthService()
+		claims := &security.Claims{UserUUID: "u1", RefreshTokenUUID: "r1"}
+		claims.ID = "jti-1"
+
+		jwtRepo.On("RevokeRefreshToken", mock.Anything, "r1").Return(errors.New("not found"))
+
+		err := svc.Logout(context.Background(), claims)
+
+		assert.Contains(t, err.Error(), "не удалось использовать токен")
+		denylist.AssertNotCalled(t, "DenyToken", mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("revoke all sessions sets issued-before watermark", func(t *testing.T) {
+		svc, jwtRepo, denylist := newTestDenylistAuthService()
+		ctx := context.WithValue(context.Background(), security.UserContextKey,
+			&security.Claims{UserUUID: "admin", Permissions: []string{model.PermissionUsersManage}})
+		before := time.Now()
+
+		jwtRepo.On("RevokeSessions", mock.Anything, "u2", "").Return([]string{"r1", "r2"}, nil)
+		denylist.On("RevokeIssuedBefore", mock.Anything, "u2", mock.MatchedBy(func(issuedBefore time.Time) bool {
+			return issuedBefore.Before(before) == false
+		})).Return(nil)
+
+		revoked, err := svc.RevokeAllSessions(ctx, "u2")
+
+		assert.NoError(t, err)
+		assert.Equal(t, int64(2), revoked)
+		denylist.AssertExpectations(t)
+	})
+}
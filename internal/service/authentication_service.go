@@ -20,19 +20,23 @@ type AuthenticationService struct {
 	*config.AppConfig
 	jwtServiceInterface ports.JWTServiceInterface
 	userRepository      ports.UserRepository
+	tokenDenylist       ports.TokenDenylist
 }
 
+// NewAuthenticationService : tokenDenylist обязателен — через него отзываются access-токены при выходе и завершении сессий
 func NewAuthenticationService(
 	repo ports.JWTRepositoryInterface,
 	cfg *config.AppConfig,
 	service ports.JWTServiceInterface,
 	userInterface ports.UserRepository,
+	tokenDenylist ports.TokenDenylist,
 ) *AuthenticationService {
 	return &AuthenticationService{
 		repo,
 		cfg,
 		service,
 		userInterface,
+		tokenDenylist,
 	}
 }
 
@@ -51,7 +55,7 @@ func (s *AuthenticationService) Login(ctx context.Context, email, password, user
 		return nil, fmt.Errorf("[AuthenticationService] неверный логин или пароль")
 	}
 
-	tokens, refreshToken, err := s.jwtServiceInterface.GenerateAccessRefreshTokens(user.UUID)
+	tokens, refreshToken, err := s.jwtServiceInterface.GenerateAccessRefreshTokens(user.UUID, user.Roles)
 	if err != nil {
 		return nil, util.LogError("[AuthenticationService] ошибка генерации токенов", err)
 	}
@@ -74,6 +78,8 @@ func (s *AuthenticationService) Login(ctx context.Context, email, password, user
 //     который попытался выполнить обновление токенов.
 //  3. При попытке обновления токенов с нового IP отправляет POST-запрос на заданный webhook
 //     с информацией о попытке входа со стороннего IP. Запрещать операцию в данном случае не нужно.
+//  4. Токены одной сессии образуют семейство. Если предъявлен уже использованный refresh-токен семейства,
+//     токен, скорее всего, украден: всё семейство отзывается, а событие уходит на webhook и в журнал безопасности.
 //
 // Параметры:
 //   - ctx: контекст выполнения (для отмены и таймаутов)
@@ -108,8 +114,13 @@ func (s *AuthenticationService) RefreshToken(ctx context.Context, userAgent stri
 	if err != nil {
 		return nil, util.LogError("не удалось найти рефреш токен", err)
 	}
+	if storedRefreshToken.RevokedAt != nil {
+		log.Printf("refresh token %s отозван", refreshTokenUUID)
+		return nil, fmt.Errorf("невалидный токен")
+	}
 	if storedRefreshToken.Used {
 		log.Printf("refresh token %s уже был использован", refreshTokenUUID)
+		s.detectRefreshTokenReuse(ctx, storedRefreshToken, refreshToken, userAgent, ipAddress)
 		return nil, fmt.Errorf("невалидный токен")
 	}
 
@@ -126,31 +137,35 @@ func (s *AuthenticationService) RefreshToken(ctx context.Context, userAgent stri
 		return nil, fmt.Errorf("невалидный токен")
 	}
 
-	if storedRefreshToken.IpAddress != ipAddress {
-		log.Printf("обнаружен вход с нового ip адреса, отправка webhook")
-		go func() {
-			if err := notifier.NotifyWebhook(s.AppConfig.Webhook.URL, userUUID, ipAddress, storedRefreshToken.IpAddress); err != nil {
-				log.Printf("ошибка отправки webhook: %v", err)
-			}
-		}()
-	}
-
 	err = bcrypt.CompareHashAndPassword([]byte(storedRefreshToken.TokenHash), []byte(refreshToken))
 	if err != nil {
 		return nil, util.LogError("невалидный токен", err)
 	}
 
+	if storedRefreshToken.IpAddress != ipAddress {
+		log.Printf("обнаружен вход с нового ip адреса, отправка webhook")
+		s.reportSecurityEvent(ctx, &model.SecurityEvent{
+			UserUUID:    userUUID,
+			Event:       model.SecurityEventNewIP,
+			SessionUUID: optionalString(storedRefreshToken.SessionUUID),
+			IpAddress:   ipAddress,
+			UserAgent:   userAgent,
+			Details:     fmt.Sprintf("прежний IP: %s", storedRefreshToken.IpAddress),
+		}, storedRefreshToken.IpAddress)
+	}
+
 	if err := s.jwtRepoInterface.MarkRefreshTokenUsedByUUID(ctx, refreshTokenUUID); err != nil {
 		return nil, util.LogError("не удалось использовать токен", err)
 	}
 
-	tokensPair, newRefreshToken, err := s.jwtServiceInterface.GenerateAccessRefreshTokens(userUUID)
+	tokensPair, newRefreshToken, err := s.jwtServiceInterface.GenerateAccessRefreshTokens(userUUID, s.currentRoles(ctx, claims))
 	if err != nil {
 		return nil, util.LogError("ошибка генерации токенов", err)
 	}
 
 	newRefreshToken.UserAgent = userAgent
 	newRefreshToken.IpAddress = ipAddress
+	newRefreshToken.SessionUUID = storedRefreshToken.SessionUUID
 	err = s.jwtRepoInterface.SaveRefreshToken(ctx, newRefreshToken, ipAddress)
 	if err != nil {
 		return nil, util.LogError("не удалось сохранить рефреш токен", err)
@@ -160,22 +175,209 @@ func (s *AuthenticationService) RefreshToken(ctx context.Context, userAgent stri
 }
 
 // Logout "деактивирует" пользователя.
-// Изменяет статус поля used у refresh-токена и делает его равным true
+// Изменяет статус поля used у refresh-токена и делает его равным true, revoked_at — время выхода.
+// Access-токен добавляется в denylist по jti и перестаёт приниматься сразу, а не после exp
 //
 // Параметры:
 //   - ctx: контекст выполнения (для отмены и таймаутов)
-//   - refreshTokenUUID: UUID рефреш токена из базы даных
+//   - claims: claims access-токена, которым выполняется выход
 //
 // Пример:
 //
-//	err := handler.AuthenticationService.Logout(ctx, "your refresh token uuid")
+//	err := handler.AuthenticationService.Logout(ctx, claims)
 //
 // Возвращает:
 //   - ошибку, если не удалось изменить поле used
-func (s *AuthenticationService) Logout(ctx context.Context, refreshTokenUUID string) error {
-	err := s.jwtRepoInterface.MarkRefreshTokenUsedByUUID(ctx, refreshTokenUUID)
+func (s *AuthenticationService) Logout(ctx context.Context, claims *security.Claims) error {
+	err := s.jwtRepoInterface.RevokeRefreshToken(ctx, claims.RefreshTokenUUID)
 	if err != nil {
 		return fmt.Errorf("не удалось использовать токен: %w", err)
 	}
+
+	if claims.ExpiresAt != nil {
+		if err := s.tokenDenylist.DenyToken(ctx, claims.ID, claims.ExpiresAt.Time); err != nil {
+			log.Printf("[AuthenticationService] access токен %s не добавлен в denylist: %v", claims.ID, err)
+		}
+	}
+	return nil
+}
+
+// ListSessions : активные сессии пользователя. Свои сессии видит каждый, чужие — с правом users:read
+func (s *AuthenticationService) ListSessions(ctx context.Context, userUUID string) ([]*model.Session, error) {
+	claims, err := sessionOwnerClaims(ctx, userUUID, model.PermissionUsersRead)
+	if err != nil {
+		return nil, err
+	}
+
+	sessions, err := s.jwtRepoInterface.ListSessions(ctx, userUUID)
+	if err != nil {
+		return nil, err
+	}
+	for _, session := range sessions {
+		session.Current = session.TokenUUID == claims.RefreshTokenUUID
+	}
+	return sessions, nil
+}
+
+// RevokeSession : завершает сессию. Свою — каждый, чужую — с правом users:manage.
+// Access-токены сессии перестают приниматься сразу, обновить их уже нельзя
+func (s *AuthenticationService) RevokeSession(ctx context.Context, userUUID string, sessionUUID string) error {
+	claims, err := sessionOwnerClaims(ctx, userUUID, model.PermissionUsersManage)
+	if err != nil {
+		return err
+	}
+
+	tokenUUIDs, err := s.jwtRepoInterface.RevokeSession(ctx, userUUID, sessionUUID)
+	if err != nil {
+		return err
+	}
+	s.denyAccessTokens(ctx, userUUID, tokenUUIDs)
+	log.Printf("[AuthenticationService] сессия %s пользователя %s завершена пользователем %s", sessionUUID, userUUID, claims.UserUUID)
 	return nil
 }
+
+// RevokeOtherSessions : «выйти на всех остальных устройствах» — завершает все сессии пользователя, кроме текущей.
+// Access-токены остальных сессий отзываются по jti: отметка по времени выпуска отозвала бы и текущий токен
+func (s *AuthenticationService) RevokeOtherSessions(ctx context.Context) (int64, error) {
+	claims, err := security.GetClaimsFromContext(ctx)
+	if err != nil || claims == nil {
+		return 0, fmt.Errorf("[AuthenticationService] пользователь не авторизован")
+	}
+
+	current, err := s.jwtRepoInterface.FindByUUID(ctx, claims.RefreshTokenUUID)
+	if err != nil {
+		return 0, util.LogError("[AuthenticationService] не удалось найти текущую сессию", err)
+	}
+
+	tokenUUIDs, err := s.jwtRepoInterface.RevokeSessions(ctx, claims.UserUUID, current.SessionUUID)
+	if err != nil {
+		return 0, err
+	}
+	s.denyAccessTokens(ctx, claims.UserUUID, tokenUUIDs)
+	log.Printf("[AuthenticationService] пользователь %s завершил остальные сессии: %d", claims.UserUUID, len(tokenUUIDs))
+	return int64(len(tokenUUIDs)), nil
+}
+
+// RevokeAllSessions : завершает все сессии пользователя (право users:manage), его access-токены перестают приниматься сразу
+func (s *AuthenticationService) RevokeAllSessions(ctx context.Context, userUUID string) (int64, error) {
+	claims, err := security.GetClaimsFromContext(ctx)
+	if err != nil || claims == nil {
+		return 0, fmt.Errorf("[AuthenticationService] пользователь не авторизован")
+	}
+	if claims.Can(model.PermissionUsersManage) == false {
+		return 0, fmt.Errorf("[AuthenticationService] доступ запрещён")
+	}
+
+	tokenUUIDs, err := s.jwtRepoInterface.RevokeSessions(ctx, userUUID, "")
+	if err != nil {
+		return 0, err
+	}
+	if err := s.tokenDenylist.RevokeIssuedBefore(ctx, userUUID, time.Now()); err != nil {
+		log.Printf("[AuthenticationService] access токены пользователя %s не отозваны: %v", userUUID, err)
+	}
+	log.Printf("[AuthenticationService] все сессии пользователя %s (%d) завершены пользователем %s", userUUID, len(tokenUUIDs), claims.UserUUID)
+	return int64(len(tokenUUIDs)), nil
+}
+
+// ListSecurityEvents : журнал безопасности пользователя от новых событий к старым.
+// Свой журнал видит каждый, чужой — с правом users:read
+func (s *AuthenticationService) ListSecurityEvents(ctx context.Context, userUUID string, cursor string, limit int) ([]*model.SecurityEvent, string, error) {
+	if _, err := sessionOwnerClaims(ctx, userUUID, model.PermissionUsersRead); err != nil {
+		return nil, "", err
+	}
+	return s.jwtRepoInterface.ListSecurityEvents(ctx, userUUID, cursor, limit)
+}
+
+// detectRefreshTokenReuse : повторное предъявление использованного refresh-токена. Если токен подлинный,
+// отзывается всё его семейство (сессия), включая токены, выданные при обновлении, и действующий access-токен сессии.
+// Ответ клиенту от этого не меняется
+func (s *AuthenticationService) detectRefreshTokenReuse(ctx context.Context, storedRefreshToken *model.RefreshToken, refreshToken string, userAgent string, ipAddress string) {
+	if bcrypt.CompareHashAndPassword([]byte(storedRefreshToken.TokenHash), []byte(refreshToken)) != nil {
+		return
+	}
+
+	log.Printf("[AuthenticationService] повторное использование refresh token %s, сессия %s пользователя %s отзывается",
+		storedRefreshToken.UUID, storedRefreshToken.SessionUUID, storedRefreshToken.UserUUID)
+	tokenUUIDs, err := s.jwtRepoInterface.RevokeSession(ctx, storedRefreshToken.UserUUID, storedRefreshToken.SessionUUID)
+	if err != nil {
+		log.Printf("[AuthenticationService] не удалось отозвать сессию %s: %v", storedRefreshToken.SessionUUID, err)
+	}
+	s.denyAccessTokens(ctx, storedRefreshToken.UserUUID, tokenUUIDs)
+
+	s.reportSecurityEvent(ctx, &model.SecurityEvent{
+		UserUUID:    storedRefreshToken.UserUUID,
+		Event:       model.SecurityEventRefreshTokenReuse,
+		SessionUUID: optionalString(storedRefreshToken.SessionUUID),
+		IpAddress:   ipAddress,
+		UserAgent:   userAgent,
+		Details:     fmt.Sprintf("refresh-токен %s предъявлен повторно, сессия отозвана", storedRefreshToken.UUID),
+	}, storedRefreshToken.IpAddress)
+}
+
+// denyAccessTokens : отзывает access-токены отозванных сессий. jti access-токена совпадает с uuid refresh-токена,
+// выданного вместе с ним. Ошибка только логируется: отзыв сессий всё равно проверяется по refresh-токенам в БД
+func (s *AuthenticationService) denyAccessTokens(ctx context.Context, userUUID string, tokenUUIDs []string) {
+	if err := s.tokenDenylist.DenyTokens(ctx, tokenUUIDs); err != nil {
+		log.Printf("[AuthenticationService] access токены отозванных сессий пользователя %s не добавлены в denylist: %v", userUUID, err)
+	}
+}
+
+// reportSecurityEvent : записывает событие в журнал безопасности и отправляет его на webhook, если он настроен.
+// Ошибки только логируются, чтобы не влиять на ответ клиенту
+func (s *AuthenticationService) reportSecurityEvent(ctx context.Context, event *model.SecurityEvent, oldIP string) {
+	if err := s.jwtRepoInterface.SaveSecurityEvent(ctx, event); err != nil {
+		log.Printf("[AuthenticationService] не удалось записать событие %s пользователя %s: %v", event.Event, event.UserUUID, err)
+	}
+
+	if s.AppConfig.Webhook.URL == "" {
+		return
+	}
+	payload := &notifier.WebhookNotify{
+		UserUUID: event.UserUUID,
+		NewIP:    event.IpAddress,
+		OldIP:    oldIP,
+		Event:    event.Event,
+	}
+	if event.SessionUUID != nil {
+		payload.SessionUUID = *event.SessionUUID
+	}
+	go func() {
+		if err := notifier.NotifySecurityEvent(s.AppConfig.Webhook.URL, payload); err != nil {
+			log.Printf("ошибка отправки webhook: %v", err)
+		}
+	}()
+}
+
+func optionalString(value string) *string {
+	if value == "" {
+		return nil
+	}
+	return &value
+}
+
+// sessionOwnerClaims : доступ к сессиям пользователя — свои или с правом permission
+func sessionOwnerClaims(ctx context.Context, userUUID string, permission string) (*security.Claims, error) {
+	claims, err := security.GetClaimsFromContext(ctx)
+	if err != nil || claims == nil {
+		return nil, fmt.Errorf("[AuthenticationService] пользователь не авторизован")
+	}
+	if claims.UserUUID != userUUID && claims.Can(permission) == false {
+		return nil, fmt.Errorf("[AuthenticationService] доступ запрещён")
+	}
+	return claims, nil
+}
+
+// currentRoles : роли перечитываются при каждом обновлении токенов, чтобы назначенные и снятые роли попадали в новый токен.
+// Если прочитать не удалось, остаются роли из старого токена
+func (s *AuthenticationService) currentRoles(ctx context.Context, claims *security.Claims) []string {
+	db, ok := ctx.Value("db").(*config.Database)
+	if ok == false {
+		return claims.Roles
+	}
+	user, err := s.userRepository.FindByUUID(ctx, db, claims.UserUUID)
+	if err != nil {
+		log.Printf("[AuthenticationService] не удалось перечитать роли пользователя %s: %v", claims.UserUUID, err)
+		return claims.Roles
+	}
+	return user.Roles
+}
@@ -0,0 +1,321 @@
+package service
+
+import (
+	"caching-web-server/config"
+	"caching-web-server/internal/model"
+	"caching-web-server/internal/ports"
+	"caching-web-server/internal/security"
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+)
+
+const (
+	maxWarmDocuments     = 500 // UUID в одном запросе прогрева
+	defaultWarmUserLimit = 20
+	maxWarmUserLimit     = 100
+	defaultWarmTop       = 100
+	defaultWarmRate      = 100 // документов в секунду
+	defaultWarmBatchSize = 20
+)
+
+type CacheAdminService struct {
+	warmRepository  ports.CacheWarmRepository
+	grantRepository ports.GrantDocumentRepository
+	cacheRepository ports.CacheRepository
+	top             int
+	rate            int
+	batchSize       int
+}
+
+func NewCacheAdminService(
+	warmRepository ports.CacheWarmRepository,
+	grantRepository ports.GrantDocumentRepository,
+	cacheRepository ports.CacheRepository,
+	cfg *config.CacheWarmupConfig,
+) *CacheAdminService {
+	s := &CacheAdminService{
+		warmRepository:  warmRepository,
+		grantRepository: grantRepository,
+		cacheRepository: cacheRepository,
+		top:             defaultWarmTop,
+		rate:            defaultWarmRate,
+		batchSize:       defaultWarmBatchSize,
+	}
+	if cfg != nil {
+		if cfg.Top > 0 {
+			s.top = min(cfg.Top, maxWarmDocuments)
+		}
+		if cfg.Rate > 0 {
+			s.rate = cfg.Rate
+		}
+		if cfg.BatchSize > 0 {
+			s.batchSize = cfg.BatchSize
+		}
+	}
+	return s
+}
+
+// InspectDocument : запись кэша документа document:{uuid} (право cache:read)
+func (s *CacheAdminService) InspectDocument(ctx context.Context, documentUUID string) (*model.CacheKeyInfo, error) {
+	return s.InspectKey(ctx, "document:"+documentUUID)
+}
+
+// InspectKey : значение, TTL, размер и кодировка ключа кэша (право cache:read)
+func (s *CacheAdminService) InspectKey(ctx context.Context, key string) (*model.CacheKeyInfo, error) {
+	admin, err := s.cacheAdmin(ctx, model.PermissionCacheRead)
+	if err != nil {
+		return nil, err
+	}
+
+	info, err := admin.InspectKey(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	if info == nil {
+		return nil, fmt.Errorf("[CacheAdminService] ключ %s не найден в кэше", key)
+	}
+	return info, nil
+}
+
+// EvictKeys : удаляет ключ или ключи по шаблону (право cache:manage)
+func (s *CacheAdminService) EvictKeys(ctx context.Context, pattern string) (int64, error) {
+	admin, err := s.cacheAdmin(ctx, model.PermissionCacheManage)
+	if err != nil {
+		return 0, err
+	}
+
+	pattern = strings.TrimSpace(pattern)
+	if pattern == "" {
+		return 0, fmt.Errorf("[CacheAdminService] не указан ключ или шаблон")
+	}
+
+	deleted, err := admin.EvictKeys(ctx, pattern)
+	if err != nil {
+		return deleted, err
+	}
+	log.Printf("[CacheAdminService] администратор %s удалил из кэша ключей: %d (%s)", adminUUID(ctx), deleted, pattern)
+	return deleted, nil
+}
+
+// FlushDocuments : очищает всё пространство кэша документов (право cache:manage)
+func (s *CacheAdminService) FlushDocuments(ctx context.Context) (int64, error) {
+	admin, err := s.cacheAdmin(ctx, model.PermissionCacheManage)
+	if err != nil {
+		return 0, err
+	}
+
+	deleted, err := admin.FlushDocuments(ctx)
+	if err != nil {
+		return deleted, err
+	}
+	log.Printf("[CacheAdminService] администратор %s очистил кэш документов: удалено ключей %d", adminUUID(ctx), deleted)
+	return deleted, nil
+}
+
+// WarmDocuments : загружает документы из БД в кэш (право cache:manage)
+func (s *CacheAdminService) WarmDocuments(ctx context.Context, documentUUIDs []string) (*model.CacheWarmResult, error) {
+	db, err := s.adminDatabase(ctx, model.PermissionCacheManage)
+	if err != nil {
+		return nil, err
+	}
+
+	uuids := uniqueNonEmpty(documentUUIDs)
+	if len(uuids) == 0 {
+		return nil, fmt.Errorf("[CacheAdminService] не указаны документы для прогрева")
+	}
+	if len(uuids) > maxWarmDocuments {
+		return nil, fmt.Errorf("[CacheAdminService] слишком много документов для прогрева, максимум %d", maxWarmDocuments)
+	}
+
+	return s.warm(ctx, db, uuids)
+}
+
+// WarmUserDocuments : загружает в кэш последние изменённые документы пользователя (право cache:manage)
+func (s *CacheAdminService) WarmUserDocuments(ctx context.Context, userUUID string, limit int) (*model.CacheWarmResult, error) {
+	db, err := s.adminDatabase(ctx, model.PermissionCacheManage)
+	if err != nil {
+		return nil, err
+	}
+
+	if limit <= 0 {
+		limit = defaultWarmUserLimit
+	}
+	if limit > maxWarmUserLimit {
+		limit = maxWarmUserLimit
+	}
+
+	uuids, err := s.warmRepository.ListRecentDocumentUUIDs(ctx, db, userUUID, limit)
+	if err != nil {
+		return nil, err
+	}
+	if len(uuids) == 0 {
+		return &model.CacheWarmResult{}, nil
+	}
+	return s.warm(ctx, db, uuids)
+}
+
+// WarmPopularDocuments : загружает в кэш самые читаемые документы, по умолчанию cache.warmup.top (право cache:manage)
+func (s *CacheAdminService) WarmPopularDocuments(ctx context.Context, limit int) (*model.CacheWarmResult, error) {
+	db, err := s.adminDatabase(ctx, model.PermissionCacheManage)
+	if err != nil {
+		return nil, err
+	}
+
+	if limit <= 0 {
+		limit = s.top
+	}
+	return s.warmPopular(ctx, db, min(limit, maxWarmDocuments))
+}
+
+// WarmOnStartup : прогрев популярных документов после старта реплики, пока кэш пуст после деплоя
+// или очистки Redis. ctx должен содержать db
+func (s *CacheAdminService) WarmOnStartup(ctx context.Context) {
+	db, ok := ctx.Value("db").(*config.Database)
+	if !ok {
+		log.Printf("[CacheAdminService] database connection не найден в context, прогрев пропущен")
+		return
+	}
+
+	started := time.Now()
+	result, err := s.warmPopular(ctx, db, s.top)
+	if err != nil {
+		log.Printf("[CacheAdminService] прогрев кэша при старте не выполнен: %v", err)
+		return
+	}
+	log.Printf("[CacheAdminService] прогрев кэша при старте: загружено %d, нет в БД %d, ошибок %d за %s",
+		result.Warmed, len(result.Missing), len(result.Failed), time.Since(started).Round(time.Millisecond))
+}
+
+func (s *CacheAdminService) warmPopular(ctx context.Context, db *config.Database, limit int) (*model.CacheWarmResult, error) {
+	popularity, ok := s.cacheRepository.(ports.DocumentPopularity)
+	if ok == false {
+		return nil, fmt.Errorf("[CacheAdminService] кэш не учитывает популярность документов")
+	}
+
+	uuids, err := popularity.TopDocuments(ctx, limit)
+	if err != nil {
+		return nil, err
+	}
+	if len(uuids) == 0 {
+		return &model.CacheWarmResult{}, nil
+	}
+	return s.warm(ctx, db, uuids)
+}
+
+// warm : документы читаются из БД пачками по batchSize не быстрее rate документов в секунду,
+// чтобы прогрев не конкурировал с обычными запросами. Удалённые документы убираются из популярных
+func (s *CacheAdminService) warm(ctx context.Context, db *config.Database, uuids []string) (*model.CacheWarmResult, error) {
+	result := &model.CacheWarmResult{}
+	batchInterval := time.Duration(float64(time.Second) * float64(s.batchSize) / float64(s.rate))
+
+	for start := 0; start < len(uuids); start += s.batchSize {
+		batchStarted := time.Now()
+		end := min(start+s.batchSize, len(uuids))
+		if err := s.warmBatch(ctx, db, uuids[start:end], result); err != nil {
+			return nil, err
+		}
+
+		if end < len(uuids) {
+			select {
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			case <-time.After(time.Until(batchStarted.Add(batchInterval))):
+			}
+		}
+	}
+
+	if popularity, ok := s.cacheRepository.(ports.DocumentPopularity); ok && len(result.Missing) > 0 {
+		if err := popularity.ForgetPopularity(ctx, result.Missing...); err != nil {
+			log.Printf("[CacheAdminService] ошибка удаления отсутствующих документов из популярных: %v", err)
+		}
+	}
+	return result, nil
+}
+
+// warmBatch : документы кладутся в кэш вместе со списком grant, как при обычной загрузке из БД.
+// Ошибка по одному документу не прерывает прогрев остальных
+func (s *CacheAdminService) warmBatch(ctx context.Context, db *config.Database, uuids []string, result *model.CacheWarmResult) error {
+	documents, err := s.warmRepository.GetDocuments(ctx, db, uuids)
+	if err != nil {
+		return err
+	}
+
+	found := make(map[string]bool, len(documents))
+	for i := range documents {
+		document := &documents[i]
+		found[document.UUID] = true
+
+		grants, err := s.grantRepository.ListGrants(ctx, db, document.UUID)
+		if err != nil {
+			result.Failed = append(result.Failed, document.UUID)
+			continue
+		}
+		document.GrantLogins = grants
+
+		if err := s.cacheRepository.SetDocument(ctx, document); err != nil {
+			result.Failed = append(result.Failed, document.UUID)
+			continue
+		}
+		result.Warmed++
+	}
+
+	for _, uuid := range uuids {
+		if found[uuid] == false {
+			result.Missing = append(result.Missing, uuid)
+		}
+	}
+	return nil
+}
+
+func (s *CacheAdminService) cacheAdmin(ctx context.Context, permission string) (ports.CacheAdmin, error) {
+	if _, err := s.adminDatabase(ctx, permission); err != nil {
+		return nil, err
+	}
+	admin, ok := s.cacheRepository.(ports.CacheAdmin)
+	if ok == false {
+		return nil, fmt.Errorf("[CacheAdminService] кэш не поддерживает администрирование")
+	}
+	return admin, nil
+}
+
+// adminDatabase : проверяет право администратора кэша
+func (s *CacheAdminService) adminDatabase(ctx context.Context, permission string) (*config.Database, error) {
+	claims, err := security.GetClaimsFromContext(ctx)
+	if err != nil || claims == nil {
+		return nil, fmt.Errorf("[CacheAdminService] пользователь не авторизован")
+	}
+	if claims.Can(permission) == false {
+		return nil, fmt.Errorf("[CacheAdminService] доступ запрещён")
+	}
+
+	db, ok := ctx.Value("db").(*config.Database)
+	if !ok {
+		return nil, fmt.Errorf("[CacheAdminService] database connection не найден в context")
+	}
+	return db, nil
+}
+
+func adminUUID(ctx context.Context) string {
+	claims, err := security.GetClaimsFromContext(ctx)
+	if err != nil || claims == nil {
+		return ""
+	}
+	return claims.UserUUID
+}
+
+func uniqueNonEmpty(values []string) []string {
+	seen := make(map[string]bool, len(values))
+	unique := make([]string, 0, len(values))
+	for _, value := range values {
+		value = strings.TrimSpace(value)
+		if value == "" || seen[value] {
+			continue
+		}
+		seen[value] = true
+		unique = append(unique, value)
+	}
+	return unique
+}
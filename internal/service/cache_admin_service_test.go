@@ -0,0 +1,171 @@
+package service_test
+
+import (
+	"caching-web-server/config"
+	"caching-web-server/internal/model"
+	"caching-web-server/internal/security"
+	"caching-web-server/internal/service"
+	"context"
+	"errors"
+	"github.com/jmoiron/sqlx"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+// MockAdminCacheRepository : кэш с администрированием ключей
+type MockAdminCacheRepository struct{ MockCacheRepository }
+
+func (m *MockAdminCacheRepository) InspectKey(ctx context.Context, key string) (*model.CacheKeyInfo, error) {
+	args := m.Called(ctx, key)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.CacheKeyInfo), args.Error(1)
+}
+
+func (m *MockAdminCacheRepository) EvictKeys(ctx context.Context, pattern string) (int64, error) {
+	args := m.Called(ctx, pattern)
+	return args.Get(0).(int64), args.Error(1)
+}
+
+func (m *MockAdminCacheRepository) FlushDocuments(ctx context.Context) (int64, error) {
+	args := m.Called(ctx)
+	return args.Get(0).(int64), args.Error(1)
+}
+
+// MockPopularCacheRepository : кэш, учитывающий популярность документов
+type MockPopularCacheRepository struct{ MockCacheRepository }
+
+func (m *MockPopularCacheRepository) RecordAccess(uuid string) {
+	m.Called(uuid)
+}
+
+func (m *MockPopularCacheRepository) TopDocuments(ctx context.Context, limit int) ([]string, error) {
+	args := m.Called(ctx, limit)
+	if uuids, ok := args.Get(0).([]string); ok {
+		return uuids, args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *MockPopularCacheRepository) ForgetPopularity(ctx context.Context, uuids ...string) error {
+	return m.Called(ctx, uuids).Error(0)
+}
+
+type MockCacheWarmRepository struct{ mock.Mock }
+
+func (m *MockCacheWarmRepository) GetDocuments(ctx context.Context, exec sqlx.ExtContext, documentUUIDs []string) ([]model.Document, error) {
+	args := m.Called(ctx, exec, documentUUIDs)
+	if docs, ok := args.Get(0).([]model.Document); ok {
+		return docs, args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *MockCacheWarmRepository) ListRecentDocumentUUIDs(ctx context.Context, exec sqlx.ExtContext, ownerUUID string, limit int) ([]string, error) {
+	args := m.Called(ctx, exec, ownerUUID, limit)
+	if uuids, ok := args.Get(0).([]string); ok {
+		return uuids, args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+// newTestCacheAdminService : сервис администрирования кэша с моками репозиториев
+func newTestCacheAdminService() (*service.CacheAdminService, *MockCacheWarmRepository, *MockGrantRepository, *MockAdminCacheRepository) {
+	warmRepo := new(MockCacheWarmRepository)
+	grantRepo := new(MockGrantRepository)
+	cache := new(MockAdminCacheRepository)
+	return service.NewCacheAdminService(warmRepo, grantRepo, cache, nil), warmRepo, grantRepo, cache
+}
+
+func TestCacheAdminService(t *testing.T) {
+	db := &config.Database{}
+	adminCtx := context.WithValue(context.Background(), security.UserContextKey, &security.Claims{UserUUID: "admin", IsAdmin: true})
+	adminCtx = context.WithValue(adminCtx, "db", db)
+
+	t.Run("Только администратор", func(t *testing.T) {
+		svc, _, _, cache := newTestCacheAdminService()
+		userCtx := context.WithValue(context.Background(), security.UserContextKey, &security.Claims{UserUUID: "user1"})
+		userCtx = context.WithValue(userCtx, "db", db)
+
+		_, err := svc.FlushDocuments(userCtx)
+
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "доступ запрещён")
+		cache.AssertNotCalled(t, "FlushDocuments", mock.Anything)
+	})
+
+	t.Run("Запись документа ищется по ключу document:{uuid}", func(t *testing.T) {
+		svc, _, _, cache := newTestCacheAdminService()
+		info := &model.CacheKeyInfo{Key: "document:doc1", Type: "string", Encoding: "binary"}
+		cache.On("InspectKey", adminCtx, "document:doc1").Return(info, nil).Once()
+		cache.On("InspectKey", adminCtx, "document:doc2").Return(nil, nil).Once()
+
+		got, err := svc.InspectDocument(adminCtx, "doc1")
+		require.NoError(t, err)
+		assert.Equal(t, info, got)
+
+		_, err = svc.InspectDocument(adminCtx, "doc2")
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "не найден в кэше")
+	})
+
+	t.Run("Прогрев кладёт документы с grant и сообщает об отсутствующих и неудачных", func(t *testing.T) {
+		svc, warmRepo, grantRepo, cache := newTestCacheAdminService()
+		warmRepo.On("GetDocuments", adminCtx, db, []string{"doc1", "doc2", "doc3"}).Return([]model.Document{
+			{UUID: "doc1", OwnerUUID: "user1"},
+			{UUID: "doc3", OwnerUUID: "user1"},
+		}, nil).Once()
+		grantRepo.On("ListGrants", adminCtx, db, "doc1").Return([]string{"bob"}, nil).Once()
+		grantRepo.On("ListGrants", adminCtx, db, "doc3").Return([]string{}, nil).Once()
+		cache.On("SetDocument", adminCtx, mock.MatchedBy(func(doc *model.Document) bool {
+			return doc.UUID == "doc1" && assert.ObjectsAreEqual([]string{"bob"}, doc.GrantLogins)
+		})).Return(nil).Once()
+		cache.On("SetDocument", adminCtx, mock.MatchedBy(func(doc *model.Document) bool {
+			return doc.UUID == "doc3"
+		})).Return(errors.New("redis down")).Once()
+
+		result, err := svc.WarmDocuments(adminCtx, []string{"doc1", "doc2", "doc1", " ", "doc3"})
+
+		require.NoError(t, err)
+		assert.Equal(t, 1, result.Warmed)
+		assert.Equal(t, []string{"doc2"}, result.Missing)
+		assert.Equal(t, []string{"doc3"}, result.Failed)
+		cache.AssertExpectations(t)
+	})
+
+	t.Run("Прогрев документов пользователя ограничивает limit", func(t *testing.T) {
+		svc, warmRepo, _, _ := newTestCacheAdminService()
+		warmRepo.On("ListRecentDocumentUUIDs", adminCtx, db, "user1", 100).Return([]string{}, nil).Once()
+
+		result, err := svc.WarmUserDocuments(adminCtx, "user1", 1000)
+
+		require.NoError(t, err)
+		assert.Equal(t, 0, result.Warmed)
+		warmRepo.AssertExpectations(t)
+	})
+
+	t.Run("Популярные документы читаются из БД пачками, удалённые забываются", func(t *testing.T) {
+		warmRepo := new(MockCacheWarmRepository)
+		grantRepo := new(MockGrantRepository)
+		cache := new(MockPopularCacheRepository)
+		svc := service.NewCacheAdminService(warmRepo, grantRepo, cache, &config.CacheWarmupConfig{Top: 3, Rate: 1000, BatchSize: 2})
+
+		cache.On("TopDocuments", adminCtx, 3).Return([]string{"doc1", "doc2", "doc3"}, nil).Once()
+		warmRepo.On("GetDocuments", adminCtx, db, []string{"doc1", "doc2"}).Return([]model.Document{{UUID: "doc1"}}, nil).Once()
+		warmRepo.On("GetDocuments", adminCtx, db, []string{"doc3"}).Return([]model.Document{{UUID: "doc3"}}, nil).Once()
+		grantRepo.On("ListGrants", adminCtx, db, mock.Anything).Return([]string{}, nil)
+		cache.On("SetDocument", adminCtx, mock.Anything).Return(nil).Twice()
+		cache.On("ForgetPopularity", adminCtx, []string{"doc2"}).Return(nil).Once()
+
+		result, err := svc.WarmPopularDocuments(adminCtx, 0)
+
+		require.NoError(t, err)
+		assert.Equal(t, 2, result.Warmed)
+		assert.Equal(t, []string{"doc2"}, result.Missing)
+		warmRepo.AssertExpectations(t)
+		cache.AssertExpectations(t)
+	})
+}
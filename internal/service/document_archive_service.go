@@ -0,0 +1,136 @@
+package service
+
+import (
+	"archive/zip"
+	"caching-web-server/config"
+	"caching-web-server/internal/model"
+	"caching-web-server/internal/security"
+	"caching-web-server/internal/util"
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"path"
+	"strings"
+)
+
+// MaxArchiveDocuments : максимальное количество документов в одном ZIP-архиве
+const MaxArchiveDocuments = 100
+
+// ResolveArchiveDocuments : собирает список документов для ZIP-архива.
+// Документы берутся либо по списку UUID, либо (если список пуст) по фильтру среди собственных документов пользователя.
+// Каждый документ проходит те же проверки доступа, что и в GetDocumentByUUID
+func (s *DocumentService) ResolveArchiveDocuments(ctx context.Context, documentUUIDs []string, filterKey, filterValue string) ([]*model.Document, error) {
+	claims, err := security.GetClaimsFromContext(ctx)
+	if err != nil || claims == nil {
+		return nil, fmt.Errorf("[DocumentService] пользователь не авторизован")
+	}
+
+	if len(documentUUIDs) == 0 && filterKey != "" {
+		db, ok := ctx.Value("db").(*config.Database)
+		if !ok {
+			return nil, fmt.Errorf("[DocumentService] database connection не найден в context")
+		}
+
+		docs, err := s.documentRepository.ListDocuments(ctx, db, claims.UserUUID, "", filterKey, filterValue, MaxArchiveDocuments)
+		if err != nil {
+			return nil, util.LogError("[DocumentService] не удалось получить список документов", err)
+		}
+		for _, doc := range docs {
+			documentUUIDs = append(documentUUIDs, doc.UUID)
+		}
+	}
+
+	if len(documentUUIDs) == 0 {
+		return nil, fmt.Errorf("[DocumentService] не выбрано ни одного документа для архива")
+	}
+	if len(documentUUIDs) > MaxArchiveDocuments {
+		return nil, fmt.Errorf("[DocumentService] слишком много документов для архива: максимум %d", MaxArchiveDocuments)
+	}
+
+	seen := make(map[string]bool, len(documentUUIDs))
+	documents := make([]*model.Document, 0, len(documentUUIDs))
+	for _, documentUUID := range documentUUIDs {
+		if seen[documentUUID] {
+			continue
+		}
+		seen[documentUUID] = true
+
+		document, err := s.getAuthorizedDocument(ctx, documentUUID)
+		if err != nil {
+			return nil, err
+		}
+		if document.IsFile == false || document.StoragePath == "" {
+			continue
+		}
+		documents = append(documents, document)
+	}
+
+	return documents, nil
+}
+
+// WriteArchive : потоково пишет ZIP-архив с содержимым документов из S3 в writer.
+// Файлы не буферизуются в памяти целиком: каждый объект копируется из S3 прямо в запись архива
+func (s *DocumentService) WriteArchive(ctx context.Context, documents []*model.Document, writer io.Writer) error {
+	zipWriter := zip.NewWriter(writer)
+	entryNames := make(map[string]bool, len(documents))
+
+	for _, document := range documents {
+		if err := ctx.Err(); err != nil {
+			return util.LogError("[DocumentService] формирование архива прервано", err)
+		}
+
+		header := &zip.FileHeader{
+			Name:     uniqueArchiveEntryName(entryNames, document.FilenameOriginal),
+			Method:   zip.Deflate,
+			Modified: document.CreatedAt,
+		}
+
+		entry, err := zipWriter.CreateHeader(header)
+		if err != nil {
+			return util.LogError("[DocumentService] не удалось создать запись архива", err)
+		}
+
+		if err := s.copyObject(ctx, document.StoragePath, entry); err != nil {
+			return err
+		}
+	}
+
+	if err := zipWriter.Close(); err != nil {
+		return util.LogError("[DocumentService] не удалось завершить архив", err)
+	}
+
+	log.Printf("[DocumentService] архив из %d документов успешно сформирован", len(documents))
+	return nil
+}
+
+func (s *DocumentService) copyObject(ctx context.Context, storagePath string, writer io.Writer) error {
+	body, err := s.storageInterface.GetObject(ctx, storagePath)
+	if err != nil {
+		return util.LogError("[DocumentService] не удалось получить файл из S3", err)
+	}
+	defer body.Close()
+
+	if _, err := io.Copy(writer, body); err != nil {
+		return util.LogError("[DocumentService] ошибка записи файла в архив", err)
+	}
+	return nil
+}
+
+// uniqueArchiveEntryName : возвращает имя записи архива без путей, добавляя суффикс " (N)" к повторяющимся именам
+func uniqueArchiveEntryName(used map[string]bool, filename string) string {
+	name := path.Base(strings.ReplaceAll(filename, "\\", "/"))
+	if name == "." || name == "/" || name == "" {
+		name = "document"
+	}
+
+	candidate := name
+	ext := path.Ext(name)
+	base := strings.TrimSuffix(name, ext)
+	for n := 1; used[candidate]; n++ {
+		candidate = fmt.Sprintf("%s (%d)%s", base, n, ext)
+	}
+
+	used[candidate] = true
+	return candidate
+}
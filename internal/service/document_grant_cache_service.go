@@ -0,0 +1,74 @@
+package service
+
+import (
+	"caching-web-server/internal/ports"
+	"context"
+	"github.com/jmoiron/sqlx"
+	"log"
+)
+
+// hasAccess : проверка grant по кэшированному множеству пользователей документа. Если множества нет,
+// оно неполное или Redis недоступен, решение принимает БД (fail closed), а множество перечитывается
+func (s *DocumentService) hasAccess(ctx context.Context, exec sqlx.ExtContext, documentUUID string, userUUID string) (bool, error) {
+	grantCache, ok := s.cacheRepository.(ports.GrantCache)
+	if ok == false {
+		return s.grantRepository.HasAccess(ctx, exec, documentUUID, userUUID)
+	}
+
+	granted, cached, err := grantCache.IsGranted(ctx, documentUUID, userUUID)
+	if err != nil {
+		log.Printf("[DocumentService] кэш grant недоступен, доступ проверяется по БД: %v", err)
+	} else if cached {
+		return granted, nil
+	}
+
+	// версия читается до БД: если grant изменятся, пока идёт загрузка, множество не сохранится
+	version, versionErr := grantCache.GrantSetVersion(ctx, documentUUID)
+
+	hasAccess, err := s.grantRepository.HasAccess(ctx, exec, documentUUID, userUUID)
+	if err != nil {
+		return false, err
+	}
+
+	if versionErr == nil {
+		s.storeGrantSet(ctx, exec, grantCache, documentUUID, version)
+	}
+	return hasAccess, nil
+}
+
+func (s *DocumentService) storeGrantSet(ctx context.Context, exec sqlx.ExtContext, grantCache ports.GrantCache, documentUUID string, version int64) {
+	userUUIDs, err := s.grantRepository.ListGrantUserUUIDs(ctx, exec, documentUUID)
+	if err != nil {
+		log.Printf("[DocumentService] не удалось загрузить grant документа %s для кэша: %v", documentUUID, err)
+		return
+	}
+
+	if err := grantCache.StoreGrantSet(ctx, documentUUID, userUUIDs, version); err != nil {
+		log.Printf("[DocumentService] не удалось закэшировать grant документа %s: %v", documentUUID, err)
+	}
+}
+
+// updateGrantSet : меняет кэшированное множество grant после коммита изменения в БД. Если изменить
+// множество не удалось, оно удаляется целиком: иначе отозванный grant продолжал бы действовать до истечения TTL
+func (s *DocumentService) updateGrantSet(ctx context.Context, documentUUID string, userUUID string, granted bool) {
+	grantCache, ok := s.cacheRepository.(ports.GrantCache)
+	if ok == false {
+		return
+	}
+
+	var err error
+	if granted {
+		err = grantCache.AddToGrantSet(ctx, documentUUID, userUUID)
+	} else {
+		err = grantCache.RemoveFromGrantSet(ctx, documentUUID, userUUID)
+	}
+	if err == nil {
+		return
+	}
+	log.Printf("[DocumentService] не удалось обновить кэш grant документа %s: %v", documentUUID, err)
+
+	if err := grantCache.DropGrantSet(ctx, documentUUID); err != nil {
+		// кэш сам запомнит документ и удалит множество, когда Redis снова будет доступен
+		log.Printf("[DocumentService] не удалось удалить множество grant документа %s из кэша: %v", documentUUID, err)
+	}
+}
@@ -0,0 +1,143 @@
+package service_test
+
+import (
+	"caching-web-server/config"
+	"caching-web-server/internal/model"
+	"caching-web-server/internal/security"
+	"caching-web-server/internal/service"
+	"context"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+// MockGrantCacheRepository : кэш с множествами grant
+type MockGrantCacheRepository struct{ MockCacheRepository }
+
+func (m *MockGrantCacheRepository) IsGranted(ctx context.Context, documentUUID string, userUUID string) (bool, bool, error) {
+	args := m.Called(ctx, documentUUID, userUUID)
+	return args.Bool(0), args.Bool(1), args.Error(2)
+}
+
+func (m *MockGrantCacheRepository) GrantSetVersion(ctx context.Context, documentUUID string) (int64, error) {
+	args := m.Called(ctx, documentUUID)
+	return args.Get(0).(int64), args.Error(1)
+}
+
+func (m *MockGrantCacheRepository) StoreGrantSet(ctx context.Context, documentUUID string, userUUIDs []string, version int64) error {
+	return m.Called(ctx, documentUUID, userUUIDs, version).Error(0)
+}
+
+func (m *MockGrantCacheRepository) AddToGrantSet(ctx context.Context, documentUUID string, userUUID string) error {
+	return m.Called(ctx, documentUUID, userUUID).Error(0)
+}
+
+func (m *MockGrantCacheRepository) RemoveFromGrantSet(ctx context.Context, documentUUID string, userUUID string) error {
+	return m.Called(ctx, documentUUID, userUUID).Error(0)
+}
+
+func (m *MockGrantCacheRepository) DropGrantSet(ctx context.Context, documentUUID string) error {
+	return m.Called(ctx, documentUUID).Error(0)
+}
+
+// newTestGrantCacheService : сервис документов поверх кэша с множествами grant
+func newTestGrantCacheService() (*service.DocumentService, *MockDocumentRepository, *MockGrantCacheRepository, *MockGrantRepository) {
+	mockDocRepo := new(MockDocumentRepository)
+	mockCache := new(MockGrantCacheRepository)
+	mockGrantRepo := new(MockGrantRepository)
+	svc := service.NewDocumentService(mockDocRepo, mockCache, mockGrantRepo, new(MockS3Storage), nil, nil, nil, time.Minute)
+	return svc, mockDocRepo, mockCache, mockGrantRepo
+}
+
+func TestGrantCache(t *testing.T) {
+	ctx := context.WithValue(context.Background(), security.UserContextKey, &security.Claims{UserUUID: "user2"})
+	db := &config.Database{}
+	ctx = context.WithValue(ctx, "db", db)
+
+	sharedDoc := &model.Document{UUID: "doc1", OwnerUUID: "user1", FilenameOriginal: "file.txt"}
+
+	t.Run("Доступ по кэшированному множеству — без БД", func(t *testing.T) {
+		svc, _, mockCache, mockGrantRepo := newTestGrantCacheService()
+		mockCache.On("GetDocument", ctx, "doc1").Return(sharedDoc, nil).Once()
+		mockCache.On("IsGranted", ctx, "doc1", "user2").Return(true, true, nil).Once()
+
+		res, err := svc.GetDocumentByUUID(ctx, "doc1")
+
+		require.NoError(t, err)
+		assert.Equal(t, sharedDoc, res.Document)
+		mockGrantRepo.AssertNotCalled(t, "HasAccess", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("Пользователя нет в множестве — отказ", func(t *testing.T) {
+		svc, _, mockCache, mockGrantRepo := newTestGrantCacheService()
+		mockCache.On("GetDocument", ctx, "doc1").Return(sharedDoc, nil).Once()
+		mockCache.On("IsGranted", ctx, "doc1", "user2").Return(false, true, nil).Once()
+
+		_, err := svc.GetDocumentByUUID(ctx, "doc1")
+
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "доступ запрещён")
+		mockGrantRepo.AssertNotCalled(t, "HasAccess", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("Множества нет — решение по БД и загрузка множества", func(t *testing.T) {
+		svc, _, mockCache, mockGrantRepo := newTestGrantCacheService()
+		mockCache.On("GetDocument", ctx, "doc1").Return(sharedDoc, nil).Once()
+		mockCache.On("IsGranted", ctx, "doc1", "user2").Return(false, false, nil).Once()
+		mockCache.On("GrantSetVersion", ctx, "doc1").Return(int64(3), nil).Once()
+		mockGrantRepo.On("HasAccess", ctx, db, "doc1", "user2").Return(false, nil).Once()
+		mockGrantRepo.On("ListGrantUserUUIDs", ctx, db, "doc1").Return([]string{"user3"}, nil).Once()
+		mockCache.On("StoreGrantSet", ctx, "doc1", []string{"user3"}, int64(3)).Return(nil).Once()
+
+		_, err := svc.GetDocumentByUUID(ctx, "doc1")
+
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "доступ запрещён")
+		mockCache.AssertExpectations(t)
+		mockGrantRepo.AssertExpectations(t)
+	})
+
+	t.Run("Redis недоступен — решение по БД", func(t *testing.T) {
+		svc, _, mockCache, mockGrantRepo := newTestGrantCacheService()
+		mockCache.On("GetDocument", ctx, "doc1").Return(sharedDoc, nil).Once()
+		mockCache.On("IsGranted", ctx, "doc1", "user2").Return(false, false, errors.New("redis down")).Once()
+		mockCache.On("GrantSetVersion", ctx, "doc1").Return(int64(0), errors.New("redis down")).Once()
+		mockGrantRepo.On("HasAccess", ctx, db, "doc1", "user2").Return(true, nil).Once()
+
+		_, err := svc.GetDocumentByUUID(ctx, "doc1")
+
+		require.NoError(t, err)
+		mockGrantRepo.AssertNotCalled(t, "ListGrantUserUUIDs", mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("RemoveGrant убирает пользователя из множества", func(t *testing.T) {
+		svc, mockDocRepo, mockCache, mockGrantRepo := newTestGrantCacheService()
+		mockTx := &fakeTx{}
+		mockDocRepo.On("BeginTX", ctx).Return(mockTx, func() error { return nil }, func() error { return nil }, nil).Once()
+		mockGrantRepo.On("CheckOwner", ctx, mockTx, "doc1", "user1").Return(true, nil).Once()
+		mockGrantRepo.On("RemoveGrant", ctx, mockTx, "doc1", "user2").Return(nil).Once()
+		mockCache.On("RemoveFromGrantSet", ctx, "doc1", "user2").Return(nil).Once()
+		mockCache.On("DeleteDocument", ctx, "doc1").Return(nil).Once()
+
+		require.NoError(t, svc.RemoveGrant(ctx, "doc1", "user1", "user2"))
+		mockCache.AssertExpectations(t)
+	})
+
+	t.Run("RemoveGrant не смог изменить множество — множество удаляется", func(t *testing.T) {
+		svc, mockDocRepo, mockCache, mockGrantRepo := newTestGrantCacheService()
+		mockTx := &fakeTx{}
+		mockDocRepo.On("BeginTX", ctx).Return(mockTx, func() error { return nil }, func() error { return nil }, nil).Once()
+		mockGrantRepo.On("CheckOwner", ctx, mockTx, "doc1", "user1").Return(true, nil).Once()
+		mockGrantRepo.On("RemoveGrant", ctx, mockTx, "doc1", "user2").Return(nil).Once()
+		mockCache.On("RemoveFromGrantSet", ctx, "doc1", "user2").Return(errors.New("script timeout")).Once()
+		// без этого user2 сохранял бы доступ по кэшу до истечения TTL множества
+		mockCache.On("DropGrantSet", ctx, "doc1").Return(nil).Once()
+		mockCache.On("DeleteDocument", ctx, "doc1").Return(nil).Once()
+
+		require.NoError(t, svc.RemoveGrant(ctx, "doc1", "user1", "user2"))
+		mockCache.AssertExpectations(t)
+	})
+}
@@ -0,0 +1,64 @@
+package service
+
+import (
+	"caching-web-server/internal/model"
+	"caching-web-server/internal/ports"
+	"context"
+	"log"
+)
+
+// cachedDocumentList : страница списка из кэша, если кэш это умеет и страница там есть
+func (s *DocumentService) cachedDocumentList(ctx context.Context, query model.DocumentListQuery) *model.DocumentListPage {
+	lists, ok := s.cacheRepository.(ports.DocumentListCache)
+	if !ok {
+		return nil
+	}
+
+	page, err := lists.GetDocumentList(ctx, query)
+	if err != nil {
+		log.Printf("[DocumentService] ошибка чтения списка документов из кэша: %v", err)
+		return nil
+	}
+	return page
+}
+
+// storeDocumentList : кэширует страницу с тегами всех документов на ней и их владельцев.
+// Пустая страница по чужому login не кэшируется — к ней не привязать ни один тег, и новый документ
+// этого пользователя не появился бы в списке до истечения TTL
+func (s *DocumentService) storeDocumentList(ctx context.Context, query model.DocumentListQuery, docs []model.Document, page *model.DocumentListPage) {
+	lists, ok := s.cacheRepository.(ports.DocumentListCache)
+	if !ok {
+		return
+	}
+
+	documentUUIDs := make([]string, 0, len(docs))
+	ownerUUIDs := make([]string, 0, 1)
+	seenOwners := make(map[string]struct{})
+	if query.OwnerUUID != "" {
+		ownerUUIDs = append(ownerUUIDs, query.OwnerUUID)
+		seenOwners[query.OwnerUUID] = struct{}{}
+	}
+	for _, doc := range docs {
+		documentUUIDs = append(documentUUIDs, doc.UUID)
+		if _, seen := seenOwners[doc.OwnerUUID]; !seen {
+			seenOwners[doc.OwnerUUID] = struct{}{}
+			ownerUUIDs = append(ownerUUIDs, doc.OwnerUUID)
+		}
+	}
+	if len(ownerUUIDs) == 0 {
+		return
+	}
+
+	if err := lists.StoreDocumentList(ctx, query, page, documentUUIDs, ownerUUIDs); err != nil {
+		log.Printf("[DocumentService] ошибка сохранения списка документов в кэш: %v", err)
+	}
+}
+
+// invalidateOwnerLists : новый документ должен сразу появиться в списках владельца
+func (s *DocumentService) invalidateOwnerLists(ctx context.Context, ownerUUID string) {
+	if lists, ok := s.cacheRepository.(ports.DocumentListCache); ok {
+		if err := lists.InvalidateOwnerLists(ctx, ownerUUID); err != nil {
+			log.Printf("[DocumentService] ошибка инвалидации списков документов: %v", err)
+		}
+	}
+}
@@ -0,0 +1,92 @@
+package service_test
+
+import (
+	"caching-web-server/config"
+	"caching-web-server/internal/model"
+	"caching-web-server/internal/service"
+	"context"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+// MockListCacheRepository : кэш со страницами списков документов
+type MockListCacheRepository struct{ MockCacheRepository }
+
+func (m *MockListCacheRepository) GetDocumentList(ctx context.Context, query model.DocumentListQuery) (*model.DocumentListPage, error) {
+	args := m.Called(ctx, query)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.DocumentListPage), args.Error(1)
+}
+
+func (m *MockListCacheRepository) StoreDocumentList(ctx context.Context, query model.DocumentListQuery, page *model.DocumentListPage, documentUUIDs []string, ownerUUIDs []string) error {
+	return m.Called(ctx, query, page, documentUUIDs, ownerUUIDs).Error(0)
+}
+
+func (m *MockListCacheRepository) InvalidateOwnerLists(ctx context.Context, ownerUUID string) error {
+	return m.Called(ctx, ownerUUID).Error(0)
+}
+
+// newTestListCacheService : сервис документов поверх кэша страниц списков
+func newTestListCacheService() (*service.DocumentService, *MockDocumentRepository, *MockListCacheRepository, *MockGrantRepository, *MockS3Storage) {
+	mockDocRepo := new(MockDocumentRepository)
+	mockCache := new(MockListCacheRepository)
+	mockGrantRepo := new(MockGrantRepository)
+	mockS3 := new(MockS3Storage)
+	svc := service.NewDocumentService(mockDocRepo, mockCache, mockGrantRepo, mockS3, nil, nil, nil, time.Minute)
+	return svc, mockDocRepo, mockCache, mockGrantRepo, mockS3
+}
+
+func TestDocumentListCache(t *testing.T) {
+	ctx := context.WithValue(context.Background(), "db", &config.Database{})
+
+	t.Run("Страница из кэша — без БД", func(t *testing.T) {
+		svc, mockDocRepo, mockCache, _, _ := newTestListCacheService()
+		query := model.DocumentListQuery{OwnerUUID: "user1", FilterKey: "mime", FilterValue: "text/plain", Limit: 1}
+		page := &model.DocumentListPage{Documents: []model.DocumentResponse{{UUID: "doc1"}}, NextCursor: "doc1"}
+		mockCache.On("GetDocumentList", ctx, query).Return(page, nil).Once()
+
+		docs, cursor, err := svc.ListDocuments(ctx, "user1", "", "mime", "text/plain", 1)
+
+		require.NoError(t, err)
+		assert.Equal(t, page.Documents, docs)
+		assert.Equal(t, "doc1", cursor)
+		mockDocRepo.AssertNotCalled(t, "ListDocuments", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("Промах — страница сохраняется с тегами документов и владельцев", func(t *testing.T) {
+		svc, mockDocRepo, mockCache, mockGrantRepo, mockS3 := newTestListCacheService()
+		query := model.DocumentListQuery{Login: "alice", Limit: 10}
+		docs := []model.Document{{UUID: "doc1", OwnerUUID: "alice-uuid", StoragePath: "s3/doc1"}}
+		mockCache.On("GetDocumentList", ctx, query).Return(nil, nil).Once()
+		mockDocRepo.On("ListDocuments", ctx, mock.Anything, "user1", "alice", "", "", 10).Return(docs, nil).Once()
+		mockGrantRepo.On("ListGrants", ctx, mock.Anything, "doc1").Return([]string{"user1"}, nil).Once()
+		mockS3.On("GeneratePresignedGetURL", ctx, "s3/doc1", mock.Anything).Return("url1", nil).Once()
+		mockCache.On("StoreDocumentList", ctx, query, mock.Anything, []string{"doc1"}, []string{"alice-uuid"}).Return(nil).Once()
+
+		res, cursor, err := svc.ListDocuments(ctx, "user1", "alice", "", "", 10)
+
+		require.NoError(t, err)
+		require.Len(t, res, 1)
+		assert.Equal(t, "url1", res[0].PresignedURL)
+		assert.Empty(t, cursor)
+		mockCache.AssertExpectations(t)
+	})
+
+	t.Run("Пустой список по чужому login не кэшируется", func(t *testing.T) {
+		svc, mockDocRepo, mockCache, _, _ := newTestListCacheService()
+		query := model.DocumentListQuery{Login: "bob", Limit: 10}
+		mockCache.On("GetDocumentList", ctx, query).Return(nil, nil).Once()
+		mockDocRepo.On("ListDocuments", ctx, mock.Anything, "user1", "bob", "", "", 10).Return([]model.Document{}, nil).Once()
+
+		res, _, err := svc.ListDocuments(ctx, "user1", "bob", "", "", 10)
+
+		require.NoError(t, err)
+		assert.Empty(t, res)
+		mockCache.AssertNotCalled(t, "StoreDocumentList", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	})
+}
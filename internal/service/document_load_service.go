@@ -0,0 +1,211 @@
+package service
+
+import (
+	"caching-web-server/internal/model"
+	"caching-web-server/internal/ports"
+	"context"
+	"database/sql"
+	"errors"
+	"log"
+	"sync"
+	"time"
+)
+
+// revalidateTimeout : сколько может идти фоновое обновление устаревшей копии
+const revalidateTimeout = 10 * time.Second
+
+var errDocumentAccessDenied = errors.New("[DocumentService] доступ запрещён")
+
+// documentLoadGroup : объединяет одновременные загрузки одного документа внутри процесса,
+// чтобы при истечении ключа популярного документа в БД шёл один запрос, а не каждый
+type documentLoadGroup struct {
+	mu     sync.Mutex
+	calls  map[string]*documentLoadCall
+	onWait func(key string) // вызывается, когда запрос начинает ждать чужую загрузку; задаётся только в тестах
+}
+
+type documentLoadCall struct {
+	done     chan struct{}
+	document *model.Document
+	err      error
+}
+
+func newDocumentLoadGroup() *documentLoadGroup {
+	return &documentLoadGroup{calls: make(map[string]*documentLoadCall)}
+}
+
+// do : выполняет load, если загрузка key ещё не идёт, иначе ждёт результат идущей.
+// shared = true — результат получен от загрузки другого запроса
+func (g *documentLoadGroup) do(ctx context.Context, key string, load func() (*model.Document, error)) (*model.Document, bool, error) {
+	g.mu.Lock()
+	if call, ok := g.calls[key]; ok {
+		g.mu.Unlock()
+		if g.onWait != nil {
+			g.onWait(key)
+		}
+		select {
+		case <-call.done:
+			return call.document, true, call.err
+		case <-ctx.Done():
+			return nil, true, ctx.Err()
+		}
+	}
+
+	call := &documentLoadCall{done: make(chan struct{})}
+	g.calls[key] = call
+	g.mu.Unlock()
+
+	defer func() {
+		g.mu.Lock()
+		delete(g.calls, key)
+		g.mu.Unlock()
+		close(call.done)
+	}()
+
+	call.document, call.err = load()
+	return call.document, false, call.err
+}
+
+// inFlight : идёт ли сейчас загрузка key
+func (g *documentLoadGroup) inFlight(key string) bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	_, ok := g.calls[key]
+	return ok
+}
+
+// loadDocument : загружает документ при промахе кэша с объединением одновременных запросов.
+// authorized = false — документ получен не от имени пользователя, доступ нужно проверить отдельно
+func (s *DocumentService) loadDocument(ctx context.Context, documentUUID string, userUUID string) (*model.Document, bool, error) {
+	var authorized bool
+	document, shared, err := s.documentLoads.do(ctx, documentUUID, func() (*model.Document, error) {
+		var document *model.Document
+		var err error
+		document, authorized, err = s.loadDocumentAcrossReplicas(ctx, documentUUID, userUUID)
+		return document, err
+	})
+	if shared == false {
+		return document, authorized, err
+	}
+	if ctx.Err() != nil {
+		return nil, false, ctx.Err()
+	}
+
+	// чужая загрузка могла не удаться из-за прав того пользователя — тогда грузим сами
+	if err != nil || document == nil {
+		return s.loadDocumentAcrossReplicas(ctx, documentUUID, userUUID)
+	}
+	// копия, чтобы запросы, ожидавшие чужую загрузку, не делили один указатель
+	return document.Clone(), false, nil
+}
+
+// loadDocumentAcrossReplicas : если кэш поддерживает блокировку загрузки, в БД идёт только реплика,
+// захватившая её, остальные ждут появления документа в кэше
+func (s *DocumentService) loadDocumentAcrossReplicas(ctx context.Context, documentUUID string, userUUID string) (*model.Document, bool, error) {
+	locker, ok := s.cacheRepository.(ports.CacheLoadLocker)
+	if ok == false {
+		document, err := s.loadDocumentFromDB(ctx, documentUUID, userUUID)
+		return document, true, err
+	}
+
+	release, acquired, err := locker.AcquireLoadLock(ctx, documentUUID)
+	if err != nil {
+		log.Printf("[DocumentService] блокировка загрузки недоступна, документ читается из БД: %v", err)
+	} else if acquired {
+		defer release()
+	} else {
+		document, err := locker.WaitForDocument(ctx, documentUUID)
+		if err != nil {
+			log.Printf("[DocumentService] ошибка ожидания документа в кэше: %v", err)
+		}
+		if document != nil {
+			return document, false, nil
+		}
+	}
+
+	document, err := s.loadDocumentFromDB(ctx, documentUUID, userUUID)
+	return document, true, err
+}
+
+// revalidateDocument : обновляет устаревшую копию в кэше в фоне, не задерживая ответ.
+// Если документ уже загружается, повторная загрузка не запускается
+func (s *DocumentService) revalidateDocument(ctx context.Context, documentUUID string, userUUID string) {
+	if s.documentLoads.inFlight(documentUUID) {
+		return
+	}
+
+	refreshCtx := context.WithoutCancel(ctx)
+	go func() {
+		refreshCtx, cancel := context.WithTimeout(refreshCtx, revalidateTimeout)
+		defer cancel()
+
+		_, _, err := s.documentLoads.do(refreshCtx, documentUUID, func() (*model.Document, error) {
+			document, _, err := s.loadDocumentAcrossReplicas(refreshCtx, documentUUID, userUUID)
+			return document, err
+		})
+		if err != nil {
+			log.Printf("[DocumentService] не удалось обновить устаревшую копию документа %s: %v", documentUUID, err)
+		}
+	}()
+}
+
+// isDocumentMissing : есть ли отрицательная запись «не найден» для пользователя. Ошибка кэша — не повод отказывать
+func (s *DocumentService) isDocumentMissing(ctx context.Context, documentUUID string, userUUID string) bool {
+	negative, ok := s.cacheRepository.(ports.NegativeDocumentCache)
+	if ok == false {
+		return false
+	}
+
+	missing, err := negative.IsDocumentMissing(ctx, documentUUID, userUUID)
+	if err != nil {
+		log.Printf("[DocumentService] ошибка чтения отрицательного кэша: %v", err)
+		return false
+	}
+	return missing
+}
+
+func (s *DocumentService) rememberDocumentMissing(ctx context.Context, documentUUID string, userUUID string) {
+	if negative, ok := s.cacheRepository.(ports.NegativeDocumentCache); ok {
+		if err := negative.SetDocumentMissing(ctx, documentUUID, userUUID); err != nil {
+			log.Printf("[DocumentService] ошибка записи в отрицательный кэш: %v", err)
+		}
+	}
+}
+
+func (s *DocumentService) isPublicTokenMissing(ctx context.Context, token string) bool {
+	negative, ok := s.cacheRepository.(ports.NegativeDocumentCache)
+	if ok == false {
+		return false
+	}
+
+	missing, err := negative.IsPublicTokenMissing(ctx, token)
+	if err != nil {
+		log.Printf("[DocumentService] ошибка чтения отрицательного кэша: %v", err)
+		return false
+	}
+	return missing
+}
+
+func (s *DocumentService) rememberPublicTokenMissing(ctx context.Context, token string) {
+	if negative, ok := s.cacheRepository.(ports.NegativeDocumentCache); ok {
+		if err := negative.SetPublicTokenMissing(ctx, token); err != nil {
+			log.Printf("[DocumentService] ошибка записи в отрицательный кэш: %v", err)
+		}
+	}
+}
+
+// forgetMissingDocument : только что созданный документ не должен оставаться «не найденным» до истечения TTL
+func (s *DocumentService) forgetMissingDocument(ctx context.Context, document *model.Document) {
+	if negative, ok := s.cacheRepository.(ports.NegativeDocumentCache); ok {
+		if err := negative.ForgetMissing(ctx, document.UUID, document.AccessToken); err != nil {
+			log.Printf("[DocumentService] ошибка очистки отрицательного кэша: %v", err)
+		}
+	}
+}
+
+// isSourceUnavailable : ошибка загрузки означает недоступность БД, а не отсутствие документа или прав
+func isSourceUnavailable(err error) bool {
+	return errors.Is(err, sql.ErrNoRows) == false &&
+		errors.Is(err, errDocumentAccessDenied) == false &&
+		errors.Is(err, context.Canceled) == false
+}
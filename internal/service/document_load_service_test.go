@@ -0,0 +1,249 @@
+package service_test
+
+import (
+	"caching-web-server/config"
+	"caching-web-server/internal/model"
+	"caching-web-server/internal/security"
+	"caching-web-server/internal/service"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestGetDocumentByUUID_CoalescesConcurrentMisses(t *testing.T) {
+	svc, mockDocRepo, mockStorage, mockCache, mockGrantRepo := newTestDocumentServiceWithGrants()
+
+	ctx := context.WithValue(context.Background(), security.UserContextKey, &security.Claims{UserUUID: "user1"})
+	ctx = context.WithValue(ctx, "db", &config.Database{})
+
+	expiresAt := time.Now().Add(time.Hour)
+	doc := &model.Document{UUID: "doc1", OwnerUUID: "user1", FilenameOriginal: "file.txt", StoragePath: "docs/doc1.txt", ExpiresAt: &expiresAt}
+	mockTx := &fakeTx{}
+
+	mockCache.On("GetDocument", ctx, "doc1").Return(nil, nil)
+	mockCache.On("SetDocument", ctx, doc).Return(nil).Once()
+	mockDocRepo.On("BeginTX", ctx).Return(mockTx, func() error { return nil }, func() error { return nil }, nil).Once()
+	mockGrantRepo.On("ListGrants", ctx, mockTx, "doc1").Return([]string{"user2"}, nil).Once()
+	mockStorage.On("GeneratePresignedGetURL", ctx, doc.StoragePath, time.Minute).Return("http://get-url", nil)
+
+	const requests = 5
+	// загрузка не завершается, пока остальные запросы не встанут в ожидание её результата
+	waiting := make(chan struct{}, requests)
+	release := make(chan struct{})
+	svc.OnDocumentLoadWait(func(string) { waiting <- struct{}{} })
+	mockDocRepo.On("GetByUUID", ctx, mockTx, "doc1", "user1").Return(doc, []string{}, nil).Run(func(mock.Arguments) { <-release }).Once()
+	go func() {
+		for i := 0; i < requests-1; i++ {
+			<-waiting
+		}
+		close(release)
+	}()
+
+	var wg sync.WaitGroup
+	results := make([]*model.Document, requests)
+	errs := make([]error, requests)
+	for i := 0; i < requests; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			res, err := svc.GetDocumentByUUID(ctx, "doc1")
+			errs[i] = err
+			if res != nil {
+				results[i] = res.Document
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	for i := 0; i < requests; i++ {
+		assert.NoError(t, errs[i])
+		assert.Equal(t, "doc1", results[i].UUID)
+		assert.Equal(t, []string{"user2"}, results[i].GrantLogins)
+		// ожидавшие запросы получают глубокие копии, а не общий документ
+		for j := 0; j < i; j++ {
+			assert.NotSame(t, results[j], results[i])
+			assert.NotSame(t, results[j].ExpiresAt, results[i].ExpiresAt)
+		}
+	}
+
+	mockDocRepo.AssertNumberOfCalls(t, "BeginTX", 1)
+	mockDocRepo.AssertNumberOfCalls(t, "GetByUUID", 1)
+	mockGrantRepo.AssertNotCalled(t, "HasAccess", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+// MockStaleCacheRepository : кэш, хранящий устаревшие копии документов
+type MockStaleCacheRepository struct{ MockCacheRepository }
+
+func (m *MockStaleCacheRepository) GetDocumentEntry(ctx context.Context, uuid string) (*model.CachedDocument, error) {
+	args := m.Called(ctx, uuid)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.CachedDocument), args.Error(1)
+}
+
+func TestGetDocumentByUUID_StaleIfError(t *testing.T) {
+	ctx := context.WithValue(context.Background(), security.UserContextKey, &security.Claims{UserUUID: "user1"})
+	ctx = context.WithValue(ctx, "db", &config.Database{})
+
+	doc := &model.Document{UUID: "doc1", OwnerUUID: "user1", FilenameOriginal: "file.txt", StoragePath: "docs/doc1.txt"}
+	now := time.Now()
+	// мягкий TTL и окно фонового обновления прошли, но копия ещё годится при недоступной БД
+	staleEntry := &model.CachedDocument{
+		Document:   doc,
+		StoredAt:   now.Add(-30 * time.Minute),
+		FreshUntil: now.Add(-15 * time.Minute),
+		StaleUntil: now.Add(-14 * time.Minute),
+		ErrorUntil: now.Add(30 * time.Minute),
+	}
+
+	tests := []struct {
+		name      string
+		loadErr   error
+		wantErr   string
+		wantStale bool
+	}{
+		{name: "БД недоступна — отдаём устаревшую копию", loadErr: errors.New("connection refused"), wantStale: true},
+		{name: "Документ удалён — ошибка, а не копия", loadErr: sql.ErrNoRows, wantErr: "документ не найден"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockDocRepo := new(MockDocumentRepository)
+			mockStorage := new(MockS3Storage)
+			mockCache := new(MockStaleCacheRepository)
+			mockGrantRepo := new(MockGrantRepository)
+			svc := service.NewDocumentService(mockDocRepo, mockCache, mockGrantRepo, mockStorage, nil, nil, nil, time.Minute)
+
+			mockTx := &fakeTx{}
+			mockCache.On("GetDocumentEntry", ctx, "doc1").Return(staleEntry, nil).Once()
+			mockDocRepo.On("BeginTX", ctx).Return(mockTx, func() error { return nil }, func() error { return nil }, nil).Once()
+			mockDocRepo.On("GetByUUID", ctx, mockTx, "doc1", "user1").Return(nil, fmt.Errorf("[DocumentRepo] не удалось получить документ по UUID: %w", tt.loadErr)).Once()
+			mockStorage.On("GeneratePresignedGetURL", ctx, doc.StoragePath, time.Minute).Return("http://get-url", nil).Maybe()
+
+			res, err := svc.GetDocumentByUUID(ctx, "doc1")
+
+			if tt.wantErr != "" {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.True(t, res.Stale)
+			assert.Equal(t, doc, res.Document)
+			assert.InDelta(t, (30 * time.Minute).Seconds(), res.Age.Seconds(), 5)
+			mockCache.AssertNotCalled(t, "GetDocument", mock.Anything, mock.Anything)
+		})
+	}
+}
+
+// MockNegativeCacheRepository : кэш с отрицательными записями
+type MockNegativeCacheRepository struct{ MockCacheRepository }
+
+func (m *MockNegativeCacheRepository) IsDocumentMissing(ctx context.Context, uuid string, userUUID string) (bool, error) {
+	args := m.Called(ctx, uuid, userUUID)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *MockNegativeCacheRepository) SetDocumentMissing(ctx context.Context, uuid string, userUUID string) error {
+	return m.Called(ctx, uuid, userUUID).Error(0)
+}
+
+func (m *MockNegativeCacheRepository) IsPublicTokenMissing(ctx context.Context, token string) (bool, error) {
+	args := m.Called(ctx, token)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *MockNegativeCacheRepository) SetPublicTokenMissing(ctx context.Context, token string) error {
+	return m.Called(ctx, token).Error(0)
+}
+
+func (m *MockNegativeCacheRepository) ForgetMissing(ctx context.Context, uuid string, token string) error {
+	return m.Called(ctx, uuid, token).Error(0)
+}
+
+// newTestNegativeCacheService : сервис документов поверх кэша с отрицательными записями
+func newTestNegativeCacheService() (*service.DocumentService, *MockDocumentRepository, *MockS3Storage, *MockNegativeCacheRepository) {
+	mockDocRepo := new(MockDocumentRepository)
+	mockStorage := new(MockS3Storage)
+	mockCache := new(MockNegativeCacheRepository)
+	svc := service.NewDocumentService(mockDocRepo, mockCache, new(MockGrantRepository), mockStorage, nil, nil, nil, time.Minute)
+	return svc, mockDocRepo, mockStorage, mockCache
+}
+
+func TestNegativeCache(t *testing.T) {
+	ctx := context.WithValue(context.Background(), security.UserContextKey, &security.Claims{UserUUID: "user1"})
+	ctx = context.WithValue(ctx, "db", &config.Database{})
+
+	t.Run("Отрицательная запись — БД не трогаем", func(t *testing.T) {
+		svc, mockDocRepo, _, mockCache := newTestNegativeCacheService()
+		mockCache.On("GetDocument", ctx, "missing").Return(nil, nil).Once()
+		mockCache.On("IsDocumentMissing", ctx, "missing", "user1").Return(true, nil).Once()
+
+		_, err := svc.GetDocumentByUUID(ctx, "missing")
+
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "документ не найден")
+		mockDocRepo.AssertNotCalled(t, "BeginTX", mock.Anything)
+	})
+
+	t.Run("Документ не найден в БД — запоминаем", func(t *testing.T) {
+		svc, mockDocRepo, _, mockCache := newTestNegativeCacheService()
+		mockTx := &fakeTx{}
+		mockCache.On("GetDocument", ctx, "missing").Return(nil, nil).Once()
+		mockCache.On("IsDocumentMissing", ctx, "missing", "user1").Return(false, nil).Once()
+		mockDocRepo.On("BeginTX", ctx).Return(mockTx, func() error { return nil }, func() error { return nil }, nil).Once()
+		mockDocRepo.On("GetByUUID", ctx, mockTx, "missing", "user1").Return(nil, fmt.Errorf("[DocumentRepo] не удалось получить документ по UUID: %w", sql.ErrNoRows)).Once()
+		mockCache.On("SetDocumentMissing", ctx, "missing", "user1").Return(nil).Once()
+
+		_, err := svc.GetDocumentByUUID(ctx, "missing")
+
+		require.Error(t, err)
+		mockCache.AssertExpectations(t)
+	})
+
+	t.Run("Неверный публичный токен из кэша", func(t *testing.T) {
+		svc, mockDocRepo, _, mockCache := newTestNegativeCacheService()
+		mockCache.On("IsPublicTokenMissing", ctx, "bad-token").Return(true, nil).Once()
+
+		_, err := svc.GetPublicDocument(ctx, "", "bad-token")
+
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "публичный документ не найден")
+		mockDocRepo.AssertNotCalled(t, "BeginTX", mock.Anything)
+	})
+
+	t.Run("Неверный публичный токен — запоминаем", func(t *testing.T) {
+		svc, mockDocRepo, _, mockCache := newTestNegativeCacheService()
+		mockTx := &fakeTx{}
+		mockCache.On("IsPublicTokenMissing", ctx, "bad-token").Return(false, nil).Once()
+		mockDocRepo.On("BeginTX", ctx).Return(mockTx, func() error { return nil }, func() error { return nil }, nil).Once()
+		mockDocRepo.On("GetPublicByToken", ctx, mockTx, "bad-token").Return(nil, fmt.Errorf("[DocumentRepo] не удалось получить публичный документ по токену: %w", sql.ErrNoRows)).Once()
+		mockCache.On("SetPublicTokenMissing", ctx, "bad-token").Return(nil).Once()
+
+		_, err := svc.GetPublicDocument(ctx, "", "bad-token")
+
+		require.Error(t, err)
+		mockCache.AssertExpectations(t)
+	})
+
+	t.Run("Создание документа снимает отрицательные записи", func(t *testing.T) {
+		svc, mockDocRepo, mockStorage, mockCache := newTestNegativeCacheService()
+		doc := &model.Document{UUID: "doc1", FilenameOriginal: "file.txt", StoragePath: "docs/doc1.txt", AccessToken: "token1"}
+		mockStorage.On("GeneratePresignedPutURL", ctx, doc.StoragePath, time.Minute).Return("http://put-url", nil).Once()
+		mockDocRepo.On("Create", ctx, mock.Anything, doc).Return(nil).Once()
+		mockCache.On("ForgetMissing", ctx, "doc1", "token1").Return(nil).Once()
+
+		_, err := svc.CreateDocument(ctx, doc)
+
+		require.NoError(t, err)
+		mockCache.AssertExpectations(t)
+	})
+}
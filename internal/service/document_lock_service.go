@@ -0,0 +1,178 @@
+package service
+
+import (
+	"caching-web-server/config"
+	"caching-web-server/internal/model"
+	"caching-web-server/internal/security"
+	"caching-web-server/internal/util"
+	"context"
+	"fmt"
+	"log"
+	"time"
+)
+
+const (
+	// DefaultLockTTL : срок блокировки документа, если клиент его не указал
+	DefaultLockTTL = 15 * time.Minute
+	// MaxLockTTL : максимальный срок одной блокировки, дальше её нужно продлевать
+	MaxLockTTL = 8 * time.Hour
+)
+
+// LockDocument : блокирует документ на время редактирования текущим пользователем.
+// Заблокировать можно свой документ или документ, выданный через grant. Повторный вызов держателем продлевает блокировку
+func (s *DocumentService) LockDocument(ctx context.Context, documentUUID, reason string, ttl time.Duration) (*model.DocumentLock, error) {
+	if s.lockRepository == nil {
+		return nil, fmt.Errorf("[DocumentService] блокировки документов не настроены")
+	}
+
+	claims, err := security.GetClaimsFromContext(ctx)
+	if err != nil || claims == nil {
+		return nil, fmt.Errorf("[DocumentService] пользователь не авторизован")
+	}
+
+	if err := s.ensureCanEdit(ctx, documentUUID, claims.UserUUID); err != nil {
+		return nil, err
+	}
+
+	if ttl <= 0 {
+		ttl = DefaultLockTTL
+	}
+	if ttl > MaxLockTTL {
+		ttl = MaxLockTTL
+	}
+
+	now := time.Now()
+	lock := &model.DocumentLock{
+		DocumentUUID: documentUUID,
+		HolderUUID:   claims.UserUUID,
+		Reason:       reason,
+		LockedAt:     now,
+		ExpiresAt:    now.Add(ttl),
+	}
+
+	current, acquired, err := s.lockRepository.Acquire(ctx, lock)
+	if err != nil {
+		return nil, util.LogError("[DocumentService] не удалось заблокировать документ", err)
+	}
+	if acquired == false {
+		return current, fmt.Errorf("[DocumentService] документ заблокирован другим пользователем до %s", current.ExpiresAt.Format(time.RFC3339))
+	}
+
+	log.Printf("[DocumentService] документ %s заблокирован пользователем %s до %s", documentUUID, claims.UserUUID, lock.ExpiresAt.Format(time.RFC3339))
+	return lock, nil
+}
+
+// UnlockDocument : снимает блокировку, поставленную текущим пользователем
+func (s *DocumentService) UnlockDocument(ctx context.Context, documentUUID string) error {
+	if s.lockRepository == nil {
+		return fmt.Errorf("[DocumentService] блокировки документов не настроены")
+	}
+
+	claims, err := security.GetClaimsFromContext(ctx)
+	if err != nil || claims == nil {
+		return fmt.Errorf("[DocumentService] пользователь не авторизован")
+	}
+
+	released, err := s.lockRepository.Release(ctx, documentUUID, claims.UserUUID)
+	if err != nil {
+		return util.LogError("[DocumentService] не удалось снять блокировку", err)
+	}
+	if released == false {
+		return fmt.Errorf("[DocumentService] блокировка не найдена или принадлежит другому пользователю")
+	}
+
+	return nil
+}
+
+// ForceUnlockDocument : владелец документа или пользователь с правом locks:manage снимает чужую блокировку
+func (s *DocumentService) ForceUnlockDocument(ctx context.Context, documentUUID string) error {
+	if s.lockRepository == nil {
+		return fmt.Errorf("[DocumentService] блокировки документов не настроены")
+	}
+
+	claims, err := security.GetClaimsFromContext(ctx)
+	if err != nil || claims == nil {
+		return fmt.Errorf("[DocumentService] пользователь не авторизован")
+	}
+
+	db, ok := ctx.Value("db").(*config.Database)
+	if !ok {
+		return fmt.Errorf("[DocumentService] database connection не найден в context")
+	}
+
+	if claims.Can(model.PermissionLocksManage) == false {
+		isOwner, err := s.grantRepository.CheckOwner(ctx, db, documentUUID, claims.UserUUID)
+		if err != nil {
+			return util.LogError("[DocumentService] ошибка проверки владельца", err)
+		}
+		if isOwner == false {
+			return fmt.Errorf("[DocumentService] доступ запрещён: снять чужую блокировку может только владелец документа")
+		}
+	}
+
+	if err := s.lockRepository.ForceRelease(ctx, documentUUID); err != nil {
+		return util.LogError("[DocumentService] не удалось снять блокировку", err)
+	}
+
+	log.Printf("[DocumentService] блокировка документа %s принудительно снята пользователем %s", documentUUID, claims.UserUUID)
+	return nil
+}
+
+// GetDocumentLock : текущая блокировка документа (nil, если документ не заблокирован)
+func (s *DocumentService) GetDocumentLock(ctx context.Context, documentUUID string) (*model.DocumentLock, error) {
+	if s.lockRepository == nil {
+		return nil, nil
+	}
+
+	if _, err := s.getAuthorizedDocument(ctx, documentUUID); err != nil {
+		return nil, err
+	}
+
+	lock, err := s.lockRepository.Get(ctx, documentUUID)
+	if err != nil {
+		return nil, util.LogError("[DocumentService] не удалось получить блокировку", err)
+	}
+	return lock, nil
+}
+
+// EnsureDocumentUnlocked : возвращает ошибку, если документ заблокирован кем-то кроме userUUID.
+// Вызывается перед любым изменением документа или его метаданных
+func (s *DocumentService) EnsureDocumentUnlocked(ctx context.Context, documentUUID, userUUID string) error {
+	if s.lockRepository == nil {
+		return nil
+	}
+
+	lock, err := s.lockRepository.Get(ctx, documentUUID)
+	if err != nil {
+		return util.LogError("[DocumentService] не удалось проверить блокировку документа", err)
+	}
+	if lock != nil && lock.HolderUUID != userUUID {
+		return fmt.Errorf("[DocumentService] документ заблокирован другим пользователем до %s", lock.ExpiresAt.Format(time.RFC3339))
+	}
+	return nil
+}
+
+// ensureCanEdit : редактировать (и блокировать) документ может владелец или пользователь с grant,
+// публичности документа для этого недостаточно
+func (s *DocumentService) ensureCanEdit(ctx context.Context, documentUUID, userUUID string) error {
+	document, err := s.getAuthorizedDocument(ctx, documentUUID)
+	if err != nil {
+		return err
+	}
+	if document.OwnerUUID == userUUID || document.IsPublic == false {
+		return nil
+	}
+
+	db, ok := ctx.Value("db").(*config.Database)
+	if !ok {
+		return fmt.Errorf("[DocumentService] database connection не найден в context")
+	}
+	hasAccess, err := s.grantRepository.HasAccess(ctx, db, documentUUID, userUUID)
+	if err != nil {
+		return util.LogError("[DocumentService] ошибка проверки доступа", err)
+	}
+	if hasAccess == false {
+		return fmt.Errorf("[DocumentService] доступ запрещён")
+	}
+	return nil
+}
@@ -0,0 +1,160 @@
+package service_test
+
+import (
+	"caching-web-server/config"
+	"caching-web-server/internal/model"
+	"caching-web-server/internal/security"
+	"caching-web-server/internal/service"
+	"context"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+type MockDocumentLockRepository struct{ mock.Mock }
+
+func (m *MockDocumentLockRepository) Acquire(ctx context.Context, lock *model.DocumentLock) (*model.DocumentLock, bool, error) {
+	args := m.Called(ctx, lock)
+	current, _ := args.Get(0).(*model.DocumentLock)
+	return current, args.Bool(1), args.Error(2)
+}
+
+func (m *MockDocumentLockRepository) Get(ctx context.Context, documentUUID string) (*model.DocumentLock, error) {
+	args := m.Called(ctx, documentUUID)
+	lock, _ := args.Get(0).(*model.DocumentLock)
+	return lock, args.Error(1)
+}
+
+func (m *MockDocumentLockRepository) Release(ctx context.Context, documentUUID, holderUUID string) (bool, error) {
+	args := m.Called(ctx, documentUUID, holderUUID)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *MockDocumentLockRepository) ForceRelease(ctx context.Context, documentUUID string) error {
+	return m.Called(ctx, documentUUID).Error(0)
+}
+
+func newTestDocumentServiceWithLocks() (*service.DocumentService, *MockDocumentRepository, *MockCacheRepository, *MockGrantRepository, *MockDocumentLockRepository) {
+	mockDocRepo := new(MockDocumentRepository)
+	mockCache := new(MockCacheRepository)
+	mockGrantRepo := new(MockGrantRepository)
+	mockLockRepo := new(MockDocumentLockRepository)
+
+	svc := service.NewDocumentService(mockDocRepo, mockCache, mockGrantRepo, new(MockS3Storage), nil, nil, mockLockRepo, time.Minute)
+	return svc, mockDocRepo, mockCache, mockGrantRepo, mockLockRepo
+}
+
+func lockTestContext(userUUID string) context.Context {
+	ctx := context.WithValue(context.Background(), security.UserContextKey, &security.Claims{UserUUID: userUUID})
+	return context.WithValue(ctx, "db", &config.Database{})
+}
+
+func TestLockDocument(t *testing.T) {
+	doc := &model.Document{UUID: "doc1", OwnerUUID: "owner"}
+
+	t.Run("владелец блокирует документ", func(t *testing.T) {
+		svc, _, mockCache, _, mockLockRepo := newTestDocumentServiceWithLocks()
+		ctx := lockTestContext("owner")
+
+		mockCache.On("GetDocument", ctx, "doc1").Return(doc, nil).Once()
+		mockLockRepo.On("Acquire", ctx, mock.MatchedBy(func(lock *model.DocumentLock) bool {
+			return lock.HolderUUID == "owner" && lock.Reason == "правки" &&
+				lock.ExpiresAt.Sub(lock.LockedAt) == service.DefaultLockTTL
+		})).Return(nil, true, nil).Once()
+
+		lock, err := svc.LockDocument(ctx, "doc1", "правки", 0)
+		require.NoError(t, err)
+		assert.Equal(t, "owner", lock.HolderUUID)
+		mockLockRepo.AssertExpectations(t)
+	})
+
+	t.Run("документ уже заблокирован другим", func(t *testing.T) {
+		svc, _, mockCache, mockGrantRepo, mockLockRepo := newTestDocumentServiceWithLocks()
+		ctx := lockTestContext("editor")
+		current := &model.DocumentLock{DocumentUUID: "doc1", HolderUUID: "owner", ExpiresAt: time.Now().Add(time.Minute)}
+
+		mockCache.On("GetDocument", ctx, "doc1").Return(doc, nil).Once()
+		mockGrantRepo.On("HasAccess", ctx, mock.Anything, "doc1", "editor").Return(true, nil).Once()
+		mockLockRepo.On("Acquire", ctx, mock.Anything).Return(current, false, nil).Once()
+
+		lock, err := svc.LockDocument(ctx, "doc1", "", 24*time.Hour)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "документ заблокирован")
+		assert.Equal(t, current, lock)
+	})
+}
+
+func TestMutationsRespectLock(t *testing.T) {
+	otherLock := &model.DocumentLock{DocumentUUID: "doc1", HolderUUID: "editor", ExpiresAt: time.Now().Add(time.Minute)}
+
+	t.Run("DeleteDocument отклоняется", func(t *testing.T) {
+		svc, mockDocRepo, _, _, mockLockRepo := newTestDocumentServiceWithLocks()
+		ctx := context.Background()
+
+		mockLockRepo.On("Get", ctx, "doc1").Return(otherLock, nil).Once()
+
+		_, err := svc.DeleteDocument(ctx, "doc1", "owner")
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "документ заблокирован")
+		mockDocRepo.AssertNotCalled(t, "BeginTX", mock.Anything)
+	})
+
+	t.Run("AddGrant отклоняется", func(t *testing.T) {
+		svc, mockDocRepo, _, _, mockLockRepo := newTestDocumentServiceWithLocks()
+		ctx := context.Background()
+
+		mockLockRepo.On("Get", ctx, "doc1").Return(otherLock, nil).Once()
+
+		err := svc.AddGrant(ctx, "doc1", "owner", "user2")
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "документ заблокирован")
+		mockDocRepo.AssertNotCalled(t, "BeginTX", mock.Anything)
+	})
+
+	t.Run("держатель блокировки может менять документ", func(t *testing.T) {
+		svc, _, _, _, mockLockRepo := newTestDocumentServiceWithLocks()
+		ctx := context.Background()
+
+		mockLockRepo.On("Get", ctx, "doc1").Return(otherLock, nil).Once()
+
+		assert.NoError(t, svc.EnsureDocumentUnlocked(ctx, "doc1", "editor"))
+	})
+}
+
+func TestUnlockDocument(t *testing.T) {
+	t.Run("чужую блокировку снять нельзя", func(t *testing.T) {
+		svc, _, _, _, mockLockRepo := newTestDocumentServiceWithLocks()
+		ctx := lockTestContext("user2")
+
+		mockLockRepo.On("Release", ctx, "doc1", "user2").Return(false, nil).Once()
+
+		err := svc.UnlockDocument(ctx, "doc1")
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "блокировка не найдена")
+	})
+
+	t.Run("принудительно снимает только владелец", func(t *testing.T) {
+		svc, _, _, mockGrantRepo, mockLockRepo := newTestDocumentServiceWithLocks()
+		ctx := lockTestContext("user2")
+
+		mockGrantRepo.On("CheckOwner", ctx, mock.Anything, "doc1", "user2").Return(false, nil).Once()
+
+		err := svc.ForceUnlockDocument(ctx, "doc1")
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "доступ запрещён")
+		mockLockRepo.AssertNotCalled(t, "ForceRelease", mock.Anything, mock.Anything)
+	})
+
+	t.Run("владелец снимает чужую блокировку", func(t *testing.T) {
+		svc, _, _, mockGrantRepo, mockLockRepo := newTestDocumentServiceWithLocks()
+		ctx := lockTestContext("owner")
+
+		mockGrantRepo.On("CheckOwner", ctx, mock.Anything, "doc1", "owner").Return(true, nil).Once()
+		mockLockRepo.On("ForceRelease", ctx, "doc1").Return(nil).Once()
+
+		assert.NoError(t, svc.ForceUnlockDocument(ctx, "doc1"))
+		mockLockRepo.AssertExpectations(t)
+	})
+}
@@ -0,0 +1,10 @@
+package service
+
+import "caching-web-server/internal/ports"
+
+// recordAccess : учитывает чтение документа для прогрева кэша самыми популярными документами
+func (s *DocumentService) recordAccess(documentUUID string) {
+	if popularity, ok := s.cacheRepository.(ports.DocumentPopularity); ok {
+		popularity.RecordAccess(documentUUID)
+	}
+}
@@ -7,9 +7,11 @@ import (
 	"caching-web-server/internal/security"
 	"caching-web-server/internal/util"
 	"context"
+	"database/sql"
 	"errors"
 	"fmt"
 	_ "github.com/aws/aws-sdk-go-v2/config"
+	"github.com/google/uuid"
 	"log"
 	"time"
 )
@@ -20,6 +22,9 @@ type DocumentService struct {
 	grantRepository    ports.GrantDocumentRepository
 	storageInterface   ports.S3Storage
 	userRepository     ports.UserRepository
+	quotaService       ports.QuotaService
+	lockRepository     ports.DocumentLockRepository
+	documentLoads      *documentLoadGroup
 	ttl                time.Duration
 }
 
@@ -29,6 +34,8 @@ func NewDocumentService(
 	shareRepository ports.GrantDocumentRepository,
 	storageInterface ports.S3Storage,
 	userRepository ports.UserRepository,
+	quotaService ports.QuotaService,
+	lockRepository ports.DocumentLockRepository,
 	ttl time.Duration,
 ) *DocumentService {
 	return &DocumentService{
@@ -37,6 +44,9 @@ func NewDocumentService(
 		grantRepository:    shareRepository,
 		storageInterface:   storageInterface,
 		userRepository:     userRepository,
+		quotaService:       quotaService,
+		lockRepository:     lockRepository,
+		documentLoads:      newDocumentLoadGroup(),
 		ttl:                ttl,
 	}
 }
@@ -53,20 +63,88 @@ func (s *DocumentService) CreateDocument(ctx context.Context, document *model.Do
 		return "", util.LogError("[DocumentService] не удалось URL", err)
 	}
 
-	if err := s.documentRepository.Create(ctx, db, document); err != nil {
-		return "", util.LogError("[DocumentService] не удалось сохранить документ в БД", err)
+	if err := s.insertDocument(ctx, db, document); err != nil {
+		return "", err
 	}
+	s.forgetMissingDocument(ctx, document)
+	s.cacheCreatedDocument(ctx, document)
+	s.invalidateOwnerLists(ctx, document.OwnerUUID)
 
 	log.Printf("[DocumentService] документ %s успешно создан", document.FilenameOriginal)
 
 	return putURL, nil
 }
 
-// GetDocumentByUUID : возвращает документ для авторизованного пользователя (владелец или по grants)
+// insertDocument : сохраняет документ в БД. Если квоты включены, проверка квоты и вставка
+// выполняются в одной транзакции, чтобы параллельные загрузки не превысили лимит
+func (s *DocumentService) insertDocument(ctx context.Context, db *config.Database, document *model.Document) error {
+	if s.quotaService == nil {
+		if err := s.documentRepository.Create(ctx, db, document); err != nil {
+			return util.LogError("[DocumentService] не удалось сохранить документ в БД", err)
+		}
+		return nil
+	}
+
+	exec, rollback, commit, err := s.documentRepository.BeginTX(ctx)
+	if err != nil {
+		return util.LogError("[DocumentService] не удалось начать транзакцию", err)
+	}
+	defer rollback()
+
+	if err := s.quotaService.CheckQuota(ctx, exec, document.OwnerUUID, document.SizeBytes); err != nil {
+		return err
+	}
+
+	if err := s.documentRepository.Create(ctx, exec, document); err != nil {
+		return util.LogError("[DocumentService] не удалось сохранить документ в БД", err)
+	}
+
+	if err := commit(); err != nil {
+		return util.LogError("[DocumentService] не удалось сохранить документ в БД: ошибка коммита транзакции", err)
+	}
+
+	return nil
+}
+
+// GetDocumentByUUID : возвращает документ для авторизованного пользователя (владелец или по grants).
+// Если кэш хранит устаревшие копии, они отдаются с обновлением в фоне или при недоступности БД — результат помечается Stale
 func (s *DocumentService) GetDocumentByUUID(ctx context.Context, documentUUID string) (*model.GetDocumentResult, error) {
-	var document *model.Document
-	var err error
+	lookup, err := s.lookupAuthorizedDocument(ctx, documentUUID, true)
+	if err != nil {
+		return nil, err
+	}
+	document := lookup.document
+	s.recordAccess(document.UUID)
+
+	result := &model.GetDocumentResult{
+		Document: document,
+		Stale:    lookup.stale,
+		Age:      lookup.age,
+	}
+	if err := s.attachGetURL(ctx, result); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// documentLookup : найденный документ и признак того, что он отдан из устаревшей копии кэша
+type documentLookup struct {
+	document *model.Document
+	stale    bool
+	age      time.Duration
+}
 
+// getAuthorizedDocument : достаёт документ из кэша или БД и проверяет, что текущий пользователь имеет к нему доступ
+func (s *DocumentService) getAuthorizedDocument(ctx context.Context, documentUUID string) (*model.Document, error) {
+	lookup, err := s.lookupAuthorizedDocument(ctx, documentUUID, false)
+	if err != nil {
+		return nil, err
+	}
+	return lookup.document, nil
+}
+
+// lookupAuthorizedDocument : как getAuthorizedDocument, но при allowStale может вернуть устаревшую копию из кэша
+func (s *DocumentService) lookupAuthorizedDocument(ctx context.Context, documentUUID string, allowStale bool) (*documentLookup, error) {
 	db, ok := ctx.Value("db").(*config.Database)
 	if !ok {
 		return nil, fmt.Errorf("[DocumentService] database connection не найден в context")
@@ -77,73 +155,119 @@ func (s *DocumentService) GetDocumentByUUID(ctx context.Context, documentUUID st
 		return nil, fmt.Errorf("[DocumentService] пользователь не авторизован")
 	}
 
-	document, err = s.cacheRepository.GetDocument(ctx, documentUUID)
+	entry, err := s.getCacheEntry(ctx, documentUUID, allowStale)
 	if err != nil {
 		log.Printf("[DocumentService] ошибка кэширования: %v", err)
 	}
 
-	if document == nil {
-		exec, rollback, commit, err := s.documentRepository.BeginTX(ctx)
-		if err != nil {
-			return nil, util.LogError("[DocumentService] не удалось начать транзакцию", err)
+	now := time.Now()
+	if entry != nil && (entry.IsFresh(now) || entry.CanServeWhileRevalidating(now)) {
+		if err := s.checkCachedDocumentAccess(ctx, db, documentUUID, entry.Document, claims.UserUUID); err != nil {
+			return nil, err
 		}
-		defer rollback()
-
-		document, _, err = s.documentRepository.GetByUUID(ctx, exec, documentUUID, claims.UserUUID)
-		if err != nil {
-			return nil, util.LogError("[DocumentService] документ не найден или доступ запрещён", err)
+		if entry.IsFresh(now) {
+			log.Printf("[DocumentService] документ %s взят из кэша Redis", entry.Document.FilenameOriginal)
+			return &documentLookup{document: entry.Document}, nil
 		}
 
-		grants, err := s.grantRepository.ListGrants(ctx, exec, documentUUID)
-		if err != nil {
-			return nil, util.LogError("[DocumentService] не удалось получить список grant", err)
-		}
-		document.GrantLogins = grants
+		s.revalidateDocument(ctx, documentUUID, claims.UserUUID)
+		log.Printf("[DocumentService] документ %s отдан из устаревшей копии кэша, обновляется в фоне", entry.Document.FilenameOriginal)
+		return &documentLookup{document: entry.Document, stale: true, age: entry.Age(now)}, nil
+	}
 
-		if document.OwnerUUID != claims.UserUUID && !document.IsPublic {
-			hasAccess, err := s.grantRepository.HasAccess(ctx, exec, documentUUID, claims.UserUUID)
-			if err != nil {
-				return nil, util.LogError("[DocumentService] ошибка проверки доступа", err)
-			}
-			if !hasAccess {
-				return nil, fmt.Errorf("[DocumentService] доступ запрещён")
+	if entry == nil && s.isDocumentMissing(ctx, documentUUID, claims.UserUUID) {
+		return nil, fmt.Errorf("[DocumentService] документ не найден или доступ запрещён: %w", sql.ErrNoRows)
+	}
+
+	document, authorized, err := s.loadDocument(ctx, documentUUID, claims.UserUUID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			s.rememberDocumentMissing(ctx, documentUUID, claims.UserUUID)
+		}
+		if entry != nil && entry.CanServeOnError(now) && isSourceUnavailable(err) {
+			if accessErr := s.checkCachedDocumentAccess(ctx, db, documentUUID, entry.Document, claims.UserUUID); accessErr != nil {
+				return nil, err
 			}
+			log.Printf("[DocumentService] БД недоступна, документ %s отдан из устаревшей копии кэша: %v", entry.Document.FilenameOriginal, err)
+			return &documentLookup{document: entry.Document, stale: true, age: entry.Age(now)}, nil
 		}
-
-		if err := commit(); err != nil {
-			return nil, util.LogError("[DocumentService] не удалось закоммитить транзакцию", err)
+		return nil, err
+	}
+	if authorized == false {
+		if err := s.checkCachedDocumentAccess(ctx, db, documentUUID, document, claims.UserUUID); err != nil {
+			return nil, err
 		}
+	}
 
-		if err := s.cacheRepository.SetDocument(ctx, document); err != nil {
-			fmt.Printf("[DocumentService] ошибка кэширования документа: %v\n", err)
-		}
+	return &documentLookup{document: document}, nil
+}
 
-		log.Printf("[DocumentService] документ %s взят из БД и успешно кэширован Redis", document.FilenameOriginal)
-	} else {
-		if document.IsPublic == false && document.OwnerUUID != claims.UserUUID {
-			hasAccess, err := s.grantRepository.HasAccess(ctx, db, documentUUID, claims.UserUUID)
-			if err != nil {
-				return nil, util.LogError("[DocumentService] ошибка проверки доступа", err)
-			}
-			if !hasAccess {
-				return nil, fmt.Errorf("[DocumentService] доступ запрещён")
-			}
-		}
-		log.Printf("[DocumentService] документ %s взят из кэша Redis", document.FilenameOriginal)
+// getCacheEntry : запись кэша документа. Устаревшие копии возвращаются, только если allowStale и кэш их хранит
+func (s *DocumentService) getCacheEntry(ctx context.Context, documentUUID string, allowStale bool) (*model.CachedDocument, error) {
+	if staleCache, ok := s.cacheRepository.(ports.StaleDocumentCache); ok && allowStale {
+		return staleCache.GetDocumentEntry(ctx, documentUUID)
+	}
+
+	document, err := s.cacheRepository.GetDocument(ctx, documentUUID)
+	if document == nil {
+		return nil, err
 	}
+	return &model.CachedDocument{Document: document}, err
+}
 
-	var getURL string
-	if document.StoragePath != "" {
-		getURL, err = s.storageInterface.GeneratePresignedGetURL(ctx, document.StoragePath, s.ttl)
+// loadDocumentFromDB : читает документ с grant из БД от имени пользователя и кладёт его в кэш
+func (s *DocumentService) loadDocumentFromDB(ctx context.Context, documentUUID string, userUUID string) (*model.Document, error) {
+	exec, rollback, commit, err := s.documentRepository.BeginTX(ctx)
+	if err != nil {
+		return nil, util.LogError("[DocumentService] не удалось начать транзакцию", err)
+	}
+	defer rollback()
+
+	document, _, err := s.documentRepository.GetByUUID(ctx, exec, documentUUID, userUUID)
+	if err != nil {
+		return nil, util.LogError("[DocumentService] документ не найден или доступ запрещён", err)
+	}
+
+	grants, err := s.grantRepository.ListGrants(ctx, exec, documentUUID)
+	if err != nil {
+		return nil, util.LogError("[DocumentService] не удалось получить список grant", err)
+	}
+	document.GrantLogins = grants
+
+	if document.OwnerUUID != userUUID && !document.IsPublic {
+		hasAccess, err := s.grantRepository.HasAccess(ctx, exec, documentUUID, userUUID)
 		if err != nil {
-			return nil, util.LogError("[DocumentService] не удалось сгенерировать pre-signed GET URL", err)
+			return nil, util.LogError("[DocumentService] ошибка проверки доступа", err)
+		}
+		if !hasAccess {
+			return nil, errDocumentAccessDenied
 		}
 	}
 
-	return &model.GetDocumentResult{
-		Document: document,
-		GetURL:   getURL,
-	}, nil
+	if err := commit(); err != nil {
+		return nil, util.LogError("[DocumentService] не удалось закоммитить транзакцию", err)
+	}
+
+	if err := s.cacheRepository.SetDocument(ctx, document); err != nil {
+		fmt.Printf("[DocumentService] ошибка кэширования документа: %v\n", err)
+	}
+
+	log.Printf("[DocumentService] документ %s взят из БД и успешно кэширован Redis", document.FilenameOriginal)
+	return document, nil
+}
+
+// checkCachedDocumentAccess : проверка доступа к документу, полученному не от имени текущего пользователя
+func (s *DocumentService) checkCachedDocumentAccess(ctx context.Context, db *config.Database, documentUUID string, document *model.Document, userUUID string) error {
+	if document.IsPublic == false && document.OwnerUUID != userUUID {
+		hasAccess, err := s.hasAccess(ctx, db, documentUUID, userUUID)
+		if err != nil {
+			return util.LogError("[DocumentService] ошибка проверки доступа", err)
+		}
+		if !hasAccess {
+			return errDocumentAccessDenied
+		}
+	}
+	return nil
 }
 
 // GetDocumentByToken : возвращает публичный документ по токену
@@ -153,27 +277,26 @@ func (s *DocumentService) GetDocumentByToken(ctx context.Context, token string)
 		return nil, fmt.Errorf("[UserService] database connection не найден в context")
 	}
 
-	document, err := s.documentRepository.GetByToken(ctx, db, token)
-	if err != nil {
-		return nil, util.LogError("[DocumentService] не удалось получить документ по токену", err)
-	}
-
-	if document == nil || document.IsPublic == false {
-		return nil, errors.New("[DocumentService] документ не является публичным или не найден")
-	}
-
-	var getURL string
-	if document.StoragePath != "" {
-		getURL, err = s.storageInterface.GeneratePresignedGetURL(ctx, document.StoragePath, s.ttl)
+	document := s.cachedPublicDocument(ctx, "", token)
+	if document == nil {
+		var err error
+		document, err = s.documentRepository.GetByToken(ctx, db, token)
 		if err != nil {
-			return nil, util.LogError("[DocumentService] не удалось сгенерировать pre-signed GET URL", err)
+			return nil, util.LogError("[DocumentService] не удалось получить документ по токену", err)
+		}
+
+		if document == nil || document.IsPublic == false {
+			return nil, errors.New("[DocumentService] документ не является публичным или не найден")
 		}
+		s.rememberPublicDocument(ctx, document)
 	}
+	s.recordAccess(document.UUID)
 
-	return &model.GetDocumentResult{
-		Document: document,
-		GetURL:   getURL,
-	}, nil
+	result := &model.GetDocumentResult{Document: publicProjection(document)}
+	if err := s.attachGetURL(ctx, result); err != nil {
+		return nil, err
+	}
+	return result, nil
 }
 
 // GetPublicDocument : возвращает публичный документ по UUID или токену
@@ -181,14 +304,44 @@ func (s *DocumentService) GetPublicDocument(ctx context.Context, documentUUID, t
 	var document *model.Document
 	var err error
 
+	if token != "" && s.isPublicTokenMissing(ctx, token) {
+		return nil, fmt.Errorf("[DocumentService] публичный документ не найден: %w", sql.ErrNoRows)
+	}
+
+	document = s.cachedPublicDocument(ctx, documentUUID, token)
+	if document == nil {
+		document, err = s.loadPublicDocument(ctx, documentUUID, token)
+		if err != nil {
+			return nil, err
+		}
+		s.rememberPublicDocument(ctx, document)
+	}
+
+	// генерируем ссылку
+	result := &model.GetDocumentResult{Document: publicProjection(document)}
+	if document != nil {
+		s.recordAccess(document.UUID)
+		if err := s.attachGetURL(ctx, result); err != nil {
+			return nil, err
+		}
+	}
+	return result, nil
+}
+
+// loadPublicDocument : публичный документ из БД по UUID или токену
+func (s *DocumentService) loadPublicDocument(ctx context.Context, documentUUID, token string) (*model.Document, error) {
 	exec, rollback, commit, err := s.documentRepository.BeginTX(ctx)
 	if err != nil {
 		return nil, util.LogError("[DocumentService] не удалось начать транзакцию", err)
 	}
 	defer rollback()
 
+	var document *model.Document
 	if token != "" {
 		document, err = s.documentRepository.GetPublicByToken(ctx, exec, token)
+		if errors.Is(err, sql.ErrNoRows) {
+			s.rememberPublicTokenMissing(ctx, token)
+		}
 	} else {
 		document, err = s.documentRepository.GetPublicByUUID(ctx, exec, documentUUID)
 	}
@@ -199,20 +352,72 @@ func (s *DocumentService) GetPublicDocument(ctx context.Context, documentUUID, t
 	if err := commit(); err != nil {
 		return nil, util.LogError("[DocumentService] не удалось закоммитить транзакцию", err)
 	}
+	return document, nil
+}
+
+// CopyDocument : создаёт копию документа для текущего пользователя.
+// Исходный документ должен быть доступен пользователю (владелец, grant или публичный),
+// файл копируется на стороне S3 в префикс пользователя. Пустое имя и nil isPublic означают "как у оригинала"
+func (s *DocumentService) CopyDocument(ctx context.Context, documentUUID string, name string, isPublic *bool) (*model.Document, error) {
+	db, ok := ctx.Value("db").(*config.Database)
+	if !ok {
+		return nil, fmt.Errorf("[DocumentService] database connection не найден в context")
+	}
 
-	// генерируем ссылку
-	var getURL string
-	if document != nil && document.StoragePath != "" {
-		getURL, err = s.storageInterface.GeneratePresignedGetURL(ctx, document.StoragePath, s.ttl)
-		if err != nil {
-			return nil, util.LogError("[DocumentService] не удалось сгенерировать pre-signed GET URL", err)
+	claims, err := security.GetClaimsFromContext(ctx)
+	if err != nil || claims == nil {
+		return nil, fmt.Errorf("[DocumentService] пользователь не авторизован")
+	}
+
+	source, err := s.getAuthorizedDocument(ctx, documentUUID)
+	if err != nil {
+		return nil, err
+	}
+
+	if name == "" {
+		name = source.FilenameOriginal
+	}
+	public := source.IsPublic
+	if isPublic != nil {
+		public = *isPublic
+	}
+
+	now := time.Now()
+	document := &model.Document{
+		UUID:             uuid.New().String(),
+		OwnerUUID:        claims.UserUUID,
+		FilenameOriginal: name,
+		SizeBytes:        source.SizeBytes,
+		MimeType:         source.MimeType,
+		Sha256:           source.Sha256,
+		IsFile:           source.IsFile,
+		IsPublic:         public,
+		CreatedAt:        now,
+		UpdatedAt:        now,
+	}
+
+	if source.StoragePath != "" {
+		document.StoragePath = util.BuildStoragePath(claims.UserUUID, name)
+		if err := s.storageInterface.CopyObject(ctx, source.StoragePath, document.StoragePath); err != nil {
+			return nil, util.LogError("[DocumentService] не удалось скопировать файл в S3", err)
 		}
 	}
 
-	return &model.GetDocumentResult{
-		Document: document,
-		GetURL:   getURL,
-	}, nil
+	if err := s.insertDocument(ctx, db, document); err != nil {
+		if document.StoragePath != "" {
+			if err := s.storageInterface.DeleteObject(ctx, document.StoragePath); err != nil {
+				log.Printf("[DocumentService] не удалось удалить копию файла %s из S3: %v", document.StoragePath, err)
+			}
+		}
+		return nil, err
+	}
+	s.forgetMissingDocument(ctx, document)
+	s.cacheCreatedDocument(ctx, document)
+	s.invalidateOwnerLists(ctx, document.OwnerUUID)
+
+	log.Printf("[DocumentService] документ %s скопирован в %s", source.UUID, document.UUID)
+
+	return document, nil
 }
 
 // ShareDocument : добавить пользователя к документу
@@ -222,6 +427,10 @@ func (s *DocumentService) ShareDocument(
 	ownerUUID string,
 	targetUserUUID string,
 ) error {
+	if err := s.EnsureDocumentUnlocked(ctx, documentUUID, ownerUUID); err != nil {
+		return err
+	}
+
 	exec, rollback, commit, err := s.documentRepository.BeginTX(ctx)
 	if err != nil {
 		return util.LogError("[DocumentService] не удалось начать транзакцию", err)
@@ -256,21 +465,26 @@ func (s *DocumentService) ShareDocument(
 	if err := s.grantRepository.AddGrant(ctx, exec, documentUUID, ownerUUID, targetUserUUID); err != nil {
 		return util.LogError("[DocumentService] ошибка изменения прав доступа", err)
 	}
+	changed := s.reloadForWriteThrough(ctx, exec, documentUUID, ownerUUID)
 
 	if err := commit(); err != nil {
 		return util.LogError("[DocumentService] ошибка коммита транзакции", err)
 	}
 
-	// удаляем документ из кэша (чтобы потом перечитать актуальные grant при получении документа)
-	if err := s.cacheRepository.DeleteDocument(ctx, documentUUID); err != nil {
-		fmt.Printf("[DocumentService] ошибка удаления документа из кэша: %v", err)
-	}
+	s.updateGrantSet(ctx, documentUUID, targetUserUUID, true)
+
+	// документ с новыми grant записывается в кэш или удаляется из него, чтобы grant перечитались при получении документа
+	s.cacheChangedDocument(ctx, documentUUID, changed)
 
 	return nil
 }
 
 // DeleteDocument помечает документ удалённым, инвалидирует кэш и удаляет файл из S3
 func (s *DocumentService) DeleteDocument(ctx context.Context, documentUUID string, userUUID string) (map[string]bool, error) {
+	if err := s.EnsureDocumentUnlocked(ctx, documentUUID, userUUID); err != nil {
+		return nil, err
+	}
+
 	exec, rollback, commit, err := s.documentRepository.BeginTX(ctx)
 	if err != nil {
 		return nil, util.LogError("[DocumentService] ошибка начала транзакции", err)
@@ -286,6 +500,10 @@ func (s *DocumentService) DeleteDocument(ctx context.Context, documentUUID strin
 		return nil, fmt.Errorf("[DocumentService] только владелец может удалить документ")
 	}
 
+	if document.LegalHold {
+		return nil, fmt.Errorf("[DocumentService] документ находится на юридическом удержании и не может быть удалён")
+	}
+
 	deletedUUID, err := s.documentRepository.Delete(ctx, exec, documentUUID, document.OwnerUUID)
 	if err != nil {
 		return nil, util.LogError("[DocumentService] ошибка удаления документа из БД", err)
@@ -295,9 +513,7 @@ func (s *DocumentService) DeleteDocument(ctx context.Context, documentUUID strin
 		return nil, fmt.Errorf("[DocumentService] ошибка коммита транзакции: %w", err)
 	}
 
-	if err := s.cacheRepository.DeleteDocument(ctx, documentUUID); err != nil {
-		fmt.Printf("[DocumentService] ошибка удаления из кэша: %v\n", err)
-	}
+	s.cacheDeletedDocument(ctx, documentUUID)
 
 	if err := s.storageInterface.DeleteObject(ctx, document.StoragePath); err != nil {
 		return nil, util.LogError("[DocumentService] ошибка удаления файла из S3", err)
@@ -319,6 +535,15 @@ func (s *DocumentService) ListDocuments(ctx context.Context, userUUID string, lo
 		return nil, "", fmt.Errorf("[DocumentService] database connection не найден в context")
 	}
 
+	// список по чужому login одинаков для всех, поэтому владелец в ключ не входит
+	query := model.DocumentListQuery{Login: login, FilterKey: filterKey, FilterValue: filterValue, Limit: limit}
+	if login == "" {
+		query.OwnerUUID = userUUID
+	}
+	if page := s.cachedDocumentList(ctx, query); page != nil {
+		return page.Documents, page.NextCursor, nil
+	}
+
 	docs, err := s.documentRepository.ListDocuments(ctx, db, userUUID, login, filterKey, filterValue, limit)
 	if err != nil {
 		return nil, "", util.LogError("[DocumentService] не удалось получить список документов", err)
@@ -333,10 +558,12 @@ func (s *DocumentService) ListDocuments(ctx context.Context, userUUID string, lo
 			grants = []string{} // на случай ошибки оставляем пустой массив
 		}
 
-		url, err := s.storageInterface.GeneratePresignedGetURL(ctx, doc.StoragePath, 15*time.Minute)
+		var url string
+		presigned, err := s.presignedGetURL(ctx, doc.StoragePath, listPresignedURLTTL)
 		if err != nil {
 			fmt.Printf("[DocumentService] ошибка генерации pre-signed URL для документа %s: %v\n", doc.UUID, err)
-			url = ""
+		} else {
+			url = presigned.URL
 		}
 
 		responses = append(responses, model.DocumentResponse{
@@ -356,11 +583,17 @@ func (s *DocumentService) ListDocuments(ctx context.Context, userUUID string, lo
 		nextCursor = docs[len(docs)-1].UUID
 	}
 
+	s.storeDocumentList(ctx, query, docs, &model.DocumentListPage{Documents: responses, NextCursor: nextCursor})
+
 	return responses, nextCursor, nil
 }
 
 // AddGrant : добавляет пользователя к документу для совместного доступа и инвалидирует кэш
 func (s *DocumentService) AddGrant(ctx context.Context, documentUUID, ownerUUID, targetUserUUID string) error {
+	if err := s.EnsureDocumentUnlocked(ctx, documentUUID, ownerUUID); err != nil {
+		return err
+	}
+
 	exec, rollback, commit, err := s.documentRepository.BeginTX(ctx)
 	if err != nil {
 		return util.LogError("[DocumentService] ошибка начала транзакции", err)
@@ -378,21 +611,26 @@ func (s *DocumentService) AddGrant(ctx context.Context, documentUUID, ownerUUID,
 	if err := s.grantRepository.AddGrant(ctx, exec, documentUUID, ownerUUID, targetUserUUID); err != nil {
 		return util.LogError("[DocumentService] не удалось добавить доступ к документу", err)
 	}
+	changed := s.reloadForWriteThrough(ctx, exec, documentUUID, ownerUUID)
 
 	if err := commit(); err != nil {
 		return util.LogError("[DocumentService] ошибка коммита транзакции", err)
 	}
 
-	// Инвалидируем кэш документа, чтобы новые гранты были учтены
-	if err := s.cacheRepository.DeleteDocument(ctx, documentUUID); err != nil {
-		fmt.Printf("[DocumentService] ошибка удаления документа из кэша: %v\n", err)
-	}
+	s.updateGrantSet(ctx, documentUUID, targetUserUUID, true)
+
+	// Обновляем кэш документа, чтобы новые гранты были учтены
+	s.cacheChangedDocument(ctx, documentUUID, changed)
 
 	return nil
 }
 
 // RemoveGrant : удаляет пользователя из доступа к документу и инвалидирует кэш
 func (s *DocumentService) RemoveGrant(ctx context.Context, documentUUID, ownerUUID, targetUserUUID string) error {
+	if err := s.EnsureDocumentUnlocked(ctx, documentUUID, ownerUUID); err != nil {
+		return err
+	}
+
 	exec, rollback, commit, err := s.documentRepository.BeginTX(ctx)
 	if err != nil {
 		return util.LogError("[DocumentService] ошибка начала транзакции", err)
@@ -410,14 +648,15 @@ func (s *DocumentService) RemoveGrant(ctx context.Context, documentUUID, ownerUU
 	if err := s.grantRepository.RemoveGrant(ctx, exec, documentUUID, targetUserUUID); err != nil {
 		return err
 	}
+	changed := s.reloadForWriteThrough(ctx, exec, documentUUID, ownerUUID)
 
 	if err := commit(); err != nil {
 		return fmt.Errorf("[DocumentService] ошибка коммита транзакции: %w", err)
 	}
 
-	if err := s.cacheRepository.DeleteDocument(ctx, documentUUID); err != nil {
-		fmt.Printf("[DocumentService] ошибка удаления документа из кэша: %v\n", err)
-	}
+	s.updateGrantSet(ctx, documentUUID, targetUserUUID, false)
+
+	s.cacheChangedDocument(ctx, documentUUID, changed)
 
 	return nil
 }
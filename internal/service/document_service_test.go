@@ -1,6 +1,8 @@
 package service_test
 
 import (
+	"archive/zip"
+	"bytes"
 	"caching-web-server/config"
 	"caching-web-server/internal/model"
 	"caching-web-server/internal/security"
@@ -14,6 +16,8 @@ import (
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
 	"github.com/stretchr/testify/require"
+	"io"
+	"strings"
 	"testing"
 	"time"
 )
@@ -108,6 +112,18 @@ func (m *MockS3Storage) DeleteObject(ctx context.Context, key string) error {
 	return m.Called(ctx, key).Error(0)
 }
 
+func (m *MockS3Storage) CopyObject(ctx context.Context, sourceKey, destinationKey string) error {
+	return m.Called(ctx, sourceKey, destinationKey).Error(0)
+}
+
+func (m *MockS3Storage) GetObject(ctx context.Context, key string) (io.ReadCloser, error) {
+	args := m.Called(ctx, key)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(io.ReadCloser), args.Error(1)
+}
+
 func (m *MockGrantRepository) AddGrant(ctx context.Context, exec sqlx.ExtContext, documentUUID string, ownerUUID string, targetUserUUID string) error {
 	args := m.Called(ctx, exec, documentUUID, ownerUUID, targetUserUUID)
 	return args.Error(0)
@@ -170,6 +186,8 @@ func newTestDocumentService() (*service.DocumentService, *MockDocumentRepository
 		nil, // GrantRepository не нужен для CreateDocument
 		mockStorage,
 		nil,       // UserRepository не нужен для CreateDocument
+		nil,       // квоты выключены
+		nil,       // блокировки выключены
 		time.Hour, // TTL
 	)
 
@@ -250,6 +268,8 @@ func newTestDocumentServiceWithGrants() (*service.DocumentService, *MockDocument
 		mockGrantRepo,
 		mockStorage,
 		nil,
+		nil,
+		nil,
 		time.Minute,
 	)
 
@@ -263,6 +283,14 @@ func (m *MockGrantRepository) ListGrants(ctx context.Context, exec sqlx.ExtConte
 	args := m.Called(ctx, exec, documentUUID)
 	return args.Get(0).([]string), args.Error(1)
 }
+func (m *MockGrantRepository) ListGrantUserUUIDs(ctx context.Context, exec sqlx.ExtContext, documentUUID string) ([]string, error) {
+	args := m.Called(ctx, exec, documentUUID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]string), args.Error(1)
+}
+
 func (m *MockGrantRepository) CheckOwner(ctx context.Context, exec sqlx.ExtContext, documentUUID, ownerUUID string) (bool, error) {
 	args := m.Called(ctx, exec, documentUUID, ownerUUID)
 	return args.Bool(0), args.Error(1)
@@ -841,7 +869,7 @@ func TestShareDocument_AllCases(t *testing.T) {
 
 			tt.setupMocks(mockDocRepo, mockUserRepo, mockGrantRepo, mockCacheRepo)
 
-			svc := service.NewDocumentService(mockDocRepo, mockCacheRepo, mockGrantRepo, nil, mockUserRepo, time.Minute)
+			svc := service.NewDocumentService(mockDocRepo, mockCacheRepo, mockGrantRepo, nil, mockUserRepo, nil, nil, time.Minute)
 			err := svc.ShareDocument(ctx, docUUID, ownerUUID, targetUUID)
 
 			if tt.expectError != "" {
@@ -905,6 +933,22 @@ func TestDeleteDocument_AllCases(t *testing.T) {
 			},
 			expectError: "только владелец может удалить документ",
 		},
+		{
+			name: "Legal hold",
+			setupMocks: func(docRepo *MockDocumentRepository, cacheRepo *MockCacheRepository, s3 *MockS3Storage, grantRepo *MockGrantRepository) {
+				exec := new(sqlx.Tx)
+				rollback := func() error { return nil }
+				commit := func() error { return nil }
+
+				docRepo.On("BeginTX", ctx).Return(exec, rollback, commit, nil)
+				docRepo.On("GetByUUID", ctx, exec, documentUUID, userUUID).Return(&model.Document{
+					UUID:      documentUUID,
+					OwnerUUID: userUUID,
+					LegalHold: true,
+				}, []string{}, nil)
+			},
+			expectError: "юридическом удержании",
+		},
 		{
 			name: "GetByUUID error",
 			setupMocks: func(docRepo *MockDocumentRepository, cacheRepo *MockCacheRepository, s3 *MockS3Storage, grantRepo *MockGrantRepository) {
@@ -1042,7 +1086,7 @@ func TestListDocuments_AllCases(t *testing.T) {
 
 			tt.setupMocks(mockDocRepo, mockGrantRepo, mockS3)
 
-			svc := service.NewDocumentService(mockDocRepo, nil, mockGrantRepo, mockS3, nil, time.Minute)
+			svc := service.NewDocumentService(mockDocRepo, nil, mockGrantRepo, mockS3, nil, nil, nil, time.Minute)
 			res, nextCursor, err := svc.ListDocuments(ctx, userUUID, login, filterKey, filterValue, limit)
 
 			if tt.expectError != "" {
@@ -1167,7 +1211,7 @@ func TestAddGrant_AllCases(t *testing.T) {
 
 			tt.setupMocks(mockDocRepo, mockGrantRepo, mockCache)
 
-			svc := service.NewDocumentService(mockDocRepo, mockCache, mockGrantRepo, nil, nil, time.Minute)
+			svc := service.NewDocumentService(mockDocRepo, mockCache, mockGrantRepo, nil, nil, nil, nil, time.Minute)
 			err := svc.AddGrant(ctx, documentUUID, ownerUUID, targetUUID)
 
 			if tt.expectError != "" {
@@ -1283,7 +1327,7 @@ func TestRemoveGrant_AllCases(t *testing.T) {
 
 			tt.setupMocks(mockDocRepo, mockGrantRepo, mockCache)
 
-			svc := service.NewDocumentService(mockDocRepo, mockCache, mockGrantRepo, nil, nil, time.Minute)
+			svc := service.NewDocumentService(mockDocRepo, mockCache, mockGrantRepo, nil, nil, nil, nil, time.Minute)
 			err := svc.RemoveGrant(ctx, documentUUID, ownerUUID, targetUUID)
 
 			if tt.expectError != "" {
@@ -1299,3 +1343,157 @@ func TestRemoveGrant_AllCases(t *testing.T) {
 		})
 	}
 }
+
+func TestArchiveDocuments(t *testing.T) {
+	ctx := context.WithValue(context.Background(), security.UserContextKey, &security.Claims{UserUUID: "user1"})
+	ctx = context.WithValue(ctx, "db", &config.Database{})
+
+	first := &model.Document{UUID: "doc1", OwnerUUID: "user1", FilenameOriginal: "report.pdf", StoragePath: "users/user1/documents/report-1.pdf", IsFile: true}
+	second := &model.Document{UUID: "doc2", OwnerUUID: "user1", FilenameOriginal: "report.pdf", StoragePath: "users/user1/documents/report-2.pdf", IsFile: true}
+	third := &model.Document{UUID: "doc3", OwnerUUID: "user1", FilenameOriginal: "../../etc/report.pdf", StoragePath: "users/user1/documents/report-3.pdf", IsFile: true}
+
+	t.Run("Success with duplicate names", func(t *testing.T) {
+		svc, _, mockStorage, mockCache, _ := newTestDocumentServiceWithGrants()
+
+		mockCache.On("GetDocument", ctx, "doc1").Return(first, nil).Once()
+		mockCache.On("GetDocument", ctx, "doc2").Return(second, nil).Once()
+		mockCache.On("GetDocument", ctx, "doc3").Return(third, nil).Once()
+		mockStorage.On("GetObject", ctx, first.StoragePath).Return(io.NopCloser(bytes.NewBufferString("first")), nil).Once()
+		mockStorage.On("GetObject", ctx, second.StoragePath).Return(io.NopCloser(bytes.NewBufferString("second")), nil).Once()
+		mockStorage.On("GetObject", ctx, third.StoragePath).Return(io.NopCloser(bytes.NewBufferString("third")), nil).Once()
+
+		documents, err := svc.ResolveArchiveDocuments(ctx, []string{"doc1", "doc2", "doc1", "doc3"}, "", "")
+		require.NoError(t, err)
+		require.Len(t, documents, 3)
+
+		var buffer bytes.Buffer
+		require.NoError(t, svc.WriteArchive(ctx, documents, &buffer))
+
+		reader, err := zip.NewReader(bytes.NewReader(buffer.Bytes()), int64(buffer.Len()))
+		require.NoError(t, err)
+
+		contents := map[string]string{}
+		for _, file := range reader.File {
+			rc, err := file.Open()
+			require.NoError(t, err)
+			data, err := io.ReadAll(rc)
+			require.NoError(t, err)
+			rc.Close()
+			contents[file.Name] = string(data)
+		}
+
+		assert.Equal(t, map[string]string{
+			"report.pdf":     "first",
+			"report (1).pdf": "second",
+			"report (2).pdf": "third",
+		}, contents)
+		mockCache.AssertExpectations(t)
+		mockStorage.AssertExpectations(t)
+	})
+
+	t.Run("Access denied", func(t *testing.T) {
+		svc, _, _, mockCache, mockGrantRepo := newTestDocumentServiceWithGrants()
+		foreign := &model.Document{UUID: "doc4", OwnerUUID: "user2", FilenameOriginal: "secret.txt", StoragePath: "users/user2/documents/secret.txt", IsFile: true}
+
+		mockCache.On("GetDocument", ctx, "doc1").Return(first, nil).Once()
+		mockCache.On("GetDocument", ctx, "doc4").Return(foreign, nil).Once()
+		mockGrantRepo.On("HasAccess", ctx, mock.Anything, "doc4", "user1").Return(false, nil).Once()
+
+		_, err := svc.ResolveArchiveDocuments(ctx, []string{"doc1", "doc4"}, "", "")
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "доступ запрещён")
+	})
+
+	t.Run("Empty selection", func(t *testing.T) {
+		svc, _, _, _, _ := newTestDocumentServiceWithGrants()
+
+		_, err := svc.ResolveArchiveDocuments(ctx, nil, "", "")
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "не выбрано ни одного документа")
+	})
+}
+
+func TestCopyDocument(t *testing.T) {
+	ctx := context.WithValue(context.Background(), security.UserContextKey, &security.Claims{UserUUID: "user1"})
+	ctx = context.WithValue(ctx, "db", &config.Database{})
+
+	source := &model.Document{
+		UUID:             "doc1",
+		OwnerUUID:        "user2",
+		FilenameOriginal: "report.pdf",
+		SizeBytes:        42,
+		MimeType:         "application/pdf",
+		Sha256:           "abc",
+		StoragePath:      "users/user2/documents/report-1.pdf",
+		IsFile:           true,
+	}
+
+	t.Run("Copy of granted document", func(t *testing.T) {
+		svc, mockDocRepo, mockStorage, mockCache, mockGrantRepo := newTestDocumentServiceWithGrants()
+		public := true
+
+		mockCache.On("GetDocument", ctx, "doc1").Return(source, nil).Once()
+		mockGrantRepo.On("HasAccess", ctx, mock.Anything, "doc1", "user1").Return(true, nil).Once()
+		mockStorage.On("CopyObject", ctx, source.StoragePath, mock.MatchedBy(func(key string) bool {
+			return strings.HasPrefix(key, "users/user1/documents/copy-")
+		})).Return(nil).Once()
+		mockDocRepo.On("Create", ctx, mock.Anything, mock.MatchedBy(func(doc *model.Document) bool {
+			return doc.OwnerUUID == "user1" && doc.FilenameOriginal == "copy.pdf" && doc.IsPublic && doc.Sha256 == "abc" && doc.UUID != "doc1"
+		})).Return(nil).Once()
+
+		document, err := svc.CopyDocument(ctx, "doc1", "copy.pdf", &public)
+		require.NoError(t, err)
+		assert.Equal(t, "user1", document.OwnerUUID)
+		assert.Equal(t, int64(42), document.SizeBytes)
+
+		mockCache.AssertExpectations(t)
+		mockGrantRepo.AssertExpectations(t)
+		mockStorage.AssertExpectations(t)
+		mockDocRepo.AssertExpectations(t)
+	})
+
+	t.Run("No access", func(t *testing.T) {
+		svc, _, mockStorage, mockCache, mockGrantRepo := newTestDocumentServiceWithGrants()
+
+		mockCache.On("GetDocument", ctx, "doc1").Return(source, nil).Once()
+		mockGrantRepo.On("HasAccess", ctx, mock.Anything, "doc1", "user1").Return(false, nil).Once()
+
+		_, err := svc.CopyDocument(ctx, "doc1", "", nil)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "доступ запрещён")
+		mockStorage.AssertNotCalled(t, "CopyObject", mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("DB error removes copied object", func(t *testing.T) {
+		svc, mockDocRepo, mockStorage, mockCache, mockGrantRepo := newTestDocumentServiceWithGrants()
+
+		mockCache.On("GetDocument", ctx, "doc1").Return(source, nil).Once()
+		mockGrantRepo.On("HasAccess", ctx, mock.Anything, "doc1", "user1").Return(true, nil).Once()
+		mockStorage.On("CopyObject", ctx, source.StoragePath, mock.Anything).Return(nil).Once()
+		mockDocRepo.On("Create", ctx, mock.Anything, mock.Anything).Return(errors.New("db error")).Once()
+		mockStorage.On("DeleteObject", ctx, mock.Anything).Return(nil).Once()
+
+		_, err := svc.CopyDocument(ctx, "doc1", "", nil)
+		require.Error(t, err)
+		mockStorage.AssertExpectations(t)
+	})
+}
+
+func TestDocumentService_RecordsAccess(t *testing.T) {
+	mockDocRepo := new(MockDocumentRepository)
+	mockCache := new(MockPopularCacheRepository)
+	mockS3 := new(MockS3Storage)
+	svc := service.NewDocumentService(mockDocRepo, mockCache, nil, mockS3, nil, nil, nil, time.Minute)
+
+	ctx := context.WithValue(context.Background(), security.UserContextKey, &security.Claims{UserUUID: "user1"})
+	ctx = context.WithValue(ctx, "db", &config.Database{})
+	doc := &model.Document{UUID: "doc1", OwnerUUID: "user1", StoragePath: "docs/doc1"}
+	mockCache.On("GetDocument", ctx, "doc1").Return(doc, nil).Once()
+	mockCache.On("RecordAccess", "doc1").Once()
+	mockS3.On("GeneratePresignedGetURL", ctx, "docs/doc1", time.Minute).Return("http://get-url", nil).Once()
+
+	_, err := svc.GetDocumentByUUID(ctx, "doc1")
+
+	require.NoError(t, err)
+	mockCache.AssertExpectations(t)
+}
@@ -0,0 +1,122 @@
+package service
+
+import (
+	"caching-web-server/internal/model"
+	"caching-web-server/internal/ports"
+	"context"
+	"github.com/jmoiron/sqlx"
+	"log"
+)
+
+// writeThroughCache : кэш, в который изменённый документ записывается сразу после коммита
+func (s *DocumentService) writeThroughCache() (ports.VersionedDocumentCache, bool) {
+	versioned, ok := s.cacheRepository.(ports.VersionedDocumentCache)
+	return versioned, ok && versioned.WriteThroughEnabled()
+}
+
+// reloadForWriteThrough : перечитывает изменённый документ с grant в той же транзакции, чтобы после коммита записать его в кэш.
+// nil — write-through выключен или документ прочитать не удалось, тогда кэш только инвалидируется
+func (s *DocumentService) reloadForWriteThrough(ctx context.Context, exec sqlx.ExtContext, documentUUID string, ownerUUID string) *model.Document {
+	if _, ok := s.writeThroughCache(); ok == false {
+		return nil
+	}
+
+	document, _, err := s.documentRepository.GetByUUID(ctx, exec, documentUUID, ownerUUID)
+	if err != nil {
+		log.Printf("[DocumentService] не удалось перечитать документ %s для кэша: %v", documentUUID, err)
+		return nil
+	}
+	grants, err := s.grantRepository.ListGrants(ctx, exec, documentUUID)
+	if err != nil {
+		log.Printf("[DocumentService] не удалось перечитать grant документа %s для кэша: %v", documentUUID, err)
+		return nil
+	}
+	document.GrantLogins = grants
+	return document
+}
+
+// cacheChangedDocument : после коммита записывает новую версию документа в кэш. Если write-through выключен,
+// документ не перечитан или запись не удалась, документ удаляется из кэша, как раньше
+func (s *DocumentService) cacheChangedDocument(ctx context.Context, documentUUID string, document *model.Document) {
+	if versioned, ok := s.writeThroughCache(); ok && document != nil {
+		stored, err := versioned.StoreDocumentVersion(ctx, document)
+		if err == nil {
+			if stored == false {
+				log.Printf("[DocumentService] в кэше уже более новая версия документа %s, запись пропущена", documentUUID)
+			}
+			return
+		}
+		log.Printf("[DocumentService] не удалось записать документ %s в кэш: %v", documentUUID, err)
+	}
+
+	if err := s.cacheRepository.DeleteDocument(ctx, documentUUID); err != nil {
+		log.Printf("[DocumentService] ошибка удаления документа из кэша: %v", err)
+	}
+}
+
+// CacheDocumentVersion : обновляет кэш после изменения документа в обход DocumentService (срок хранения,
+// удержание, политика хранения). version — версия документа после изменения: при write-through в кэш записывается
+// перечитанный документ не старше неё, иначе (или если записать не удалось) копии старше version инвалидируются
+func (s *DocumentService) CacheDocumentVersion(ctx context.Context, documentUUID, ownerUUID string, version int64) {
+	versioned, ok := s.cacheRepository.(ports.VersionedDocumentCache)
+	if ok == false {
+		if err := s.cacheRepository.DeleteDocument(ctx, documentUUID); err != nil {
+			log.Printf("[DocumentService] ошибка удаления документа из кэша: %v", err)
+		}
+		return
+	}
+
+	if document := s.reloadCommitted(ctx, documentUUID, ownerUUID); document != nil && int64(document.Version) >= version {
+		stored, err := versioned.StoreDocumentVersion(ctx, document)
+		if err == nil {
+			if stored == false {
+				log.Printf("[DocumentService] в кэше уже более новая версия документа %s, запись пропущена", documentUUID)
+			}
+			return
+		}
+		log.Printf("[DocumentService] не удалось записать документ %s в кэш: %v", documentUUID, err)
+	}
+
+	if err := versioned.InvalidateDocumentVersion(ctx, documentUUID, version); err != nil {
+		log.Printf("[DocumentService] ошибка удаления документа из кэша: %v", err)
+	}
+}
+
+// reloadCommitted : перечитывает уже закоммиченный документ для write-through, nil — write-through выключен или ошибка
+func (s *DocumentService) reloadCommitted(ctx context.Context, documentUUID string, ownerUUID string) *model.Document {
+	if _, ok := s.writeThroughCache(); ok == false {
+		return nil
+	}
+
+	exec, rollback, _, err := s.documentRepository.BeginTX(ctx)
+	if err != nil {
+		log.Printf("[DocumentService] не удалось начать транзакцию для кэша: %v", err)
+		return nil
+	}
+	defer rollback()
+	return s.reloadForWriteThrough(ctx, exec, documentUUID, ownerUUID)
+}
+
+// cacheCreatedDocument : новый документ сразу попадает в кэш, если включён write-through
+func (s *DocumentService) cacheCreatedDocument(ctx context.Context, document *model.Document) {
+	versioned, ok := s.writeThroughCache()
+	if ok == false {
+		return
+	}
+	if _, err := versioned.StoreDocumentVersion(ctx, document); err != nil {
+		log.Printf("[DocumentService] не удалось записать новый документ %s в кэш: %v", document.UUID, err)
+	}
+}
+
+// cacheDeletedDocument : удалённый документ помечается в кэше, чтобы загрузка, начатая до удаления, не вернула его обратно
+func (s *DocumentService) cacheDeletedDocument(ctx context.Context, documentUUID string) {
+	var err error
+	if versioned, ok := s.cacheRepository.(ports.VersionedDocumentCache); ok {
+		err = versioned.TombstoneDocument(ctx, documentUUID)
+	} else {
+		err = s.cacheRepository.DeleteDocument(ctx, documentUUID)
+	}
+	if err != nil {
+		log.Printf("[DocumentService] ошибка удаления из кэша: %v", err)
+	}
+}
@@ -0,0 +1,157 @@
+package service_test
+
+import (
+	"caching-web-server/internal/model"
+	"caching-web-server/internal/service"
+	"context"
+	"errors"
+	"github.com/jmoiron/sqlx"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+// MockVersionedCacheRepository : кэш с версиями документов и write-through
+type MockVersionedCacheRepository struct{ MockCacheRepository }
+
+func (m *MockVersionedCacheRepository) WriteThroughEnabled() bool {
+	return m.Called().Bool(0)
+}
+
+func (m *MockVersionedCacheRepository) StoreDocumentVersion(ctx context.Context, document *model.Document) (bool, error) {
+	args := m.Called(ctx, document)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *MockVersionedCacheRepository) InvalidateDocumentVersion(ctx context.Context, uuid string, version int64) error {
+	return m.Called(ctx, uuid, version).Error(0)
+}
+
+func (m *MockVersionedCacheRepository) TombstoneDocument(ctx context.Context, uuid string) error {
+	return m.Called(ctx, uuid).Error(0)
+}
+
+// newTestWriteThroughService : сервис документов поверх версионированного кэша; writeThrough — включён ли write-through
+func newTestWriteThroughService(ctx context.Context, writeThrough bool) (*service.DocumentService, *MockDocumentRepository, *MockVersionedCacheRepository, *MockGrantRepository, sqlx.ExtContext) {
+	mockDocRepo := new(MockDocumentRepository)
+	mockCache := new(MockVersionedCacheRepository)
+	mockGrantRepo := new(MockGrantRepository)
+	exec := new(sqlx.Tx)
+	mockDocRepo.On("BeginTX", ctx).Return(exec, func() error { return nil }, func() error { return nil }, nil)
+	mockCache.On("WriteThroughEnabled").Return(writeThrough).Maybe()
+	mockS3 := new(MockS3Storage)
+	mockS3.On("DeleteObject", ctx, mock.Anything).Return(nil).Maybe()
+	svc := service.NewDocumentService(mockDocRepo, mockCache, mockGrantRepo, mockS3, nil, nil, nil, time.Minute)
+	return svc, mockDocRepo, mockCache, mockGrantRepo, exec
+}
+
+func TestDocumentWriteThrough(t *testing.T) {
+	ctx := context.Background()
+	documentUUID := "doc-123"
+	ownerUUID := "owner-123"
+	targetUUID := "target-456"
+
+	t.Run("Grant — документ перечитывается в транзакции и записывается в кэш", func(t *testing.T) {
+		svc, mockDocRepo, mockCache, mockGrantRepo, exec := newTestWriteThroughService(ctx, true)
+		document := &model.Document{UUID: documentUUID, OwnerUUID: ownerUUID, Version: 3}
+		mockGrantRepo.On("CheckOwner", ctx, exec, documentUUID, ownerUUID).Return(true, nil).Once()
+		mockGrantRepo.On("AddGrant", ctx, exec, documentUUID, ownerUUID, targetUUID).Return(nil).Once()
+		mockDocRepo.On("GetByUUID", ctx, exec, documentUUID, ownerUUID).Return(document, []string{}, nil).Once()
+		mockGrantRepo.On("ListGrants", ctx, exec, documentUUID).Return([]string{"target"}, nil).Once()
+		mockCache.On("StoreDocumentVersion", ctx, mock.MatchedBy(func(d *model.Document) bool {
+			return d.Version == 3 && assert.ObjectsAreEqual([]string{"target"}, d.GrantLogins)
+		})).Return(true, nil).Once()
+
+		require.NoError(t, svc.AddGrant(ctx, documentUUID, ownerUUID, targetUUID))
+		mockCache.AssertExpectations(t)
+		mockCache.AssertNotCalled(t, "DeleteDocument", mock.Anything, mock.Anything)
+	})
+
+	t.Run("Более новая версия в кэше — документ не инвалидируется", func(t *testing.T) {
+		svc, mockDocRepo, mockCache, mockGrantRepo, exec := newTestWriteThroughService(ctx, true)
+		mockGrantRepo.On("CheckOwner", ctx, exec, documentUUID, ownerUUID).Return(true, nil).Once()
+		mockGrantRepo.On("RemoveGrant", ctx, exec, documentUUID, targetUUID).Return(nil).Once()
+		mockDocRepo.On("GetByUUID", ctx, exec, documentUUID, ownerUUID).Return(&model.Document{UUID: documentUUID, Version: 2}, []string{}, nil).Once()
+		mockGrantRepo.On("ListGrants", ctx, exec, documentUUID).Return([]string{}, nil).Once()
+		mockCache.On("StoreDocumentVersion", ctx, mock.Anything).Return(false, nil).Once()
+
+		require.NoError(t, svc.RemoveGrant(ctx, documentUUID, ownerUUID, targetUUID))
+		mockCache.AssertNotCalled(t, "DeleteDocument", mock.Anything, mock.Anything)
+	})
+
+	t.Run("Ошибка записи — документ удаляется из кэша", func(t *testing.T) {
+		svc, mockDocRepo, mockCache, mockGrantRepo, exec := newTestWriteThroughService(ctx, true)
+		mockGrantRepo.On("CheckOwner", ctx, exec, documentUUID, ownerUUID).Return(true, nil).Once()
+		mockGrantRepo.On("AddGrant", ctx, exec, documentUUID, ownerUUID, targetUUID).Return(nil).Once()
+		mockDocRepo.On("GetByUUID", ctx, exec, documentUUID, ownerUUID).Return(&model.Document{UUID: documentUUID, Version: 2}, []string{}, nil).Once()
+		mockGrantRepo.On("ListGrants", ctx, exec, documentUUID).Return([]string{}, nil).Once()
+		mockCache.On("StoreDocumentVersion", ctx, mock.Anything).Return(false, errors.New("redis down")).Once()
+		mockCache.On("DeleteDocument", ctx, documentUUID).Return(nil).Once()
+
+		require.NoError(t, svc.AddGrant(ctx, documentUUID, ownerUUID, targetUUID))
+		mockCache.AssertExpectations(t)
+	})
+
+	t.Run("Write-through выключен — документ не перечитывается", func(t *testing.T) {
+		svc, mockDocRepo, mockCache, mockGrantRepo, exec := newTestWriteThroughService(ctx, false)
+		mockGrantRepo.On("CheckOwner", ctx, exec, documentUUID, ownerUUID).Return(true, nil).Once()
+		mockGrantRepo.On("AddGrant", ctx, exec, documentUUID, ownerUUID, targetUUID).Return(nil).Once()
+		mockCache.On("DeleteDocument", ctx, documentUUID).Return(nil).Once()
+
+		require.NoError(t, svc.AddGrant(ctx, documentUUID, ownerUUID, targetUUID))
+		mockCache.AssertExpectations(t)
+		mockDocRepo.AssertNotCalled(t, "GetByUUID", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+		mockCache.AssertNotCalled(t, "StoreDocumentVersion", mock.Anything, mock.Anything)
+	})
+
+	t.Run("Изменение в обход сервиса — закоммиченный документ записывается в кэш", func(t *testing.T) {
+		svc, mockDocRepo, mockCache, mockGrantRepo, exec := newTestWriteThroughService(ctx, true)
+		mockDocRepo.On("GetByUUID", ctx, exec, documentUUID, ownerUUID).Return(&model.Document{UUID: documentUUID, Version: 5}, []string{}, nil).Once()
+		mockGrantRepo.On("ListGrants", ctx, exec, documentUUID).Return([]string{"target"}, nil).Once()
+		mockCache.On("StoreDocumentVersion", ctx, mock.MatchedBy(func(d *model.Document) bool {
+			return d.Version == 5 && assert.ObjectsAreEqual([]string{"target"}, d.GrantLogins)
+		})).Return(true, nil).Once()
+
+		svc.CacheDocumentVersion(ctx, documentUUID, ownerUUID, 5)
+
+		mockCache.AssertExpectations(t)
+		mockCache.AssertNotCalled(t, "InvalidateDocumentVersion", mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("Изменение в обход сервиса — перечитана версия старше изменения, документ инвалидируется", func(t *testing.T) {
+		svc, mockDocRepo, mockCache, mockGrantRepo, exec := newTestWriteThroughService(ctx, true)
+		mockDocRepo.On("GetByUUID", ctx, exec, documentUUID, ownerUUID).Return(&model.Document{UUID: documentUUID, Version: 4}, []string{}, nil).Once()
+		mockGrantRepo.On("ListGrants", ctx, exec, documentUUID).Return([]string{}, nil).Once()
+		mockCache.On("InvalidateDocumentVersion", ctx, documentUUID, int64(5)).Return(nil).Once()
+
+		svc.CacheDocumentVersion(ctx, documentUUID, ownerUUID, 5)
+
+		mockCache.AssertExpectations(t)
+		mockCache.AssertNotCalled(t, "StoreDocumentVersion", mock.Anything, mock.Anything)
+	})
+
+	t.Run("Изменение в обход сервиса без write-through — только инвалидация версии", func(t *testing.T) {
+		svc, mockDocRepo, mockCache, _, _ := newTestWriteThroughService(ctx, false)
+		mockCache.On("InvalidateDocumentVersion", ctx, documentUUID, int64(5)).Return(nil).Once()
+
+		svc.CacheDocumentVersion(ctx, documentUUID, ownerUUID, 5)
+
+		mockCache.AssertExpectations(t)
+		mockDocRepo.AssertNotCalled(t, "BeginTX", mock.Anything)
+	})
+
+	t.Run("Удаление оставляет метку в кэше", func(t *testing.T) {
+		svc, mockDocRepo, mockCache, _, exec := newTestWriteThroughService(ctx, false)
+		mockDocRepo.On("GetByUUID", ctx, exec, documentUUID, ownerUUID).Return(&model.Document{UUID: documentUUID, OwnerUUID: ownerUUID}, []string{}, nil).Once()
+		mockDocRepo.On("Delete", ctx, exec, documentUUID, ownerUUID).Return(documentUUID, nil).Once()
+		mockCache.On("TombstoneDocument", ctx, documentUUID).Return(nil).Once()
+
+		_, err := svc.DeleteDocument(ctx, documentUUID, ownerUUID)
+
+		require.NoError(t, err)
+		mockCache.AssertExpectations(t)
+		mockCache.AssertNotCalled(t, "DeleteDocument", mock.Anything, mock.Anything)
+	})
+}
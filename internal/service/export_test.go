@@ -0,0 +1,6 @@
+package service
+
+// OnDocumentLoadWait : сообщает тесту, что запрос ждёт уже идущую загрузку документа
+func (s *DocumentService) OnDocumentLoadWait(onWait func(documentUUID string)) {
+	s.documentLoads.onWait = onWait
+}
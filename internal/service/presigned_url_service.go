@@ -0,0 +1,56 @@
+package service
+
+import (
+	"caching-web-server/internal/model"
+	"caching-web-server/internal/ports"
+	"caching-web-server/internal/util"
+	"context"
+	"log"
+	"time"
+)
+
+// listPresignedURLTTL : срок, на который выдаются ссылки в списке документов
+const listPresignedURLTTL = 15 * time.Minute
+
+// attachGetURL : добавляет к результату ссылку на файл и момент её истечения
+func (s *DocumentService) attachGetURL(ctx context.Context, result *model.GetDocumentResult) error {
+	if result.Document.StoragePath == "" {
+		return nil
+	}
+
+	presigned, err := s.presignedGetURL(ctx, result.Document.StoragePath, s.ttl)
+	if err != nil {
+		return util.LogError("[DocumentService] не удалось сгенерировать pre-signed GET URL", err)
+	}
+	result.GetURL = presigned.URL
+	result.URLExpiresAt = presigned.ExpiresAt
+	return nil
+}
+
+// presignedGetURL : pre-signed GET ссылка на объект на срок expire; закэшированная отдаётся, пока ей осталось
+// достаточно времени, поэтому реальный срок действия берётся из ExpiresAt, а не из expire
+func (s *DocumentService) presignedGetURL(ctx context.Context, storagePath string, expire time.Duration) (*model.PresignedURL, error) {
+	presigns, cacheable := s.cacheRepository.(ports.PresignedURLCache)
+	if cacheable {
+		cached, err := presigns.GetPresignedURL(ctx, storagePath, expire)
+		if err != nil {
+			log.Printf("[DocumentService] ошибка чтения pre-signed ссылки из кэша: %v", err)
+		} else if cached != nil {
+			return cached, nil
+		}
+	}
+
+	expiresAt := time.Now().Add(expire)
+	url, err := s.storageInterface.GeneratePresignedGetURL(ctx, storagePath, expire)
+	if err != nil {
+		return nil, err
+	}
+
+	presigned := &model.PresignedURL{URL: url, ExpiresAt: expiresAt}
+	if cacheable {
+		if err := presigns.SetPresignedURL(ctx, storagePath, expire, presigned); err != nil {
+			log.Printf("[DocumentService] ошибка сохранения pre-signed ссылки в кэш: %v", err)
+		}
+	}
+	return presigned, nil
+}
@@ -0,0 +1,61 @@
+package service_test
+
+import (
+	"caching-web-server/config"
+	"caching-web-server/internal/model"
+	"caching-web-server/internal/security"
+	"caching-web-server/internal/service"
+	"context"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+// newTestPresignedURLService : сервис документов поверх кэша pre-signed ссылок
+func newTestPresignedURLService() (*service.DocumentService, *MockDocumentRepository, *MockS3Storage, *MockPublicCacheRepository, *MockGrantRepository) {
+	mockDocRepo := new(MockDocumentRepository)
+	mockStorage := new(MockS3Storage)
+	mockCache := new(MockPublicCacheRepository)
+	mockGrantRepo := new(MockGrantRepository)
+	svc := service.NewDocumentService(mockDocRepo, mockCache, mockGrantRepo, mockStorage, nil, nil, nil, time.Minute)
+	return svc, mockDocRepo, mockStorage, mockCache, mockGrantRepo
+}
+
+func TestPresignedURLCache(t *testing.T) {
+	ctx := context.WithValue(context.Background(), security.UserContextKey, &security.Claims{UserUUID: "user1"})
+	ctx = context.WithValue(ctx, "db", &config.Database{})
+	doc := &model.Document{UUID: "doc1", OwnerUUID: "user1", StoragePath: "docs/doc1.txt"}
+
+	t.Run("Ссылка из кэша — срок действия берётся из неё", func(t *testing.T) {
+		svc, _, mockStorage, mockCache, _ := newTestPresignedURLService()
+		expiresAt := time.Now().Add(40 * time.Second)
+		mockCache.On("GetDocument", ctx, "doc1").Return(doc, nil).Once()
+		mockCache.On("GetPresignedURL", ctx, "docs/doc1.txt", time.Minute).Return(&model.PresignedURL{URL: "http://cached-url", ExpiresAt: expiresAt}, nil).Once()
+
+		res, err := svc.GetDocumentByUUID(ctx, "doc1")
+
+		require.NoError(t, err)
+		assert.Equal(t, "http://cached-url", res.GetURL)
+		assert.Equal(t, expiresAt, res.URLExpiresAt)
+		mockStorage.AssertNotCalled(t, "GeneratePresignedGetURL", mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("Список документов использует собственный срок ссылок", func(t *testing.T) {
+		svc, mockDocRepo, mockStorage, mockCache, mockGrantRepo := newTestPresignedURLService()
+		mockDocRepo.On("ListDocuments", ctx, mock.Anything, "user1", "", "", "", 10).Return([]model.Document{*doc}, nil).Once()
+		mockGrantRepo.On("ListGrants", ctx, mock.Anything, "doc1").Return([]string{}, nil).Once()
+		mockCache.On("GetPresignedURL", ctx, "docs/doc1.txt", 15*time.Minute).Return(nil, nil).Once()
+		mockStorage.On("GeneratePresignedGetURL", ctx, "docs/doc1.txt", 15*time.Minute).Return("http://list-url", nil).Once()
+		mockCache.On("SetPresignedURL", ctx, "docs/doc1.txt", 15*time.Minute, mock.Anything).Return(nil).Once()
+
+		res, _, err := svc.ListDocuments(ctx, "user1", "", "", "", 10)
+
+		require.NoError(t, err)
+		require.Len(t, res, 1)
+		assert.Equal(t, "http://list-url", res[0].PresignedURL)
+		mockCache.AssertExpectations(t)
+		mockStorage.AssertExpectations(t)
+	})
+}
@@ -0,0 +1,69 @@
+package service
+
+import (
+	"caching-web-server/internal/model"
+	"caching-web-server/internal/ports"
+	"context"
+	"log"
+)
+
+// cachedPublicDocument : публичный документ из кэша по UUID или токену. Копия годится, только если документ
+// всё ещё публичный и его текущий токен совпадает с запрошенным — поэтому соответствие токена, оставшееся
+// после смены токена или is_public, ни к чему не приводит
+func (s *DocumentService) cachedPublicDocument(ctx context.Context, documentUUID string, token string) *model.Document {
+	public, ok := s.cacheRepository.(ports.PublicDocumentCache)
+	if !ok {
+		return nil
+	}
+
+	if token != "" {
+		uuid, err := public.GetPublicTokenUUID(ctx, token)
+		if err != nil {
+			log.Printf("[DocumentService] ошибка чтения токена из кэша: %v", err)
+			return nil
+		}
+		if uuid == "" {
+			return nil
+		}
+		documentUUID = uuid
+	}
+
+	document, err := public.GetPublicDocument(ctx, documentUUID)
+	if err != nil {
+		log.Printf("[DocumentService] ошибка чтения публичного документа из кэша: %v", err)
+		return nil
+	}
+	if document == nil || document.IsPublic == false || (token != "" && document.AccessToken != token) {
+		return nil
+	}
+	return document
+}
+
+// publicProjection : то, что видит любой по публичной ссылке, — документ без списка grant
+func publicProjection(document *model.Document) *model.Document {
+	if document == nil {
+		return nil
+	}
+	public := document.Clone()
+	public.GrantLogins = nil
+	return public
+}
+
+// rememberPublicDocument : кэширует публичную копию документа и соответствие его токена.
+// Запись документа владельца не трогается — в ней есть grant, которых в публичной копии нет
+func (s *DocumentService) rememberPublicDocument(ctx context.Context, document *model.Document) {
+	public, ok := s.cacheRepository.(ports.PublicDocumentCache)
+	if !ok || document == nil || document.IsPublic == false {
+		return
+	}
+
+	if err := public.SetPublicDocument(ctx, document); err != nil {
+		log.Printf("[DocumentService] ошибка сохранения публичного документа в кэш: %v", err)
+		return
+	}
+	if document.AccessToken != "" {
+		if err := public.SetPublicTokenUUID(ctx, document.AccessToken, document.UUID); err != nil {
+			log.Printf("[DocumentService] ошибка сохранения токена в кэш: %v", err)
+		}
+	}
+}
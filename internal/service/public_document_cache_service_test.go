@@ -0,0 +1,136 @@
+package service_test
+
+import (
+	"caching-web-server/internal/model"
+	"caching-web-server/internal/service"
+	"context"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+// MockPublicCacheRepository : кэш с публичными копиями документов, их токенами и pre-signed ссылками
+type MockPublicCacheRepository struct{ MockCacheRepository }
+
+func (m *MockPublicCacheRepository) GetPublicDocument(ctx context.Context, uuid string) (*model.Document, error) {
+	args := m.Called(ctx, uuid)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.Document), args.Error(1)
+}
+
+func (m *MockPublicCacheRepository) SetPublicDocument(ctx context.Context, document *model.Document) error {
+	return m.Called(ctx, document).Error(0)
+}
+
+func (m *MockPublicCacheRepository) GetPublicTokenUUID(ctx context.Context, token string) (string, error) {
+	args := m.Called(ctx, token)
+	return args.String(0), args.Error(1)
+}
+
+func (m *MockPublicCacheRepository) SetPublicTokenUUID(ctx context.Context, token string, uuid string) error {
+	return m.Called(ctx, token, uuid).Error(0)
+}
+
+func (m *MockPublicCacheRepository) GetPresignedURL(ctx context.Context, storagePath string, expire time.Duration) (*model.PresignedURL, error) {
+	args := m.Called(ctx, storagePath, expire)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.PresignedURL), args.Error(1)
+}
+
+func (m *MockPublicCacheRepository) SetPresignedURL(ctx context.Context, storagePath string, expire time.Duration, url *model.PresignedURL) error {
+	return m.Called(ctx, storagePath, expire, url).Error(0)
+}
+
+// newTestPublicCacheService : сервис документов поверх кэша публичных документов
+func newTestPublicCacheService() (*service.DocumentService, *MockDocumentRepository, *MockS3Storage, *MockPublicCacheRepository) {
+	mockDocRepo := new(MockDocumentRepository)
+	mockStorage := new(MockS3Storage)
+	mockCache := new(MockPublicCacheRepository)
+	svc := service.NewDocumentService(mockDocRepo, mockCache, new(MockGrantRepository), mockStorage, nil, nil, nil, time.Minute)
+	return svc, mockDocRepo, mockStorage, mockCache
+}
+
+func TestPublicDocumentCache(t *testing.T) {
+	ctx := context.Background()
+	doc := &model.Document{UUID: "doc1", StoragePath: "docs/doc1.txt", IsPublic: true, AccessToken: "token1"}
+
+	t.Run("Документ и ссылка по токену из кэша — без БД и подписи", func(t *testing.T) {
+		svc, mockDocRepo, mockStorage, mockCache := newTestPublicCacheService()
+		mockCache.On("GetPublicTokenUUID", ctx, "token1").Return("doc1", nil).Once()
+		mockCache.On("GetPublicDocument", ctx, "doc1").Return(doc, nil).Once()
+		mockCache.On("GetPresignedURL", ctx, "docs/doc1.txt", time.Minute).Return(&model.PresignedURL{URL: "http://cached-url", ExpiresAt: time.Now().Add(time.Minute)}, nil).Once()
+
+		res, err := svc.GetPublicDocument(ctx, "", "token1")
+
+		require.NoError(t, err)
+		assert.Equal(t, doc, res.Document)
+		assert.Equal(t, "http://cached-url", res.GetURL)
+		mockDocRepo.AssertNotCalled(t, "BeginTX", mock.Anything)
+		mockStorage.AssertNotCalled(t, "GeneratePresignedGetURL", mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("Токен сменился — соответствие из кэша игнорируется", func(t *testing.T) {
+		svc, mockDocRepo, mockStorage, mockCache := newTestPublicCacheService()
+		rotated := &model.Document{UUID: "doc1", StoragePath: "docs/doc1.txt", IsPublic: true, AccessToken: "token2"}
+		mockTx := &fakeTx{}
+		mockCache.On("GetPublicTokenUUID", ctx, "token1").Return("doc1", nil).Once()
+		mockCache.On("GetPublicDocument", ctx, "doc1").Return(rotated, nil).Once()
+		mockDocRepo.On("BeginTX", ctx).Return(mockTx, func() error { return nil }, func() error { return nil }, nil).Once()
+		mockDocRepo.On("GetPublicByToken", ctx, mockTx, "token1").Return(nil, errors.New("not found")).Once()
+
+		_, err := svc.GetPublicDocument(ctx, "", "token1")
+
+		require.Error(t, err)
+		mockStorage.AssertNotCalled(t, "GeneratePresignedGetURL", mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("Промах — публичная копия, токен и ссылка сохраняются в кэш", func(t *testing.T) {
+		svc, mockDocRepo, mockStorage, mockCache := newTestPublicCacheService()
+		mockTx := &fakeTx{}
+		mockCache.On("GetPublicDocument", ctx, "doc1").Return(nil, nil).Once()
+		mockDocRepo.On("BeginTX", ctx).Return(mockTx, func() error { return nil }, func() error { return nil }, nil).Once()
+		mockDocRepo.On("GetPublicByUUID", ctx, mockTx, "doc1").Return(doc, nil).Once()
+		mockCache.On("SetPublicDocument", ctx, doc).Return(nil).Once()
+		mockCache.On("SetPublicTokenUUID", ctx, "token1", "doc1").Return(nil).Once()
+		mockCache.On("GetPresignedURL", ctx, "docs/doc1.txt", time.Minute).Return(nil, nil).Once()
+		mockStorage.On("GeneratePresignedGetURL", ctx, "docs/doc1.txt", time.Minute).Return("http://get-url", nil).Once()
+		mockCache.On("SetPresignedURL", ctx, "docs/doc1.txt", time.Minute, mock.MatchedBy(func(url *model.PresignedURL) bool {
+			return url.URL == "http://get-url" && time.Until(url.ExpiresAt) > 0
+		})).Return(nil).Once()
+
+		res, err := svc.GetPublicDocument(ctx, "doc1", "")
+
+		require.NoError(t, err)
+		assert.Equal(t, "http://get-url", res.GetURL)
+		mockCache.AssertExpectations(t)
+		// запись документа владельца с grant публичным запросом не перезаписывается
+		mockCache.AssertNotCalled(t, "SetDocument", mock.Anything, mock.Anything)
+	})
+
+	t.Run("Публичный ответ не раскрывает grant", func(t *testing.T) {
+		svc, mockDocRepo, mockStorage, mockCache := newTestPublicCacheService()
+		shared := &model.Document{UUID: "doc2", StoragePath: "docs/doc2.txt", IsPublic: true, AccessToken: "token2", GrantLogins: []string{"alice"}}
+		mockTx := &fakeTx{}
+		mockCache.On("GetPublicDocument", ctx, "doc2").Return(nil, nil).Once()
+		mockDocRepo.On("BeginTX", ctx).Return(mockTx, func() error { return nil }, func() error { return nil }, nil).Once()
+		mockDocRepo.On("GetPublicByUUID", ctx, mockTx, "doc2").Return(shared, nil).Once()
+		mockCache.On("SetPublicDocument", ctx, shared).Return(nil).Once()
+		mockCache.On("SetPublicTokenUUID", ctx, "token2", "doc2").Return(nil).Once()
+		mockCache.On("GetPresignedURL", ctx, "docs/doc2.txt", time.Minute).Return(nil, nil).Once()
+		mockStorage.On("GeneratePresignedGetURL", ctx, "docs/doc2.txt", time.Minute).Return("http://get-url", nil).Once()
+		mockCache.On("SetPresignedURL", ctx, "docs/doc2.txt", time.Minute, mock.Anything).Return(nil).Once()
+
+		res, err := svc.GetPublicDocument(ctx, "doc2", "")
+
+		require.NoError(t, err)
+		assert.Nil(t, res.Document.GrantLogins)
+		assert.Equal(t, []string{"alice"}, shared.GrantLogins)
+	})
+}
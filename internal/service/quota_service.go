@@ -0,0 +1,133 @@
+package service
+
+import (
+	"caching-web-server/config"
+	"caching-web-server/internal/model"
+	"caching-web-server/internal/ports"
+	"caching-web-server/internal/security"
+	"caching-web-server/internal/util"
+	"context"
+	"fmt"
+	"github.com/jmoiron/sqlx"
+)
+
+type QuotaService struct {
+	quotaRepository ports.QuotaRepository
+	defaultBytes    int64
+}
+
+func NewQuotaService(quotaRepository ports.QuotaRepository, quotaConfig *config.QuotaConfig) *QuotaService {
+	var defaultBytes int64
+	if quotaConfig != nil {
+		defaultBytes = quotaConfig.DefaultBytes
+	}
+	return &QuotaService{
+		quotaRepository: quotaRepository,
+		defaultBytes:    defaultBytes,
+	}
+}
+
+// CheckQuota : проверяет, что после добавления additionalBytes пользователь не выйдет за квоту.
+// Должна вызываться внутри транзакции, в которой затем создаётся документ: блокировка держится до коммита
+func (s *QuotaService) CheckQuota(ctx context.Context, exec sqlx.ExtContext, userUUID string, additionalBytes int64) error {
+	quotaBytes, err := s.effectiveQuota(ctx, exec, userUUID)
+	if err != nil {
+		return err
+	}
+	if quotaBytes == 0 {
+		return nil
+	}
+
+	if err := s.quotaRepository.LockUsage(ctx, exec, userUUID); err != nil {
+		return err
+	}
+
+	used, err := s.quotaRepository.GetUsedBytes(ctx, exec, userUUID)
+	if err != nil {
+		return err
+	}
+
+	if used+additionalBytes > quotaBytes {
+		return fmt.Errorf("[QuotaService] превышена квота хранилища: занято %d из %d байт, требуется ещё %d", used, quotaBytes, additionalBytes)
+	}
+
+	return nil
+}
+
+// GetUsage : использование хранилища пользователем. Доступно самому пользователю и с правом users:read
+func (s *QuotaService) GetUsage(ctx context.Context, userUUID string) (*model.StorageUsage, error) {
+	claims, err := security.GetClaimsFromContext(ctx)
+	if err != nil || claims == nil {
+		return nil, fmt.Errorf("[QuotaService] пользователь не авторизован")
+	}
+
+	if claims.Can(model.PermissionUsersRead) == false && claims.UserUUID != userUUID {
+		return nil, fmt.Errorf("[QuotaService] доступ запрещён")
+	}
+
+	db, ok := ctx.Value("db").(*config.Database)
+	if !ok {
+		return nil, fmt.Errorf("[QuotaService] database connection не найден в context")
+	}
+
+	byMime, err := s.quotaRepository.GetUsageByMime(ctx, db, userUUID)
+	if err != nil {
+		return nil, util.LogError("[QuotaService] не удалось получить использование хранилища", err)
+	}
+
+	quotaBytes, err := s.effectiveQuota(ctx, db, userUUID)
+	if err != nil {
+		return nil, err
+	}
+
+	usage := &model.StorageUsage{
+		UserUUID:   userUUID,
+		QuotaBytes: quotaBytes,
+		ByMime:     byMime,
+	}
+	for _, item := range byMime {
+		usage.UsedBytes += item.Bytes
+		usage.Count += item.Count
+	}
+
+	return usage, nil
+}
+
+// SetQuota : устанавливает индивидуальную квоту пользователя (право users:manage).
+// nil сбрасывает квоту к значению по умолчанию из конфига
+func (s *QuotaService) SetQuota(ctx context.Context, userUUID string, quotaBytes *int64) error {
+	claims, err := security.GetClaimsFromContext(ctx)
+	if err != nil || claims == nil {
+		return fmt.Errorf("[QuotaService] пользователь не авторизован")
+	}
+
+	if claims.Can(model.PermissionUsersManage) == false {
+		return fmt.Errorf("[QuotaService] доступ запрещён")
+	}
+
+	db, ok := ctx.Value("db").(*config.Database)
+	if !ok {
+		return fmt.Errorf("[QuotaService] database connection не найден в context")
+	}
+
+	if quotaBytes == nil {
+		return s.quotaRepository.DeleteQuota(ctx, db, userUUID)
+	}
+
+	if *quotaBytes < 0 {
+		return fmt.Errorf("[QuotaService] квота не может быть отрицательной")
+	}
+
+	return s.quotaRepository.SetQuota(ctx, db, userUUID, *quotaBytes)
+}
+
+func (s *QuotaService) effectiveQuota(ctx context.Context, exec sqlx.ExtContext, userUUID string) (int64, error) {
+	override, err := s.quotaRepository.GetQuota(ctx, exec, userUUID)
+	if err != nil {
+		return 0, err
+	}
+	if override != nil {
+		return *override, nil
+	}
+	return s.defaultBytes, nil
+}
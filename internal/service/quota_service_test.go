@@ -0,0 +1,181 @@
+package service_test
+
+import (
+	"caching-web-server/config"
+	"caching-web-server/internal/model"
+	"caching-web-server/internal/security"
+	"caching-web-server/internal/service"
+	"context"
+	"github.com/jmoiron/sqlx"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+type MockQuotaRepository struct{ mock.Mock }
+
+func (m *MockQuotaRepository) GetQuota(ctx context.Context, exec sqlx.ExtContext, userUUID string) (*int64, error) {
+	args := m.Called(ctx, exec, userUUID)
+	if quota, ok := args.Get(0).(*int64); ok {
+		return quota, args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *MockQuotaRepository) SetQuota(ctx context.Context, exec sqlx.ExtContext, userUUID string, quotaBytes int64) error {
+	return m.Called(ctx, exec, userUUID, quotaBytes).Error(0)
+}
+
+func (m *MockQuotaRepository) DeleteQuota(ctx context.Context, exec sqlx.ExtContext, userUUID string) error {
+	return m.Called(ctx, exec, userUUID).Error(0)
+}
+
+func (m *MockQuotaRepository) LockUsage(ctx context.Context, exec sqlx.ExtContext, userUUID string) error {
+	return m.Called(ctx, exec, userUUID).Error(0)
+}
+
+func (m *MockQuotaRepository) GetUsedBytes(ctx context.Context, exec sqlx.ExtContext, userUUID string) (int64, error) {
+	args := m.Called(ctx, exec, userUUID)
+	return args.Get(0).(int64), args.Error(1)
+}
+
+func (m *MockQuotaRepository) GetUsageByMime(ctx context.Context, exec sqlx.ExtContext, userUUID string) ([]model.MimeUsage, error) {
+	args := m.Called(ctx, exec, userUUID)
+	if usage, ok := args.Get(0).([]model.MimeUsage); ok {
+		return usage, args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func TestCheckQuota(t *testing.T) {
+	ctx := context.Background()
+	tx := &fakeTx{}
+	override := int64(500)
+
+	tests := []struct {
+		name      string
+		override  *int64
+		used      int64
+		size      int64
+		expectErr bool
+	}{
+		{name: "в пределах квоты по умолчанию", used: 600, size: 400},
+		{name: "превышение квоты по умолчанию", used: 600, size: 401, expectErr: true},
+		{name: "индивидуальная квота", override: &override, used: 400, size: 101, expectErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := new(MockQuotaRepository)
+			svc := service.NewQuotaService(repo, &config.QuotaConfig{DefaultBytes: 1000})
+
+			repo.On("GetQuota", ctx, tx, "user1").Return(tt.override, nil).Once()
+			repo.On("LockUsage", ctx, tx, "user1").Return(nil).Once()
+			repo.On("GetUsedBytes", ctx, tx, "user1").Return(tt.used, nil).Once()
+
+			err := svc.CheckQuota(ctx, tx, "user1", tt.size)
+			if tt.expectErr {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), "превышена квота хранилища")
+			} else {
+				assert.NoError(t, err)
+			}
+			repo.AssertExpectations(t)
+		})
+	}
+
+	t.Run("без ограничений", func(t *testing.T) {
+		repo := new(MockQuotaRepository)
+		svc := service.NewQuotaService(repo, &config.QuotaConfig{})
+
+		repo.On("GetQuota", ctx, tx, "user1").Return(nil, nil).Once()
+
+		assert.NoError(t, svc.CheckQuota(ctx, tx, "user1", 1<<40))
+		repo.AssertNotCalled(t, "GetUsedBytes", mock.Anything, mock.Anything, mock.Anything)
+	})
+}
+
+func TestGetUsage(t *testing.T) {
+	db := &config.Database{}
+
+	t.Run("владелец получает статистику", func(t *testing.T) {
+		repo := new(MockQuotaRepository)
+		svc := service.NewQuotaService(repo, &config.QuotaConfig{DefaultBytes: 1000})
+
+		ctx := context.WithValue(context.Background(), security.UserContextKey, &security.Claims{UserUUID: "user1"})
+		ctx = context.WithValue(ctx, "db", db)
+
+		repo.On("GetUsageByMime", ctx, db, "user1").Return([]model.MimeUsage{
+			{MimeType: "application/pdf", Bytes: 300, Count: 2},
+			{MimeType: "text/plain", Bytes: 20, Count: 5},
+		}, nil).Once()
+		repo.On("GetQuota", ctx, db, "user1").Return(nil, nil).Once()
+
+		usage, err := svc.GetUsage(ctx, "user1")
+		require.NoError(t, err)
+		assert.Equal(t, int64(320), usage.UsedBytes)
+		assert.Equal(t, int64(7), usage.Count)
+		assert.Equal(t, int64(1000), usage.QuotaBytes)
+		assert.Len(t, usage.ByMime, 2)
+	})
+
+	t.Run("чужая статистика запрещена", func(t *testing.T) {
+		repo := new(MockQuotaRepository)
+		svc := service.NewQuotaService(repo, &config.QuotaConfig{})
+
+		ctx := context.WithValue(context.Background(), security.UserContextKey, &security.Claims{UserUUID: "user2"})
+		ctx = context.WithValue(ctx, "db", db)
+
+		_, err := svc.GetUsage(ctx, "user1")
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "доступ запрещён")
+	})
+}
+
+func TestSetQuota_OnlyAdmin(t *testing.T) {
+	db := &config.Database{}
+	repo := new(MockQuotaRepository)
+	svc := service.NewQuotaService(repo, &config.QuotaConfig{})
+	quota := int64(2048)
+
+	userCtx := context.WithValue(context.Background(), security.UserContextKey, &security.Claims{UserUUID: "user1"})
+	userCtx = context.WithValue(userCtx, "db", db)
+	err := svc.SetQuota(userCtx, "user1", &quota)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "доступ запрещён")
+
+	adminCtx := context.WithValue(context.Background(), security.UserContextKey, &security.Claims{UserUUID: "admin", IsAdmin: true})
+	adminCtx = context.WithValue(adminCtx, "db", db)
+	repo.On("SetQuota", adminCtx, db, "user1", quota).Return(nil).Once()
+	repo.On("DeleteQuota", adminCtx, db, "user1").Return(nil).Once()
+
+	assert.NoError(t, svc.SetQuota(adminCtx, "user1", &quota))
+	assert.NoError(t, svc.SetQuota(adminCtx, "user1", nil))
+	repo.AssertExpectations(t)
+}
+
+func TestCreateDocument_QuotaExceeded(t *testing.T) {
+	mockDocRepo := new(MockDocumentRepository)
+	mockStorage := new(MockS3Storage)
+	quotaRepo := new(MockQuotaRepository)
+	quotaService := service.NewQuotaService(quotaRepo, &config.QuotaConfig{DefaultBytes: 100})
+
+	svc := service.NewDocumentService(mockDocRepo, new(MockCacheRepository), nil, mockStorage, nil, quotaService, nil, time.Hour)
+
+	ctx := context.WithValue(context.Background(), "db", &config.Database{})
+	doc := &model.Document{UUID: "doc1", OwnerUUID: "user1", SizeBytes: 60, StoragePath: "users/user1/documents/a"}
+
+	tx := &fakeTx{}
+	mockStorage.On("GeneratePresignedPutURL", ctx, doc.StoragePath, time.Hour).Return("http://put-url", nil).Once()
+	mockDocRepo.On("BeginTX", ctx).Return(tx, func() error { return nil }, func() error { return nil }, nil).Once()
+	quotaRepo.On("GetQuota", ctx, tx, "user1").Return(nil, nil).Once()
+	quotaRepo.On("LockUsage", ctx, tx, "user1").Return(nil).Once()
+	quotaRepo.On("GetUsedBytes", ctx, tx, "user1").Return(int64(50), nil).Once()
+
+	_, err := svc.CreateDocument(ctx, doc)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "превышена квота хранилища")
+	mockDocRepo.AssertNotCalled(t, "Create", mock.Anything, mock.Anything, mock.Anything)
+}
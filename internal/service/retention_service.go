@@ -0,0 +1,215 @@
+package service
+
+import (
+	"caching-web-server/config"
+	"caching-web-server/internal/model"
+	"caching-web-server/internal/ports"
+	"caching-web-server/internal/security"
+	"caching-web-server/internal/util"
+	"context"
+	"fmt"
+	"github.com/google/uuid"
+	"log"
+	"strings"
+	"time"
+)
+
+type RetentionService struct {
+	retentionRepository ports.RetentionRepository
+	documentService     ports.DocumentService
+}
+
+func NewRetentionService(
+	retentionRepository ports.RetentionRepository,
+	documentService ports.DocumentService,
+) *RetentionService {
+	return &RetentionService{
+		retentionRepository: retentionRepository,
+		documentService:     documentService,
+	}
+}
+
+// ListPolicies : список политик хранения (право retention:read)
+func (s *RetentionService) ListPolicies(ctx context.Context) ([]model.RetentionPolicy, error) {
+	db, err := s.adminDatabase(ctx, model.PermissionRetentionRead)
+	if err != nil {
+		return nil, err
+	}
+	return s.retentionRepository.ListPolicies(ctx, db)
+}
+
+// SavePolicy : создаёт или обновляет политику хранения для MIME-типа (право retention:manage).
+// Новые документы получают expires_at при создании; applyExisting выставляет срок и уже загруженным документам без срока
+func (s *RetentionService) SavePolicy(ctx context.Context, mimeType string, retentionDays int, applyExisting bool) (*model.RetentionPolicy, error) {
+	db, err := s.adminDatabase(ctx, model.PermissionRetentionManage)
+	if err != nil {
+		return nil, err
+	}
+
+	mimeType = strings.ToLower(strings.TrimSpace(mimeType))
+	if mimeType == "" || strings.Contains(mimeType, "/") == false {
+		return nil, fmt.Errorf("[RetentionService] некорректный MIME-тип политики")
+	}
+	if retentionDays <= 0 {
+		return nil, fmt.Errorf("[RetentionService] срок хранения должен быть больше нуля")
+	}
+
+	policy := &model.RetentionPolicy{
+		UUID:          uuid.New().String(),
+		MimeType:      mimeType,
+		RetentionDays: retentionDays,
+	}
+	if err := s.retentionRepository.SavePolicy(ctx, db, policy); err != nil {
+		return nil, err
+	}
+
+	if applyExisting {
+		affected, err := s.retentionRepository.ApplyPolicy(ctx, db, policy)
+		if err != nil {
+			return nil, err
+		}
+		for _, doc := range affected {
+			s.documentService.CacheDocumentVersion(ctx, doc.UUID, doc.OwnerUUID, int64(doc.Version))
+		}
+		log.Printf("[RetentionService] политика %s применена к %d документам", policy.MimeType, len(affected))
+	}
+
+	return policy, nil
+}
+
+// DeletePolicy : удаляет политику хранения (право retention:manage)
+func (s *RetentionService) DeletePolicy(ctx context.Context, policyUUID string) error {
+	db, err := s.adminDatabase(ctx, model.PermissionRetentionManage)
+	if err != nil {
+		return err
+	}
+	return s.retentionRepository.DeletePolicy(ctx, db, policyUUID)
+}
+
+// SetDocumentExpiry : владелец задаёт или снимает (nil) срок хранения своего документа.
+// Если для MIME-типа документа есть политика, срок нельзя снять или сделать позже, чем требует политика
+func (s *RetentionService) SetDocumentExpiry(ctx context.Context, documentUUID string, expiresAt *time.Time) error {
+	claims, err := security.GetClaimsFromContext(ctx)
+	if err != nil || claims == nil {
+		return fmt.Errorf("[RetentionService] пользователь не авторизован")
+	}
+
+	db, ok := ctx.Value("db").(*config.Database)
+	if !ok {
+		return fmt.Errorf("[RetentionService] database connection не найден в context")
+	}
+
+	if err := s.documentService.EnsureDocumentUnlocked(ctx, documentUUID, claims.UserUUID); err != nil {
+		return err
+	}
+
+	policyExpiry, err := s.retentionRepository.PolicyExpiry(ctx, db, documentUUID, claims.UserUUID)
+	if err != nil {
+		return err
+	}
+	if policyExpiry != nil && (expiresAt == nil || expiresAt.After(*policyExpiry)) {
+		return fmt.Errorf("[RetentionService] срок хранения ограничен политикой: не позже %s", policyExpiry.Format(time.RFC3339))
+	}
+
+	version, err := s.retentionRepository.SetExpiry(ctx, db, documentUUID, claims.UserUUID, expiresAt)
+	if err != nil {
+		return err
+	}
+
+	s.documentService.CacheDocumentVersion(ctx, documentUUID, claims.UserUUID, version)
+	return nil
+}
+
+// SetLegalHold : ставит или снимает юридическое удержание (право retention:manage).
+// Документ на удержании нельзя удалить, в том числе владельцу и по истечении срока хранения
+func (s *RetentionService) SetLegalHold(ctx context.Context, documentUUID string, hold bool) error {
+	db, err := s.adminDatabase(ctx, model.PermissionRetentionManage)
+	if err != nil {
+		return err
+	}
+
+	document, err := s.retentionRepository.SetLegalHold(ctx, db, documentUUID, hold)
+	if err != nil {
+		return err
+	}
+
+	s.documentService.CacheDocumentVersion(ctx, documentUUID, document.OwnerUUID, int64(document.Version))
+	log.Printf("[RetentionService] юридическое удержание документа %s: %t", documentUUID, hold)
+	return nil
+}
+
+// DeleteExpired : удаляет до limit документов с истёкшим сроком хранения через обычный DeleteDocument.
+// Неудачные попытки откладываются с растущим интервалом
+func (s *RetentionService) DeleteExpired(ctx context.Context, limit int) (int, error) {
+	db, ok := ctx.Value("db").(*config.Database)
+	if !ok {
+		return 0, fmt.Errorf("[RetentionService] database connection не найден в context")
+	}
+
+	docs, err := s.retentionRepository.ListExpired(ctx, db, time.Now(), limit)
+	if err != nil {
+		return 0, err
+	}
+
+	deleted := 0
+	for _, doc := range docs {
+		if _, err := s.documentService.DeleteDocument(ctx, doc.UUID, doc.OwnerUUID); err != nil {
+			log.Printf("[RetentionService] не удалось удалить просроченный документ %s: %v", doc.UUID, err)
+			// иначе документ останется в начале выборки и будет мешать удалению остальных
+			if err := s.retentionRepository.DeferExpired(ctx, db, doc.UUID, time.Now()); err != nil {
+				log.Printf("[RetentionService] не удалось отложить удаление документа %s: %v", doc.UUID, err)
+			}
+			continue
+		}
+		deleted++
+	}
+
+	return deleted, nil
+}
+
+// RunExpiryWorker : периодически удаляет просроченные документы, пока не отменён ctx.
+// ctx должен содержать подключение к БД под ключом "db"
+func (s *RetentionService) RunExpiryWorker(ctx context.Context, interval time.Duration, batchSize int) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			// удаляем пачками, пока находятся просроченные документы
+			for {
+				deleted, err := s.DeleteExpired(ctx, batchSize)
+				if err != nil {
+					util.LogError("[RetentionService] ошибка удаления просроченных документов", err)
+					break
+				}
+				if deleted > 0 {
+					log.Printf("[RetentionService] удалено просроченных документов: %d", deleted)
+				}
+				// неудачно удалённые документы отложены и в следующую выборку не попадут, остальное подберёт следующий тик
+				if deleted < batchSize || ctx.Err() != nil {
+					break
+				}
+			}
+		}
+	}
+}
+
+// adminDatabase : проверяет право на политики хранения
+func (s *RetentionService) adminDatabase(ctx context.Context, permission string) (*config.Database, error) {
+	claims, err := security.GetClaimsFromContext(ctx)
+	if err != nil || claims == nil {
+		return nil, fmt.Errorf("[RetentionService] пользователь не авторизован")
+	}
+	if claims.Can(permission) == false {
+		return nil, fmt.Errorf("[RetentionService] доступ запрещён")
+	}
+
+	db, ok := ctx.Value("db").(*config.Database)
+	if !ok {
+		return nil, fmt.Errorf("[RetentionService] database connection не найден в context")
+	}
+	return db, nil
+}
@@ -0,0 +1,220 @@
+package service_test
+
+import (
+	"caching-web-server/config"
+	"caching-web-server/internal/model"
+	"caching-web-server/internal/security"
+	"caching-web-server/internal/service"
+	"context"
+	"github.com/jmoiron/sqlx"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+type MockRetentionRepository struct{ mock.Mock }
+
+func (m *MockRetentionRepository) ListPolicies(ctx context.Context, exec sqlx.ExtContext) ([]model.RetentionPolicy, error) {
+	args := m.Called(ctx, exec)
+	if policies, ok := args.Get(0).([]model.RetentionPolicy); ok {
+		return policies, args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *MockRetentionRepository) SavePolicy(ctx context.Context, exec sqlx.ExtContext, policy *model.RetentionPolicy) error {
+	return m.Called(ctx, exec, policy).Error(0)
+}
+
+func (m *MockRetentionRepository) DeletePolicy(ctx context.Context, exec sqlx.ExtContext, policyUUID string) error {
+	return m.Called(ctx, exec, policyUUID).Error(0)
+}
+
+func (m *MockRetentionRepository) ApplyPolicy(ctx context.Context, exec sqlx.ExtContext, policy *model.RetentionPolicy) ([]model.Document, error) {
+	args := m.Called(ctx, exec, policy)
+	if docs, ok := args.Get(0).([]model.Document); ok {
+		return docs, args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *MockRetentionRepository) PolicyExpiry(ctx context.Context, exec sqlx.ExtContext, documentUUID, ownerUUID string) (*time.Time, error) {
+	args := m.Called(ctx, exec, documentUUID, ownerUUID)
+	if expiresAt, ok := args.Get(0).(*time.Time); ok {
+		return expiresAt, args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *MockRetentionRepository) DeferExpired(ctx context.Context, exec sqlx.ExtContext, documentUUID string, now time.Time) error {
+	return m.Called(ctx, exec, documentUUID, now).Error(0)
+}
+
+func (m *MockRetentionRepository) SetExpiry(ctx context.Context, exec sqlx.ExtContext, documentUUID, ownerUUID string, expiresAt *time.Time) (int64, error) {
+	args := m.Called(ctx, exec, documentUUID, ownerUUID, expiresAt)
+	return args.Get(0).(int64), args.Error(1)
+}
+
+func (m *MockRetentionRepository) SetLegalHold(ctx context.Context, exec sqlx.ExtContext, documentUUID string, hold bool) (*model.Document, error) {
+	args := m.Called(ctx, exec, documentUUID, hold)
+	if document, ok := args.Get(0).(*model.Document); ok {
+		return document, args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *MockRetentionRepository) ListExpired(ctx context.Context, exec sqlx.ExtContext, now time.Time, limit int) ([]model.Document, error) {
+	args := m.Called(ctx, exec, now, limit)
+	if docs, ok := args.Get(0).([]model.Document); ok {
+		return docs, args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func TestDeleteExpired_UsesDeleteDocument(t *testing.T) {
+	db := &config.Database{}
+	ctx := context.WithValue(context.Background(), "db", db)
+
+	docSvc, mockDocRepo, mockStorage, mockCache := newTestDocumentService()
+	retentionRepo := new(MockRetentionRepository)
+	svc := service.NewRetentionService(retentionRepo, docSvc)
+
+	expired := []model.Document{
+		{UUID: "doc1", OwnerUUID: "user1"},
+		{UUID: "doc2", OwnerUUID: "user2"},
+	}
+	retentionRepo.On("ListExpired", ctx, db, mock.AnythingOfType("time.Time"), 10).Return(expired, nil).Once()
+
+	tx := &fakeTx{}
+	mockDocRepo.On("BeginTX", ctx).Return(tx, func() error { return nil }, func() error { return nil }, nil)
+	mockDocRepo.On("GetByUUID", ctx, tx, "doc1", "user1").Return(&model.Document{
+		UUID: "doc1", OwnerUUID: "user1", StoragePath: "users/user1/documents/a",
+	}, []string{}, nil).Once()
+	// doc2 поставили на удержание уже после выборки — DeleteDocument должен отказать
+	mockDocRepo.On("GetByUUID", ctx, tx, "doc2", "user2").Return(&model.Document{
+		UUID: "doc2", OwnerUUID: "user2", LegalHold: true,
+	}, []string{}, nil).Once()
+	mockDocRepo.On("Delete", ctx, tx, "doc1", "user1").Return("doc1", nil).Once()
+	// неудачная попытка откладывается, чтобы doc2 не занимал начало следующих выборок
+	retentionRepo.On("DeferExpired", ctx, db, "doc2", mock.AnythingOfType("time.Time")).Return(nil).Once()
+	mockCache.On("DeleteDocument", ctx, "doc1").Return(nil).Once()
+	mockStorage.On("DeleteObject", ctx, "users/user1/documents/a").Return(nil).Once()
+
+	deleted, err := svc.DeleteExpired(ctx, 10)
+
+	require.NoError(t, err)
+	assert.Equal(t, 1, deleted)
+	mockDocRepo.AssertNotCalled(t, "Delete", ctx, tx, "doc2", "user2")
+	mockDocRepo.AssertExpectations(t)
+	mockStorage.AssertExpectations(t)
+	retentionRepo.AssertExpectations(t)
+}
+
+func TestSetLegalHold_OnlyAdmin(t *testing.T) {
+	db := &config.Database{}
+	docSvc, _, _, mockCache := newTestDocumentService()
+	retentionRepo := new(MockRetentionRepository)
+	svc := service.NewRetentionService(retentionRepo, docSvc)
+
+	userCtx := context.WithValue(context.Background(), security.UserContextKey, &security.Claims{UserUUID: "user1"})
+	userCtx = context.WithValue(userCtx, "db", db)
+	err := svc.SetLegalHold(userCtx, "doc1", true)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "доступ запрещён")
+
+	adminCtx := context.WithValue(context.Background(), security.UserContextKey, &security.Claims{UserUUID: "admin", IsAdmin: true})
+	adminCtx = context.WithValue(adminCtx, "db", db)
+	retentionRepo.On("SetLegalHold", adminCtx, db, "doc1", true).Return(&model.Document{UUID: "doc1", OwnerUUID: "user1", Version: 2}, nil).Once()
+	mockCache.On("DeleteDocument", adminCtx, "doc1").Return(nil).Once()
+
+	assert.NoError(t, svc.SetLegalHold(adminCtx, "doc1", true))
+	retentionRepo.AssertExpectations(t)
+	mockCache.AssertExpectations(t)
+}
+
+func TestSavePolicy_Validation(t *testing.T) {
+	db := &config.Database{}
+	retentionRepo := new(MockRetentionRepository)
+	docSvc, _, _, mockCache := newTestDocumentService()
+	svc := service.NewRetentionService(retentionRepo, docSvc)
+
+	ctx := context.WithValue(context.Background(), security.UserContextKey, &security.Claims{UserUUID: "admin", IsAdmin: true})
+	ctx = context.WithValue(ctx, "db", db)
+
+	_, err := svc.SavePolicy(ctx, "pdf", 30, false)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "некорректный MIME-тип")
+
+	_, err = svc.SavePolicy(ctx, "image/*", 0, false)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "срок хранения должен")
+
+	retentionRepo.On("SavePolicy", ctx, db, mock.AnythingOfType("*model.RetentionPolicy")).Return(nil).Once()
+	retentionRepo.On("ApplyPolicy", ctx, db, mock.AnythingOfType("*model.RetentionPolicy")).Return([]model.Document{
+		{UUID: "doc1", OwnerUUID: "user1", Version: 4},
+		{UUID: "doc2", OwnerUUID: "user2", Version: 2},
+	}, nil).Once()
+	// срок хранения изменился в БД — закэшированные копии с прежним expires_at удаляются
+	mockCache.On("DeleteDocument", ctx, "doc1").Return(nil).Once()
+	mockCache.On("DeleteDocument", ctx, "doc2").Return(nil).Once()
+
+	policy, err := svc.SavePolicy(ctx, " Image/* ", 30, true)
+	require.NoError(t, err)
+	assert.Equal(t, "image/*", policy.MimeType)
+	retentionRepo.AssertExpectations(t)
+	mockCache.AssertExpectations(t)
+}
+
+func TestSetDocumentExpiry_PolicyLimit(t *testing.T) {
+	db := &config.Database{}
+	ctx := context.WithValue(context.Background(), security.UserContextKey, &security.Claims{UserUUID: "user1"})
+	ctx = context.WithValue(ctx, "db", db)
+
+	docSvc, _, _, mockCache := newTestDocumentService()
+	retentionRepo := new(MockRetentionRepository)
+	svc := service.NewRetentionService(retentionRepo, docSvc)
+
+	policyExpiry := time.Now().Add(30 * 24 * time.Hour)
+	retentionRepo.On("PolicyExpiry", ctx, db, "doc1", "user1").Return(&policyExpiry, nil)
+
+	err := svc.SetDocumentExpiry(ctx, "doc1", nil)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "ограничен политикой")
+
+	later := policyExpiry.Add(time.Hour)
+	err = svc.SetDocumentExpiry(ctx, "doc1", &later)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "ограничен политикой")
+	retentionRepo.AssertNotCalled(t, "SetExpiry", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+
+	earlier := policyExpiry.Add(-time.Hour)
+	retentionRepo.On("SetExpiry", ctx, db, "doc1", "user1", &earlier).Return(int64(3), nil).Once()
+	mockCache.On("DeleteDocument", ctx, "doc1").Return(nil).Once()
+
+	require.NoError(t, svc.SetDocumentExpiry(ctx, "doc1", &earlier))
+	retentionRepo.AssertExpectations(t)
+}
+
+func TestSetLegalHold_WriteThrough(t *testing.T) {
+	db := &config.Database{}
+	ctx := context.WithValue(context.Background(), security.UserContextKey, &security.Claims{UserUUID: "admin", IsAdmin: true})
+	ctx = context.WithValue(ctx, "db", db)
+
+	docSvc, mockDocRepo, mockCache, mockGrantRepo, exec := newTestWriteThroughService(ctx, true)
+	retentionRepo := new(MockRetentionRepository)
+	svc := service.NewRetentionService(retentionRepo, docSvc)
+
+	retentionRepo.On("SetLegalHold", ctx, db, "doc1", true).Return(&model.Document{UUID: "doc1", OwnerUUID: "user1", Version: 3}, nil).Once()
+	// удержание ставит администратор — документ перечитывается от имени владельца
+	mockDocRepo.On("GetByUUID", ctx, exec, "doc1", "user1").Return(&model.Document{UUID: "doc1", OwnerUUID: "user1", LegalHold: true, Version: 3}, []string{}, nil).Once()
+	mockGrantRepo.On("ListGrants", ctx, exec, "doc1").Return([]string{}, nil).Once()
+	mockCache.On("StoreDocumentVersion", ctx, mock.MatchedBy(func(d *model.Document) bool {
+		return d.LegalHold && d.Version == 3
+	})).Return(true, nil).Once()
+
+	require.NoError(t, svc.SetLegalHold(ctx, "doc1", true))
+	mockCache.AssertExpectations(t)
+	mockCache.AssertNotCalled(t, "InvalidateDocumentVersion", mock.Anything, mock.Anything, mock.Anything)
+}
@@ -0,0 +1,227 @@
+package service
+
+import (
+	"caching-web-server/config"
+	"caching-web-server/internal/model"
+	"caching-web-server/internal/ports"
+	"caching-web-server/internal/security"
+	"caching-web-server/internal/util"
+	"context"
+	"fmt"
+	"log"
+	"regexp"
+	"slices"
+	"sync"
+	"time"
+)
+
+// rolePermissionsTTL : сколько права ролей берутся из памяти. Изменения на этой реплике видны сразу,
+// на остальных — не позже чем через rolePermissionsTTL
+const rolePermissionsTTL = 30 * time.Second
+
+var roleNamePattern = regexp.MustCompile(`^[a-z][a-z0-9_-]{1,31}$`)
+
+type RoleService struct {
+	roleRepository ports.RoleRepository
+	userRepository ports.UserRepository
+	jwtRepository  ports.JWTRepositoryInterface
+	tokenDenylist  ports.TokenDenylist
+
+	mu          sync.Mutex
+	permissions map[string][]string
+	loadedAt    time.Time
+}
+
+func NewRoleService(
+	roleRepository ports.RoleRepository,
+	userRepository ports.UserRepository,
+	jwtRepository ports.JWTRepositoryInterface,
+	tokenDenylist ports.TokenDenylist,
+) *RoleService {
+	return &RoleService{
+		roleRepository: roleRepository,
+		userRepository: userRepository,
+		jwtRepository:  jwtRepository,
+		tokenDenylist:  tokenDenylist,
+	}
+}
+
+// Permissions : объединение прав ролей. Неизвестные роли (например, удалённые) прав не дают
+func (s *RoleService) Permissions(ctx context.Context, roles []string) ([]string, error) {
+	byRole, err := s.rolePermissions(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	permissions := []string{}
+	for _, role := range roles {
+		for _, permission := range byRole[role] {
+			if slices.Contains(permissions, permission) == false {
+				permissions = append(permissions, permission)
+			}
+		}
+	}
+	return permissions, nil
+}
+
+// ListRoles : все роли с правами (право roles:manage)
+func (s *RoleService) ListRoles(ctx context.Context) ([]*model.Role, error) {
+	db, err := s.managerDatabase(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return s.roleRepository.ListRoles(ctx, db)
+}
+
+// SaveRole : создаёт или изменяет пользовательскую роль (право roles:manage)
+func (s *RoleService) SaveRole(ctx context.Context, role *model.Role) error {
+	db, err := s.managerDatabase(ctx)
+	if err != nil {
+		return err
+	}
+
+	if roleNamePattern.MatchString(role.Name) == false {
+		return fmt.Errorf("[RoleService] некорректное имя роли: нужны строчные латинские буквы, цифры, '-' и '_', от 2 до 32 символов")
+	}
+	if isBuiltinRole(role.Name) {
+		return fmt.Errorf("[RoleService] встроенная роль %s не изменяется", role.Name)
+	}
+
+	permissions := []string{}
+	for _, permission := range role.Permissions {
+		if permission != model.PermissionAll && slices.Contains(model.Permissions, permission) == false {
+			return fmt.Errorf("[RoleService] неизвестное право %q", permission)
+		}
+		if slices.Contains(permissions, permission) == false {
+			permissions = append(permissions, permission)
+		}
+	}
+	role.Permissions = permissions
+	role.Builtin = false
+
+	if err := s.roleRepository.SaveRole(ctx, db, role); err != nil {
+		return err
+	}
+	s.resetPermissions()
+	log.Printf("[RoleService] роль %s сохранена пользователем %s: %v", role.Name, adminUUID(ctx), role.Permissions)
+	return nil
+}
+
+// DeleteRole : удаляет пользовательскую роль и снимает её со всех пользователей (право roles:manage)
+func (s *RoleService) DeleteRole(ctx context.Context, name string) error {
+	db, err := s.managerDatabase(ctx)
+	if err != nil {
+		return err
+	}
+	if isBuiltinRole(name) {
+		return fmt.Errorf("[RoleService] встроенная роль %s не удаляется", name)
+	}
+
+	if err := s.roleRepository.DeleteRole(ctx, db, name); err != nil {
+		return err
+	}
+	s.resetPermissions()
+	log.Printf("[RoleService] роль %s удалена пользователем %s", name, adminUUID(ctx))
+	return nil
+}
+
+// SetUserRoles : заменяет роли пользователя (право roles:manage). Сессии пользователя завершаются, а выданные
+// access-токены перестают приниматься сразу: иначе пользователь, у которого сняли роль, сохранил бы её права до exp
+// Роли заменяются в одной транзакции: если роль не найдена, у пользователя остаются прежние роли
+func (s *RoleService) SetUserRoles(ctx context.Context, userUUID string, roles []string) error {
+	if _, err := s.managerDatabase(ctx); err != nil {
+		return err
+	}
+	if len(roles) == 0 {
+		return fmt.Errorf("[RoleService] у пользователя должна быть хотя бы одна роль")
+	}
+
+	exec, rollback, commit, err := s.userRepository.BeginTX(ctx)
+	if err != nil {
+		return util.LogError("[RoleService] не удалось начать транзакцию", err)
+	}
+	defer rollback()
+
+	exists, err := s.userRepository.Exists(ctx, exec, userUUID)
+	if err != nil {
+		return err
+	}
+	if exists == false {
+		return fmt.Errorf("[RoleService] пользователь не найден")
+	}
+
+	if err := s.userRepository.SetRoles(ctx, exec, userUUID, roles); err != nil {
+		return err
+	}
+	if err := commit(); err != nil {
+		return util.LogError("[RoleService] не удалось назначить роли: ошибка коммита транзакции", err)
+	}
+	log.Printf("[RoleService] пользователю %s назначены роли %v пользователем %s", userUUID, roles, adminUUID(ctx))
+
+	if _, err := s.jwtRepository.RevokeSessions(ctx, userUUID, ""); err != nil {
+		log.Printf("[RoleService] не удалось завершить сессии пользователя %s после смены ролей: %v", userUUID, err)
+	}
+	if err := s.tokenDenylist.RevokeIssuedBefore(ctx, userUUID, time.Now()); err != nil {
+		return util.LogError("[RoleService] роли назначены, но выданные access токены не отозваны", err)
+	}
+	return nil
+}
+
+// rolePermissions : права всех ролей из памяти; после rolePermissionsTTL перечитываются из БД.
+// Если БД недоступна, используются последние прочитанные права
+func (s *RoleService) rolePermissions(ctx context.Context) (map[string][]string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.permissions != nil && time.Since(s.loadedAt) < rolePermissionsTTL {
+		return s.permissions, nil
+	}
+
+	db, ok := ctx.Value("db").(*config.Database)
+	if !ok {
+		return nil, fmt.Errorf("[RoleService] database connection не найден в context")
+	}
+
+	roles, err := s.roleRepository.ListRoles(ctx, db)
+	if err != nil {
+		if s.permissions != nil {
+			log.Printf("[RoleService] не удалось перечитать роли, используются прежние права: %v", err)
+			return s.permissions, nil
+		}
+		return nil, err
+	}
+
+	s.permissions = make(map[string][]string, len(roles))
+	for _, role := range roles {
+		s.permissions[role.Name] = role.Permissions
+	}
+	s.loadedAt = time.Now()
+	return s.permissions, nil
+}
+
+// resetPermissions : следующий запрос перечитает права из БД
+func (s *RoleService) resetPermissions() {
+	s.mu.Lock()
+	s.loadedAt = time.Time{}
+	s.mu.Unlock()
+}
+
+func (s *RoleService) managerDatabase(ctx context.Context) (*config.Database, error) {
+	claims, err := security.GetClaimsFromContext(ctx)
+	if err != nil || claims == nil {
+		return nil, fmt.Errorf("[RoleService] пользователь не авторизован")
+	}
+	if claims.Can(model.PermissionRolesManage) == false {
+		return nil, fmt.Errorf("[RoleService] доступ запрещён")
+	}
+
+	db, ok := ctx.Value("db").(*config.Database)
+	if !ok {
+		return nil, fmt.Errorf("[RoleService] database connection не найден в context")
+	}
+	return db, nil
+}
+
+func isBuiltinRole(name string) bool {
+	return name == model.RoleAdmin || name == model.RoleUser || name == model.RoleAuditor
+}
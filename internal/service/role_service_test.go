@@ -0,0 +1,162 @@
+package service_test
+
+import (
+	"caching-web-server/config"
+	"caching-web-server/internal/model"
+	"caching-web-server/internal/security"
+	"caching-web-server/internal/service"
+	"context"
+	"errors"
+	"github.com/jmoiron/sqlx"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+type MockRoleRepository struct{ mock.Mock }
+
+func (m *MockRoleRepository) ListRoles(ctx context.Context, exec sqlx.ExtContext) ([]*model.Role, error) {
+	args := m.Called(ctx, exec)
+	if roles, ok := args.Get(0).([]*model.Role); ok {
+		return roles, args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *MockRoleRepository) SaveRole(ctx context.Context, exec sqlx.ExtContext, role *model.Role) error {
+	return m.Called(ctx, exec, role).Error(0)
+}
+
+func (m *MockRoleRepository) DeleteRole(ctx context.Context, exec sqlx.ExtContext, name string) error {
+	return m.Called(ctx, exec, name).Error(0)
+}
+
+func TestRoleService(t *testing.T) {
+	db := &config.Database{}
+	dbCtx := context.WithValue(context.Background(), "db", db)
+	managerCtx := context.WithValue(dbCtx, security.UserContextKey, &security.Claims{UserUUID: "admin", Permissions: []string{model.PermissionAll}})
+	roles := []*model.Role{
+		{Name: model.RoleUser, Permissions: []string{model.PermissionDocumentsRead, model.PermissionDocumentsWrite}, Builtin: true},
+		{Name: model.RoleAuditor, Permissions: []string{model.PermissionDocumentsRead, model.PermissionUsersRead}, Builtin: true},
+	}
+
+	t.Run("Права ролей объединяются и берутся из памяти", func(t *testing.T) {
+		mockRoles := new(MockRoleRepository)
+		mockRoles.On("ListRoles", dbCtx, db).Return(roles, nil).Once()
+		svc := service.NewRoleService(mockRoles, new(MockUserRepository), new(MockJWTRepo), new(MockTokenDenylist))
+
+		permissions, err := svc.Permissions(dbCtx, []string{model.RoleUser, model.RoleAuditor, "deleted"})
+		require.NoError(t, err)
+		assert.Equal(t, []string{model.PermissionDocumentsRead, model.PermissionDocumentsWrite, model.PermissionUsersRead}, permissions)
+
+		_, err = svc.Permissions(dbCtx, []string{model.RoleUser})
+		require.NoError(t, err)
+		mockRoles.AssertExpectations(t)
+	})
+
+	t.Run("Ошибка БД при первой загрузке", func(t *testing.T) {
+		mockRoles := new(MockRoleRepository)
+		mockRoles.On("ListRoles", dbCtx, db).Return(nil, errors.New("db down")).Once()
+		svc := service.NewRoleService(mockRoles, new(MockUserRepository), new(MockJWTRepo), new(MockTokenDenylist))
+
+		_, err := svc.Permissions(dbCtx, []string{model.RoleUser})
+		assert.Error(t, err)
+	})
+
+	t.Run("Сохранение роли сбрасывает права в памяти", func(t *testing.T) {
+		mockRoles := new(MockRoleRepository)
+		mockRoles.On("ListRoles", mock.Anything, db).Return(roles, nil).Twice()
+		mockRoles.On("SaveRole", managerCtx, db, mock.MatchedBy(func(role *model.Role) bool {
+			return role.Name == "support" && assert.ObjectsAreEqual([]string{model.PermissionUsersRead}, role.Permissions)
+		})).Return(nil).Once()
+		svc := service.NewRoleService(mockRoles, new(MockUserRepository), new(MockJWTRepo), new(MockTokenDenylist))
+
+		_, err := svc.Permissions(dbCtx, []string{model.RoleUser})
+		require.NoError(t, err)
+		require.NoError(t, svc.SaveRole(managerCtx, &model.Role{Name: "support", Permissions: []string{model.PermissionUsersRead, model.PermissionUsersRead}}))
+		_, err = svc.Permissions(dbCtx, []string{model.RoleUser})
+		require.NoError(t, err)
+		mockRoles.AssertExpectations(t)
+	})
+
+	t.Run("Встроенные роли и неизвестные права не сохраняются", func(t *testing.T) {
+		mockRoles := new(MockRoleRepository)
+		svc := service.NewRoleService(mockRoles, new(MockUserRepository), new(MockJWTRepo), new(MockTokenDenylist))
+
+		err := svc.SaveRole(managerCtx, &model.Role{Name: model.RoleAdmin, Permissions: []string{model.PermissionDocumentsRead}})
+		assert.ErrorContains(t, err, "встроенная роль")
+		err = svc.SaveRole(managerCtx, &model.Role{Name: "support", Permissions: []string{"documents:destroy"}})
+		assert.ErrorContains(t, err, "неизвестное право")
+		err = svc.DeleteRole(managerCtx, model.RoleUser)
+		assert.ErrorContains(t, err, "встроенная роль")
+		mockRoles.AssertNotCalled(t, "SaveRole", mock.Anything, mock.Anything, mock.Anything)
+		mockRoles.AssertNotCalled(t, "DeleteRole", mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("Назначать роли может только пользователь с roles:manage", func(t *testing.T) {
+		mockUsers := new(MockUserRepository)
+		mockJWTRepo := new(MockJWTRepo)
+		denylist := new(MockTokenDenylist)
+		svc := service.NewRoleService(new(MockRoleRepository), mockUsers, mockJWTRepo, denylist)
+		auditorCtx := context.WithValue(dbCtx, security.UserContextKey, &security.Claims{UserUUID: "u1", Permissions: []string{model.PermissionUsersRead}})
+
+		err := svc.SetUserRoles(auditorCtx, "u2", []string{model.RoleAdmin})
+		assert.ErrorContains(t, err, "доступ запрещён")
+
+		tx := &fakeTx{}
+		committed := false
+		mockUsers.On("BeginTX", managerCtx).Return(tx, func() error { return nil }, func() error { committed = true; return nil }, nil).Once()
+		mockUsers.On("Exists", managerCtx, tx, "u2").Return(true, nil).Once()
+		mockUsers.On("SetRoles", managerCtx, tx, "u2", []string{model.RoleAuditor}).Return(nil).Once()
+		mockJWTRepo.On("RevokeSessions", managerCtx, "u2", "").Return([]string{"r1"}, nil).Once()
+		denylist.On("RevokeIssuedBefore", managerCtx, "u2", mock.Anything).Return(nil).Once()
+		require.NoError(t, svc.SetUserRoles(managerCtx, "u2", []string{model.RoleAuditor}))
+		assert.True(t, committed)
+		mockUsers.AssertExpectations(t)
+		mockJWTRepo.AssertExpectations(t)
+		denylist.AssertExpectations(t)
+	})
+
+	t.Run("Снятая роль перестаёт действовать сразу: сессии завершаются, access-токены отзываются", func(t *testing.T) {
+		mockUsers := new(MockUserRepository)
+		mockJWTRepo := new(MockJWTRepo)
+		denylist := new(MockTokenDenylist)
+		svc := service.NewRoleService(new(MockRoleRepository), mockUsers, mockJWTRepo, denylist)
+
+		tx := &fakeTx{}
+		mockUsers.On("BeginTX", managerCtx).Return(tx, func() error { return nil }, func() error { return nil }, nil).Once()
+		mockUsers.On("Exists", managerCtx, tx, "admin2").Return(true, nil).Once()
+		mockUsers.On("SetRoles", managerCtx, tx, "admin2", []string{model.RoleUser}).Return(nil).Once()
+		mockJWTRepo.On("RevokeSessions", managerCtx, "admin2", "").Return(nil, errors.New("db down")).Once()
+		denylist.On("RevokeIssuedBefore", managerCtx, "admin2", mock.Anything).Return(errors.New("redis down")).Once()
+
+		// роли уже сменены, но старые токены продолжили бы действовать — об этом нужно сообщить
+		err := svc.SetUserRoles(managerCtx, "admin2", []string{model.RoleUser})
+		assert.ErrorContains(t, err, "не отозваны")
+		mockJWTRepo.AssertExpectations(t)
+		denylist.AssertExpectations(t)
+	})
+
+	t.Run("Неизвестная роль: транзакция откатывается, прежние роли и сессии остаются", func(t *testing.T) {
+		mockUsers := new(MockUserRepository)
+		mockJWTRepo := new(MockJWTRepo)
+		denylist := new(MockTokenDenylist)
+		svc := service.NewRoleService(new(MockRoleRepository), mockUsers, mockJWTRepo, denylist)
+
+		tx := &fakeTx{}
+		committed, rolledBack := false, false
+		mockUsers.On("BeginTX", managerCtx).Return(tx, func() error { rolledBack = true; return nil }, func() error { committed = true; return nil }, nil).Once()
+		mockUsers.On("Exists", managerCtx, tx, "admin2").Return(true, nil).Once()
+		mockUsers.On("SetRoles", managerCtx, tx, "admin2", []string{model.RoleAdmin, "support"}).Return(errors.New("[UserRepo] роль не найдена")).Once()
+
+		err := svc.SetUserRoles(managerCtx, "admin2", []string{model.RoleAdmin, "support"})
+		assert.ErrorContains(t, err, "роль не найдена")
+		// удаление прежних ролей откатывается вместе с неудачной вставкой
+		assert.False(t, committed)
+		assert.True(t, rolledBack)
+		mockUsers.AssertExpectations(t)
+		mockJWTRepo.AssertNotCalled(t, "RevokeSessions", mock.Anything, mock.Anything, mock.Anything)
+		denylist.AssertNotCalled(t, "RevokeIssuedBefore", mock.Anything, mock.Anything, mock.Anything)
+	})
+}
@@ -8,7 +8,10 @@ import (
 	awsConfig "github.com/aws/aws-sdk-go-v2/config"
 	"github.com/aws/aws-sdk-go-v2/credentials"
 	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"io"
 	"log"
+	"net/url"
+	"strings"
 	"time"
 )
 
@@ -115,3 +118,33 @@ func (s *S3Service) DeleteObject(ctx context.Context, key string) error {
 	}
 	return nil
 }
+
+// GetObject : потоковое чтение объекта, вызывающий обязан закрыть возвращённый reader
+func (s *S3Service) GetObject(ctx context.Context, key string) (io.ReadCloser, error) {
+	output, err := s.client.GetObject(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(key),
+	})
+	if err != nil {
+		return nil, util.LogError("[S3Service] не удалось получить объект", err)
+	}
+	return output.Body, nil
+}
+
+// CopyObject : серверное копирование объекта внутри бакета без скачивания содержимого
+func (s *S3Service) CopyObject(ctx context.Context, sourceKey, destinationKey string) error {
+	segments := strings.Split(s.bucket+"/"+sourceKey, "/")
+	for i, segment := range segments {
+		segments[i] = url.PathEscape(segment)
+	}
+
+	_, err := s.client.CopyObject(ctx, &s3.CopyObjectInput{
+		Bucket:     aws.String(s.bucket),
+		Key:        aws.String(destinationKey),
+		CopySource: aws.String(strings.Join(segments, "/")),
+	})
+	if err != nil {
+		return util.LogError("[S3Service] не удалось скопировать объект", err)
+	}
+	return nil
+}
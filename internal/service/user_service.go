@@ -5,9 +5,14 @@ import (
 	"caching-web-server/internal/model"
 	"caching-web-server/internal/ports"
 	"caching-web-server/internal/security"
+	"caching-web-server/internal/util"
 	"context"
 	"fmt"
 	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+	"log"
+	"slices"
+	"time"
 	"unicode"
 )
 
@@ -16,6 +21,7 @@ type UserService struct {
 	jwtService     ports.JWTServiceInterface
 	jwtRepository  ports.JWTRepositoryInterface
 	adminToken     *config.AdminConfig
+	tokenDenylist  ports.TokenDenylist
 }
 
 func NewUserService(
@@ -23,33 +29,122 @@ func NewUserService(
 	jwtService ports.JWTServiceInterface,
 	jwtRepository ports.JWTRepositoryInterface,
 	adminToken *config.AdminConfig,
+	tokenDenylist ports.TokenDenylist,
 ) *UserService {
 	return &UserService{
 		userRepository: userRepository,
 		jwtService:     jwtService,
 		jwtRepository:  jwtRepository,
 		adminToken:     adminToken,
+		tokenDenylist:  tokenDenylist,
 	}
 }
 
+// Register : создаёт первого администратора по токену из конфигурации. Пока администратор есть,
+// регистрация закрыта, остальных пользователей создают через CreateUser
 func (s *UserService) Register(ctx context.Context, adminToken string, login string, password string, ipAddress string) (*model.TokensPair, error) {
-	if s.adminToken == nil || adminToken != s.adminToken.AdminToken {
+	if s.adminToken == nil || s.adminToken.AdminToken == "" || adminToken != s.adminToken.AdminToken {
 		return nil, fmt.Errorf("[UserService] неверный токен администратора")
 	}
 
+	if err := validateCredentials(login, password); err != nil {
+		return nil, err
+	}
+
+	exec, rollback, commit, err := s.userRepository.BeginTX(ctx)
+	if err != nil {
+		return nil, util.LogError("[UserService] не удалось начать транзакцию", err)
+	}
+	defer rollback()
+
+	// проверка и создание под одной блокировкой: иначе две параллельные регистрации создадут двух администраторов
+	if err := s.userRepository.LockRoleHolders(ctx, exec, model.RoleAdmin); err != nil {
+		return nil, err
+	}
+	adminExists, err := s.userRepository.RoleHolderExists(ctx, exec, model.RoleAdmin)
+	if err != nil {
+		return nil, fmt.Errorf("[UserService] ошибка проверки администратора: %w", err)
+	}
+	if adminExists {
+		return nil, fmt.Errorf("[UserService] администратор уже создан")
+	}
+
+	created, err := s.createUser(ctx, exec, login, password, []string{model.RoleAdmin})
+	if err != nil {
+		return nil, err
+	}
+	if err := commit(); err != nil {
+		return nil, util.LogError("[UserService] не удалось создать администратора: ошибка коммита транзакции", err)
+	}
+
+	tokens, refreshToken, err := s.jwtService.GenerateAccessRefreshTokens(created.UUID, created.Roles)
+	if err != nil {
+		return nil, fmt.Errorf("[UserService] ошибка генерации токенов: %w", err)
+	}
+
+	if err := s.jwtRepository.SaveRefreshToken(ctx, refreshToken, ipAddress); err != nil {
+		return nil, fmt.Errorf("[UserService] не удалось сохранить refresh токен: %w", err)
+	}
+
+	return tokens, nil
+}
+
+// CreateUser : создание пользователя администратором. Без ролей пользователь получает роль user,
+// другие роли может выдать только тот, кто управляет ролями
+func (s *UserService) CreateUser(ctx context.Context, login string, password string, roles []string) (*model.User, error) {
+	claims, err := security.GetClaimsFromContext(ctx)
+	if err != nil || claims == nil {
+		return nil, fmt.Errorf("[UserService] пользователь не авторизован")
+	}
+	if claims.Can(model.PermissionUsersManage) == false {
+		return nil, fmt.Errorf("[UserService] доступ запрещён")
+	}
+
+	if len(roles) == 0 {
+		roles = []string{model.RoleUser}
+	}
+	if claims.Can(model.PermissionRolesManage) == false && slices.Equal(roles, []string{model.RoleUser}) == false {
+		return nil, fmt.Errorf("[UserService] доступ запрещён: нет права назначать роли")
+	}
+
+	if err := validateCredentials(login, password); err != nil {
+		return nil, err
+	}
+
+	exec, rollback, commit, err := s.userRepository.BeginTX(ctx)
+	if err != nil {
+		return nil, util.LogError("[UserService] не удалось начать транзакцию", err)
+	}
+	defer rollback()
+
+	created, err := s.createUser(ctx, exec, login, password, roles)
+	if err != nil {
+		return nil, err
+	}
+	if err := commit(); err != nil {
+		return nil, util.LogError("[UserService] не удалось создать пользователя: ошибка коммита транзакции", err)
+	}
+	return created, nil
+}
+
+func validateCredentials(login string, password string) error {
 	if len(login) < 8 {
-		return nil, fmt.Errorf("[UserService] логин должен быть не меньше 8 символов")
+		return fmt.Errorf("[UserService] логин должен быть не меньше 8 символов")
 	}
 	for _, c := range login {
 		if !unicode.IsLetter(c) && !unicode.IsDigit(c) {
-			return nil, fmt.Errorf("[UserService] логин должен содержать только латинские буквы и цифры")
+			return fmt.Errorf("[UserService] логин должен содержать только латинские буквы и цифры")
 		}
 	}
 
 	if err := validatePassword(password); err != nil {
-		return nil, fmt.Errorf("[UserService] %w", err)
+		return fmt.Errorf("[UserService] %w", err)
 	}
+	return nil
+}
 
+// createUser : пользователь и его роли сохраняются в транзакции exec — без ролей пользователь не остаётся
+func (s *UserService) createUser(ctx context.Context, exec sqlx.ExtContext, login string, password string, roles []string) (*model.User, error) {
 	hash, err := security.HashPassword(password)
 	if err != nil {
 		return nil, fmt.Errorf("[UserService] не удалось создать хэш пароля: %w", err)
@@ -61,26 +156,17 @@ func (s *UserService) Register(ctx context.Context, adminToken string, login str
 		PasswordHash: hash,
 	}
 
-	db, ok := ctx.Value("db").(*config.Database)
-	if !ok {
-		return nil, fmt.Errorf("[UserService] database connection не найден в context")
-	}
-
-	created, err := s.userRepository.CreateUser(ctx, db, user)
+	created, err := s.userRepository.CreateUser(ctx, exec, user)
 	if err != nil {
 		return nil, fmt.Errorf("[UserService] ошибка создания пользователя: %w", err)
 	}
 
-	tokens, refreshToken, err := s.jwtService.GenerateAccessRefreshTokens(created.UUID)
-	if err != nil {
-		return nil, fmt.Errorf("[UserService] ошибка генерации токенов: %w", err)
+	if err := s.userRepository.SetRoles(ctx, exec, created.UUID, roles); err != nil {
+		return nil, fmt.Errorf("[UserService] ошибка назначения ролей: %w", err)
 	}
+	created.Roles = roles
 
-	if err := s.jwtRepository.SaveRefreshToken(ctx, refreshToken, ipAddress); err != nil {
-		return nil, fmt.Errorf("[UserService] не удалось сохранить refresh токен: %w", err)
-	}
-
-	return tokens, nil
+	return created, nil
 }
 
 func validatePassword(password string) error {
@@ -127,7 +213,7 @@ func (s *UserService) GetUser(ctx context.Context, uuid string) (*model.User, er
 		return nil, fmt.Errorf("[UserService] пользователь не авторизован")
 	}
 
-	if claims.IsAdmin == false && claims.UserUUID != uuid {
+	if claims.Can(model.PermissionUsersRead) == false && claims.UserUUID != uuid {
 		return nil, fmt.Errorf("[UserService] доступ запрещён")
 	}
 
@@ -157,6 +243,8 @@ func (s *UserService) UpdateUser(ctx context.Context, updatedUser *model.User) e
 	return s.userRepository.UpdateUser(ctx, db, updatedUser)
 }
 
+// UpdatePassword : после смены пароля все сессии пользователя завершаются, а выданные access-токены
+// перестают приниматься, включая токен, которым выполнена смена — нужно войти заново
 func (s *UserService) UpdatePassword(ctx context.Context, uuid, newPassword string) error {
 	claims, err := security.GetClaimsFromContext(ctx)
 	if err != nil || claims == nil {
@@ -177,7 +265,22 @@ func (s *UserService) UpdatePassword(ctx context.Context, uuid, newPassword stri
 		return err
 	}
 
-	return s.userRepository.UpdatePassword(ctx, db, uuid, hash)
+	if err := s.userRepository.UpdatePassword(ctx, db, uuid, hash); err != nil {
+		return err
+	}
+
+	if _, err := s.jwtRepository.RevokeSessions(ctx, uuid, ""); err != nil {
+		log.Printf("[UserService] не удалось завершить сессии пользователя %s после смены пароля: %v", uuid, err)
+	}
+	s.revokeAccessTokens(ctx, uuid)
+	return nil
+}
+
+// revokeAccessTokens : выданные пользователю access-токены перестают приниматься сразу, а не после exp
+func (s *UserService) revokeAccessTokens(ctx context.Context, uuid string) {
+	if err := s.tokenDenylist.RevokeIssuedBefore(ctx, uuid, time.Now()); err != nil {
+		log.Printf("[UserService] не удалось отозвать access токены пользователя %s: %v", uuid, err)
+	}
 }
 
 func (s *UserService) DeleteUser(ctx context.Context, uuid string) error {
@@ -191,28 +294,28 @@ func (s *UserService) DeleteUser(ctx context.Context, uuid string) error {
 		return fmt.Errorf("[UserService] database connection не найден в context")
 	}
 
-	if claims.IsAdmin == false && claims.UserUUID != uuid {
+	if claims.Can(model.PermissionUsersManage) == false && claims.UserUUID != uuid {
 		return fmt.Errorf("[UserService] доступ запрещён")
 	}
 
 	if err := s.userRepository.DeleteUser(ctx, db, uuid); err != nil {
 		return fmt.Errorf("[UserService] пользователь не найден")
 	}
+	s.revokeAccessTokens(ctx, uuid)
 
 	return nil
 }
 
-func (s *UserService) ListUsers(ctx context.Context, adminToken string, cursor string, limit int) ([]*model.User, string, error) {
-	if s.adminToken != nil && adminToken == s.adminToken.AdminToken {
-		return s.listFromRepo(ctx, cursor, limit)
-	}
-
+func (s *UserService) ListUsers(ctx context.Context, cursor string, limit int) ([]*model.User, string, error) {
 	claims, err := security.GetClaimsFromContext(ctx)
-	if err == nil && claims != nil {
-		return s.listFromRepo(ctx, cursor, limit)
+	if err != nil || claims == nil {
+		return nil, "", fmt.Errorf("[UserService] пользователь не авторизован")
+	}
+	if claims.Can(model.PermissionUsersList) == false {
+		return nil, "", fmt.Errorf("[UserService] доступ запрещён")
 	}
 
-	return nil, "", fmt.Errorf("[UserService] доступ запрещён: нужен админ или авторизация")
+	return s.listFromRepo(ctx, cursor, limit)
 }
 
 func (s *UserService) listFromRepo(ctx context.Context, cursor string, limit int) ([]*model.User, string, error) {
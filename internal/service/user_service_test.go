@@ -23,6 +23,8 @@ func TestUserService_Register(t *testing.T) {
 	ctx = context.WithValue(ctx, "db", db)
 
 	adminConfig := &config.AdminConfig{AdminToken: "secret-admin-token"}
+	tx := &fakeTx{}
+	noop := func() error { return nil }
 
 	tests := []struct {
 		name        string
@@ -59,10 +61,25 @@ func TestUserService_Register(t *testing.T) {
 			login:      "validlogin",
 			password:   "StrongPass123!",
 			setupMocks: func(u *MockUserRepository, j *MockJWTService, r *MockJWTRepo) {
-				u.On("CreateUser", ctx, db, mock.Anything).Return(nil, errors.New("db error"))
+				u.On("BeginTX", ctx).Return(tx, noop, noop, nil)
+				u.On("LockRoleHolders", ctx, tx, model.RoleAdmin).Return(nil)
+				u.On("RoleHolderExists", ctx, tx, model.RoleAdmin).Return(false, nil)
+				u.On("CreateUser", ctx, tx, mock.Anything).Return(nil, errors.New("db error"))
 			},
 			expectError: "[UserService] ошибка создания пользователя: db error",
 		},
+		{
+			name:       "admin already exists",
+			adminToken: "secret-admin-token",
+			login:      "validlogin",
+			password:   "StrongPass123!",
+			setupMocks: func(u *MockUserRepository, j *MockJWTService, r *MockJWTRepo) {
+				u.On("BeginTX", ctx).Return(tx, noop, noop, nil)
+				u.On("LockRoleHolders", ctx, tx, model.RoleAdmin).Return(nil)
+				u.On("RoleHolderExists", ctx, tx, model.RoleAdmin).Return(true, nil)
+			},
+			expectError: "[UserService] администратор уже создан",
+		},
 		{
 			name:       "success",
 			adminToken: "secret-admin-token",
@@ -70,8 +87,12 @@ func TestUserService_Register(t *testing.T) {
 			password:   "StrongPass123!",
 			setupMocks: func(u *MockUserRepository, j *MockJWTService, r *MockJWTRepo) {
 				createdUser := &model.User{UUID: "user-123", Login: "validlogin"}
-				u.On("CreateUser", mock.Anything, mock.Anything, mock.Anything).Return(createdUser, nil)
-				j.On("GenerateAccessRefreshTokens", mock.Anything).Return(
+				u.On("BeginTX", ctx).Return(tx, noop, noop, nil)
+				u.On("LockRoleHolders", ctx, tx, model.RoleAdmin).Return(nil)
+				u.On("RoleHolderExists", ctx, tx, model.RoleAdmin).Return(false, nil)
+				u.On("CreateUser", ctx, tx, mock.Anything).Return(createdUser, nil)
+				u.On("SetRoles", ctx, tx, "user-123", []string{model.RoleAdmin}).Return(nil)
+				j.On("GenerateAccessRefreshTokens", "user-123", []string{model.RoleAdmin}).Return(
 					&model.TokensPair{AccessToken: "at", RefreshToken: "rt"},
 					&model.RefreshToken{UUID: "rt-123"},
 					nil,
@@ -87,7 +108,7 @@ func TestUserService_Register(t *testing.T) {
 			mockUserRepo := new(MockUserRepository)
 			mockJWTService := new(MockJWTService)
 			mockJWTRepo := new(MockJWTRepo)
-			service := srv.NewUserService(mockUserRepo, mockJWTService, mockJWTRepo, adminConfig)
+			service := srv.NewUserService(mockUserRepo, mockJWTService, mockJWTRepo, adminConfig, nil)
 
 			if tt.setupMocks != nil {
 				tt.setupMocks(mockUserRepo, mockJWTService, mockJWTRepo)
@@ -111,6 +132,44 @@ func TestUserService_Register(t *testing.T) {
 	}
 }
 
+func TestUserService_CreateUser_RolesInSameTransaction(t *testing.T) {
+	ctx := context.WithValue(context.Background(), "db", &config.Database{})
+	ctx = context.WithValue(ctx, security.UserContextKey, &security.Claims{UserUUID: "admin", IsAdmin: true})
+	tx := &fakeTx{}
+
+	t.Run("Ошибка назначения ролей откатывает создание пользователя", func(t *testing.T) {
+		mockRepo := new(MockUserRepository)
+		var rolledBack, committed bool
+		mockRepo.On("BeginTX", ctx).Return(tx, func() error { rolledBack = true; return nil }, func() error { committed = true; return nil }, nil).Once()
+		mockRepo.On("CreateUser", ctx, tx, mock.Anything).Return(&model.User{UUID: "user-123"}, nil).Once()
+		mockRepo.On("SetRoles", ctx, tx, "user-123", []string{"support"}).Return(errors.New("[UserRepo] роль не найдена")).Once()
+
+		user, err := srv.NewUserService(mockRepo, nil, nil, nil, nil).CreateUser(ctx, "validlogin", "StrongPass123!", []string{"support"})
+
+		assert.ErrorContains(t, err, "ошибка назначения ролей")
+		assert.Nil(t, user)
+		assert.True(t, rolledBack)
+		assert.False(t, committed)
+		mockRepo.AssertNotCalled(t, "DeleteUser", mock.Anything, mock.Anything, mock.Anything)
+		mockRepo.AssertExpectations(t)
+	})
+
+	t.Run("Пользователь и роли сохраняются одним коммитом", func(t *testing.T) {
+		mockRepo := new(MockUserRepository)
+		var committed bool
+		mockRepo.On("BeginTX", ctx).Return(tx, func() error { return nil }, func() error { committed = true; return nil }, nil).Once()
+		mockRepo.On("CreateUser", ctx, tx, mock.Anything).Return(&model.User{UUID: "user-123"}, nil).Once()
+		mockRepo.On("SetRoles", ctx, tx, "user-123", []string{model.RoleUser}).Return(nil).Once()
+
+		user, err := srv.NewUserService(mockRepo, nil, nil, nil, nil).CreateUser(ctx, "validlogin", "StrongPass123!", nil)
+
+		assert.NoError(t, err)
+		assert.Equal(t, []string{model.RoleUser}, user.Roles)
+		assert.True(t, committed)
+		mockRepo.AssertExpectations(t)
+	})
+}
+
 func TestUserService_GetUser(t *testing.T) {
 	db := &config.Database{}
 
@@ -157,7 +216,7 @@ func TestUserService_GetUser(t *testing.T) {
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			mockRepo := new(MockUserRepository)
-			service := srv.NewUserService(mockRepo, nil, nil, nil)
+			service := srv.NewUserService(mockRepo, nil, nil, nil, nil)
 
 			ctx := context.WithValue(context.Background(), "db", db)
 			if tt.claims != nil {
@@ -230,7 +289,7 @@ func TestUserService_UpdateUser(t *testing.T) {
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			mockRepo := new(MockUserRepository)
-			service := srv.NewUserService(mockRepo, nil, nil, nil)
+			service := srv.NewUserService(mockRepo, nil, nil, nil, nil)
 
 			ctx := context.Background()
 			if tt.name != "db missing" {
@@ -266,7 +325,7 @@ func TestUserService_UpdatePassword(t *testing.T) {
 		claims      *security.Claims
 		uuid        string
 		newPassword string
-		setupMocks  func(mockRepo *MockUserRepository)
+		setupMocks  func(mockRepo *MockUserRepository, jwtRepo *MockJWTRepo, denylist *MockTokenDenylist)
 		expectError string
 	}{
 		{
@@ -288,9 +347,11 @@ func TestUserService_UpdatePassword(t *testing.T) {
 			claims:      &security.Claims{UserUUID: "user-123"},
 			uuid:        "user-123",
 			newPassword: "newpass",
-			setupMocks: func(mockRepo *MockUserRepository) {
+			setupMocks: func(mockRepo *MockUserRepository, jwtRepo *MockJWTRepo, denylist *MockTokenDenylist) {
 				mockRepo.On("UpdatePassword", mock.Anything, mock.Anything, "user-123", mock.Anything).
 					Return(nil)
+				jwtRepo.On("RevokeSessions", mock.Anything, "user-123", "").Return([]string{"r1", "r2"}, nil)
+				denylist.On("RevokeIssuedBefore", mock.Anything, "user-123", mock.Anything).Return(nil)
 			},
 		},
 	}
@@ -298,7 +359,9 @@ func TestUserService_UpdatePassword(t *testing.T) {
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			mockRepo := new(MockUserRepository)
-			service := srv.NewUserService(mockRepo, nil, nil, nil)
+			mockJWTRepo := new(MockJWTRepo)
+			denylist := new(MockTokenDenylist)
+			service := srv.NewUserService(mockRepo, nil, mockJWTRepo, nil, denylist)
 
 			ctx := context.WithValue(context.Background(), "db", db)
 			if tt.claims != nil {
@@ -306,7 +369,7 @@ func TestUserService_UpdatePassword(t *testing.T) {
 			}
 
 			if tt.setupMocks != nil {
-				tt.setupMocks(mockRepo)
+				tt.setupMocks(mockRepo, mockJWTRepo, denylist)
 			}
 
 			err := service.UpdatePassword(ctx, tt.uuid, tt.newPassword)
@@ -319,6 +382,8 @@ func TestUserService_UpdatePassword(t *testing.T) {
 			}
 
 			mockRepo.AssertExpectations(t)
+			mockJWTRepo.AssertExpectations(t)
+			denylist.AssertExpectations(t)
 		})
 	}
 }
@@ -330,7 +395,7 @@ func TestUserService_DeleteUser(t *testing.T) {
 		name        string
 		claims      *security.Claims
 		uuid        string
-		setupMocks  func(mockRepo *MockUserRepository)
+		setupMocks  func(mockRepo *MockUserRepository, jwtRepo *MockJWTRepo, denylist *MockTokenDenylist)
 		expectError string
 	}{
 		{
@@ -349,7 +414,7 @@ func TestUserService_DeleteUser(t *testing.T) {
 			name:   "user not found",
 			claims: &security.Claims{UserUUID: "admin", IsAdmin: true},
 			uuid:   "user-123",
-			setupMocks: func(mockRepo *MockUserRepository) {
+			setupMocks: func(mockRepo *MockUserRepository, jwtRepo *MockJWTRepo, denylist *MockTokenDenylist) {
 				mockRepo.On("DeleteUser", mock.Anything, mock.Anything, "user-123").
 					Return(errors.New("db error"))
 			},
@@ -359,9 +424,10 @@ func TestUserService_DeleteUser(t *testing.T) {
 			name:   "success",
 			claims: &security.Claims{UserUUID: "user-123", IsAdmin: false},
 			uuid:   "user-123",
-			setupMocks: func(mockRepo *MockUserRepository) {
+			setupMocks: func(mockRepo *MockUserRepository, jwtRepo *MockJWTRepo, denylist *MockTokenDenylist) {
 				mockRepo.On("DeleteUser", mock.Anything, mock.Anything, "user-123").
 					Return(nil)
+				denylist.On("RevokeIssuedBefore", mock.Anything, "user-123", mock.Anything).Return(nil)
 			},
 		},
 	}
@@ -369,7 +435,9 @@ func TestUserService_DeleteUser(t *testing.T) {
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			mockRepo := new(MockUserRepository)
-			service := srv.NewUserService(mockRepo, nil, nil, nil)
+			mockJWTRepo := new(MockJWTRepo)
+			denylist := new(MockTokenDenylist)
+			service := srv.NewUserService(mockRepo, nil, mockJWTRepo, nil, denylist)
 
 			ctx := context.Background()
 			ctx = context.WithValue(ctx, "db", db)
@@ -378,7 +446,7 @@ func TestUserService_DeleteUser(t *testing.T) {
 			}
 
 			if tt.setupMocks != nil {
-				tt.setupMocks(mockRepo)
+				tt.setupMocks(mockRepo, mockJWTRepo, denylist)
 			}
 
 			err := service.DeleteUser(ctx, tt.uuid)
@@ -391,6 +459,8 @@ func TestUserService_DeleteUser(t *testing.T) {
 			}
 
 			mockRepo.AssertExpectations(t)
+			mockJWTRepo.AssertExpectations(t)
+			denylist.AssertExpectations(t)
 		})
 	}
 }
@@ -398,14 +468,12 @@ func TestUserService_DeleteUser(t *testing.T) {
 func TestUserService_ListUsers(t *testing.T) {
 	db := &config.Database{}
 	mockRepo := new(MockUserRepository)
-	adminConfig := &config.AdminConfig{AdminToken: "secret-admin-token"}
 
-	service := srv.NewUserService(mockRepo, nil, nil, adminConfig)
+	service := srv.NewUserService(mockRepo, nil, nil, nil, nil)
 
 	tests := []struct {
 		name        string
 		ctx         context.Context
-		adminToken  string
 		cursor      string
 		limit       int
 		setupMocks  func()
@@ -414,21 +482,32 @@ func TestUserService_ListUsers(t *testing.T) {
 		expectError string
 	}{
 		{
-			name:        "access denied",
+			name:        "not authorized",
 			ctx:         context.Background(),
 			cursor:      "",
 			limit:       2,
+			expectError: "[UserService] пользователь не авторизован",
+		},
+		{
+			name: "access denied without users:list",
+			ctx: func() context.Context {
+				c := context.WithValue(context.Background(), "db", db)
+				c = context.WithValue(c, security.UserContextKey, &security.Claims{UserUUID: "u1", Permissions: []string{model.PermissionDocumentsRead}})
+				return c
+			}(),
+			cursor:      "",
+			limit:       2,
 			expectError: "[UserService] доступ запрещён",
 		},
 		{
-			name: "admin token access",
+			name: "admin access",
 			ctx: func() context.Context {
 				c := context.WithValue(context.Background(), "db", db)
+				c = context.WithValue(c, security.UserContextKey, &security.Claims{UserUUID: "admin", IsAdmin: true})
 				return c
 			}(),
-			adminToken: "secret-admin-token",
-			cursor:     "",
-			limit:      2,
+			cursor: "",
+			limit:  2,
 			setupMocks: func() {
 				mockRepo.On("ListUsers", mock.Anything, mock.Anything, "", 2).Return(
 					[]*model.User{
@@ -443,7 +522,7 @@ func TestUserService_ListUsers(t *testing.T) {
 			name: "authorized user access",
 			ctx: func() context.Context {
 				c := context.WithValue(context.Background(), "db", db)
-				c = context.WithValue(c, security.UserContextKey, &security.Claims{UserUUID: "u1"})
+				c = context.WithValue(c, security.UserContextKey, &security.Claims{UserUUID: "u1", Permissions: []string{model.PermissionUsersList}})
 				return c
 			}(),
 			cursor: "",
@@ -466,7 +545,7 @@ func TestUserService_ListUsers(t *testing.T) {
 				tt.setupMocks()
 			}
 
-			users, next, err := service.ListUsers(tt.ctx, tt.adminToken, tt.cursor, tt.limit)
+			users, next, err := service.ListUsers(tt.ctx, tt.cursor, tt.limit)
 
 			if tt.expectError != "" {
 				assert.Error(t, err)
@@ -0,0 +1,21 @@
+package util
+
+import (
+	"fmt"
+	"github.com/google/uuid"
+	"net/url"
+	"path/filepath"
+	"strings"
+)
+
+// BuildStoragePath : формирует ключ объекта в S3 в префиксе пользователя: users/<uuid>/documents/<имя>-<суффикс><расширение>
+func BuildStoragePath(userUUID string, filename string) string {
+	fileExt := filepath.Ext(filename)
+	fileName := strings.TrimSuffix(filename, fileExt)
+	return fmt.Sprintf("users/%s/documents/%s-%s%s",
+		userUUID,
+		url.PathEscape(fileName),
+		uuid.New().String()[:8],
+		fileExt,
+	)
+}